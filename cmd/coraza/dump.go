@@ -0,0 +1,104 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// dumpCommand prints the rules compiled from the configuration files, in
+// evaluation order, with the phase they are defined for and the phases
+// inferred from their variables.
+func dumpCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	raw := fs.Bool("raw", false, "print the directive each rule was compiled from")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: coraza dump [-raw] <config>...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	waf, err := loadWAF(fs.Args(), stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, lintMessage(fs.Arg(0), err))
+		return 1
+	}
+
+	rules := waf.Rules.GetRules()
+	if *raw {
+		// the directives span multiple lines, the table is not used
+		for _, r := range rules {
+			header := fmt.Sprintf("%s phase:%s inferred:%s %s:%d %s", ruleID(&r), rulePhase(&r), formatPhases(r.InferredPhases()), r.File_, r.Line_, ruleNotes(&r))
+			fmt.Fprintln(stdout, strings.TrimSpace(header))
+			for _, l := range strings.Split(strings.TrimSpace(r.Raw_), "\n") {
+				fmt.Fprintf(stdout, "    %s\n", strings.TrimSpace(l))
+			}
+		}
+		return 0
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tPHASE\tINFERRED\tLOCATION\tNOTES")
+	for _, r := range rules {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s:%d\t%s\n", ruleID(&r), rulePhase(&r), formatPhases(r.InferredPhases()), r.File_, r.Line_, ruleNotes(&r))
+	}
+	tw.Flush()
+	return 0
+}
+
+func ruleID(r *corazawaf.Rule) string {
+	if r.SecMark_ != "" {
+		return "-"
+	}
+	return strconv.Itoa(r.ID_)
+}
+
+func rulePhase(r *corazawaf.Rule) string {
+	if r.SecMark_ != "" {
+		return "-"
+	}
+	return strconv.Itoa(int(r.Phase_))
+}
+
+func formatPhases(phases []types.RulePhase) string {
+	if len(phases) == 0 {
+		return "-"
+	}
+	s := make([]string, len(phases))
+	for i, p := range phases {
+		s[i] = strconv.Itoa(int(p))
+	}
+	return strings.Join(s, ",")
+}
+
+func ruleNotes(r *corazawaf.Rule) string {
+	if r.SecMark_ != "" {
+		return "marker " + r.SecMark_
+	}
+	chain := 0
+	for c := r.Chain; c != nil; c = c.Chain {
+		chain++
+	}
+	if chain > 0 {
+		return fmt.Sprintf("chain of %d", chain+1)
+	}
+	return ""
+}
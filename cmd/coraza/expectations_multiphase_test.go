@@ -0,0 +1,13 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo && coraza.rule.multiphase_evaluation
+
+package main
+
+// With the multiphase evaluation, rule 102 evaluates ARGS_GET from phase 1
+// and matches the query argument before the phase 2 ARGS collection.
+const (
+	dumpInferredPhases102 = "1,2"
+	replayMatchedArg102   = "ARGS_GET:q"
+)
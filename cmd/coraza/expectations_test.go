@@ -0,0 +1,11 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo && !coraza.rule.multiphase_evaluation
+
+package main
+
+const (
+	dumpInferredPhases102 = "2"
+	replayMatchedArg102   = "ARGS:q"
+)
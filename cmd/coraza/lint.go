@@ -0,0 +1,56 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/corazawaf/coraza/v3/internal/seclang"
+)
+
+// lintCommand compiles every configuration file in its own WAF and reports
+// the first error of each one, prefixed by the position of the failing
+// directive when it is known.
+func lintCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: coraza lint <config>...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	failed := 0
+	for _, file := range fs.Args() {
+		if _, err := loadWAF([]string{file}, stderr); err != nil {
+			failed++
+			fmt.Fprintln(stdout, lintMessage(file, err))
+		}
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// lintMessage formats err as file:line: message, falling back to the
+// linted file when the error is not tied to a directive.
+func lintMessage(file string, err error) string {
+	var perr *seclang.ParseError
+	if errors.As(err, &perr) {
+		return perr.Error()
+	}
+	return fmt.Sprintf("%s: %s", file, err.Error())
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+
+// Command coraza lints, tests, replays and dumps SecLang configurations
+// without network access.
+//
+// Usage:
+//
+//	coraza lint <config>...
+//	coraza test [-root dir] [-run regexp] <profile.yaml|dir>...
+//	coraza replay -rules <config> [-v] <request.txt|file.har>...
+//	coraza dump [-raw] <config>...
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/debuglog"
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/internal/seclang"
+)
+
+const usage = `Usage: coraza <command> [flags] [arguments]
+
+Commands:
+  lint    parse configuration files and report errors with file:line
+  test    run YAML test profiles against the rules they define
+  replay  feed raw HTTP requests or HAR files through a WAF and print matched rules
+  dump    print the compiled rules with their inferred phases
+
+Run "coraza <command> -h" for the flags of a command.
+`
+
+type command func(args []string, stdout, stderr io.Writer) int
+
+var commands = map[string]command{
+	"lint":   lintCommand,
+	"test":   testCommand,
+	"replay": replayCommand,
+	"dump":   dumpCommand,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command in args and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "coraza: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	return cmd(args[1:], stdout, stderr)
+}
+
+// stringsFlag is a flag that can be set multiple times.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// loadWAF compiles the configuration files into a new WAF the same way
+// coraza.NewWAF does, without initializing the audit log writer so that
+// no files or connections are opened. Parser warnings are written to
+// stderr.
+func loadWAF(files []string, stderr io.Writer) (*corazawaf.WAF, error) {
+	waf := corazawaf.NewWAF()
+	waf.Logger = debuglog.Default().WithLevel(debuglog.LevelWarn).WithOutput(stderr)
+	parser := seclang.NewParser(waf)
+	for _, f := range files {
+		if err := parser.FromFile(f); err != nil {
+			return nil, err
+		}
+	}
+	if err := waf.Validate(); err != nil {
+		return nil, err
+	}
+	waf.Rules.BuildIndex()
+	return waf, nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func runCommand(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(args, stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	if code, _, stderr := runCommand(t); code != 2 || !strings.Contains(stderr, "Usage: coraza <command>") {
+		t.Errorf("expected the usage, got %d %q", code, stderr)
+	}
+	if code, _, stderr := runCommand(t, "unknown"); code != 2 || !strings.Contains(stderr, `unknown command "unknown"`) {
+		t.Errorf("expected an unknown command error, got %d %q", code, stderr)
+	}
+	if code, stdout, _ := runCommand(t, "help"); code != 0 || !strings.Contains(stdout, "replay") {
+		t.Errorf("expected the usage, got %d %q", code, stdout)
+	}
+	for _, cmd := range []string{"lint", "test", "replay", "dump"} {
+		if code, _, stderr := runCommand(t, cmd); code != 2 || !strings.Contains(stderr, "Usage: coraza "+cmd) {
+			t.Errorf("expected the %s usage, got %d %q", cmd, code, stderr)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	code, stdout, _ := runCommand(t, "lint", "testdata/rules.conf")
+	if code != 0 || stdout != "" {
+		t.Errorf("expected no errors, got %d %q", code, stdout)
+	}
+
+	code, stdout, _ = runCommand(t, "lint", "testdata/rules.conf", "testdata/invalid.conf", "testdata/missing.conf")
+	if code != 1 {
+		t.Errorf("unexpected exit code %d", code)
+	}
+	want := `invalid.conf:3: failed to compile the directive "secrule": invalid action "unknownaction"`
+	if !strings.Contains(stdout, want) {
+		t.Errorf("expected %q in %q", want, stdout)
+	}
+	if !strings.Contains(stdout, "testdata/missing.conf: failed to readfile") {
+		t.Errorf("expected the missing file to be reported, got %q", stdout)
+	}
+}
+
+func TestDump(t *testing.T) {
+	code, stdout, _ := runCommand(t, "dump", "testdata/rules.conf")
+	if code != 0 {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	want := [][]string{
+		{"ID", "PHASE", "INFERRED", "LOCATION", "NOTES"},
+		{"101", "1", "1", "testdata/rules.conf:5"},
+		{"102", "2", dumpInferredPhases102, "testdata/rules.conf:7"},
+		{"103", "2", "1,2", "testdata/rules.conf:8", "chain", "of", "2"},
+		{"-", "-", "-", "testdata/rules.conf:10", "marker", "END_REQUEST"},
+		{"104", "3", "3", "testdata/rules.conf:11"},
+		{"105", "5", "5", "testdata/rules.conf:12"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("unexpected output %q", stdout)
+	}
+	for i, l := range lines {
+		if have := strings.Fields(l); strings.Join(have, " ") != strings.Join(want[i], " ") {
+			t.Errorf("unexpected line %d, want %q, have %q", i, want[i], have)
+		}
+	}
+
+	code, stdout, _ = runCommand(t, "dump", "-raw", "testdata/rules.conf")
+	if code != 0 {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+	if want := "103 phase:2 inferred:1,2 testdata/rules.conf:8 chain of 2\n" +
+		`    SecRule REQUEST_METHOD "@streq POST" "id:103,phase:2,pass,log,msg:'Post with a',chain"` + "\n" +
+		`    SecRule ARGS_POST:a "@eq 1" ""` + "\n"; !strings.Contains(stdout, want) {
+		t.Errorf("expected %q in %q", want, stdout)
+	}
+
+	if code, _, stderr := runCommand(t, "dump", "testdata/invalid.conf"); code != 1 || !strings.Contains(stderr, "invalid.conf:3:") {
+		t.Errorf("expected the parse error, got %d %q", code, stderr)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	code, stdout, _ := runCommand(t, "replay", "-rules", "testdata/rules.conf", "testdata/requests.txt", "testdata/requests.har")
+	if code != 0 {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+	want := `#1 GET /?q=attack HTTP/1.1
+  matched 101 phase:1 "Scanner detected" REQUEST_HEADERS:User-Agent
+  matched 102 phase:2 "Attack in arguments" ` + replayMatchedArg102 + `
+  matched 105 phase:5 ""
+  interrupted by rule 102: deny (403)
+#2 POST /form HTTP/1.1
+  matched 103 phase:2 "Post with a" REQUEST_METHOD,ARGS_POST:a
+  matched 105 phase:5 ""
+#3 GET /health HTTP/2.0
+  matched 104 phase:3 "Server error" RESPONSE_STATUS
+  matched 105 phase:5 ""
+`
+	if stdout != want {
+		t.Errorf("unexpected output\nwant:\n%s\nhave:\n%s", want, stdout)
+	}
+
+	code, stdout, _ = runCommand(t, "replay", "-v", "-client", "10.0.0.1", "-rules", "testdata/rules.conf", "testdata/requests.txt")
+	if code != 0 {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+	if want := `[client "10.0.0.1"] Coraza: Access denied (phase 2)`; !strings.Contains(stdout, want) {
+		t.Errorf("expected %q in %q", want, stdout)
+	}
+
+	if code, _, stderr := runCommand(t, "replay", "-rules", "testdata/rules.conf", "testdata/rules.conf"); code != 1 || !strings.Contains(stderr, "request 1:") {
+		t.Errorf("expected an invalid request error, got %d %q", code, stderr)
+	}
+}
+
+func TestReadHAR(t *testing.T) {
+	for name, har := range map[string]string{
+		"invalid json": `{`,
+		"no entries":   `{"log":{"entries":[]}}`,
+		"invalid url":  `{"log":{"entries":[{"request":{"method":"GET","url":"://"}}]}}`,
+		"invalid body": `{"log":{"entries":[{"request":{"method":"GET","url":"/"},"response":{"status":200,"content":{"text":"!","encoding":"base64"}}}]}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := readHAR([]byte(har)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	entries, err := readHAR([]byte(`{"log":{"entries":[{"request":{"method":"POST","url":"http://a.com/p?x=1","httpVersion":"HTTP/1.0",
+		"headers":[{"name":"Host","value":"b.com"},{"name":"X-A","value":"1"}],"postData":{"text":"a=1"}}}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := entries[0]
+	if e.uri != "/p?x=1" || e.proto != "HTTP/1.0" || e.host != "b.com" || string(e.body) != "a=1" || e.response != nil {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if len(e.headers) != 1 || e.headers[0] != (replayHeader{"X-A", "1"}) {
+		t.Errorf("unexpected headers %v", e.headers)
+	}
+}
+
+func TestTest(t *testing.T) {
+	code, stdout, _ := runCommand(t, "test", "-v", "testdata/profile.yaml")
+	if code != 0 {
+		t.Errorf("unexpected exit code %d, output %q", code, stdout)
+	}
+	want := "PASS  cli.yaml/blocked\nPASS  cli.yaml/raw request\n2 passed, 0 failed\n"
+	if stdout != want {
+		t.Errorf("unexpected output\nwant:\n%s\nhave:\n%s", want, stdout)
+	}
+
+	code, stdout, _ = runCommand(t, "test", "testdata")
+	if code != 1 {
+		t.Errorf("unexpected exit code %d", code)
+	}
+	want = "FAIL  failing.yaml/not triggered\n      Expected rule '1' to be triggered\n2 passed, 1 failed\n"
+	if stdout != want {
+		t.Errorf("unexpected output\nwant:\n%s\nhave:\n%s", want, stdout)
+	}
+
+	code, stdout, _ = runCommand(t, "test", "-run", "raw", "testdata/profile.yaml")
+	if code != 0 || stdout != "1 passed, 0 failed\n" {
+		t.Errorf("expected a single test to run, got %d %q", code, stdout)
+	}
+}
@@ -0,0 +1,349 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// replayCommand feeds the requests, and the responses when the input holds
+// them, through a WAF compiled from the rules and prints the matched rules
+// and the interruption of every transaction. Audit logging is disabled.
+func replayCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var rules stringsFlag
+	fs.Var(&rules, "rules", "configuration file to compile, can be repeated")
+	client := fs.String("client", "127.0.0.1", "client address of the replayed transactions")
+	verbose := fs.Bool("v", false, "print the full error log line of the matched rules")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: coraza replay -rules <config> [-client addr] [-v] <request.txt|file.har>...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if len(rules) == 0 || fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	waf, err := loadWAF(rules, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, lintMessage(rules[0], err))
+		return 1
+	}
+	waf.AuditEngine = types.AuditEngineOff
+
+	n := 0
+	for _, file := range fs.Args() {
+		entries, err := readReplayFile(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %s\n", file, err.Error())
+			return 1
+		}
+		for _, e := range entries {
+			n++
+			fmt.Fprintf(stdout, "#%d %s %s %s\n", n, e.method, e.uri, e.proto)
+			tx := waf.NewTransaction()
+			if err := replayEntry(tx, e, *client); err != nil {
+				fmt.Fprintf(stdout, "  error: %s\n", err.Error())
+			}
+			tx.ProcessLogging()
+			printTransaction(stdout, tx, *verbose)
+			if err := tx.Close(); err != nil {
+				fmt.Fprintf(stderr, "failed to close the transaction: %s\n", err.Error())
+			}
+		}
+	}
+	return 0
+}
+
+type replayHeader struct {
+	name  string
+	value string
+}
+
+// replayRequest is a request read from a raw HTTP dump or a HAR file,
+// response is only available in HAR files.
+type replayRequest struct {
+	method   string
+	uri      string
+	proto    string
+	host     string
+	headers  []replayHeader
+	body     []byte
+	response *replayResponse
+}
+
+type replayResponse struct {
+	status  int
+	proto   string
+	headers []replayHeader
+	body    []byte
+}
+
+func replayEntry(tx *corazawaf.Transaction, e replayRequest, client string) error {
+	tx.ProcessConnection(client, 0, "", 0)
+	tx.ProcessURI(e.uri, e.method, e.proto)
+	for _, h := range e.headers {
+		tx.AddRequestHeader(h.name, h.value)
+	}
+	if e.host != "" {
+		tx.AddRequestHeader("Host", e.host)
+		tx.SetServerName(e.host)
+	}
+	if it := tx.ProcessRequestHeaders(); it != nil {
+		return nil
+	}
+	if tx.IsRequestBodyAccessible() && len(e.body) > 0 {
+		it, _, err := tx.WriteRequestBody(e.body)
+		if err != nil {
+			return err
+		}
+		if it != nil {
+			return nil
+		}
+	}
+	it, err := tx.ProcessRequestBody()
+	if err != nil || it != nil {
+		return err
+	}
+	if e.response == nil {
+		return nil
+	}
+
+	for _, h := range e.response.headers {
+		tx.AddResponseHeader(h.name, h.value)
+	}
+	if it := tx.ProcessResponseHeaders(e.response.status, e.response.proto); it != nil {
+		return nil
+	}
+	if tx.IsResponseBodyAccessible() && len(e.response.body) > 0 {
+		it, _, err := tx.WriteResponseBody(e.response.body)
+		if err != nil {
+			return err
+		}
+		if it != nil {
+			return nil
+		}
+	}
+	_, err = tx.ProcessResponseBody()
+	return err
+}
+
+func printTransaction(w io.Writer, tx *corazawaf.Transaction, verbose bool) {
+	for _, mr := range tx.MatchedRules() {
+		if verbose {
+			fmt.Fprintf(w, "  %s\n", mr.ErrorLog())
+			continue
+		}
+		var vars []string
+		for _, md := range mr.MatchedDatas() {
+			if md.Variable() == variables.Unknown {
+				// actions don't match variables
+				continue
+			}
+			v := md.Variable().Name()
+			if md.Key() != "" {
+				v += ":" + md.Key()
+			}
+			vars = append(vars, v)
+		}
+		line := fmt.Sprintf("  matched %d phase:%d %q %s", mr.Rule().ID(), mr.Rule().Phase(), mr.Message(), strings.Join(vars, ","))
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+	if it := tx.Interruption(); it != nil {
+		fmt.Fprintf(w, "  interrupted by rule %d: %s (%d)\n", it.RuleID, it.Action, it.Status)
+	}
+}
+
+func readReplayFile(file string) ([]replayRequest, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(file, ".har") || bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return readHAR(data)
+	}
+	return readRawRequests(data)
+}
+
+// readRawRequests reads the HTTP/1.x requests in data, one after the other
+// and optionally separated by blank lines. Request bodies are delimited by
+// Content-Length or chunked encoding.
+func readRawRequests(data []byte) ([]replayRequest, error) {
+	var entries []replayRequest
+	br := bufio.NewReader(bytes.NewReader(data))
+	for {
+		if err := skipBlankLines(br); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return nil, fmt.Errorf("request %d: %w", len(entries)+1, err)
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("request %d: %w", len(entries)+1, err)
+		}
+		e := replayRequest{
+			method: req.Method,
+			uri:    req.RequestURI,
+			proto:  req.Proto,
+			host:   req.Host,
+			body:   body,
+		}
+		for k, vs := range req.Header {
+			for _, v := range vs {
+				e.headers = append(e.headers, replayHeader{k, v})
+			}
+		}
+		// Transfer-Encoding is removed from the headers by net/http
+		for _, te := range req.TransferEncoding {
+			e.headers = append(e.headers, replayHeader{"Transfer-Encoding", te})
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func skipBlankLines(br *bufio.Reader) error {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return err
+		}
+		if b[0] != '\r' && b[0] != '\n' {
+			return nil
+		}
+		if _, err := br.Discard(1); err != nil {
+			return err
+		}
+	}
+}
+
+// harFile is the subset of the HTTP Archive 1.2 format used for replays.
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method      string      `json:"method"`
+				URL         string      `json:"url"`
+				HTTPVersion string      `json:"httpVersion"`
+				Headers     []harHeader `json:"headers"`
+				PostData    *struct {
+					Text string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+			Response *struct {
+				Status      int         `json:"status"`
+				HTTPVersion string      `json:"httpVersion"`
+				Headers     []harHeader `json:"headers"`
+				Content     struct {
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"content"`
+			} `json:"response"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func readHAR(data []byte) ([]replayRequest, error) {
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("invalid HAR file: %w", err)
+	}
+	if len(har.Log.Entries) == 0 {
+		return nil, errors.New("invalid HAR file: no entries")
+	}
+	entries := make([]replayRequest, 0, len(har.Log.Entries))
+	for i, he := range har.Log.Entries {
+		u, err := url.Parse(he.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		e := replayRequest{
+			method:  he.Request.Method,
+			uri:     u.RequestURI(),
+			proto:   harProto(he.Request.HTTPVersion),
+			host:    u.Host,
+			headers: harHeaders(he.Request.Headers),
+		}
+		if he.Request.PostData != nil {
+			e.body = []byte(he.Request.PostData.Text)
+		}
+		for _, h := range he.Request.Headers {
+			if strings.EqualFold(h.Name, "host") || h.Name == ":authority" {
+				e.host = h.Value
+			}
+		}
+		if r := he.Response; r != nil && r.Status != 0 {
+			e.response = &replayResponse{
+				status:  r.Status,
+				proto:   harProto(r.HTTPVersion),
+				headers: harHeaders(r.Headers),
+				body:    []byte(r.Content.Text),
+			}
+			if r.Content.Encoding == "base64" {
+				if e.response.body, err = base64.StdEncoding.DecodeString(r.Content.Text); err != nil {
+					return nil, fmt.Errorf("entry %d: %w", i+1, err)
+				}
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// harHeaders skips the HTTP/2 pseudo headers and the host header, which
+// is added from the request URL.
+func harHeaders(headers []harHeader) []replayHeader {
+	var res []replayHeader
+	for _, h := range headers {
+		if strings.HasPrefix(h.Name, ":") || strings.EqualFold(h.Name, "host") {
+			continue
+		}
+		res = append(res, replayHeader{h.Name, h.Value})
+	}
+	return res
+}
+
+// harProto normalizes the versions written by browsers, like "h2" or
+// "http/2.0", to the protocol format used by the WAF.
+func harProto(v string) string {
+	switch strings.ToLower(v) {
+	case "", "unknown":
+		return "HTTP/1.1"
+	case "h2", "http/2", "http/2.0":
+		return "HTTP/2.0"
+	case "h3", "http/3", "http/3.0":
+		return "HTTP/3.0"
+	}
+	return strings.ToUpper(v)
+}
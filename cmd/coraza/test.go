@@ -0,0 +1,184 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/corazawaf/coraza/v3"
+	coraztesting "github.com/corazawaf/coraza/v3/testing"
+	"github.com/corazawaf/coraza/v3/testing/profile"
+)
+
+// testCommand runs the tests of YAML profiles, in the format defined by
+// the testing/profile package, against the rules of each profile.
+func testCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	root := flags.String("root", "", "directory used to resolve the files referenced by the profile rules")
+	runPattern := flags.String("run", "", "only run the tests whose profile/title match the regular expression")
+	verbose := flags.Bool("v", false, "print the passing tests and the request of the failing ones")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: coraza test [-root dir] [-run regexp] [-v] <profile.yaml|dir>...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var filter *regexp.Regexp
+	if *runPattern != "" {
+		var err error
+		if filter, err = regexp.Compile(*runPattern); err != nil {
+			fmt.Fprintf(stderr, "invalid -run: %s\n", err.Error())
+			return 2
+		}
+	}
+
+	files, err := profileFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+
+	config := coraza.NewWAFConfig()
+	if *root != "" {
+		config = config.WithRootFS(os.DirFS(*root))
+	}
+
+	passed, failed := 0, 0
+	for _, file := range files {
+		p, err := readProfile(file)
+		if err != nil {
+			fmt.Fprintf(stdout, "FAIL  %s: %s\n", file, err.Error())
+			failed++
+			continue
+		}
+		tests, err := coraztesting.NewProfileTests(&p, config)
+		if err != nil {
+			fmt.Fprintf(stdout, "FAIL  %s: %s\n", p.Meta.Name, err.Error())
+			failed++
+			continue
+		}
+		for _, test := range tests {
+			name := p.Meta.Name + "/" + test.Name
+			if filter != nil && !filter.MatchString(name) {
+				continue
+			}
+			if errs := runProfileTest(test); len(errs) > 0 {
+				failed++
+				fmt.Fprintf(stdout, "FAIL  %s\n", name)
+				for _, e := range errs {
+					fmt.Fprintf(stdout, "      %s\n", e)
+				}
+				if *verbose {
+					fmt.Fprintf(stdout, "      request:\n%s\n", indent(test.Request(), "        "))
+				}
+				continue
+			}
+			passed++
+			if *verbose {
+				fmt.Fprintf(stdout, "PASS  %s\n", name)
+			}
+		}
+	}
+
+	fmt.Fprintf(stdout, "%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runProfileTest runs the test phases and returns the expectations that
+// were not met.
+func runProfileTest(test *coraztesting.Test) []string {
+	err := test.RunPhases()
+	switch {
+	case err != nil && !test.ExpectedOutput.ExpectError:
+		return []string{fmt.Sprintf("unexpected error: %s", err.Error())}
+	case err == nil && test.ExpectedOutput.ExpectError:
+		return []string{"expected an error"}
+	}
+	errs := test.OutputErrors()
+	return append(errs, test.OutputInterruptionErrors()...)
+}
+
+// profileFiles expands the directories in paths to the YAML files they
+// contain.
+func profileFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if ext := filepath.Ext(p); !d.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func readProfile(file string) (profile.Profile, error) {
+	var p profile.Profile
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return p, err
+	}
+	if err := yaml.UnmarshalWithOptions(data, &p, yaml.CustomUnmarshaler(unmarshalBytes)); err != nil {
+		return p, err
+	}
+	if p.Meta.Name == "" {
+		p.Meta.Name = filepath.Base(file)
+	}
+	return p, nil
+}
+
+// unmarshalBytes decodes raw_request from a plain string instead of the
+// sequence of bytes expected by default.
+func unmarshalBytes(b *[]byte, data []byte) error {
+	var s string
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*b = []byte(s)
+	return nil
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(strings.TrimRight(s, "\r\n"), "\n")
+	for i, l := range lines {
+		lines[i] = prefix + strings.TrimRight(l, "\r")
+	}
+	return strings.Join(lines, "\n")
+}
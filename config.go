@@ -7,6 +7,7 @@ import (
 	"io/fs"
 
 	"github.com/corazawaf/coraza/v3/debuglog"
+	"github.com/corazawaf/coraza/v3/experimental/metrics"
 	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
 	"github.com/corazawaf/coraza/v3/internal/corazawaf"
 	"github.com/corazawaf/coraza/v3/types"
@@ -96,6 +97,7 @@ type wafRule struct {
 // We still basically assume 64-bit usage where int are big sizes.
 type wafConfig struct {
 	ruleObserver             func(rule types.RuleMetadata)
+	metrics                  metrics.Recorder
 	rules                    []wafRule
 	auditLog                 *auditLogConfig
 	requestBodyAccess        bool
@@ -127,6 +129,12 @@ func (c *wafConfig) WithRuleObserver(observer func(rule types.RuleMetadata)) WAF
 	return ret
 }
 
+func (c *wafConfig) WithMetrics(recorder metrics.Recorder) WAFConfig {
+	ret := c.clone()
+	ret.metrics = recorder
+	return ret
+}
+
 func (c *wafConfig) WithDirectivesFromFile(path string) WAFConfig {
 	ret := c.clone()
 	ret.rules = append(ret.rules, wafRule{file: path})
@@ -0,0 +1,26 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package experimental
+
+import (
+	"github.com/corazawaf/coraza/v3"
+	"github.com/corazawaf/coraza/v3/experimental/metrics"
+)
+
+// wafConfigWithMetrics is the private capability interface
+type wafConfigWithMetrics interface {
+	WithMetrics(metrics.Recorder) coraza.WAFConfig
+}
+
+// WAFConfigWithMetrics reports the activity of the WAF to the recorder if
+// supported, see metrics.Registry for a recorder exposing it to Prometheus.
+func WAFConfigWithMetrics(
+	cfg coraza.WAFConfig,
+	recorder metrics.Recorder,
+) coraza.WAFConfig {
+	if c, ok := cfg.(wafConfigWithMetrics); ok {
+		return c.WithMetrics(recorder)
+	}
+	return cfg
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package metrics defines how the WAF reports its activity, see Recorder, and
+// provides Registry, a recorder exposing the metrics in the Prometheus text
+// format without any external dependency.
+package metrics
+
+import (
+	"time"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// Body identifies the request or the response body of a transaction.
+type Body string
+
+const (
+	BodyRequest  Body = "request"
+	BodyResponse Body = "response"
+)
+
+// Recorder receives the activity of a WAF. The transactions report it when
+// they are closed, so the implementations must be safe for concurrent use and
+// should not block.
+type Recorder interface {
+	// TransactionClosed is called once per transaction.
+	TransactionClosed()
+
+	// Interruption is called for the interrupted transactions with the
+	// disruptive action and the ID of the rule interrupting them, 0 when the
+	// interruption doesn't come from a rule (e.g. a body limit).
+	Interruption(action string, ruleID int)
+
+	// RuleMatched is called for every matched rule logging its matches, the
+	// rules with nolog are not reported.
+	RuleMatched(ruleID int, severity types.RuleSeverity, tags []string)
+
+	// PhaseDuration is called with the time spent evaluating the rules of
+	// each phase the transaction reached.
+	PhaseDuration(phase types.RulePhase, d time.Duration)
+
+	// BodyLimitExceeded is called when the body is bigger than its limit.
+	BodyLimitExceeded(body Body)
+
+	// BodyProcessorError is called when the body processor fails to parse
+	// the body.
+	BodyProcessorError(body Body, processor string)
+
+	// AuditLogWriteError is called when the audit log writer fails to write
+	// an entry.
+	AuditLogWriteError()
+}
+
+// AuditLogStats are the counters of an asynchronous audit log writer, see
+// the async format of SecAuditLog.
+type AuditLogStats struct {
+	// Queued is the number of entries waiting to be written.
+	Queued int
+	// Written is the number of entries written.
+	Written uint64
+	// Retried is the number of retried writes.
+	Retried uint64
+	// Spilled is the number of entries appended to the spill file.
+	Spilled uint64
+	// Dropped is the number of lost entries.
+	Dropped uint64
+}
+
+// AuditLogStatsObserver is implemented by the recorders exposing the counters
+// of the asynchronous audit log writers. The WAF registers the function
+// returning them when its writer is asynchronous, and calls the returned
+// function to unregister it once closed.
+type AuditLogStatsObserver interface {
+	ObserveAuditLogStats(stats func() AuditLogStats) (unregister func())
+}
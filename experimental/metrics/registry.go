@@ -0,0 +1,344 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// DefaultPhaseBuckets are the upper bounds, in seconds, of the buckets of the
+// phase duration histograms.
+var DefaultPhaseBuckets = []float64{0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// Registry is a Recorder keeping the metrics in memory, it serves them over
+// HTTP in the Prometheus text exposition format:
+//
+//	registry := metrics.NewRegistry()
+//	waf, err := coraza.NewWAF(experimental.WAFConfigWithMetrics(cfg, registry))
+//	...
+//	http.Handle("/metrics", registry)
+//
+// A registry can be shared by several WAFs, their metrics are added up.
+type Registry struct {
+	buckets []float64
+
+	mu                  sync.Mutex
+	transactions        uint64
+	interruptions       map[interruptionKey]uint64
+	matchesBySeverity   map[string]uint64
+	matchesByTag        map[string]uint64
+	phases              map[types.RulePhase]*histogram
+	bodyLimits          map[Body]uint64
+	bodyProcessorErrors map[bodyProcessorKey]uint64
+	auditLogWriteErrors uint64
+	auditLogStats       []*auditLogObserver
+	// retiredAuditLogStats are the counters of the unregistered writers, so
+	// the exposed counters don't go backwards.
+	retiredAuditLogStats *AuditLogStats
+}
+
+type auditLogObserver struct {
+	stats func() AuditLogStats
+}
+
+type interruptionKey struct {
+	action string
+	ruleID int
+}
+
+type bodyProcessorKey struct {
+	body      Body
+	processor string
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+var (
+	_ Recorder              = (*Registry)(nil)
+	_ AuditLogStatsObserver = (*Registry)(nil)
+	_ http.Handler          = (*Registry)(nil)
+)
+
+// NewRegistry returns an empty registry, the phase durations are counted in
+// the given buckets or DefaultPhaseBuckets when none.
+func NewRegistry(phaseBuckets ...float64) *Registry {
+	if len(phaseBuckets) == 0 {
+		phaseBuckets = DefaultPhaseBuckets
+	}
+	buckets := slices.Clone(phaseBuckets)
+	slices.Sort(buckets)
+	return &Registry{
+		buckets:             slices.Compact(buckets),
+		interruptions:       map[interruptionKey]uint64{},
+		matchesBySeverity:   map[string]uint64{},
+		matchesByTag:        map[string]uint64{},
+		phases:              map[types.RulePhase]*histogram{},
+		bodyLimits:          map[Body]uint64{},
+		bodyProcessorErrors: map[bodyProcessorKey]uint64{},
+	}
+}
+
+func (r *Registry) TransactionClosed() {
+	r.mu.Lock()
+	r.transactions++
+	r.mu.Unlock()
+}
+
+func (r *Registry) Interruption(action string, ruleID int) {
+	r.mu.Lock()
+	r.interruptions[interruptionKey{action: action, ruleID: ruleID}]++
+	r.mu.Unlock()
+}
+
+func (r *Registry) RuleMatched(_ int, severity types.RuleSeverity, tags []string) {
+	r.mu.Lock()
+	r.matchesBySeverity[severity.String()]++
+	for _, tag := range tags {
+		r.matchesByTag[tag]++
+	}
+	r.mu.Unlock()
+}
+
+func (r *Registry) PhaseDuration(phase types.RulePhase, d time.Duration) {
+	seconds := d.Seconds()
+	r.mu.Lock()
+	h := r.phases[phase]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(r.buckets))}
+		r.phases[phase] = h
+	}
+	// the buckets are cumulative, they are added up when written
+	if i, _ := slices.BinarySearch(r.buckets, seconds); i < len(r.buckets) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += seconds
+	r.mu.Unlock()
+}
+
+func (r *Registry) BodyLimitExceeded(body Body) {
+	r.mu.Lock()
+	r.bodyLimits[body]++
+	r.mu.Unlock()
+}
+
+func (r *Registry) BodyProcessorError(body Body, processor string) {
+	r.mu.Lock()
+	r.bodyProcessorErrors[bodyProcessorKey{body: body, processor: processor}]++
+	r.mu.Unlock()
+}
+
+func (r *Registry) AuditLogWriteError() {
+	r.mu.Lock()
+	r.auditLogWriteErrors++
+	r.mu.Unlock()
+}
+
+// ObserveAuditLogStats adds the counters of an asynchronous audit log writer
+// to the exposed metrics, they are read when the metrics are written. Once
+// unregistered, the last counters of the writer are still added up.
+func (r *Registry) ObserveAuditLogStats(stats func() AuditLogStats) func() {
+	o := &auditLogObserver{stats: stats}
+	r.mu.Lock()
+	r.auditLogStats = append(r.auditLogStats, o)
+	r.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			// the writer is not read while holding the lock
+			last := stats()
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.auditLogStats = slices.DeleteFunc(r.auditLogStats, func(s *auditLogObserver) bool { return s == o })
+			if r.retiredAuditLogStats == nil {
+				r.retiredAuditLogStats = &AuditLogStats{}
+			}
+			last.Queued = 0
+			r.retiredAuditLogStats.add(last)
+		})
+	}
+}
+
+func (s *AuditLogStats) add(o AuditLogStats) {
+	s.Queued += o.Queued
+	s.Written += o.Written
+	s.Retried += o.Retried
+	s.Spilled += o.Spilled
+	s.Dropped += o.Dropped
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.WriteText(w)
+}
+
+// WriteText writes the metrics in the Prometheus text exposition format, the
+// series are sorted by their labels.
+func (r *Registry) WriteText(w io.Writer) error {
+	// the writers are not read while holding the lock
+	r.mu.Lock()
+	observers := slices.Clone(r.auditLogStats)
+	var stats AuditLogStats
+	observed := len(observers) > 0 || r.retiredAuditLogStats != nil
+	if r.retiredAuditLogStats != nil {
+		stats = *r.retiredAuditLogStats
+	}
+	r.mu.Unlock()
+	for _, o := range observers {
+		stats.add(o.stats())
+	}
+
+	bw := bufio.NewWriter(w)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	writeHeader(bw, "coraza_transactions_total", "counter", "Number of closed transactions.")
+	writeSample(bw, "coraza_transactions_total", "", r.transactions)
+
+	writeHeader(bw, "coraza_interruptions_total", "counter", "Number of interrupted transactions by disruptive action and rule ID.")
+	for _, k := range sortedKeys(r.interruptions, func(a, b interruptionKey) int {
+		if c := strings.Compare(a.action, b.action); c != 0 {
+			return c
+		}
+		return a.ruleID - b.ruleID
+	}) {
+		writeSample(bw, "coraza_interruptions_total", labels("action", k.action, "rule_id", strconv.Itoa(k.ruleID)), r.interruptions[k])
+	}
+
+	writeHeader(bw, "coraza_rule_matches_total", "counter", "Number of logged rule matches by severity.")
+	for _, k := range sortedKeys(r.matchesBySeverity, strings.Compare) {
+		writeSample(bw, "coraza_rule_matches_total", labels("severity", k), r.matchesBySeverity[k])
+	}
+
+	writeHeader(bw, "coraza_rule_matches_by_tag_total", "counter", "Number of logged rule matches by tag.")
+	for _, k := range sortedKeys(r.matchesByTag, strings.Compare) {
+		writeSample(bw, "coraza_rule_matches_by_tag_total", labels("tag", k), r.matchesByTag[k])
+	}
+
+	writeHeader(bw, "coraza_phase_duration_seconds", "histogram", "Time spent evaluating the rules of each phase.")
+	for _, phase := range sortedKeys(r.phases, func(a, b types.RulePhase) int { return int(a) - int(b) }) {
+		h := r.phases[phase]
+		p := strconv.Itoa(int(phase))
+		cumulative := uint64(0)
+		for i, le := range r.buckets {
+			cumulative += h.counts[i]
+			writeSample(bw, "coraza_phase_duration_seconds_bucket", labels("phase", p, "le", formatFloat(le)), cumulative)
+		}
+		writeSample(bw, "coraza_phase_duration_seconds_bucket", labels("phase", p, "le", "+Inf"), h.count)
+		writeFloatSample(bw, "coraza_phase_duration_seconds_sum", labels("phase", p), h.sum)
+		writeSample(bw, "coraza_phase_duration_seconds_count", labels("phase", p), h.count)
+	}
+
+	writeHeader(bw, "coraza_body_limit_exceeded_total", "counter", "Number of bodies exceeding their limit.")
+	for _, k := range sortedKeys(r.bodyLimits, func(a, b Body) int { return strings.Compare(string(a), string(b)) }) {
+		writeSample(bw, "coraza_body_limit_exceeded_total", labels("body", string(k)), r.bodyLimits[k])
+	}
+
+	writeHeader(bw, "coraza_body_processor_errors_total", "counter", "Number of bodies the body processor failed to parse.")
+	for _, k := range sortedKeys(r.bodyProcessorErrors, func(a, b bodyProcessorKey) int {
+		if c := strings.Compare(string(a.body), string(b.body)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.processor, b.processor)
+	}) {
+		writeSample(bw, "coraza_body_processor_errors_total", labels("body", string(k.body), "processor", k.processor), r.bodyProcessorErrors[k])
+	}
+
+	writeHeader(bw, "coraza_audit_log_write_errors_total", "counter", "Number of audit log entries the writer failed to write.")
+	writeSample(bw, "coraza_audit_log_write_errors_total", "", r.auditLogWriteErrors)
+
+	if observed {
+		writeHeader(bw, "coraza_audit_log_queued_entries", "gauge", "Number of audit log entries waiting to be written.")
+		writeSample(bw, "coraza_audit_log_queued_entries", "", uint64(stats.Queued))
+		for _, c := range []struct {
+			name, help string
+			value      uint64
+		}{
+			{"coraza_audit_log_written_total", "Number of audit log entries written asynchronously.", stats.Written},
+			{"coraza_audit_log_retried_total", "Number of retried audit log writes.", stats.Retried},
+			{"coraza_audit_log_spilled_total", "Number of audit log entries appended to the spill file.", stats.Spilled},
+			{"coraza_audit_log_dropped_total", "Number of lost audit log entries.", stats.Dropped},
+		} {
+			writeHeader(bw, c.name, "counter", c.help)
+			writeSample(bw, c.name, "", c.value)
+		}
+	}
+
+	return bw.Flush()
+}
+
+func sortedKeys[K comparable, V any](m map[K]V, cmp func(a, b K) int) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, cmp)
+	return keys
+}
+
+func writeHeader(w *bufio.Writer, name, typ, help string) {
+	w.WriteString("# HELP ")
+	w.WriteString(name)
+	w.WriteByte(' ')
+	w.WriteString(help)
+	w.WriteString("\n# TYPE ")
+	w.WriteString(name)
+	w.WriteByte(' ')
+	w.WriteString(typ)
+	w.WriteByte('\n')
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value uint64) {
+	w.WriteString(name)
+	w.WriteString(labels)
+	w.WriteByte(' ')
+	w.WriteString(strconv.FormatUint(value, 10))
+	w.WriteByte('\n')
+}
+
+func writeFloatSample(w *bufio.Writer, name, labels string, value float64) {
+	w.WriteString(name)
+	w.WriteString(labels)
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels formats the name and value pairs as the labels of a sample.
+func labels(pairs ...string) string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(pairs[i])
+		sb.WriteString(`="`)
+		labelValueEscaper.WriteString(&sb, pairs[i+1])
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+func TestRegistryEmpty(t *testing.T) {
+	var sb strings.Builder
+	if err := NewRegistry().WriteText(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP coraza_transactions_total Number of closed transactions.
+# TYPE coraza_transactions_total counter
+coraza_transactions_total 0
+# HELP coraza_interruptions_total Number of interrupted transactions by disruptive action and rule ID.
+# TYPE coraza_interruptions_total counter
+# HELP coraza_rule_matches_total Number of logged rule matches by severity.
+# TYPE coraza_rule_matches_total counter
+# HELP coraza_rule_matches_by_tag_total Number of logged rule matches by tag.
+# TYPE coraza_rule_matches_by_tag_total counter
+# HELP coraza_phase_duration_seconds Time spent evaluating the rules of each phase.
+# TYPE coraza_phase_duration_seconds histogram
+# HELP coraza_body_limit_exceeded_total Number of bodies exceeding their limit.
+# TYPE coraza_body_limit_exceeded_total counter
+# HELP coraza_body_processor_errors_total Number of bodies the body processor failed to parse.
+# TYPE coraza_body_processor_errors_total counter
+# HELP coraza_audit_log_write_errors_total Number of audit log entries the writer failed to write.
+# TYPE coraza_audit_log_write_errors_total counter
+coraza_audit_log_write_errors_total 0
+`
+	if have := sb.String(); have != want {
+		t.Errorf("unexpected exposition, want:\n%s\nhave:\n%s", want, have)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(0.01, 0.001, 0.01)
+	for i := 0; i < 3; i++ {
+		r.TransactionClosed()
+	}
+	r.Interruption("deny", 942100)
+	r.Interruption("drop", 0)
+	r.Interruption("deny", 942100)
+	r.Interruption("deny", 920350)
+	r.RuleMatched(942100, types.RuleSeverityCritical, []string{"attack-sqli", "paranoia-level/1"})
+	r.RuleMatched(920350, types.RuleSeverityWarning, []string{"attack-protocol"})
+	r.RuleMatched(942101, types.RuleSeverityCritical, []string{"attack-sqli"})
+	r.RuleMatched(1, types.RuleSeverityUnset, nil)
+	r.PhaseDuration(types.PhaseRequestHeaders, 500*time.Microsecond)
+	r.PhaseDuration(types.PhaseRequestHeaders, time.Millisecond)
+	r.PhaseDuration(types.PhaseRequestHeaders, 2*time.Second)
+	r.PhaseDuration(types.PhaseRequestBody, 5*time.Millisecond)
+	r.BodyLimitExceeded(BodyRequest)
+	r.BodyProcessorError(BodyRequest, "JSON")
+	r.BodyProcessorError(BodyResponse, `weird "name"`)
+	r.AuditLogWriteError()
+	r.ObserveAuditLogStats(func() AuditLogStats {
+		return AuditLogStats{Queued: 2, Written: 10, Retried: 1, Spilled: 3, Dropped: 4}
+	})
+	unregister := r.ObserveAuditLogStats(func() AuditLogStats {
+		return AuditLogStats{Queued: 1, Written: 5}
+	})
+	// the last counters of the unregistered writers are kept, not their queue
+	unregister()
+	unregister()
+
+	want := `# HELP coraza_transactions_total Number of closed transactions.
+# TYPE coraza_transactions_total counter
+coraza_transactions_total 3
+# HELP coraza_interruptions_total Number of interrupted transactions by disruptive action and rule ID.
+# TYPE coraza_interruptions_total counter
+coraza_interruptions_total{action="deny",rule_id="920350"} 1
+coraza_interruptions_total{action="deny",rule_id="942100"} 2
+coraza_interruptions_total{action="drop",rule_id="0"} 1
+# HELP coraza_rule_matches_total Number of logged rule matches by severity.
+# TYPE coraza_rule_matches_total counter
+coraza_rule_matches_total{severity="critical"} 2
+coraza_rule_matches_total{severity="unknown"} 1
+coraza_rule_matches_total{severity="warning"} 1
+# HELP coraza_rule_matches_by_tag_total Number of logged rule matches by tag.
+# TYPE coraza_rule_matches_by_tag_total counter
+coraza_rule_matches_by_tag_total{tag="attack-protocol"} 1
+coraza_rule_matches_by_tag_total{tag="attack-sqli"} 2
+coraza_rule_matches_by_tag_total{tag="paranoia-level/1"} 1
+# HELP coraza_phase_duration_seconds Time spent evaluating the rules of each phase.
+# TYPE coraza_phase_duration_seconds histogram
+coraza_phase_duration_seconds_bucket{phase="1",le="0.001"} 2
+coraza_phase_duration_seconds_bucket{phase="1",le="0.01"} 2
+coraza_phase_duration_seconds_bucket{phase="1",le="+Inf"} 3
+coraza_phase_duration_seconds_sum{phase="1"} 2.0015
+coraza_phase_duration_seconds_count{phase="1"} 3
+coraza_phase_duration_seconds_bucket{phase="2",le="0.001"} 0
+coraza_phase_duration_seconds_bucket{phase="2",le="0.01"} 1
+coraza_phase_duration_seconds_bucket{phase="2",le="+Inf"} 1
+coraza_phase_duration_seconds_sum{phase="2"} 0.005
+coraza_phase_duration_seconds_count{phase="2"} 1
+# HELP coraza_body_limit_exceeded_total Number of bodies exceeding their limit.
+# TYPE coraza_body_limit_exceeded_total counter
+coraza_body_limit_exceeded_total{body="request"} 1
+# HELP coraza_body_processor_errors_total Number of bodies the body processor failed to parse.
+# TYPE coraza_body_processor_errors_total counter
+coraza_body_processor_errors_total{body="request",processor="JSON"} 1
+coraza_body_processor_errors_total{body="response",processor="weird \"name\""} 1
+# HELP coraza_audit_log_write_errors_total Number of audit log entries the writer failed to write.
+# TYPE coraza_audit_log_write_errors_total counter
+coraza_audit_log_write_errors_total 1
+# HELP coraza_audit_log_queued_entries Number of audit log entries waiting to be written.
+# TYPE coraza_audit_log_queued_entries gauge
+coraza_audit_log_queued_entries 2
+# HELP coraza_audit_log_written_total Number of audit log entries written asynchronously.
+# TYPE coraza_audit_log_written_total counter
+coraza_audit_log_written_total 15
+# HELP coraza_audit_log_retried_total Number of retried audit log writes.
+# TYPE coraza_audit_log_retried_total counter
+coraza_audit_log_retried_total 1
+# HELP coraza_audit_log_spilled_total Number of audit log entries appended to the spill file.
+# TYPE coraza_audit_log_spilled_total counter
+coraza_audit_log_spilled_total 3
+# HELP coraza_audit_log_dropped_total Number of lost audit log entries.
+# TYPE coraza_audit_log_dropped_total counter
+coraza_audit_log_dropped_total 4
+`
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if have := rec.Header().Get("Content-Type"); !strings.HasPrefix(have, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", have)
+	}
+	if have := rec.Body.String(); have != want {
+		t.Errorf("unexpected exposition, want:\n%s\nhave:\n%s", want, have)
+	}
+}
+
+func TestRegistryUnregisterAuditLogStats(t *testing.T) {
+	r := NewRegistry()
+	calls := 0
+	unregister := r.ObserveAuditLogStats(func() AuditLogStats {
+		calls++
+		return AuditLogStats{Queued: 1, Written: 3}
+	})
+	unregister()
+	if len(r.auditLogStats) != 0 {
+		t.Fatal("unexpected observers after unregistering")
+	}
+
+	var sb strings.Builder
+	if err := r.WriteText(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("unexpected reads of the unregistered counters, %d", calls)
+	}
+	for _, line := range []string{"coraza_audit_log_queued_entries 0\n", "coraza_audit_log_written_total 3\n"} {
+		if !strings.Contains(sb.String(), line) {
+			t.Errorf("missing %q in the exposition:\n%s", line, sb.String())
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package experimental_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/corazawaf/coraza/v3/experimental"
+	"github.com/corazawaf/coraza/v3/experimental/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	cfg := coraza.NewWAFConfig().WithDirectives(`
+		SecRuleEngine On
+		SecRequestBodyAccess On
+		SecRequestBodyLimit 16
+		SecRequestBodyLimitAction ProcessPartial
+		SecRule REQUEST_HEADERS:Content-Type "@streq application/json" "id:100,phase:1,pass,nolog,ctl:requestBodyProcessor=JSON"
+		SecRule ARGS_GET:q "@contains attack" "id:1,phase:1,deny,status:403,log,severity:CRITICAL,tag:attack-sqli"
+		SecRule REQUEST_URI "@beginsWith /warn" "id:2,phase:1,pass,log,severity:WARNING,tag:attack-protocol,tag:attack-sqli"
+	`)
+	waf, err := coraza.NewWAF(experimental.WAFConfigWithMetrics(cfg, registry))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, req := range []struct {
+		uri, contentType, body string
+	}{
+		{uri: "/?q=attack"},
+		{uri: "/warn"},
+		{uri: "/", contentType: "application/json", body: `{"a":`},
+		{uri: "/", contentType: "application/x-www-form-urlencoded", body: strings.Repeat("a=b&", 8)},
+	} {
+		tx := waf.NewTransaction()
+		tx.ProcessURI(req.uri, "POST", "HTTP/1.1")
+		if req.contentType != "" {
+			tx.AddRequestHeader("Content-Type", req.contentType)
+		}
+		tx.ProcessRequestHeaders()
+		if _, _, err := tx.WriteRequestBody([]byte(req.body)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.ProcessRequestBody(); err != nil {
+			t.Fatal(err)
+		}
+		tx.ProcessLogging()
+		if err := tx.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var sb strings.Builder
+	if err := registry.WriteText(&sb); err != nil {
+		t.Fatal(err)
+	}
+	exposition := sb.String()
+	for _, want := range []string{
+		"coraza_transactions_total 4\n",
+		`coraza_interruptions_total{action="deny",rule_id="1"} 1` + "\n",
+		`coraza_rule_matches_total{severity="critical"} 1` + "\n",
+		`coraza_rule_matches_total{severity="warning"} 1` + "\n",
+		`coraza_rule_matches_by_tag_total{tag="attack-protocol"} 1` + "\n",
+		`coraza_rule_matches_by_tag_total{tag="attack-sqli"} 2` + "\n",
+		`coraza_phase_duration_seconds_count{phase="1"} 4` + "\n",
+		`coraza_body_limit_exceeded_total{body="request"} 1` + "\n",
+		`coraza_body_processor_errors_total{body="request",processor="JSON"} 1` + "\n",
+		"coraza_audit_log_write_errors_total 0\n",
+	} {
+		if !strings.Contains(exposition, want) {
+			t.Errorf("missing %q in the exposition:\n%s", want, exposition)
+		}
+	}
+	// the nolog rule 100 is not counted
+	if strings.Contains(exposition, `severity="unknown"`) {
+		t.Errorf("unexpected match of a nolog rule:\n%s", exposition)
+	}
+}
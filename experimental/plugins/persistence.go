@@ -0,0 +1,15 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugins
+
+import (
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/persistence"
+)
+
+// RegisterPersistentStore registers a new persistent store, which can then be
+// selected with the SecPersistenceEngine directive.
+func RegisterPersistentStore(name string, storeFactory func() plugintypes.PersistentStore) {
+	persistence.RegisterStore(name, storeFactory)
+}
@@ -5,6 +5,7 @@ package plugintypes
 
 import (
 	"io/fs"
+	"time"
 
 	"github.com/corazawaf/coraza/v3/internal/collections"
 	"github.com/corazawaf/coraza/v3/types"
@@ -42,6 +43,12 @@ type AuditLogTransactionResponse interface {
 	Status() int
 	Headers() map[string][]string
 	Body() string
+	// IntermediaryHeaders are the response headers as inspected by the response
+	// headers phase (part D), Headers are the ones at logging time (part F).
+	IntermediaryHeaders() map[string][]string
+	// FinalBody is the response body delivered to the client (part G), Body is
+	// the one inspected by the response body phase (part E).
+	FinalBody() string
 }
 
 // AuditLogTransactionProducer contains producer specific information
@@ -53,6 +60,9 @@ type AuditLogTransactionProducer interface {
 	RuleEngine() string
 	Stopwatch() string
 	Rulesets() []string
+	// RulesPerformance returns the time, in microseconds, spent evaluating the
+	// rules which reached the SecRulePerfTime threshold, keyed by rule ID.
+	RulesPerformance() map[int]int64
 }
 
 // AuditLogTransactionRequest contains request specific information
@@ -117,6 +127,50 @@ type AuditLogConfig struct {
 
 	// Formatter is the formatter to use when writing formatted audit logs.
 	Formatter AuditLogFormatter
+
+	// Rotation configures the rotation and retention of the audit log files.
+	Rotation AuditLogRotation
+
+	// StorageRetention configures the retention of the transaction files
+	// written to Dir by the concurrent writer.
+	StorageRetention AuditLogStorageRetention
+}
+
+// AuditLogRotation configures the rotation of the audit log file and the
+// retention of the rotated files. Zero values disable each setting.
+type AuditLogRotation struct {
+	// MaxSize is the size in bytes the file is rotated at.
+	MaxSize int64
+
+	// Interval is the time after which the file is rotated.
+	Interval time.Duration
+
+	// Compress gzips the rotated files.
+	Compress bool
+
+	// MaxFiles is the number of rotated files kept, the oldest ones are removed.
+	MaxFiles int
+
+	// MaxAge is the age after which the rotated files are removed.
+	MaxAge time.Duration
+}
+
+// AuditLogStorageRetention configures how long the transaction files of the
+// concurrent audit log are kept. Zero values disable each setting.
+type AuditLogStorageRetention struct {
+	// MaxFiles is the number of transaction files kept, the oldest ones are
+	// removed.
+	MaxFiles int
+
+	// MaxAge is the age after which the transaction files are removed.
+	MaxAge time.Duration
+}
+
+// AuditLogReopener is implemented by the writers able to reopen their files,
+// so they can be moved away by external tools such as logrotate.
+type AuditLogReopener interface {
+	// Reopen closes the files of the writer and opens them again.
+	Reopen() error
 }
 
 // AuditLogWriter is the interface for all log writers.
@@ -23,6 +23,15 @@ type BodyProcessorOptions struct {
 	DirMode fs.FileMode
 	// RequestBodyRecursionLimit is the maximum recursion level accepted in a body processor
 	RequestBodyRecursionLimit int
+	// XMLDepthLimit is the maximum nesting level of XML elements, 0 means unlimited
+	XMLDepthLimit int
+	// XMLNodeLimit is the maximum number of nodes of XML documents, 0 means unlimited
+	XMLNodeLimit int
+	// ArgumentsLimit is the maximum number of arguments read from the request body, 0 means unlimited
+	ArgumentsLimit int
+	// KeepRawBody asks the body processor to keep a copy of the raw body for
+	// the operators validating the whole document, like @validateSchema
+	KeepRawBody bool
 }
 
 // BodyProcessor interface is used to create
@@ -0,0 +1,54 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugintypes
+
+import "time"
+
+// PersistentStoreConfig is the configuration of a persistent store,
+// populated by the SecCollectionTimeout directive.
+type PersistentStoreConfig struct {
+	// Timeout is the time a record is kept since its last update.
+	Timeout time.Duration
+}
+
+// PersistentStore backs the persistent collections (IP, SESSION, USER,
+// GLOBAL and RESOURCE) initialized with initcol, setsid and setuid.
+//
+// A record is addressed by the collection name and a key, and holds a set
+// of variables. Records expire when they are not updated within the configured
+// timeout and variables may expire individually through SetTTL. Variable names
+// are passed already lowercased.
+//
+// Implementations must be safe for concurrent use as a store is shared by
+// all the transactions of a WAF.
+type PersistentStore interface {
+	// Init prepares the store to be used. It is called once before any
+	// other method.
+	Init(cfg PersistentStoreConfig) error
+
+	// Get returns the value of a variable in a record. The boolean is false
+	// when the record or the variable does not exist or has expired.
+	Get(collection, key, variable string) (string, bool, error)
+
+	// All returns a snapshot of the live variables of a record, or an empty
+	// map if the record does not exist.
+	All(collection, key string) (map[string]string, error)
+
+	// Set stores the value of a variable, creating the record if needed and
+	// renewing its timeout.
+	Set(collection, key, variable, value string) error
+
+	// Sum atomically adds delta to the integer value of a variable, treating
+	// missing or non-numeric values as zero, and returns the new value.
+	Sum(collection, key, variable string, delta int) (int, error)
+
+	// Remove deletes a variable from a record.
+	Remove(collection, key, variable string) error
+
+	// SetTTL makes a variable expire after ttl.
+	SetTTL(collection, key, variable string, ttl time.Duration) error
+
+	// Close releases the resources held by the store.
+	Close() error
+}
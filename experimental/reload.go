@@ -0,0 +1,223 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package experimental
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// wafRetirer is implemented by the WAF instances that can release their
+// resources once their in-flight transactions finish.
+type wafRetirer interface {
+	Retire()
+}
+
+// ReloadError is returned by ReloadableWAF.Reload when the new configuration
+// cannot be loaded. The WAF keeps using the previous configuration.
+type ReloadError struct {
+	// Generation is the generation of the configuration still in use.
+	Generation uint64
+	// Err is the error returned while building the WAF from the new configuration.
+	Err error
+}
+
+func (e *ReloadError) Error() string {
+	return fmt.Sprintf("failed to reload WAF, keeping generation %d: %v", e.Generation, e.Err)
+}
+
+func (e *ReloadError) Unwrap() error {
+	return e.Err
+}
+
+// wafStateInheritor is implemented by the WAF instances able to take over the
+// persistent collections and the rate limit zones of the WAF they replace.
+type wafStateInheritor interface {
+	InheritState(previous coraza.WAF)
+}
+
+type reloadableInstance struct {
+	waf        coraza.WAF
+	generation uint64
+	// refs counts, in its upper bits, the callers using the WAF. The lowest
+	// bit is set once the instance is replaced: the WAF is retired when both
+	// the bit is set and no caller is using it.
+	refs atomic.Int64
+}
+
+// acquire takes a reference on the instance, it fails once it was replaced.
+func (i *reloadableInstance) acquire() bool {
+	for {
+		refs := i.refs.Load()
+		if refs&1 != 0 {
+			return false
+		}
+		if i.refs.CompareAndSwap(refs, refs+2) {
+			return true
+		}
+	}
+}
+
+// release drops a reference taken with acquire.
+func (i *reloadableInstance) release() {
+	if i.refs.Add(-2) == 1 {
+		retire(i.waf)
+	}
+}
+
+// replace marks the instance as replaced, the WAF is retired after the last
+// caller using it releases its reference.
+func (i *reloadableInstance) replace() {
+	if i.refs.Or(1) == 0 {
+		retire(i.waf)
+	}
+}
+
+// ReloadableWAF is a WAF whose configuration can be replaced at runtime.
+// Reload builds a new WAF from a WAFConfig and swaps it atomically: new
+// transactions use the new rules while the transactions in-flight finish on
+// the previous ones. The previous WAF is closed, releasing its cached
+// resources like the compiled regular expressions, once its last transaction
+// is closed.
+//
+// The persistent collections and the counters of the rate limit zones are
+// carried over to the new WAF as long as their configuration is unchanged.
+type ReloadableWAF struct {
+	current atomic.Pointer[reloadableInstance]
+	// mu serializes reloads
+	mu sync.Mutex
+}
+
+var (
+	_ coraza.WAF     = (*ReloadableWAF)(nil)
+	_ WAFWithOptions = (*ReloadableWAF)(nil)
+	_ WAFWithRules   = (*ReloadableWAF)(nil)
+	_ WAFCloser      = (*ReloadableWAF)(nil)
+
+	_ WAFWithAuditLogReopen = (*ReloadableWAF)(nil)
+)
+
+// NewReloadableWAF creates a ReloadableWAF from the configuration.
+func NewReloadableWAF(config coraza.WAFConfig) (*ReloadableWAF, error) {
+	waf, err := coraza.NewWAF(config)
+	if err != nil {
+		return nil, err
+	}
+	w := &ReloadableWAF{}
+	w.current.Store(&reloadableInstance{waf: waf, generation: 1})
+	return w, nil
+}
+
+// Reload builds a WAF from the configuration and replaces the current one.
+// If the configuration is invalid a *ReloadError is returned and the current
+// WAF is kept.
+//
+// The new WAF takes over the persistent collections when the persistence
+// engine and the collection timeout are unchanged, and the rate limit zones
+// whose configuration is unchanged, so their counters survive the reload.
+func (w *ReloadableWAF) Reload(config coraza.WAFConfig) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	old := w.current.Load()
+	waf, err := coraza.NewWAF(config)
+	if err != nil {
+		return &ReloadError{Generation: old.generation, Err: err}
+	}
+	if i, ok := waf.(wafStateInheritor); ok {
+		i.InheritState(old.waf)
+	}
+	w.current.Store(&reloadableInstance{waf: waf, generation: old.generation + 1})
+	old.replace()
+	return nil
+}
+
+// use calls fn with the current WAF, which is not retired until fn returns.
+func (w *ReloadableWAF) use(fn func(waf coraza.WAF)) {
+	for {
+		i := w.current.Load()
+		if i.acquire() {
+			fn(i.waf)
+			i.release()
+			return
+		}
+		// the instance is replaced after storing the next one, unless the
+		// ReloadableWAF was closed
+		if w.current.Load() == i {
+			fn(i.waf)
+			return
+		}
+	}
+}
+
+// Generation returns the number of configurations loaded, starting at 1 for
+// the configuration passed to NewReloadableWAF.
+func (w *ReloadableWAF) Generation() uint64 {
+	return w.current.Load().generation
+}
+
+// NewTransaction implements the same method on WAF.
+func (w *ReloadableWAF) NewTransaction() (tx types.Transaction) {
+	w.use(func(waf coraza.WAF) { tx = waf.NewTransaction() })
+	return tx
+}
+
+// NewTransactionWithID implements the same method on WAF.
+func (w *ReloadableWAF) NewTransactionWithID(id string) (tx types.Transaction) {
+	w.use(func(waf coraza.WAF) { tx = waf.NewTransactionWithID(id) })
+	return tx
+}
+
+// NewTransactionWithOptions implements the same method on WAFWithOptions.
+func (w *ReloadableWAF) NewTransactionWithOptions(opts Options) (tx types.Transaction) {
+	w.use(func(waf coraza.WAF) {
+		if oWAF, ok := waf.(WAFWithOptions); ok {
+			tx = oWAF.NewTransactionWithOptions(opts)
+			return
+		}
+		tx = waf.NewTransactionWithID(opts.ID)
+	})
+	return tx
+}
+
+// RulesCount returns the number of rules of the current WAF.
+func (w *ReloadableWAF) RulesCount() int {
+	if rWAF, ok := w.current.Load().waf.(WAFWithRules); ok {
+		return rWAF.RulesCount()
+	}
+	return 0
+}
+
+// ReopenAuditLog reopens the files of the audit log writer of the current WAF.
+func (w *ReloadableWAF) ReopenAuditLog() (err error) {
+	w.use(func(waf coraza.WAF) {
+		if r, ok := waf.(WAFWithAuditLogReopen); ok {
+			err = r.ReopenAuditLog()
+		}
+	})
+	return err
+}
+
+// Close releases the resources of the current WAF once its transactions
+// in-flight are closed.
+func (w *ReloadableWAF) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.current.Load().replace()
+	return nil
+}
+
+func retire(waf coraza.WAF) {
+	switch c := waf.(type) {
+	case wafRetirer:
+		c.Retire()
+	case WAFCloser:
+		_ = c.Close()
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.rateLimit
+
+package experimental_test
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/corazawaf/coraza/v3/experimental"
+)
+
+func TestReloadableWAFKeepsState(t *testing.T) {
+	config := func(limit string) coraza.WAFConfig {
+		return coraza.NewWAFConfig().WithDirectives(`
+SecRuleEngine On
+SecRateLimitZone login key=%{REMOTE_ADDR} limit=` + limit + ` period=1h
+SecAction "id:1,phase:1,nolog,pass,initcol:ip=%{REMOTE_ADDR},setvar:ip.attempts=+1"
+SecRule IP:attempts "@gt 2" "id:2,phase:1,deny,status:403"
+SecRule REMOTE_ADDR "@rateLimit login" "id:3,phase:1,deny,status:429"
+`)
+	}
+	request := func(waf coraza.WAF) int {
+		tx := waf.NewTransaction()
+		defer tx.Close()
+		tx.ProcessConnection("10.0.0.1", 1234, "", 80)
+		if it := tx.ProcessRequestHeaders(); it != nil {
+			return it.Status
+		}
+		return 200
+	}
+
+	waf, err := experimental.NewReloadableWAF(config("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer waf.Close()
+
+	if status := request(waf); status != 200 {
+		t.Fatalf("unexpected status %d", status)
+	}
+	if err := waf.Reload(config("1")); err != nil {
+		t.Fatal(err)
+	}
+	// the rate limit window survives the reload
+	if status := request(waf); status != 429 {
+		t.Errorf("expected the rate limit to be kept, have status %d", status)
+	}
+
+	// the persistent collection survives the reloads, even when the
+	// configuration of the rate limit zone changes
+	if err := waf.Reload(config("5")); err != nil {
+		t.Fatal(err)
+	}
+	if status := request(waf); status != 403 {
+		t.Errorf("expected the IP collection to be kept, have status %d", status)
+	}
+}
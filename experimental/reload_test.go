@@ -0,0 +1,141 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package experimental_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/corazawaf/coraza/v3/experimental"
+)
+
+func blockingConfig(id string, arg string) coraza.WAFConfig {
+	return coraza.NewWAFConfig().WithDirectives(`
+SecRuleEngine On
+SecRule ARGS:` + arg + ` "@rx ." "id:` + id + `,phase:1,deny,status:403"
+`)
+}
+
+func TestReloadableWAF(t *testing.T) {
+	waf, err := experimental.NewReloadableWAF(blockingConfig("1", "old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer waf.Close()
+
+	if waf.Generation() != 1 || waf.RulesCount() != 1 {
+		t.Fatalf("unexpected generation %d or rules count %d", waf.Generation(), waf.RulesCount())
+	}
+
+	inFlight := waf.NewTransactionWithID("in-flight")
+	if err := waf.Reload(blockingConfig("2", "new")); err != nil {
+		t.Fatal(err)
+	}
+	if waf.Generation() != 2 {
+		t.Errorf("unexpected generation %d", waf.Generation())
+	}
+
+	// The transaction created before the reload keeps the previous rules
+	inFlight.AddGetRequestArgument("old", "1")
+	if it := inFlight.ProcessRequestHeaders(); it == nil || it.RuleID != 1 {
+		t.Errorf("expected in-flight transaction to be interrupted by the previous rules, have %v", it)
+	}
+	if err := inFlight.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := waf.NewTransaction()
+	tx.AddGetRequestArgument("old", "1")
+	tx.AddGetRequestArgument("new", "1")
+	if it := tx.ProcessRequestHeaders(); it == nil || it.RuleID != 2 {
+		t.Errorf("expected transaction to be interrupted by the new rules, have %v", it)
+	}
+	if err := tx.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadableWAFInvalidConfig(t *testing.T) {
+	waf, err := experimental.NewReloadableWAF(blockingConfig("1", "old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer waf.Close()
+
+	err = waf.Reload(coraza.NewWAFConfig().WithDirectives("SecRule ARGS \"@unknown\" \"id:2\""))
+	var reloadErr *experimental.ReloadError
+	if !errors.As(err, &reloadErr) {
+		t.Fatalf("expected a ReloadError, have %v", err)
+	}
+	if reloadErr.Generation != 1 || reloadErr.Err == nil {
+		t.Errorf("unexpected error %+v", reloadErr)
+	}
+	if waf.Generation() != 1 {
+		t.Errorf("unexpected generation %d", waf.Generation())
+	}
+
+	tx := waf.NewTransactionWithOptions(experimental.Options{ID: "abc"})
+	defer tx.Close()
+	if tx.ID() != "abc" {
+		t.Errorf("unexpected transaction id %q", tx.ID())
+	}
+	tx.AddGetRequestArgument("old", "1")
+	if it := tx.ProcessRequestHeaders(); it == nil || it.RuleID != 1 {
+		t.Errorf("expected previous rules to be kept, have %v", it)
+	}
+}
+
+func TestReloadableWAFConcurrentReload(t *testing.T) {
+	waf, err := experimental.NewReloadableWAF(blockingConfig("1", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer waf.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				tx := waf.NewTransaction()
+				tx.AddGetRequestArgument("a", "1")
+				if it := tx.ProcessRequestHeaders(); it == nil {
+					t.Error("expected transaction to be interrupted")
+				}
+				_ = tx.Close()
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		if err := waf.Reload(blockingConfig("1", "a")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	if waf.Generation() != 11 {
+		t.Errorf("unexpected generation %d", waf.Generation())
+	}
+}
+
+func TestReloadableWAFClosed(t *testing.T) {
+	waf, err := experimental.NewReloadableWAF(blockingConfig("1", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := waf.ReopenAuditLog(); err != nil {
+		t.Fatal(err)
+	}
+	if err := waf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// the transactions created afterwards don't wait for a newer WAF
+	tx := waf.NewTransaction()
+	if err := tx.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package experimental
+
+// TransactionWithPeerAddress is implemented by transactions able to keep
+// the address of the peer the connection was received from in PEER_ADDR,
+// when the client address passed to ProcessConnection is resolved from the
+// headers of a trusted proxy.
+type TransactionWithPeerAddress interface {
+	// SetPeerAddress sets PEER_ADDR, it must be called after ProcessConnection.
+	SetPeerAddress(addr string)
+}
@@ -33,3 +33,10 @@ type WAFWithRules interface {
 type WAFCloser interface {
 	io.Closer
 }
+
+// WAFWithAuditLogReopen allows reopening the audit log files, the equivalent
+// of sending SIGHUP to a web server after its logs were moved by logrotate.
+// Connectors usually call it from their SIGHUP handler.
+type WAFWithAuditLogReopen interface {
+	ReopenAuditLog() error
+}
@@ -0,0 +1,174 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// tinygo does not support net.http so this package is not needed for it
+//go:build !tinygo
+
+package http
+
+import (
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ClientIPPolicy selects the header the client IP is resolved from when the
+// request comes from a trusted proxy. See WithTrustedProxies.
+type ClientIPPolicy struct {
+	header string
+	hops   int
+}
+
+// XForwardedFor resolves the client IP from the X-Forwarded-For header.
+// With hops greater than zero, the client IP is the hops-th address from the
+// right, hops being the number of proxies in front of the server appending to
+// the header. With zero hops, it is the rightmost address not belonging to
+// the trusted proxies.
+func XForwardedFor(hops int) ClientIPPolicy {
+	return ClientIPPolicy{header: "X-Forwarded-For", hops: hops}
+}
+
+// XRealIP resolves the client IP from the X-Real-IP header.
+func XRealIP() ClientIPPolicy {
+	return ClientIPPolicy{header: "X-Real-IP"}
+}
+
+// Forwarded resolves the client IP from the for parameter of the RFC 7239
+// Forwarded header, hops works as in XForwardedFor.
+func Forwarded(hops int) ClientIPPolicy {
+	return ClientIPPolicy{header: "Forwarded", hops: hops}
+}
+
+type trustedProxies struct {
+	prefixes []netip.Prefix
+	policy   ClientIPPolicy
+}
+
+func (p *trustedProxies) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the client address and port resolved from the headers when
+// peer is a trusted proxy. The port is 0 when the header does not hold it.
+func (p *trustedProxies) clientIP(peer string, h http.Header) (string, int, bool) {
+	peerAddr, err := netip.ParseAddr(peer)
+	if err != nil || !p.isTrusted(peerAddr.Unmap()) {
+		// headers from untrusted peers can be spoofed
+		return "", 0, false
+	}
+
+	var nodes []string
+	switch p.policy.header {
+	case "X-Real-IP":
+		nodes = []string{strings.TrimSpace(h.Get("X-Real-IP"))}
+	case "X-Forwarded-For":
+		for _, v := range h.Values("X-Forwarded-For") {
+			for _, n := range strings.Split(v, ",") {
+				nodes = append(nodes, strings.TrimSpace(n))
+			}
+		}
+	case "Forwarded":
+		for _, v := range h.Values("Forwarded") {
+			nodes = append(nodes, forwardedForNodes(v)...)
+		}
+	}
+
+	node, ok := p.selectNode(nodes)
+	if !ok {
+		return "", 0, false
+	}
+	addrPort, err := parseNode(node)
+	if err != nil {
+		return "", 0, false
+	}
+	return addrPort.Addr().String(), int(addrPort.Port()), true
+}
+
+// selectNode picks the client node out of the nodes appended by the proxies,
+// from the leftmost to the rightmost.
+func (p *trustedProxies) selectNode(nodes []string) (string, bool) {
+	if len(nodes) == 0 {
+		return "", false
+	}
+	if p.policy.hops > 0 {
+		// a shorter chain means the request entered through an inner proxy
+		return nodes[max(len(nodes)-p.policy.hops, 0)], true
+	}
+	for i := len(nodes) - 1; i >= 0; i-- {
+		addrPort, err := parseNode(nodes[i])
+		if err != nil {
+			return "", false
+		}
+		if !p.isTrusted(addrPort.Addr()) || i == 0 {
+			return nodes[i], true
+		}
+	}
+	return "", false
+}
+
+// parseNode parses an IP address, optionally with a port and IPv6 addresses
+// between brackets as in RFC 7239 node identifiers.
+func parseNode(node string) (netip.AddrPort, error) {
+	if addr, err := netip.ParseAddr(node); err == nil {
+		return netip.AddrPortFrom(addr.Unmap(), 0), nil
+	}
+	if strings.HasPrefix(node, "[") && strings.HasSuffix(node, "]") {
+		addr, err := netip.ParseAddr(node[1 : len(node)-1])
+		return netip.AddrPortFrom(addr.Unmap(), 0), err
+	}
+	addrPort, err := netip.ParseAddrPort(node)
+	if err != nil {
+		return addrPort, err
+	}
+	return netip.AddrPortFrom(addrPort.Addr().Unmap(), addrPort.Port()), nil
+}
+
+// forwardedForNodes returns the for parameters of the elements of a Forwarded
+// header value, e.g. for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711".
+// Elements without for parameter are returned as empty nodes, so they are not
+// mistaken by the client.
+func forwardedForNodes(v string) []string {
+	var nodes []string
+	for _, element := range splitQuoted(v, ',') {
+		node := ""
+		for _, pair := range splitQuoted(element, ';') {
+			k, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(k), "for") {
+				val = strings.TrimSpace(val)
+				if unquoted, err := strconv.Unquote(val); err == nil {
+					val = unquoted
+				}
+				node = val
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// splitQuoted splits s by sep, ignoring the separators in quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var (
+		parts  []string
+		quoted bool
+		start  int
+	)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,169 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// tinygo does not support net.http so this package is not needed for it
+//go:build !tinygo
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3"
+)
+
+func TestClientIP(t *testing.T) {
+	prefixes := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8:cafe::/48"),
+	}
+	tests := map[string]struct {
+		policy  ClientIPPolicy
+		peer    string
+		headers map[string][]string
+		ip      string
+		port    int
+		ok      bool
+	}{
+		"xff single hop": {
+			policy:  XForwardedFor(1),
+			peer:    "10.0.0.1",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1, 2.2.2.2"}},
+			ip:      "2.2.2.2",
+			ok:      true,
+		},
+		"xff two hops across headers": {
+			policy:  XForwardedFor(2),
+			peer:    "10.0.0.1",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1, 2.2.2.2", "10.0.0.2"}},
+			ip:      "2.2.2.2",
+			ok:      true,
+		},
+		"xff hops longer than chain": {
+			policy:  XForwardedFor(3),
+			peer:    "10.0.0.1",
+			headers: map[string][]string{"X-Forwarded-For": {"2.2.2.2"}},
+			ip:      "2.2.2.2",
+			ok:      true,
+		},
+		"xff rightmost untrusted": {
+			policy:  XForwardedFor(0),
+			peer:    "10.0.0.1",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1, 2.2.2.2, 10.0.0.3, 10.0.0.2"}},
+			ip:      "2.2.2.2",
+			ok:      true,
+		},
+		"xff all trusted": {
+			policy:  XForwardedFor(0),
+			peer:    "10.0.0.1",
+			headers: map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			ip:      "10.0.0.3",
+			ok:      true,
+		},
+		"xff invalid node": {
+			policy:  XForwardedFor(1),
+			peer:    "10.0.0.1",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1, garbage"}},
+		},
+		"xff ipv4 mapped": {
+			policy:  XForwardedFor(1),
+			peer:    "::ffff:10.0.0.1",
+			headers: map[string][]string{"X-Forwarded-For": {"::ffff:2.2.2.2"}},
+			ip:      "2.2.2.2",
+			ok:      true,
+		},
+		"xff without header": {
+			policy: XForwardedFor(1),
+			peer:   "10.0.0.1",
+		},
+		"spoofed header from untrusted peer": {
+			policy:  XForwardedFor(1),
+			peer:    "3.3.3.3",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1"}},
+		},
+		"invalid peer": {
+			policy:  XForwardedFor(1),
+			peer:    "",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1"}},
+		},
+		"x-real-ip": {
+			policy:  XRealIP(),
+			peer:    "2001:db8:cafe::1",
+			headers: map[string][]string{"X-Real-Ip": {" 2001:db8::17 "}},
+			ip:      "2001:db8::17",
+			ok:      true,
+		},
+		"forwarded quoted ipv6 with port": {
+			policy:  Forwarded(1),
+			peer:    "10.0.0.1",
+			headers: map[string][]string{"Forwarded": {`for=192.0.2.60;proto=http;by=203.0.113.43, For="[2001:db8::17]:4711"`}},
+			ip:      "2001:db8::17",
+			port:    4711,
+			ok:      true,
+		},
+		"forwarded rightmost untrusted": {
+			policy:  Forwarded(0),
+			peer:    "10.0.0.1",
+			headers: map[string][]string{"Forwarded": {`for=192.0.2.60;proto="a,b", for=10.0.0.2`}},
+			ip:      "192.0.2.60",
+			ok:      true,
+		},
+		"forwarded obfuscated": {
+			policy:  Forwarded(1),
+			peer:    "10.0.0.1",
+			headers: map[string][]string{"Forwarded": {"for=unknown"}},
+		},
+		"forwarded without for": {
+			policy:  Forwarded(1),
+			peer:    "10.0.0.1",
+			headers: map[string][]string{"Forwarded": {"for=192.0.2.60, proto=https"}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := &trustedProxies{prefixes: prefixes, policy: tc.policy}
+			ip, port, ok := p.clientIP(tc.peer, http.Header(tc.headers))
+			if ok != tc.ok || ip != tc.ip || port != tc.port {
+				t.Errorf("unexpected client, want %q %d %t, have %q %d %t", tc.ip, tc.port, tc.ok, ip, port, ok)
+			}
+		})
+	}
+}
+
+func TestWrapHandlerWithTrustedProxies(t *testing.T) {
+	waf, err := coraza.NewWAF(coraza.NewWAFConfig().WithDirectives(`
+SecRuleEngine On
+SecRule REMOTE_ADDR "@ipMatch 1.1.1.1" "id:1,phase:1,deny,status:401,chain"
+	SecRule PEER_ADDR "@ipMatch 10.0.0.0/8" ""
+SecRule REMOTE_ADDR "@ipMatch 3.3.3.3" "id:2,phase:1,deny,status:403"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := WrapHandlerWithOptions(waf, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}),
+		WithTrustedProxies([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, XForwardedFor(1)))
+
+	tests := map[string]struct {
+		remoteAddr string
+		status     int
+	}{
+		"trusted proxy":   {remoteAddr: "10.1.2.3:4567", status: 401},
+		"untrusted peer":  {remoteAddr: "3.3.3.3:4567", status: 403},
+		"trusted ip port": {remoteAddr: "[::ffff:10.1.2.3]:4567", status: 401},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set("X-Forwarded-For", "1.1.1.1")
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, req)
+			if want, have := tc.status, res.Code; want != have {
+				t.Errorf("unexpected status code, want %d, have %d", want, have)
+			}
+		})
+	}
+}
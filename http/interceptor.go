@@ -37,7 +37,9 @@ func (h *hijackerTracker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 // and returned status code.
 type rwInterceptor struct {
 	w                             http.ResponseWriter
+	r                             *http.Request
 	tx                            types.Transaction
+	opts                          *handlerOptions
 	statusCode                    int
 	proto                         string
 	isWriteHeaderFlush            bool
@@ -65,10 +67,7 @@ func (i *rwInterceptor) WriteHeader(statusCode int) {
 
 	i.statusCode = statusCode
 	if it := i.tx.ProcessResponseHeaders(statusCode, i.proto); it != nil {
-		i.cleanHeaders()
-		i.Header().Set("Content-Length", "0")
-		i.statusCode = obtainStatusCodeFromInterruptionOrDefault(it, i.statusCode)
-		i.flushWriteHeader()
+		i.interrupt(it)
 		return
 	}
 
@@ -98,6 +97,22 @@ func (i *rwInterceptor) flushWriteHeader() {
 	}
 }
 
+// interrupt cleans the headers and replaces the response with the one for
+// the interruption, unless the status code has been flushed already.
+func (i *rwInterceptor) interrupt(it *types.Interruption) {
+	if i.isWriteHeaderFlush {
+		return
+	}
+	i.cleanHeaders()
+	i.Header().Set("Content-Length", "0")
+	i.isWriteHeaderFlush = true
+	i.statusCode = i.opts.writeInterruption(i.w, i.r, i.tx, it, i.statusCode)
+	if i.statusCode == 0 {
+		// the connection was dropped
+		i.isHijacked = true
+	}
+}
+
 // cleanHeaders removes all headers from the response
 func (i *rwInterceptor) cleanHeaders() {
 	for k := range i.w.Header() {
@@ -132,12 +147,8 @@ func (i *rwInterceptor) Write(b []byte) (int, error) {
 		// to it, otherwise we just send it to the response writer.
 		it, n, err := i.tx.WriteResponseBody(b)
 		if it != nil {
-			// if there is an interruption we must clean the headers and override the status code
-			i.cleanHeaders()
-			i.Header().Set("Content-Length", "0")
-			i.overrideWriteHeader(obtainStatusCodeFromInterruptionOrDefault(it, i.statusCode))
 			// We only flush the status code after an interruption.
-			i.flushWriteHeader()
+			i.interrupt(it)
 			// We return the number of bytes as according to the interface io.Writer
 			// if we don't return an error, the number of bytes written is len(p).
 			// See https://pkg.go.dev/io#Writer
@@ -227,9 +238,18 @@ var _ responseWriter = (*rwInterceptor)(nil)
 func wrap(w http.ResponseWriter, r *http.Request, tx types.Transaction) (
 	http.ResponseWriter,
 	func(types.Transaction, *http.Request) error,
+) {
+	return wrapWithOptions(w, r, tx, defaultHandlerOptions)
+}
+
+// wrapWithOptions is like wrap, with the responses to the interruptions
+// configured by o.
+func wrapWithOptions(w http.ResponseWriter, r *http.Request, tx types.Transaction, o *handlerOptions) (
+	http.ResponseWriter,
+	func(types.Transaction, *http.Request) error,
 ) { // nolint:gocyclo
 
-	i := &rwInterceptor{w: w, tx: tx, proto: r.Proto, statusCode: 200}
+	i := &rwInterceptor{w: w, r: r, tx: tx, opts: o, proto: r.Proto, statusCode: 200}
 
 	responseProcessor := func(tx types.Transaction, r *http.Request) error {
 		// If the connection has been hijacked (e.g. WebSocket upgrade),
@@ -251,11 +271,7 @@ func wrap(w http.ResponseWriter, r *http.Request, tx types.Transaction) (
 				i.flushWriteHeader()
 				return err
 			} else if it != nil {
-				// if there is an interruption we must clean the headers and override the status code
-				i.cleanHeaders()
-				i.Header().Set("Content-Length", "0")
-				i.overrideWriteHeader(obtainStatusCodeFromInterruptionOrDefault(it, i.statusCode))
-				i.flushWriteHeader()
+				i.interrupt(it)
 				return nil
 			}
 			return i.writeBufferedResponseBodyToDownstream()
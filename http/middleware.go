@@ -24,6 +24,12 @@ import (
 // Note: This function will stop after an interruption
 // Note: Do not manually fill any request variables
 func processRequest(tx types.Transaction, req *http.Request) (*types.Interruption, error) {
+	return processRequestWithOptions(tx, req, defaultHandlerOptions)
+}
+
+// processRequestWithOptions is like processRequest, resolving the client
+// address as configured by o.
+func processRequestWithOptions(tx types.Transaction, req *http.Request, o *handlerOptions) (*types.Interruption, error) {
 	var (
 		client string
 		cport  int
@@ -34,10 +40,22 @@ func processRequest(tx types.Transaction, req *http.Request) (*types.Interruptio
 		client = req.RemoteAddr[:idx]
 		cport, _ = strconv.Atoi(req.RemoteAddr[idx+1:])
 	}
+	peer := strings.TrimSuffix(strings.TrimPrefix(client, "["), "]")
 
 	var in *types.Interruption
 	// There is no socket access in the request object, so we neither know the server client nor port.
-	tx.ProcessConnection(client, cport, "", 0)
+	if o.trustedProxies != nil {
+		if ip, port, ok := o.trustedProxies.clientIP(peer, req.Header); ok {
+			tx.ProcessConnection(ip, port, "", 0)
+			if pt, ok := tx.(experimental.TransactionWithPeerAddress); ok {
+				pt.SetPeerAddress(peer)
+			}
+		} else {
+			tx.ProcessConnection(client, cport, "", 0)
+		}
+	} else {
+		tx.ProcessConnection(client, cport, "", 0)
+	}
 	tx.ProcessURI(req.URL.String(), req.Method, req.Proto)
 	for k, vr := range req.Header {
 		for _, v := range vr {
@@ -97,7 +115,25 @@ func processRequest(tx types.Transaction, req *http.Request) (*types.Interruptio
 	return tx.ProcessRequestBody()
 }
 
+// WrapHandler wraps h with a handler that processes every request and
+// response with the WAF. Interrupted transactions get an empty response
+// with the status code of the deny action, use WrapHandlerWithOptions to
+// configure the responses.
 func WrapHandler(waf coraza.WAF, h http.Handler) http.Handler {
+	return wrapHandler(waf, h, defaultHandlerOptions)
+}
+
+// WrapHandlerWithOptions is like WrapHandler with the responses to the
+// interruptions configured by opts.
+func WrapHandlerWithOptions(waf coraza.WAF, h http.Handler, opts ...Option) http.Handler {
+	o := &handlerOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return wrapHandler(waf, h, o)
+}
+
+func wrapHandler(waf coraza.WAF, h http.Handler, o *handlerOptions) http.Handler {
 	if waf == nil {
 		return h
 	}
@@ -136,15 +172,15 @@ func WrapHandler(waf coraza.WAF, h http.Handler) http.Handler {
 		// ProcessRequest is just a wrapper around ProcessConnection, ProcessURI,
 		// ProcessRequestHeaders and ProcessRequestBody.
 		// It fails if any of these functions returns an error and it stops on interruption.
-		if it, err := processRequest(tx, r); err != nil {
+		if it, err := processRequestWithOptions(tx, r, o); err != nil {
 			tx.DebugLogger().Error().Err(err).Msg("Failed to process request")
 			return
 		} else if it != nil {
-			w.WriteHeader(obtainStatusCodeFromInterruptionOrDefault(it, http.StatusOK))
+			o.writeInterruption(w, r, tx, it, http.StatusOK)
 			return
 		}
 
-		ww, processResponse := wrap(w, r, tx)
+		ww, processResponse := wrapWithOptions(w, r, tx, o)
 
 		// We continue with the other middlewares by catching the response
 		h.ServeHTTP(ww, r)
@@ -0,0 +1,239 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// tinygo does not support net.http so this package is not needed for it
+//go:build !tinygo
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// Option configures the handler returned by WrapHandlerWithOptions.
+type Option func(*handlerOptions)
+
+// BlockPageData is the data block page templates are executed with.
+type BlockPageData struct {
+	// TransactionID is the ID of the interrupted transaction, it can be
+	// used to look up the audit log of the request.
+	TransactionID string `json:"transaction_id"`
+	// RuleID is the ID of the rule that interrupted the transaction.
+	RuleID int `json:"rule_id"`
+	// Status is the status code of the response.
+	Status int `json:"status"`
+	// Action is the disruptive action of the rule, e.g. deny.
+	Action string `json:"action"`
+}
+
+// DefaultBlockPage is the page used by WithContentNegotiation when no block
+// page is set with WithBlockPage.
+var DefaultBlockPage = template.Must(template.New("block").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Request blocked</title></head>
+<body>
+<h1>Request blocked</h1>
+<p>The request was blocked by the web application firewall.</p>
+<p>Transaction ID: {{.TransactionID}}<br>Rule ID: {{.RuleID}}</p>
+</body>
+</html>
+`))
+
+// WithBlockPage sets the template of the body written when a transaction is
+// interrupted with a client or server error status. The template is
+// executed with a BlockPageData.
+func WithBlockPage(tmpl *template.Template) Option {
+	return func(o *handlerOptions) {
+		o.blockPage = tmpl
+	}
+}
+
+// WithContentNegotiation makes blocked requests accepting application/json,
+// or any +json media type, over text/html get a JSON body holding the
+// BlockPageData fields. Any other request gets the page set with
+// WithBlockPage, or DefaultBlockPage.
+func WithContentNegotiation() Option {
+	return func(o *handlerOptions) {
+		o.contentNegotiation = true
+	}
+}
+
+// WithRedirects makes the redirect action respond with its status code, 302
+// by default, and the Location header set to the URL of the action.
+func WithRedirects() Option {
+	return func(o *handlerOptions) {
+		o.redirects = true
+	}
+}
+
+// WithDropConnections makes the drop action hijack and close the connection
+// without writing a response. When the connection can't be hijacked, as
+// with HTTP/2, the request is denied instead.
+func WithDropConnections() Option {
+	return func(o *handlerOptions) {
+		o.dropConnections = true
+	}
+}
+
+// WithOnInterruption sets a function called with every interruption right
+// before the response for it is written, for example to log or count the
+// blocked requests.
+func WithOnInterruption(fn func(r *http.Request, tx types.Transaction, it *types.Interruption)) Option {
+	return func(o *handlerOptions) {
+		o.onInterruption = fn
+	}
+}
+
+// WithTrustedProxies resolves the client IP of the requests coming from
+// the proxies in prefixes from the header selected by policy. REMOTE_ADDR
+// holds the resolved client IP and PEER_ADDR the address of the proxy. The
+// headers of requests coming from any other peer are ignored.
+func WithTrustedProxies(prefixes []netip.Prefix, policy ClientIPPolicy) Option {
+	return func(o *handlerOptions) {
+		o.trustedProxies = &trustedProxies{prefixes: prefixes, policy: policy}
+	}
+}
+
+type handlerOptions struct {
+	blockPage          *template.Template
+	contentNegotiation bool
+	redirects          bool
+	dropConnections    bool
+	onInterruption     func(r *http.Request, tx types.Transaction, it *types.Interruption)
+	trustedProxies     *trustedProxies
+}
+
+// defaultHandlerOptions keep the responses of WrapHandler, a status code
+// without body.
+var defaultHandlerOptions = &handlerOptions{}
+
+// writeInterruption writes the response for the interruption into w and
+// returns the status code sent, 0 if the connection was dropped.
+// defaultStatusCode is used when the action does not imply a status code.
+func (o *handlerOptions) writeInterruption(w http.ResponseWriter, r *http.Request, tx types.Transaction, it *types.Interruption, defaultStatusCode int) int {
+	if o.onInterruption != nil {
+		o.onInterruption(r, tx, it)
+	}
+
+	statusCode := obtainStatusCodeFromInterruptionOrDefault(it, defaultStatusCode)
+	switch it.Action {
+	case "drop":
+		if o.dropConnections {
+			if dropConnection(w) {
+				return 0
+			}
+			tx.DebugLogger().Debug().Msg("Connection can't be hijacked, denying the request instead of dropping it")
+			statusCode = it.Status
+			if statusCode == 0 {
+				statusCode = http.StatusForbidden
+			}
+		}
+	case "redirect":
+		if o.redirects && it.Data != "" {
+			statusCode = it.Status
+			if statusCode == 0 {
+				statusCode = http.StatusFound
+			}
+			w.Header().Set("Location", it.Data)
+			w.Header().Set("Content-Length", "0")
+			w.WriteHeader(statusCode)
+			return statusCode
+		}
+	}
+
+	if statusCode < http.StatusBadRequest || (o.blockPage == nil && !o.contentNegotiation) {
+		w.WriteHeader(statusCode)
+		return statusCode
+	}
+
+	data := BlockPageData{
+		TransactionID: tx.ID(),
+		RuleID:        it.RuleID,
+		Status:        statusCode,
+		Action:        it.Action,
+	}
+	var (
+		body        bytes.Buffer
+		contentType string
+		err         error
+	)
+	if o.contentNegotiation && acceptsJSON(r) {
+		contentType = "application/json"
+		err = json.NewEncoder(&body).Encode(data)
+	} else {
+		contentType = "text/html; charset=utf-8"
+		tmpl := o.blockPage
+		if tmpl == nil {
+			tmpl = DefaultBlockPage
+		}
+		err = tmpl.Execute(&body, data)
+	}
+	if err != nil {
+		tx.DebugLogger().Error().Err(err).Msg("Failed to render the block page")
+		w.WriteHeader(statusCode)
+		return statusCode
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	if r.Method != http.MethodHead {
+		if _, err := w.Write(body.Bytes()); err != nil {
+			tx.DebugLogger().Error().Err(err).Msg("Failed to write the block page")
+		}
+	}
+	return statusCode
+}
+
+// dropConnection closes the connection of w, it returns false when the
+// connection can't be hijacked.
+func dropConnection(w http.ResponseWriter) bool {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return false
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// acceptsJSON returns true when the Accept header of the request prefers a
+// JSON media type over text/html. Wildcards are not taken into account, so
+// browsers keep getting HTML.
+func acceptsJSON(r *http.Request) bool {
+	var jsonQ, htmlQ float64
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, params, _ := strings.Cut(mediaRange, ";")
+			mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+			q := 1.0
+			for _, p := range strings.Split(params, ";") {
+				k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+				if ok && strings.EqualFold(k, "q") {
+					if f, err := strconv.ParseFloat(v, 64); err == nil {
+						q = f
+					}
+				}
+			}
+			switch {
+			case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+				jsonQ = max(jsonQ, q)
+			case mediaType == "text/html":
+				htmlQ = max(htmlQ, q)
+			}
+		}
+	}
+	return jsonQ > 0 && jsonQ > htmlQ
+}
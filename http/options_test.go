@@ -0,0 +1,253 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// tinygo does not support net.http so this package is not needed for it
+//go:build !tinygo
+
+package http
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+const optionsTestDirectives = `
+SecRuleEngine On
+SecResponseBodyAccess On
+SecResponseBodyMimeType text/plain
+SecRule ARGS:deny "@eq 1" "id:1,phase:1,deny,status:401"
+SecRule ARGS:redirect "@eq 1" "id:2,phase:1,redirect:https://www.example.com/blocked"
+SecRule ARGS:redirect "@eq 2" "id:3,phase:1,status:307,redirect:https://www.example.com/blocked"
+SecRule ARGS:drop "@eq 1" "id:4,phase:1,drop"
+SecRule ARGS:response "@eq 1" "id:5,phase:1,pass,nolog,setvar:tx.block_response=1"
+SecRule TX:block_response "@eq 1" "id:6,phase:3,deny,status:403"
+SecRule ARGS:body "@eq 1" "id:7,phase:1,pass,nolog,setvar:tx.block_body=1"
+SecRule TX:block_body "@eq 1" "chain,id:8,phase:4,deny,status:403"
+	SecRule RESPONSE_BODY "@contains secret" ""
+SecRule ARGS:dropbody "@eq 1" "id:9,phase:1,pass,nolog,setvar:tx.drop_body=1"
+SecRule TX:drop_body "@eq 1" "chain,id:10,phase:4,drop"
+	SecRule RESPONSE_BODY "@contains secret" ""
+`
+
+func newOptionsTestHandler(t *testing.T, opts ...Option) http.Handler {
+	t.Helper()
+	waf, err := coraza.NewWAF(coraza.NewWAFConfig().WithDirectives(optionsTestDirectives))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return WrapHandlerWithOptions(waf, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Upstream", "1")
+		_, _ = w.Write([]byte("a secret response"))
+	}), opts...)
+}
+
+func TestWrapHandlerWithOptionsBlockPage(t *testing.T) {
+	tmpl := template.Must(template.New("page").Parse(`blocked {{.TransactionID}} by {{.RuleID}} with {{.Status}} <{{.Action}}>`))
+	var interrupted []int
+	h := newOptionsTestHandler(t,
+		WithBlockPage(tmpl),
+		WithContentNegotiation(),
+		WithOnInterruption(func(r *http.Request, tx types.Transaction, it *types.Interruption) {
+			if r == nil || tx.ID() == "" {
+				t.Error("expected the request and the transaction")
+			}
+			interrupted = append(interrupted, it.RuleID)
+		}),
+	)
+
+	tests := map[string]struct {
+		target      string
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		"request phase html": {
+			target:      "/?deny=1",
+			accept:      "text/html,application/xhtml+xml,*/*;q=0.8",
+			status:      401,
+			contentType: "text/html; charset=utf-8",
+			body:        "by 1 with 401 &lt;deny>",
+		},
+		"request phase json": {
+			target:      "/?deny=1",
+			accept:      "application/json",
+			status:      401,
+			contentType: "application/json",
+			body:        `"rule_id":1,"status":401,"action":"deny"`,
+		},
+		"response headers phase": {
+			target:      "/?response=1",
+			accept:      "application/problem+json, text/html;q=0.5",
+			status:      403,
+			contentType: "application/json",
+			body:        `"rule_id":6,"status":403,"action":"deny"`,
+		},
+		"response body phase": {
+			target:      "/?body=1",
+			status:      403,
+			contentType: "text/html; charset=utf-8",
+			body:        "by 8 with 403",
+		},
+		"not interrupted": {
+			target:      "/",
+			status:      200,
+			contentType: "text/plain",
+			body:        "a secret response",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.target, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, req)
+
+			if want, have := tc.status, res.Code; want != have {
+				t.Errorf("unexpected status code, want %d, have %d", want, have)
+			}
+			if want, have := tc.contentType, res.Header().Get("Content-Type"); want != have {
+				t.Errorf("unexpected content type, want %q, have %q", want, have)
+			}
+			if !strings.Contains(res.Body.String(), tc.body) {
+				t.Errorf("expected %q in the body %q", tc.body, res.Body.String())
+			}
+			if tc.status != 200 {
+				if strings.Contains(res.Body.String(), "secret") || res.Header().Get("X-Upstream") != "" {
+					t.Errorf("unexpected upstream response leak: %v %q", res.Header(), res.Body.String())
+				}
+			}
+		})
+	}
+	slices.Sort(interrupted)
+	if want, have := []int{1, 1, 6, 8}, interrupted; !slices.Equal(want, have) {
+		t.Errorf("unexpected interruptions, want %v, have %v", want, have)
+	}
+}
+
+func TestWrapHandlerWithOptionsDefaultBlockPage(t *testing.T) {
+	h := newOptionsTestHandler(t, WithContentNegotiation())
+
+	req := httptest.NewRequest("GET", "/?deny=1", nil)
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	if !strings.Contains(res.Body.String(), "Rule ID: 1") {
+		t.Errorf("expected the default block page, got %q", res.Body.String())
+	}
+
+	// HEAD requests get the headers of the page only
+	req = httptest.NewRequest("HEAD", "/?deny=1", nil)
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	if res.Code != 401 || res.Body.Len() != 0 || res.Header().Get("Content-Length") == "0" {
+		t.Errorf("unexpected response %d %v %q", res.Code, res.Header(), res.Body.String())
+	}
+}
+
+func TestWrapHandlerWithOptionsRedirect(t *testing.T) {
+	tests := map[string]struct {
+		opts     []Option
+		target   string
+		status   int
+		location string
+	}{
+		"default status": {
+			opts:     []Option{WithRedirects()},
+			target:   "/?redirect=1",
+			status:   302,
+			location: "https://www.example.com/blocked",
+		},
+		"rule status": {
+			opts:     []Option{WithRedirects()},
+			target:   "/?redirect=2",
+			status:   307,
+			location: "https://www.example.com/blocked",
+		},
+		"without option": {
+			target: "/?redirect=1",
+			status: 200,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			newOptionsTestHandler(t, tc.opts...).ServeHTTP(res, httptest.NewRequest("GET", tc.target, nil))
+			if want, have := tc.status, res.Code; want != have {
+				t.Errorf("unexpected status code, want %d, have %d", want, have)
+			}
+			if want, have := tc.location, res.Header().Get("Location"); want != have {
+				t.Errorf("unexpected location, want %q, have %q", want, have)
+			}
+			if res.Body.Len() != 0 {
+				t.Errorf("unexpected body %q", res.Body.String())
+			}
+		})
+	}
+}
+
+func TestWrapHandlerWithOptionsDrop(t *testing.T) {
+	srv := httptest.NewServer(newOptionsTestHandler(t, WithDropConnections()))
+	defer srv.Close()
+
+	for _, target := range []string{"/?drop=1", "/?dropbody=1"} {
+		t.Run(target, func(t *testing.T) {
+			res, err := srv.Client().Get(srv.URL + target)
+			if err == nil {
+				body, _ := io.ReadAll(res.Body)
+				res.Body.Close()
+				t.Fatalf("expected the connection to be dropped, got %d %q", res.StatusCode, body)
+			}
+		})
+	}
+
+	res, err := srv.Client().Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	// response writers that can't be hijacked deny the request
+	rec := httptest.NewRecorder()
+	newOptionsTestHandler(t, WithDropConnections()).ServeHTTP(rec, httptest.NewRequest("GET", "/?drop=1", nil))
+	if rec.Code != 403 {
+		t.Errorf("unexpected status code %d", rec.Code)
+	}
+}
+
+func TestAcceptsJSON(t *testing.T) {
+	tests := map[string]bool{
+		"":                                    false,
+		"*/*":                                 false,
+		"application/json":                    true,
+		"APPLICATION/JSON; charset=utf-8":     true,
+		"application/vnd.api+json":            true,
+		"text/html, application/json":         false,
+		"text/html;q=0.9, application/json":   true,
+		"text/html, application/json;q=1.5":   true,
+		"application/json;q=0, text/plain":    false,
+		"text/html;q=0.5, application/json;q": true,
+	}
+	for accept, want := range tests {
+		t.Run(accept, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept", accept)
+			if have := acceptsJSON(req); want != have {
+				t.Errorf("unexpected result, want %t, have %t", want, have)
+			}
+		})
+	}
+}
@@ -117,7 +117,13 @@ func init() {
 	Register("phase", phase)
 	Register("redirect", redirect)
 	Register("rev", rev)
+	Register("sanitiseArg", sanitiseArg)
+	Register("sanitiseMatched", sanitiseMatched)
+	Register("sanitiseRequestHeader", sanitiseRequestHeader)
+	Register("sanitiseResponseHeader", sanitiseResponseHeader)
 	Register("setenv", setenv)
+	Register("setsid", setsid)
+	Register("setuid", setuid)
 	Register("setvar", setvar)
 	Register("severity", severity)
 	Register("skip", skip)
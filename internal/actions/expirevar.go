@@ -4,13 +4,21 @@
 package actions
 
 import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/macro"
 	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/types/variables"
 )
 
 // Action Group: Non-disruptive
 //
 // Description:
 // Configures a collection variable to expire after the given time period (in seconds).
+// Only variables of persistent collections (IP, SESSION, USER, GLOBAL and RESOURCE) can expire.
 // You should use the `expirevar` with `setvar` action to keep the intended expiration time.
 // The expire time will be reset if they are used on their own (perhaps in a SecAction directive).
 //
@@ -23,15 +31,71 @@ import (
 //		setvar:session.suspicious=1,expirevar:session.suspicious=3600,phase:1"
 //
 // ```
-type expirevarFn struct{}
+type expirevarFn struct {
+	collection variables.RuleVariable
+	key        macro.Macro
+	ttl        macro.Macro
+}
+
+// expirableCollection is implemented by the persistent collections.
+type expirableCollection interface {
+	SetTTL(key string, ttl time.Duration)
+}
 
 func (a *expirevarFn) Init(_ plugintypes.RuleMetadata, data string) error {
+	if len(data) == 0 {
+		return ErrMissingArguments
+	}
+
+	name, ttl, ok := strings.Cut(data, "=")
+	if !ok {
+		return ErrInvalidKVArguments
+	}
+	colName, key, ok := strings.Cut(name, ".")
+	if !ok || strings.TrimSpace(key) == "" {
+		return errors.New("invalid arguments, expected syntax {collection}.{key}={seconds}")
+	}
+
+	v, err := parsePersistentCollection(colName)
+	if err != nil {
+		return err
+	}
+	keyMacro, err := macro.NewMacro(key)
+	if err != nil {
+		return err
+	}
+	ttlMacro, err := macro.NewMacro(ttl)
+	if err != nil {
+		return err
+	}
+
+	a.collection = v
+	a.key = keyMacro
+	a.ttl = ttlMacro
 	return nil
 }
 
 func (a *expirevarFn) Evaluate(r plugintypes.RuleMetadata, tx plugintypes.TransactionState) {
-	// Not supported
-	tx.DebugLogger().Warn().Int("rule_id", r.ID()).Msg("Expirevar was used but it's not supported")
+	key := strings.ToLower(a.key.Expand(tx))
+	ttl, err := strconv.Atoi(a.ttl.Expand(tx))
+	if err != nil || ttl < 0 {
+		tx.DebugLogger().Error().
+			Int("rule_id", r.ID()).
+			Str("var_key", key).
+			Err(err).
+			Msg("Invalid expiration time")
+		return
+	}
+
+	col, ok := tx.Collection(a.collection).(expirableCollection)
+	if !ok {
+		tx.DebugLogger().Error().
+			Int("rule_id", r.ID()).
+			Str("collection", a.collection.Name()).
+			Msg("Collection in expirevar is not persistent")
+		return
+	}
+	col.SetTTL(key, time.Duration(ttl)*time.Second)
 }
 
 func (a *expirevarFn) Type() plugintypes.ActionType {
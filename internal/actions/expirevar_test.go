@@ -0,0 +1,62 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+func TestExpirevarInit(t *testing.T) {
+	tests := []struct {
+		data    string
+		wantErr bool
+	}{
+		{data: "", wantErr: true},
+		{data: "ip.foo", wantErr: true},
+		{data: "ip=60", wantErr: true},
+		{data: "tx.foo=60", wantErr: true},
+		{data: "ip.foo=60"},
+		{data: "session.%{tx.name}=%{tx.ttl}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			a := expirevar()
+			err := a.Init(&md{}, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestExpirevarEvaluate(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	if err := tx.InitPersistentCollection(variables.IP, "1.2.3.4"); err != nil {
+		t.Fatal(err)
+	}
+
+	ip := tx.Collection(variables.IP).(collection.Map)
+	ip.Set("blocked", []string{"1"})
+	ip.Set("kept", []string{"1"})
+
+	a := expirevar()
+	if err := a.Init(&md{}, "ip.blocked=0"); err != nil {
+		t.Fatal(err)
+	}
+	a.Evaluate(&md{}, tx)
+
+	if v := ip.Get("blocked"); v != nil {
+		t.Errorf("expected variable to be expired, have %v", v)
+	}
+	if v := ip.Get("kept"); len(v) != 1 {
+		t.Error("expected other variables to be kept")
+	}
+}
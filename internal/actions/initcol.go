@@ -4,9 +4,13 @@
 package actions
 
 import (
+	"fmt"
 	"strings"
 
+	"github.com/corazawaf/coraza/v3/experimental/plugins/macro"
 	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/types/variables"
 )
 
 // Action Group: Non-disruptive
@@ -14,7 +18,10 @@ import (
 // Description:
 // Initializes a named persistent collection, either by loading data from storage or by creating a new collection in memory.
 // Collections are loaded into memory on-demand, when the initcol action is executed.
-// A collection will be persisted only if a change was made to it in the course of transaction processing.
+// The supported collections are IP, SESSION, USER, GLOBAL and RESOURCE. Changes made with `setvar`
+// are written to the storage right away, and the `UPDATE_COUNTER`, `LAST_UPDATE_TIME` and `UPDATE_RATE`
+// built-in variables are refreshed at the end of the transaction only if the collection was changed.
+// Records are removed once they are not updated for the time configured with `SecCollectionTimeout`.
 // See the `Persistent Storage` section for further details.
 //
 // Example:
@@ -23,9 +30,8 @@ import (
 // SecAction "phase:1,id:116,nolog,pass,initcol:ip=%{REMOTE_ADDR}"
 // ```
 type initcolFn struct {
-	collection string
-	variable   byte
-	key        string
+	collection variables.RuleVariable
+	key        macro.Macro
 }
 
 func (a *initcolFn) Init(_ plugintypes.RuleMetadata, data string) error {
@@ -34,40 +40,62 @@ func (a *initcolFn) Init(_ plugintypes.RuleMetadata, data string) error {
 		return ErrInvalidKVArguments
 	}
 
-	a.collection = col
-	a.key = key
-	a.variable = 0x0
+	v, err := parsePersistentCollection(col)
+	if err != nil {
+		return err
+	}
+
+	m, err := macro.NewMacro(key)
+	if err != nil {
+		return err
+	}
+
+	a.collection = v
+	a.key = m
 	return nil
 }
 
-func (a *initcolFn) Evaluate(_ plugintypes.RuleMetadata, _ plugintypes.TransactionState) {
-	// tx.DebugLogger().Error().Msg("initcol was used but it's not supported", zap.Int("rule", r.Id))
-	/*
-		key := tx.MacroExpansion(a.key)
-		data := tx.WAF.Persistence.Get(a.variable, key)
-		if data == nil {
-			ts := time.Now().UnixNano()
-			tss := strconv.FormatInt(ts, 10)
-			tsstimeout := strconv.FormatInt(ts+(int64(tx.WAF.CollectionTimeout)*1000), 10)
-			data = map[string][]string{
-				"CREATE_TIME":      {tss},
-				"IS_NEW":           {"1"},
-				"KEY":              {key},
-				"LAST_UPDATE_TIME": {tss},
-				"TIMEOUT":          {tsstimeout},
-				"UPDATE_COUNTER":   {"0"},
-				"UPDATE_RATE":      {"0"},
-			}
-		}
-		tx.GetCollection(a.variable).SetData(data)
-		tx.PersistentCollections[a.variable] = key
-	*/
+func (a *initcolFn) Evaluate(r plugintypes.RuleMetadata, txS plugintypes.TransactionState) {
+	initPersistentCollection(r, txS, a.collection, a.key.Expand(txS))
 }
 
 func (a *initcolFn) Type() plugintypes.ActionType {
 	return plugintypes.ActionTypeNondisruptive
 }
 
+// parsePersistentCollection returns the variable of a persistent collection
+// name, or an error if the name is not one of IP, SESSION, USER, GLOBAL or RESOURCE.
+func parsePersistentCollection(name string) (variables.RuleVariable, error) {
+	v, err := variables.Parse(strings.TrimSpace(name))
+	if err != nil {
+		return variables.Unknown, err
+	}
+	switch v {
+	case variables.IP, variables.Session, variables.User, variables.Global, variables.Resource:
+		return v, nil
+	}
+	return variables.Unknown, fmt.Errorf("invalid persistent collection %q", name)
+}
+
+func initPersistentCollection(r plugintypes.RuleMetadata, txS plugintypes.TransactionState, v variables.RuleVariable, key string) {
+	if key == "" {
+		txS.DebugLogger().Warn().
+			Int("rule_id", r.ID()).
+			Str("collection", v.Name()).
+			Msg("Empty key, persistent collection not initialized")
+		return
+	}
+
+	tx := txS.(*corazawaf.Transaction)
+	if err := tx.InitPersistentCollection(v, key); err != nil {
+		tx.DebugLogger().Error().
+			Int("rule_id", r.ID()).
+			Str("collection", v.Name()).
+			Err(err).
+			Msg("Failed to initialize persistent collection")
+	}
+}
+
 func initcol() plugintypes.Action {
 	return &initcolFn{}
 }
@@ -3,7 +3,12 @@
 
 package actions
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
 
 func TestInitcolInit(t *testing.T) {
 	t.Run("invalid argument", func(t *testing.T) {
@@ -16,9 +21,61 @@ func TestInitcolInit(t *testing.T) {
 
 	t.Run("passing argument", func(t *testing.T) {
 		initcol := initcol()
-		err := initcol.Init(nil, "foo=bar")
+		err := initcol.Init(nil, "ip=%{REMOTE_ADDR}")
 		if err != nil {
 			t.Errorf("unexpected error: %s", err.Error())
 		}
 	})
+
+	t.Run("non persistent collection", func(t *testing.T) {
+		initcol := initcol()
+		if err := initcol.Init(nil, "tx=bar"); err == nil {
+			t.Errorf("expected error")
+		}
+	})
+}
+
+func TestInitcolEvaluate(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	a := initcol()
+	if err := a.Init(&md{}, "ip=%{REMOTE_ADDR}"); err != nil {
+		t.Fatal(err)
+	}
+
+	newTx := func() *corazawaf.Transaction {
+		tx := waf.NewTransaction()
+		tx.ProcessConnection("10.0.0.1", 1234, "", 0)
+		a.Evaluate(&md{}, tx)
+		return tx
+	}
+
+	tx := newTx()
+	col := tx.Collection(variables.IP).(interface{ Get(string) []string })
+	if v := col.Get("is_new"); len(v) != 1 || v[0] != "1" {
+		t.Errorf("expected new record, got %v", v)
+	}
+	if v := col.Get("KEY"); len(v) != 1 || v[0] != "10.0.0.1" {
+		t.Errorf("unexpected key %v", v)
+	}
+	sv := setvar()
+	if err := sv.Init(&md{}, "ip.hits=+1"); err != nil {
+		t.Fatal(err)
+	}
+	sv.Evaluate(&md{}, tx)
+	if err := tx.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx = newTx()
+	defer tx.Close()
+	col = tx.Collection(variables.IP).(interface{ Get(string) []string })
+	if v := col.Get("is_new"); len(v) != 1 || v[0] != "0" {
+		t.Errorf("expected existing record, got %v", v)
+	}
+	if v := col.Get("hits"); len(v) != 1 || v[0] != "1" {
+		t.Errorf("expected persisted variable, got %v", v)
+	}
+	if v := col.Get("update_counter"); len(v) != 1 || v[0] != "1" {
+		t.Errorf("expected update counter to be 1, got %v", v)
+	}
 }
@@ -0,0 +1,97 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/internal/collections"
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+func TestSanitiseInit(t *testing.T) {
+	for name, action := range map[string]ruleActionWrapper{
+		"sanitiseArg":            sanitiseArg,
+		"sanitiseRequestHeader":  sanitiseRequestHeader,
+		"sanitiseResponseHeader": sanitiseResponseHeader,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := action().Init(&md{}, ""); err != ErrMissingArguments {
+				t.Errorf("expected ErrMissingArguments, got %v", err)
+			}
+			if err := action().Init(&md{}, "name"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	t.Run("sanitiseMatched", func(t *testing.T) {
+		if err := sanitiseMatched().Init(&md{}, ""); err != nil {
+			t.Error(err)
+		}
+		if err := sanitiseMatched().Init(&md{}, "abc"); err != ErrUnexpectedArguments {
+			t.Errorf("expected ErrUnexpectedArguments, got %v", err)
+		}
+	})
+}
+
+func TestSanitiseEvaluate(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  ruleActionWrapper
+		data    string
+		matched string
+	}{
+		{name: "sanitiseArg", action: sanitiseArg, data: "Password"},
+		{name: "sanitiseRequestHeader", action: sanitiseRequestHeader, data: "x-secret"},
+		{name: "sanitiseMatched arg", action: sanitiseMatched, matched: "ARGS_GET:password"},
+		{name: "sanitiseMatched header", action: sanitiseMatched, matched: "REQUEST_HEADERS:x-secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := tt.action()
+			if err := a.Init(&md{}, tt.data); err != nil {
+				t.Fatal(err)
+			}
+
+			waf := corazawaf.NewWAF()
+			tx := waf.NewTransaction()
+			defer tx.Close()
+			tx.AddGetRequestArgument("password", "s3cr3t")
+			tx.AddRequestHeader("X-Secret", "s3cr3t")
+			if tt.matched != "" {
+				tx.Collection(variables.MatchedVarName).(*collections.Single).Set(tt.matched)
+				tx.Collection(variables.MatchedVar).(*collections.Single).Set("s3cr3t")
+			}
+			a.Evaluate(&md{}, tx)
+
+			al := tx.AuditLog()
+			if v := al.Transaction().Request().Args().Get("password"); len(v) != 1 || v[0] != "******" {
+				t.Errorf("unexpected password argument %q", v)
+			}
+			if v := al.Transaction().Request().Headers()["x-secret"]; len(v) != 1 || v[0] != "******" {
+				t.Errorf("unexpected x-secret header %q", v)
+			}
+		})
+	}
+}
+
+func TestSanitiseResponseHeaderEvaluate(t *testing.T) {
+	a := sanitiseResponseHeader()
+	if err := a.Init(&md{}, "Set-Cookie"); err != nil {
+		t.Fatal(err)
+	}
+
+	waf := corazawaf.NewWAF()
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.AddResponseHeader("Set-Cookie", "session=abc")
+	a.Evaluate(&md{}, tx)
+
+	if v := tx.AuditLog().Transaction().Response().Headers()["set-cookie"]; len(v) != 1 || v[0] != "***********" {
+		t.Errorf("unexpected set-cookie header %q", v)
+	}
+}
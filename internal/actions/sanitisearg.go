@@ -0,0 +1,50 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+)
+
+// Action Group: Non-disruptive
+//
+// Description:
+// Prevents sensitive request parameter data from being logged to the audit log.
+// Each byte of the value of the named parameter is replaced with an asterisk, in the
+// arguments, the query string and the request body of the log.
+//
+// Example:
+// ```
+// # Never log passwords
+// SecAction "nolog,phase:2,id:131,sanitiseArg:password,sanitiseArg:newPassword,sanitiseArg:oldPassword"
+// ```
+type sanitiseArgFn struct {
+	name string
+}
+
+func (a *sanitiseArgFn) Init(_ plugintypes.RuleMetadata, data string) error {
+	if len(data) == 0 {
+		return ErrMissingArguments
+	}
+	a.name = data
+	return nil
+}
+
+func (a *sanitiseArgFn) Evaluate(_ plugintypes.RuleMetadata, tx plugintypes.TransactionState) {
+	tx.(*corazawaf.Transaction).SanitizeArg(a.name)
+}
+
+func (a *sanitiseArgFn) Type() plugintypes.ActionType {
+	return plugintypes.ActionTypeNondisruptive
+}
+
+func sanitiseArg() plugintypes.Action {
+	return &sanitiseArgFn{}
+}
+
+var (
+	_ plugintypes.Action = &sanitiseArgFn{}
+	_ ruleActionWrapper  = sanitiseArg
+)
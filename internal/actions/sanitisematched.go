@@ -0,0 +1,47 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+)
+
+// Action Group: Non-disruptive
+//
+// Description:
+// Prevents the matched variable from being logged to the audit log. Arguments and
+// request or response headers are masked by name, and the matched value is masked
+// wherever it shows up in the log, e.g. in the bodies or the messages of the rules.
+//
+// Example:
+// ```
+// # Never log the arguments that look like card numbers
+// SecRule ARGS "@rx ^(?:\d[ -]?){13,16}$" "phase:2,id:134,nolog,pass,sanitiseMatched"
+// ```
+type sanitiseMatchedFn struct{}
+
+func (a *sanitiseMatchedFn) Init(_ plugintypes.RuleMetadata, data string) error {
+	if len(data) > 0 {
+		return ErrUnexpectedArguments
+	}
+	return nil
+}
+
+func (a *sanitiseMatchedFn) Evaluate(_ plugintypes.RuleMetadata, tx plugintypes.TransactionState) {
+	tx.(*corazawaf.Transaction).SanitizeMatched()
+}
+
+func (a *sanitiseMatchedFn) Type() plugintypes.ActionType {
+	return plugintypes.ActionTypeNondisruptive
+}
+
+func sanitiseMatched() plugintypes.Action {
+	return &sanitiseMatchedFn{}
+}
+
+var (
+	_ plugintypes.Action = &sanitiseMatchedFn{}
+	_ ruleActionWrapper  = sanitiseMatched
+)
@@ -0,0 +1,49 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+)
+
+// Action Group: Non-disruptive
+//
+// Description:
+// Prevents a sensitive request header from being logged to the audit log.
+// Each byte of the value of the named request header is replaced with an asterisk.
+//
+// Example:
+// ```
+// # Never log the credentials sent by the clients
+// SecAction "nolog,phase:1,id:132,sanitiseRequestHeader:Authorization"
+// ```
+type sanitiseRequestHeaderFn struct {
+	name string
+}
+
+func (a *sanitiseRequestHeaderFn) Init(_ plugintypes.RuleMetadata, data string) error {
+	if len(data) == 0 {
+		return ErrMissingArguments
+	}
+	a.name = data
+	return nil
+}
+
+func (a *sanitiseRequestHeaderFn) Evaluate(_ plugintypes.RuleMetadata, tx plugintypes.TransactionState) {
+	tx.(*corazawaf.Transaction).SanitizeRequestHeader(a.name)
+}
+
+func (a *sanitiseRequestHeaderFn) Type() plugintypes.ActionType {
+	return plugintypes.ActionTypeNondisruptive
+}
+
+func sanitiseRequestHeader() plugintypes.Action {
+	return &sanitiseRequestHeaderFn{}
+}
+
+var (
+	_ plugintypes.Action = &sanitiseRequestHeaderFn{}
+	_ ruleActionWrapper  = sanitiseRequestHeader
+)
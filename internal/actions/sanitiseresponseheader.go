@@ -0,0 +1,49 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+)
+
+// Action Group: Non-disruptive
+//
+// Description:
+// Prevents a sensitive response header from being logged to the audit log.
+// Each byte of the value of the named response header is replaced with an asterisk.
+//
+// Example:
+// ```
+// # Never log the session cookies set by the application
+// SecAction "nolog,phase:3,id:133,sanitiseResponseHeader:Set-Cookie"
+// ```
+type sanitiseResponseHeaderFn struct {
+	name string
+}
+
+func (a *sanitiseResponseHeaderFn) Init(_ plugintypes.RuleMetadata, data string) error {
+	if len(data) == 0 {
+		return ErrMissingArguments
+	}
+	a.name = data
+	return nil
+}
+
+func (a *sanitiseResponseHeaderFn) Evaluate(_ plugintypes.RuleMetadata, tx plugintypes.TransactionState) {
+	tx.(*corazawaf.Transaction).SanitizeResponseHeader(a.name)
+}
+
+func (a *sanitiseResponseHeaderFn) Type() plugintypes.ActionType {
+	return plugintypes.ActionTypeNondisruptive
+}
+
+func sanitiseResponseHeader() plugintypes.Action {
+	return &sanitiseResponseHeaderFn{}
+}
+
+var (
+	_ plugintypes.Action = &sanitiseResponseHeaderFn{}
+	_ ruleActionWrapper  = sanitiseResponseHeader
+)
@@ -0,0 +1,60 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"github.com/corazawaf/coraza/v3/experimental/plugins/macro"
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/collections"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// Action Group: Non-disruptive
+//
+// Description:
+// Special-purpose action that initializes the SESSION collection using the session token provided.
+// The value is also stored in the SESSIONID variable. Session records are separated per `SecWebAppId`.
+//
+// Example:
+// ```
+// # Initialize session variables using the session cookie value
+// SecRule REQUEST_COOKIES:PHPSESSID "!^$" "nolog,pass,id:137,setsid:%{REQUEST_COOKIES.PHPSESSID}"
+// ```
+type setsidFn struct {
+	key macro.Macro
+}
+
+func (a *setsidFn) Init(_ plugintypes.RuleMetadata, data string) error {
+	if len(data) == 0 {
+		return ErrMissingArguments
+	}
+
+	m, err := macro.NewMacro(data)
+	if err != nil {
+		return err
+	}
+	a.key = m
+	return nil
+}
+
+func (a *setsidFn) Evaluate(r plugintypes.RuleMetadata, tx plugintypes.TransactionState) {
+	key := a.key.Expand(tx)
+	if c, ok := tx.Collection(variables.Sessionid).(*collections.Single); ok {
+		c.Set(key)
+	}
+	initPersistentCollection(r, tx, variables.Session, key)
+}
+
+func (a *setsidFn) Type() plugintypes.ActionType {
+	return plugintypes.ActionTypeNondisruptive
+}
+
+func setsid() plugintypes.Action {
+	return &setsidFn{}
+}
+
+var (
+	_ plugintypes.Action = &setsidFn{}
+	_ ruleActionWrapper  = setsid
+)
@@ -0,0 +1,49 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+func TestSetsidAndSetuid(t *testing.T) {
+	tests := []struct {
+		name       string
+		action     ruleActionWrapper
+		idVariable variables.RuleVariable
+		collection variables.RuleVariable
+	}{
+		{name: "setsid", action: setsid, idVariable: variables.Sessionid, collection: variables.Session},
+		{name: "setuid", action: setuid, idVariable: variables.Userid, collection: variables.User},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := tt.action()
+			if err := a.Init(&md{}, ""); err != ErrMissingArguments {
+				t.Errorf("expected ErrMissingArguments, got %v", err)
+			}
+			if err := a.Init(&md{}, "%{tx.id}"); err != nil {
+				t.Fatal(err)
+			}
+
+			waf := corazawaf.NewWAF()
+			tx := waf.NewTransaction()
+			defer tx.Close()
+			tx.Variables().TX().Set("id", []string{"abc"})
+			a.Evaluate(&md{}, tx)
+
+			if v := tx.Collection(tt.idVariable).(collection.Single).Get(); v != "abc" {
+				t.Errorf("unexpected %s value %q", tt.idVariable.Name(), v)
+			}
+			if v := tx.Collection(tt.collection).(collection.Map).Get("key"); len(v) != 1 || v[0] != "abc" {
+				t.Errorf("expected %s collection to be initialized, got %v", tt.collection.Name(), v)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"github.com/corazawaf/coraza/v3/experimental/plugins/macro"
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/collections"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// Action Group: Non-disruptive
+//
+// Description:
+// Special-purpose action that initializes the USER collection using the username provided.
+// The value is also stored in the USERID variable. User records are separated per `SecWebAppId`.
+//
+// Example:
+// ```
+// # Initialize user tracking
+// SecAction "nolog,id:138,pass,setuid:%{REMOTE_USER}"
+//
+// # Is the current user the administrator?
+// SecRule USERID "admin" "id:139,nolog,pass"
+// ```
+type setuidFn struct {
+	key macro.Macro
+}
+
+func (a *setuidFn) Init(_ plugintypes.RuleMetadata, data string) error {
+	if len(data) == 0 {
+		return ErrMissingArguments
+	}
+
+	m, err := macro.NewMacro(data)
+	if err != nil {
+		return err
+	}
+	a.key = m
+	return nil
+}
+
+func (a *setuidFn) Evaluate(r plugintypes.RuleMetadata, tx plugintypes.TransactionState) {
+	key := a.key.Expand(tx)
+	if c, ok := tx.Collection(variables.Userid).(*collections.Single); ok {
+		c.Set(key)
+	}
+	initPersistentCollection(r, tx, variables.User, key)
+}
+
+func (a *setuidFn) Type() plugintypes.ActionType {
+	return plugintypes.ActionTypeNondisruptive
+}
+
+func setuid() plugintypes.Action {
+	return &setuidFn{}
+}
+
+var (
+	_ plugintypes.Action = &setuidFn{}
+	_ ruleActionWrapper  = setuid
+)
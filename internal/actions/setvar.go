@@ -5,6 +5,7 @@ package actions
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -18,6 +19,8 @@ import (
 //
 // Description:
 // Creates, removes, or updates a variable. Variable names are **case-insensitive**.
+// Variables can be set in the TX collection and in the persistent collections (IP, SESSION,
+// USER, GLOBAL and RESOURCE) once they have been initialized.
 //
 // Example:
 // ```
@@ -74,17 +77,14 @@ func (a *setvarFn) Init(_ plugintypes.RuleMetadata, data string) error {
 	var err error
 	key, val, valOk := strings.Cut(data, "=")
 	colKey, colVal, colOk := strings.Cut(key, ".")
-	// Right not it only makes sense to allow setting TX
-	// key is also required
-	if strings.ToUpper(colKey) != "TX" {
-		return errors.New("invalid arguments, expected collection TX")
+	// Right now it only makes sense to allow setting TX and the persistent
+	// collections, key is also required
+	a.collection, err = variables.Parse(colKey)
+	if err != nil || !isSettableCollection(a.collection) {
+		return errors.New("invalid arguments, expected collection TX, IP, SESSION, USER, GLOBAL or RESOURCE")
 	}
 	if strings.TrimSpace(colVal) == "" {
-		return errors.New("invalid arguments, expected syntax TX.{key}={value}")
-	}
-	a.collection, err = variables.Parse(colKey)
-	if err != nil {
-		return err
+		return fmt.Errorf("invalid arguments, expected syntax %s.{key}={value}", strings.ToUpper(colKey))
 	}
 	if colOk {
 		macro, err := macro.NewMacro(colVal)
@@ -165,6 +165,15 @@ func (a *setvarFn) evaluateTxCollection(r plugintypes.RuleMetadata, tx plugintyp
 				return
 			}
 		}
+		// Persistent collections are shared by concurrent transactions, so
+		// the operation must be atomic.
+		if c, ok := col.(summableCollection); ok {
+			if value[0] == '-' {
+				val = -val
+			}
+			c.Sum(key, val)
+			return
+		}
 		currentValInt := 0
 		if currentVal != "" {
 			currentValInt, err = strconv.Atoi(currentVal)
@@ -187,6 +196,19 @@ func (a *setvarFn) evaluateTxCollection(r plugintypes.RuleMetadata, tx plugintyp
 	}
 }
 
+// summableCollection is implemented by the persistent collections.
+type summableCollection interface {
+	Sum(key string, delta int)
+}
+
+func isSettableCollection(v variables.RuleVariable) bool {
+	switch v {
+	case variables.TX, variables.IP, variables.Session, variables.User, variables.Global, variables.Resource:
+		return true
+	}
+	return false
+}
+
 func setvar() plugintypes.Action {
 	return &setvarFn{}
 }
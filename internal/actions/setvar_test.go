@@ -46,6 +46,18 @@ func TestSetvarInit(t *testing.T) {
 			t.Error(err)
 		}
 	})
+	t.Run("persistent collection set ok", func(t *testing.T) {
+		a := setvar()
+		if err := a.Init(&md{}, "IP.score=+1"); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("non settable collection", func(t *testing.T) {
+		a := setvar()
+		if err := a.Init(&md{}, "ARGS.foo=bar"); err == nil {
+			t.Error("expected error")
+		}
+	})
 	t.Run("TX without key should fail", func(t *testing.T) {
 		a := setvar()
 		if err := a.Init(&md{}, "TX=test"); err == nil {
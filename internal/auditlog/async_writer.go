@@ -0,0 +1,349 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/collections"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+var (
+	errAsyncWriterClosed    = errors.New("audit log writer closed")
+	errAsyncWriterQueueFull = errors.New("audit log queue full, entry dropped")
+)
+
+// AsyncWriterConfig configures an AsyncWriter. Zero values are replaced by
+// the defaults.
+type AsyncWriterConfig struct {
+	// QueueSize is the number of entries waiting to be written, 1024 by default.
+	QueueSize int
+	// Workers is the number of goroutines writing the entries, 1 by default.
+	Workers int
+	// BatchSize is the maximum number of queued entries a worker writes at once,
+	// 1 by default.
+	BatchSize int
+	// MaxRetries is the number of times a failed write is retried, 3 by default.
+	// Negative values disable the retries.
+	MaxRetries int
+	// Backoff is the wait before the first retry, doubled on every retry up to
+	// MaxBackoff. 100ms and 5s by default.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// SpillFile is the path of the file the entries are appended to, formatted,
+	// when the queue is full or they can't be written after the retries. When
+	// empty, those entries are dropped.
+	SpillFile string
+	// FlushTimeout is how long Close waits for the queued entries to be written,
+	// 5s by default. Entries still queued afterwards are spilled or dropped.
+	FlushTimeout time.Duration
+}
+
+// AsyncWriterStats are the counters of an AsyncWriter.
+type AsyncWriterStats struct {
+	// Queued is the number of entries waiting to be written.
+	Queued int
+	// Written is the number of entries written by the wrapped writer.
+	Written uint64
+	// Retried is the number of retried writes.
+	Retried uint64
+	// Spilled is the number of entries appended to the spill file.
+	Spilled uint64
+	// Dropped is the number of lost entries.
+	Dropped uint64
+}
+
+// batchWriter is implemented by the writers able to write several entries at
+// once, the AsyncWriter uses it to write its batches.
+type batchWriter interface {
+	WriteBatch([]plugintypes.AuditLog) error
+}
+
+// AsyncWriter writes the audit logs with a wrapped writer in the background,
+// so slow or failing outputs don't add latency to the transactions. Entries
+// are queued, written in batches by a pool of workers and retried with
+// exponential backoff. Entries not fitting in the queue or failing after the
+// retries are appended to a spill file, or dropped and counted.
+type AsyncWriter struct {
+	writer    plugintypes.AuditLogWriter
+	config    AsyncWriterConfig
+	formatter plugintypes.AuditLogFormatter
+
+	mu      sync.RWMutex
+	started bool
+	closed  bool
+	queue   chan plugintypes.AuditLog
+	workers sync.WaitGroup
+	abort   chan struct{}
+
+	spillMu sync.Mutex
+	spill   *os.File
+
+	written atomic.Uint64
+	retried atomic.Uint64
+	spilled atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// NewAsyncWriter returns an AsyncWriter wrapping w.
+func NewAsyncWriter(w plugintypes.AuditLogWriter, c AsyncWriterConfig) (*AsyncWriter, error) {
+	if w == nil {
+		return nil, errors.New("missing audit log writer")
+	}
+	if c.QueueSize < 0 || c.Workers < 0 || c.BatchSize < 0 || c.Backoff < 0 || c.MaxBackoff < 0 || c.FlushTimeout < 0 {
+		return nil, errors.New("invalid async audit log writer config, negative value")
+	}
+	if c.QueueSize == 0 {
+		c.QueueSize = 1024
+	}
+	if c.Workers == 0 {
+		c.Workers = 1
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = 1
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.Backoff == 0 {
+		c.Backoff = 100 * time.Millisecond
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = 5 * time.Second
+	}
+	if c.FlushTimeout == 0 {
+		c.FlushTimeout = 5 * time.Second
+	}
+	return &AsyncWriter{writer: w, config: c}, nil
+}
+
+// Init initializes the wrapped writer and starts the workers. Subsequent
+// calls are no-ops.
+func (a *AsyncWriter) Init(c plugintypes.AuditLogConfig) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.started {
+		return nil
+	}
+
+	if err := a.writer.Init(c); err != nil {
+		return err
+	}
+	a.formatter = c.Formatter
+	if a.config.SpillFile != "" {
+		f, err := os.OpenFile(a.config.SpillFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, c.FileMode)
+		if err != nil {
+			return fmt.Errorf("failed to open audit log spill file: %w", err)
+		}
+		a.spill = f
+	}
+
+	a.queue = make(chan plugintypes.AuditLog, a.config.QueueSize)
+	a.abort = make(chan struct{})
+	for i := 0; i < a.config.Workers; i++ {
+		a.workers.Add(1)
+		go a.work()
+	}
+	a.started = true
+	return nil
+}
+
+// Write queues the audit log. It never blocks: when the queue is full the
+// entry is spilled, or dropped and an error returned.
+func (a *AsyncWriter) Write(al plugintypes.AuditLog) error {
+	al = snapshot(al)
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if !a.started || a.closed {
+		a.dropped.Add(1)
+		return errAsyncWriterClosed
+	}
+	select {
+	case a.queue <- al:
+		return nil
+	default:
+	}
+	if a.spillEntries([]plugintypes.AuditLog{al}) {
+		return nil
+	}
+	return errAsyncWriterQueueFull
+}
+
+// Close stops accepting entries and waits up to the flush timeout for the
+// queued ones to be written before closing the wrapped writer.
+func (a *AsyncWriter) Close() error {
+	a.mu.Lock()
+	if !a.started || a.closed {
+		a.closed = true
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.queue)
+	a.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		a.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(a.config.FlushTimeout):
+		// the remaining entries are spilled or dropped by the workers
+		close(a.abort)
+		<-done
+	}
+
+	err := a.writer.Close()
+	if a.spill != nil {
+		err = errors.Join(err, a.spill.Close())
+	}
+	return err
+}
+
+// Reopen reopens the files of the wrapped writer, if it supports it.
+func (a *AsyncWriter) Reopen() error {
+	if r, ok := a.writer.(plugintypes.AuditLogReopener); ok {
+		return r.Reopen()
+	}
+	return nil
+}
+
+// Stats returns the counters of the writer.
+func (a *AsyncWriter) Stats() AsyncWriterStats {
+	a.mu.RLock()
+	queued := len(a.queue)
+	a.mu.RUnlock()
+	return AsyncWriterStats{
+		Queued:  queued,
+		Written: a.written.Load(),
+		Retried: a.retried.Load(),
+		Spilled: a.spilled.Load(),
+		Dropped: a.dropped.Load(),
+	}
+}
+
+func (a *AsyncWriter) work() {
+	defer a.workers.Done()
+	for al := range a.queue {
+		batch := []plugintypes.AuditLog{al}
+	fill:
+		for len(batch) < a.config.BatchSize {
+			select {
+			case al, ok := <-a.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, al)
+			default:
+				break fill
+			}
+		}
+		a.writeBatch(batch)
+	}
+}
+
+func (a *AsyncWriter) writeBatch(batch []plugintypes.AuditLog) {
+	if bw, ok := a.writer.(batchWriter); ok && len(batch) > 1 {
+		if a.retry(func() error { return bw.WriteBatch(batch) }) {
+			a.written.Add(uint64(len(batch)))
+		} else {
+			a.spillEntries(batch)
+		}
+		return
+	}
+	for _, al := range batch {
+		if a.retry(func() error { return a.writer.Write(al) }) {
+			a.written.Add(1)
+		} else {
+			a.spillEntries([]plugintypes.AuditLog{al})
+		}
+	}
+}
+
+// retry calls write until it succeeds, the retries are exhausted or Close
+// times out. It returns true if write succeeded.
+func (a *AsyncWriter) retry(write func() error) bool {
+	backoff := a.config.Backoff
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-a.abort:
+			return false
+		default:
+		}
+		if write() == nil {
+			return true
+		}
+		if attempt >= a.config.MaxRetries {
+			return false
+		}
+		a.retried.Add(1)
+		select {
+		case <-a.abort:
+			return false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, a.config.MaxBackoff)
+	}
+}
+
+// spillEntries appends the entries to the spill file. It returns false if
+// they were dropped.
+func (a *AsyncWriter) spillEntries(entries []plugintypes.AuditLog) bool {
+	if a.spill == nil || a.formatter == nil {
+		a.dropped.Add(uint64(len(entries)))
+		return false
+	}
+	a.spillMu.Lock()
+	defer a.spillMu.Unlock()
+	ok := true
+	for _, al := range entries {
+		bts, err := a.formatter.Format(al)
+		if err == nil && (len(bts) == 0 || bts[len(bts)-1] != '\n') {
+			bts = append(bts, '\n')
+		}
+		if err == nil {
+			_, err = a.spill.Write(bts)
+		}
+		if err != nil {
+			a.dropped.Add(1)
+			ok = false
+			continue
+		}
+		a.spilled.Add(1)
+	}
+	return ok
+}
+
+// snapshot copies the parts of the audit log still referencing the state of
+// the transaction, which is reused once the transaction is closed.
+func snapshot(al plugintypes.AuditLog) plugintypes.AuditLog {
+	l, ok := al.(*Log)
+	if !ok || l.Transaction_.Request_ == nil || l.Transaction_.Request_.Args_ == nil {
+		return al
+	}
+	args := collections.NewMap(variables.Args)
+	for _, md := range l.Transaction_.Request_.Args_.FindAll() {
+		args.Add(md.Key(), md.Value())
+	}
+	req := *l.Transaction_.Request_
+	req.Args_ = collections.NewConcatKeyed(variables.Args, args)
+	c := *l
+	c.Transaction_.Request_ = &req
+	return &c
+}
+
+var (
+	_ plugintypes.AuditLogWriter   = (*AsyncWriter)(nil)
+	_ plugintypes.AuditLogReopener = (*AsyncWriter)(nil)
+)
@@ -0,0 +1,271 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/collections"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// fakeWriter records the written entries, failing the first failures writes
+// and blocking until release is closed when set.
+type fakeWriter struct {
+	mu       sync.Mutex
+	failures int
+	release  chan struct{}
+	written  []string
+	batches  []int
+	closed   bool
+}
+
+func (w *fakeWriter) Init(plugintypes.AuditLogConfig) error { return nil }
+
+func (w *fakeWriter) Write(al plugintypes.AuditLog) error {
+	if w.release != nil {
+		<-w.release
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failures > 0 {
+		w.failures--
+		return errors.New("write failed")
+	}
+	w.written = append(w.written, al.Transaction().ID())
+	return nil
+}
+
+func (w *fakeWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+func (w *fakeWriter) ids() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.written...)
+}
+
+type fakeBatchWriter struct {
+	fakeWriter
+}
+
+func (w *fakeBatchWriter) WriteBatch(logs []plugintypes.AuditLog) error {
+	w.mu.Lock()
+	w.batches = append(w.batches, len(logs))
+	w.mu.Unlock()
+	for _, al := range logs {
+		if err := w.Write(al); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newAsyncWriterForTest(t *testing.T, w plugintypes.AuditLogWriter, c AsyncWriterConfig) *AsyncWriter {
+	t.Helper()
+	aw, err := NewAsyncWriter(w, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := NewConfig()
+	config.Formatter = &jsonFormatter{}
+	if err := aw.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	return aw
+}
+
+func testLog(id string) *Log {
+	return &Log{Transaction_: Transaction{ID_: id}}
+}
+
+func TestAsyncWriterFlushesOnClose(t *testing.T) {
+	w := &fakeWriter{}
+	aw := newAsyncWriterForTest(t, w, AsyncWriterConfig{Workers: 2})
+	for _, id := range []string{"a", "b", "c"} {
+		if err := aw.Write(testLog(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 3, len(w.ids()); want != have {
+		t.Errorf("unexpected written entries, want %d, have %d", want, have)
+	}
+	if !w.closed {
+		t.Error("expected the wrapped writer to be closed")
+	}
+	if stats := aw.Stats(); stats.Written != 3 || stats.Dropped != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if err := aw.Write(testLog("d")); err != errAsyncWriterClosed {
+		t.Errorf("unexpected error writing to a closed writer: %v", err)
+	}
+}
+
+func TestAsyncWriterRetries(t *testing.T) {
+	w := &fakeWriter{failures: 2}
+	aw := newAsyncWriterForTest(t, w, AsyncWriterConfig{Backoff: time.Millisecond})
+	if err := aw.Write(testLog("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ids := w.ids(); len(ids) != 1 || ids[0] != "a" {
+		t.Errorf("unexpected written entries %q", ids)
+	}
+	if stats := aw.Stats(); stats.Retried != 2 || stats.Written != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestAsyncWriterSpillsFailedEntries(t *testing.T) {
+	spill := filepath.Join(t.TempDir(), "spill.log")
+	w := &fakeWriter{failures: 10}
+	aw := newAsyncWriterForTest(t, w, AsyncWriterConfig{MaxRetries: 1, Backoff: time.Millisecond, SpillFile: spill})
+	if err := aw.Write(testLog("failed-entry")); err != nil {
+		t.Fatal(err)
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(spill)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "failed-entry") || !strings.HasSuffix(string(data), "\n") {
+		t.Errorf("unexpected spill file content %q", data)
+	}
+	if stats := aw.Stats(); stats.Spilled != 1 || stats.Retried != 1 || stats.Dropped != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestAsyncWriterQueueFull(t *testing.T) {
+	t.Run("drop", func(t *testing.T) {
+		w := &fakeWriter{release: make(chan struct{})}
+		aw := newAsyncWriterForTest(t, w, AsyncWriterConfig{QueueSize: 1})
+		var dropped int
+		for _, id := range []string{"a", "b", "c", "d"} {
+			if err := aw.Write(testLog(id)); err == errAsyncWriterQueueFull {
+				dropped++
+			}
+		}
+		close(w.release)
+		if err := aw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// one entry is being written, one is queued
+		if dropped < 2 {
+			t.Errorf("expected the entries over the queue size to be dropped, have %d", dropped)
+		}
+		if stats := aw.Stats(); stats.Dropped != uint64(dropped) || stats.Written+stats.Dropped != 4 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("spill", func(t *testing.T) {
+		spill := filepath.Join(t.TempDir(), "spill.log")
+		w := &fakeWriter{release: make(chan struct{})}
+		aw := newAsyncWriterForTest(t, w, AsyncWriterConfig{QueueSize: 1, SpillFile: spill})
+		for _, id := range []string{"a", "b", "c", "d"} {
+			if err := aw.Write(testLog(id)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		close(w.release)
+		if err := aw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		stats := aw.Stats()
+		if stats.Spilled < 2 || stats.Written+stats.Spilled != 4 || stats.Dropped != 0 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+		data, err := os.ReadFile(spill)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := int(stats.Spilled), strings.Count(string(data), "\n"); want != have {
+			t.Errorf("unexpected spilled lines, want %d, have %d", want, have)
+		}
+	})
+}
+
+func TestAsyncWriterFlushTimeout(t *testing.T) {
+	w := &fakeWriter{failures: 100}
+	aw := newAsyncWriterForTest(t, w, AsyncWriterConfig{Backoff: time.Hour, FlushTimeout: 10 * time.Millisecond})
+	if err := aw.Write(testLog("a")); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("close took %s", elapsed)
+	}
+	if stats := aw.Stats(); stats.Dropped != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestAsyncWriterBatches(t *testing.T) {
+	w := &fakeBatchWriter{fakeWriter{release: make(chan struct{})}}
+	aw := newAsyncWriterForTest(t, w, AsyncWriterConfig{BatchSize: 3})
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if err := aw.Write(testLog(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(w.release)
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 4, len(w.ids()); want != have {
+		t.Errorf("unexpected written entries, want %d, have %d", want, have)
+	}
+	for _, size := range w.batches {
+		if size > 3 {
+			t.Errorf("unexpected batch size %d", size)
+		}
+	}
+}
+
+func TestAsyncWriterSnapshotsArgs(t *testing.T) {
+	args := collections.NewMap(variables.Args)
+	args.Add("a", "1")
+	al := &Log{Transaction_: Transaction{Request_: &TransactionRequest{
+		Args_: collections.NewConcatKeyed(variables.Args, args),
+	}}}
+	c := snapshot(al).(*Log)
+	args.Reset()
+	if v := c.Transaction().Request().Args().Get("a"); len(v) != 1 || v[0] != "1" {
+		t.Errorf("unexpected args snapshot %q", v)
+	}
+	if al.Transaction_.Request_ == c.Transaction_.Request_ {
+		t.Error("expected the request to be copied")
+	}
+}
+
+func TestNewAsyncWriterErrors(t *testing.T) {
+	if _, err := NewAsyncWriter(nil, AsyncWriterConfig{}); err == nil {
+		t.Error("expected error for missing writer")
+	}
+	if _, err := NewAsyncWriter(&fakeWriter{}, AsyncWriterConfig{Workers: -1}); err == nil {
+		t.Error("expected error for negative workers")
+	}
+}
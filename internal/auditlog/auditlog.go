@@ -154,10 +154,12 @@ func (t Transaction) IsInterrupted() bool {
 // TransactionResponse contains response specific
 // information
 type TransactionResponse struct {
-	Protocol_ string              `json:"protocol"`
-	Status_   int                 `json:"status"`
-	Headers_  map[string][]string `json:"headers"`
-	Body_     string              `json:"body"`
+	Protocol_            string              `json:"protocol"`
+	Status_              int                 `json:"status"`
+	Headers_             map[string][]string `json:"headers"`
+	Body_                string              `json:"body"`
+	IntermediaryHeaders_ map[string][]string `json:"intermediary_headers,omitempty"`
+	FinalBody_           string              `json:"final_body,omitempty"`
 }
 
 var _ plugintypes.AuditLogTransactionResponse = (*TransactionResponse)(nil)
@@ -194,6 +196,22 @@ func (tr *TransactionResponse) Body() string {
 	return tr.Body_
 }
 
+func (tr *TransactionResponse) IntermediaryHeaders() map[string][]string {
+	if tr == nil {
+		return nil
+	}
+
+	return tr.IntermediaryHeaders_
+}
+
+func (tr *TransactionResponse) FinalBody() string {
+	if tr == nil {
+		return ""
+	}
+
+	return tr.FinalBody_
+}
+
 // TransactionProducer contains producer specific
 // information for debugging
 type TransactionProducer struct {
@@ -203,6 +221,8 @@ type TransactionProducer struct {
 	RuleEngine_ string   `json:"rule_engine"`
 	Stopwatch_  string   `json:"stopwatch"`
 	Rulesets_   []string `json:"rulesets"`
+
+	RulesPerformance_ map[int]int64 `json:"rules_performance,omitempty"`
 }
 
 var _ plugintypes.AuditLogTransactionProducer = (*TransactionProducer)(nil)
@@ -255,6 +275,14 @@ func (tp *TransactionProducer) Rulesets() []string {
 	return tp.Rulesets_
 }
 
+func (tp *TransactionProducer) RulesPerformance() map[int]int64 {
+	if tp == nil {
+		return nil
+	}
+
+	return tp.RulesPerformance_
+}
+
 // TransactionRequest contains request specific
 // information
 type TransactionRequest struct {
@@ -6,11 +6,13 @@
 package auditlog
 
 import (
+	"errors"
 	"io"
 	"io/fs"
 	"log"
 	"os"
 	"path"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -24,6 +26,8 @@ type concurrentWriter struct {
 	logDirMode  fs.FileMode
 	logFileMode fs.FileMode
 	formatter   plugintypes.AuditLogFormatter
+	index       *rotatingFile
+	retention   *storageRetention
 	io.Closer
 }
 
@@ -39,11 +43,20 @@ func (cl *concurrentWriter) Init(c plugintypes.AuditLogConfig) error {
 	cl.formatter = c.Formatter
 	cl.mux = &sync.RWMutex{}
 
-	f, err := os.OpenFile(c.Target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, cl.logFileMode)
+	f, err := openRotatingFile(c.Target, cl.logFileMode, c.Rotation)
 	if err != nil {
 		return err
 	}
+	cl.index = f
 	cl.Closer = f
+	if c.StorageRetention.MaxFiles > 0 || c.StorageRetention.MaxAge > 0 {
+		cl.retention = &storageRetention{
+			dir:      cl.logDir,
+			maxFiles: c.StorageRetention.MaxFiles,
+			maxAge:   c.StorageRetention.MaxAge,
+			interval: storageRetentionInterval,
+		}
+	}
 
 	cl.log = log.New(f, "", 0)
 	return nil
@@ -71,12 +84,8 @@ func (cl concurrentWriter) Write(al plugintypes.AuditLog) error {
 	filename := ymdhm + t.Format("05") + "-" + al.Transaction().ID()
 
 	logdir := path.Join(cl.logDir, ymd, ymdhm)
-	if err := os.MkdirAll(logdir, cl.logDirMode); err != nil {
-		return err
-	}
-
 	filepath := path.Join(logdir, filename)
-	if err = os.WriteFile(filepath, formattedAL, cl.logFileMode); err != nil {
+	if err := cl.writeFile(logdir, filepath, formattedAL); err != nil {
 		return err
 	}
 
@@ -96,7 +105,164 @@ func (cl concurrentWriter) Write(al plugintypes.AuditLog) error {
 	}
 	cl.log.Printf("%s - %s\n", al.Transaction().ID(), filepath)
 
+	if cl.retention != nil {
+		cl.retention.maybeRun(time.Now())
+	}
 	return nil
 }
 
-var _ plugintypes.AuditLogWriter = (*concurrentWriter)(nil)
+// writeFile creates the directory of a transaction file and writes it. The
+// storage retention can remove the directory of an older minute in between,
+// so a missing directory is created again once.
+func (cl concurrentWriter) writeFile(dir, name string, data []byte) error {
+	for retried := false; ; retried = true {
+		err := os.MkdirAll(dir, cl.logDirMode)
+		if err == nil {
+			err = os.WriteFile(name, data, cl.logFileMode)
+		}
+		if retried || !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+}
+
+// Reopen reopens the index file.
+func (cl *concurrentWriter) Reopen() error {
+	if cl.index == nil {
+		return nil
+	}
+	return cl.index.Reopen()
+}
+
+func (cl *concurrentWriter) Close() error {
+	if cl.retention != nil {
+		cl.retention.wait()
+	}
+	if cl.Closer == nil {
+		return nil
+	}
+	return cl.Closer.Close()
+}
+
+// storageRetentionInterval is the minimum time between two cleanups of the
+// storage directory.
+const storageRetentionInterval = time.Minute
+
+// storageRetention removes the oldest transaction files of the storage
+// directory, laid out as YYYYMMDD/YYYYMMDD-HHMM/YYYYMMDD-HHMMSS-ID, once they
+// exceed the maximum count or age.
+type storageRetention struct {
+	dir      string
+	maxFiles int
+	maxAge   time.Duration
+	interval time.Duration
+
+	mu      sync.Mutex
+	last    time.Time
+	running bool
+	pending sync.WaitGroup
+}
+
+// maybeRun starts a cleanup in the background unless one ran within the
+// interval.
+func (r *storageRetention) maybeRun(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running || now.Sub(r.last) < r.interval {
+		return
+	}
+	r.running = true
+	r.last = now
+	r.pending.Add(1)
+	go func() {
+		defer r.pending.Done()
+		r.removeExpired(now)
+		r.mu.Lock()
+		r.running = false
+		r.mu.Unlock()
+	}()
+}
+
+func (r *storageRetention) wait() {
+	r.pending.Wait()
+}
+
+// removeExpired walks the storage directory from the newest to the oldest
+// minute directory. The age of the files is given by the name of their minute
+// directory, so they are not stat'ed. A directory is only removed once expired
+// or emptied by the cleanup, as Write may have just created it, and the
+// directories of the current minute and day are always kept.
+func (r *storageRetention) removeExpired(now time.Time) {
+	kept := 0
+	days := listStorageDirs(r.dir, "20060102")
+	for i := len(days) - 1; i >= 0; i-- {
+		day := days[i]
+		minutes := listStorageDirs(day.path, "20060102-1504")
+		removed := false
+		for j := len(minutes) - 1; j >= 0; j-- {
+			minute := minutes[j]
+			// the files of a minute directory are written within the minute
+			end := minute.t.Add(time.Minute)
+			expired := r.maxAge > 0 && now.Sub(end) > r.maxAge
+			entries, err := os.ReadDir(minute.path)
+			if err != nil {
+				continue
+			}
+			left := len(entries)
+			// the layout sorts the files from the oldest to the newest
+			for k := len(entries) - 1; k >= 0; k-- {
+				e := entries[k]
+				if !e.Type().IsRegular() {
+					continue
+				}
+				if !expired && (r.maxFiles <= 0 || kept < r.maxFiles) {
+					kept++
+					continue
+				}
+				if os.Remove(filepath.Join(minute.path, e.Name())) == nil {
+					left--
+				}
+			}
+			emptied := left == 0 && (expired || left < len(entries))
+			if emptied && now.After(end) && os.Remove(minute.path) == nil {
+				removed = true
+			}
+		}
+		// the day directory is only removed when empty
+		if removed && now.After(day.t.AddDate(0, 0, 1)) {
+			_ = os.Remove(day.path)
+		}
+	}
+}
+
+type storageDir struct {
+	path string
+	t    time.Time
+}
+
+// listStorageDirs returns the directories of dir named with the given time
+// layout, sorted from the oldest to the newest.
+func listStorageDirs(dir, layout string) []storageDir {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var dirs []storageDir
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		// the directories are named after the local time of the transactions
+		t, err := time.ParseInLocation(layout, e.Name(), time.Local)
+		if err != nil {
+			continue
+		}
+		dirs = append(dirs, storageDir{path: filepath.Join(dir, e.Name()), t: t})
+	}
+	return dirs
+}
+
+var (
+	_ plugintypes.AuditLogWriter   = (*concurrentWriter)(nil)
+	_ plugintypes.AuditLogReopener = (*concurrentWriter)(nil)
+)
@@ -13,6 +13,8 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -150,3 +152,182 @@ func TestConcurrentWriterSuccess(t *testing.T) {
 		t.Errorf("unexpected log entry, want:\n%s, have:\n%s", expectedLogStr, logData)
 	}
 }
+
+func TestConcurrentWriterRetention(t *testing.T) {
+	dir := t.TempDir()
+	config := plugintypes.AuditLogConfig{
+		Target:    filepath.Join(dir, "audit.log"),
+		Dir:       dir,
+		FileMode:  fs.FileMode(0644),
+		DirMode:   fs.FileMode(0755),
+		Formatter: &jsonFormatter{},
+		// the rotation of the index file doesn't apply to the storage directory
+		Rotation:         plugintypes.AuditLogRotation{MaxFiles: 1, MaxAge: time.Nanosecond},
+		StorageRetention: plugintypes.AuditLogStorageRetention{MaxFiles: 2, MaxAge: time.Hour},
+	}
+	writer := &concurrentWriter{}
+	if err := writer.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	now := time.Now()
+	var paths []string
+	for i, age := range []time.Duration{3 * time.Hour, 30 * time.Minute, 20 * time.Minute, 10 * time.Minute} {
+		ts := now.Add(-age)
+		if err := writer.Write(&Log{Transaction_: Transaction{UnixTimestamp_: ts.UnixNano(), ID_: fmt.Sprint("tx", i)}}); err != nil {
+			t.Fatal(err)
+		}
+		// the age of the files is given by their directory, not their modification time
+		p := filepath.Join(dir, ts.Format("20060102"), ts.Format("20060102-1504"), ts.Format("20060102-150405")+fmt.Sprint("-tx", i))
+		paths = append(paths, p)
+	}
+	writer.retention.wait()
+	writer.retention.removeExpired(now)
+
+	for i, p := range paths {
+		_, err := os.Stat(p)
+		if kept := err == nil; kept != (i >= 2) {
+			t.Errorf("unexpected retention of %s, kept %t", p, kept)
+		}
+	}
+	// the directories left empty are removed
+	if _, err := os.Stat(filepath.Dir(paths[0])); !os.IsNotExist(err) {
+		t.Errorf("expected the directory of the expired file to be removed")
+	}
+	if _, err := os.Stat(config.Target); err != nil {
+		t.Errorf("expected the index file to be kept: %v", err)
+	}
+}
+
+func TestStorageRetentionKeepsNewDirectories(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	// directories created by Write but not yet filled
+	var dirs []string
+	for _, age := range []time.Duration{0, 10 * time.Minute} {
+		ts := now.Add(-age)
+		d := filepath.Join(dir, ts.Format("20060102"), ts.Format("20060102-1504"))
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, d)
+	}
+	r := &storageRetention{dir: dir, maxFiles: 1, maxAge: time.Hour}
+	r.removeExpired(now)
+	for _, d := range dirs {
+		if _, err := os.Stat(d); err != nil {
+			t.Errorf("expected the empty directory %s to be kept: %v", d, err)
+		}
+	}
+}
+
+func TestConcurrentWriterRetentionConcurrentWrites(t *testing.T) {
+	dir := t.TempDir()
+	writer := &concurrentWriter{}
+	if err := writer.Init(plugintypes.AuditLogConfig{
+		Target:           filepath.Join(dir, "audit.log"),
+		Dir:              dir,
+		FileMode:         0644,
+		DirMode:          0755,
+		Formatter:        &jsonFormatter{},
+		StorageRetention: plugintypes.AuditLogStorageRetention{MaxFiles: 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	// the first write starts a cleanup which runs along the other writes
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// spread the transactions over a few minute directories
+			ts := time.Now().Add(-time.Duration(i%5) * time.Minute)
+			errs <- writer.Write(&Log{Transaction_: Transaction{UnixTimestamp_: ts.UnixNano(), ID_: fmt.Sprint("tx", i)}})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestConcurrentWriterRotationKeepsStorage(t *testing.T) {
+	dir := t.TempDir()
+	writer := &concurrentWriter{}
+	if err := writer.Init(plugintypes.AuditLogConfig{
+		Target:    filepath.Join(dir, "audit.log"),
+		Dir:       dir,
+		FileMode:  0644,
+		DirMode:   0755,
+		Formatter: &jsonFormatter{},
+		Rotation:  plugintypes.AuditLogRotation{MaxSize: 1, MaxFiles: 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	if writer.retention != nil {
+		t.Fatal("unexpected retention of the storage directory")
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := writer.Write(&Log{Transaction_: Transaction{UnixTimestamp_: time.Now().UnixNano(), ID_: fmt.Sprint("tx", i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("unexpected transaction files %q", files)
+	}
+}
+
+func TestStorageRetentionInterval(t *testing.T) {
+	r := &storageRetention{dir: t.TempDir(), maxFiles: 1, interval: time.Minute}
+	now := time.Now()
+	r.maybeRun(now)
+	r.wait()
+	if !r.last.Equal(now) {
+		t.Fatal("expected the first cleanup to run")
+	}
+	r.maybeRun(now.Add(time.Second))
+	r.wait()
+	if !r.last.Equal(now) {
+		t.Error("unexpected cleanup within the interval")
+	}
+	r.maybeRun(now.Add(time.Minute))
+	r.wait()
+	if !r.last.Equal(now.Add(time.Minute)) {
+		t.Error("expected a cleanup after the interval")
+	}
+}
+
+func TestConcurrentWriterReopen(t *testing.T) {
+	dir := t.TempDir()
+	index := filepath.Join(dir, "audit.log")
+	writer := &concurrentWriter{}
+	if err := writer.Init(plugintypes.AuditLogConfig{Target: index, Dir: dir, FileMode: 0644, DirMode: 0755, Formatter: &jsonFormatter{}}); err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	if err := os.Rename(index, index+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Write(&Log{Transaction_: Transaction{UnixTimestamp_: time.Now().UnixNano(), ID_: "reopened"}}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(index); !strings.Contains(string(data), "reopened") {
+		t.Errorf("unexpected index file %q", data)
+	}
+}
@@ -7,8 +7,11 @@
 // The following log formats are supported:
 //
 // - JSON
-// - Coraza
+// - JSON legacy
 // - Native
+// - OCSF
+// - CEF
+// - LEEF
 //
 // The following log writers are supported:
 //
@@ -20,8 +23,10 @@
 package auditlog
 
 import (
+	"bytes"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -95,26 +100,22 @@ func (nativeFormatter) Format(al plugintypes.AuditLog) ([]byte, error) {
 					res.WriteByte('\n')
 				}
 			}
+		case types.AuditLogPartIntermediaryResponseHeaders:
+			// Part D: Intermediary response headers
+			if al.Transaction().HasResponse() && al.Transaction().Response().IntermediaryHeaders() != nil {
+				writeResponseHeaders(&res, al.Transaction().Response(), al.Transaction().Response().IntermediaryHeaders())
+			}
 		case types.AuditLogPartResponseHeaders:
 			// Part F: Response headers
 			if al.Transaction().HasResponse() {
-				// Write status line: HTTP/1.1 200 OK
-				protocol := al.Transaction().Response().Protocol()
-				if protocol == "" {
-					protocol = "HTTP/1.1"
-				}
-				status := al.Transaction().Response().Status()
-				statusText := http.StatusText(status)
-				_, _ = fmt.Fprintf(&res, "%s %d %s\n", protocol, status, statusText)
-
-				// Write headers
-				for k, vv := range al.Transaction().Response().Headers() {
-					for _, v := range vv {
-						res.WriteString(k)
-						res.WriteString(": ")
-						res.WriteString(v)
-						res.WriteByte('\n')
-					}
+				writeResponseHeaders(&res, al.Transaction().Response(), al.Transaction().Response().Headers())
+			}
+		case types.AuditLogPartResponseBody:
+			// Part G: Response body delivered to the client
+			if al.Transaction().HasResponse() {
+				if body := al.Transaction().Response().FinalBody(); body != "" {
+					res.WriteString(body)
+					res.WriteByte('\n')
 				}
 			}
 		case types.AuditLogPartAuditLogTrailer:
@@ -126,6 +127,9 @@ func (nativeFormatter) Format(al plugintypes.AuditLog) ([]byte, error) {
 					res.WriteByte('\n')
 				}
 			}
+			if p := al.Transaction().Producer(); p != nil && len(p.RulesPerformance()) > 0 {
+				writeRulesPerformance(&res, p.RulesPerformance())
+			}
 		case types.AuditLogPartUploadedFiles:
 			// Part J: Uploaded files information
 			// Format matches ModSecurity v2: index,size,"filename","content_type"
@@ -154,7 +158,7 @@ func (nativeFormatter) Format(al plugintypes.AuditLog) ([]byte, error) {
 		case types.AuditLogPartEndMarker:
 			// Part Z: Final boundary marker with no content
 		default:
-			// For any other parts (D, G, I) that aren't explicitly handled,
+			// For any other parts (I) that aren't explicitly handled,
 			// they remain empty
 		}
 
@@ -167,10 +171,78 @@ func (nativeFormatter) Format(al plugintypes.AuditLog) ([]byte, error) {
 	return []byte(res.String()), nil
 }
 
+// writeRulesPerformance writes the rules above the SecRulePerfTime threshold
+// like ModSecurity, slowest first: Rules-Performance-Info: "id=usec", ...
+func writeRulesPerformance(res *strings.Builder, perf map[int]int64) {
+	ids := make([]int, 0, len(perf))
+	for id := range perf {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if perf[ids[i]] != perf[ids[j]] {
+			return perf[ids[i]] > perf[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	res.WriteString("Rules-Performance-Info: ")
+	for i, id := range ids {
+		if i > 0 {
+			res.WriteString(", ")
+		}
+		_, _ = fmt.Fprintf(res, "\"%d=%d\"", id, perf[id])
+	}
+	res.WriteByte('\n')
+}
+
+// writeResponseHeaders writes the status line of the response followed by the
+// headers, e.g. HTTP/1.1 200 OK.
+func writeResponseHeaders(res *strings.Builder, r plugintypes.AuditLogTransactionResponse, headers map[string][]string) {
+	protocol := r.Protocol()
+	if protocol == "" {
+		protocol = "HTTP/1.1"
+	}
+	status := r.Status()
+	_, _ = fmt.Fprintf(res, "%s %d %s\n", protocol, status, http.StatusText(status))
+
+	for k, vv := range headers {
+		for _, v := range vv {
+			res.WriteString(k)
+			res.WriteString(": ")
+			res.WriteString(v)
+			res.WriteByte('\n')
+		}
+	}
+}
+
 func (nativeFormatter) MIME() string {
 	return "application/x-coraza-auditlog-native"
 }
 
+// eventsFormatter is implemented by the formatters writing several events per
+// audit log, one per line.
+type eventsFormatter interface {
+	eventPerLine()
+}
+
+// records returns the records sent by the syslog, HTTPS, socket and Kafka
+// writers for the payload of the formatter, one per event for the formatters
+// writing an event per line. Empty payloads are not sent.
+func records(f plugintypes.AuditLogFormatter, payload []byte) [][]byte {
+	if len(payload) == 0 {
+		return nil
+	}
+	if _, ok := f.(eventsFormatter); !ok {
+		return [][]byte{payload}
+	}
+	var res [][]byte
+	for _, line := range bytes.Split(payload, []byte{'\n'}) {
+		if len(line) > 0 {
+			res = append(res, line)
+		}
+	}
+	return res
+}
+
 var (
 	_ plugintypes.AuditLogFormatter = (*nativeFormatter)(nil)
 )
@@ -0,0 +1,175 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+const (
+	siemVendor  = "OWASP"
+	siemProduct = "Coraza"
+)
+
+// siemSeverity maps the rule severity, which follows the syslog levels, to
+// the 0-10 scale of CEF and LEEF, 10 being the most severe.
+func siemSeverity(s types.RuleSeverity) int {
+	switch s {
+	case types.RuleSeverityEmergency:
+		return 10
+	case types.RuleSeverityAlert:
+		return 9
+	case types.RuleSeverityCritical:
+		return 8
+	case types.RuleSeverityError:
+		return 7
+	case types.RuleSeverityWarning:
+		return 5
+	case types.RuleSeverityNotice:
+		return 4
+	case types.RuleSeverityInfo:
+		return 2
+	case types.RuleSeverityDebug:
+		return 1
+	}
+	return 0
+}
+
+// siemEvents calls event for every matched rule of the audit log, skipping
+// the messages only carrying an error message, and returns the events one
+// per line.
+func siemEvents(al plugintypes.AuditLog, event func(*strings.Builder, plugintypes.AuditLogTransaction, plugintypes.AuditLogMessageData)) []byte {
+	var res strings.Builder
+	tx := al.Transaction()
+	for _, m := range al.Messages() {
+		md := messageData(m)
+		if md == nil {
+			continue
+		}
+		if res.Len() > 0 {
+			res.WriteByte('\n')
+		}
+		event(&res, tx, md)
+	}
+	if res.Len() == 0 {
+		return nil
+	}
+	return []byte(res.String())
+}
+
+func siemDeviceVersion(tx plugintypes.AuditLogTransaction) string {
+	if p := tx.Producer(); p != nil {
+		return p.Version()
+	}
+	return ""
+}
+
+func siemRuleName(md plugintypes.AuditLogMessageData) string {
+	if md.Msg() != "" {
+		return md.Msg()
+	}
+	return "Rule " + strconv.Itoa(md.ID())
+}
+
+func siemAction(tx plugintypes.AuditLogTransaction) string {
+	if tx.IsInterrupted() {
+		return "blocked"
+	}
+	return "detected"
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+// cefFormatter writes an ArcSight Common Event Format (CEF) event for every
+// rule matched (part K):
+//
+//	CEF:0|OWASP|Coraza|<version>|<rule id>|<msg>|<severity>|<extension>
+//
+// The extension carries the transaction ID (externalId), the client and
+// server addresses, the request method and URI and the rule details in the
+// custom string fields.
+type cefFormatter struct{}
+
+func (cefFormatter) Format(al plugintypes.AuditLog) ([]byte, error) {
+	return siemEvents(al, func(res *strings.Builder, tx plugintypes.AuditLogTransaction, md plugintypes.AuditLogMessageData) {
+		res.WriteString("CEF:0|")
+		for _, field := range []string{
+			siemVendor,
+			siemProduct,
+			siemDeviceVersion(tx),
+			strconv.Itoa(md.ID()),
+			siemRuleName(md),
+			strconv.Itoa(siemSeverity(md.Severity())),
+		} {
+			res.WriteString(cefHeaderEscaper.Replace(field))
+			res.WriteByte('|')
+		}
+
+		ext := cefExtension{res: res}
+		ext.add("rt", strconv.FormatInt(tx.UnixTimestamp()/1e6, 10))
+		ext.add("externalId", tx.ID())
+		ext.add("act", siemAction(tx))
+		ext.add("src", tx.ClientIP())
+		ext.addPort("spt", tx.ClientPort())
+		ext.add("dst", tx.HostIP())
+		ext.addPort("dpt", tx.HostPort())
+		ext.add("dhost", tx.ServerID())
+		if tx.HasRequest() {
+			ext.add("requestMethod", tx.Request().Method())
+			ext.add("request", tx.Request().URI())
+		}
+		ext.add("msg", md.Data())
+		ext.add("cs1Label", "ruleId")
+		ext.add("cs1", strconv.Itoa(md.ID()))
+		if len(md.Tags()) > 0 {
+			ext.add("cs2Label", "ruleTags")
+			ext.add("cs2", strings.Join(md.Tags(), ","))
+		}
+		if md.Ver() != "" {
+			ext.add("cs3Label", "ruleVersion")
+			ext.add("cs3", md.Ver())
+		}
+	}), nil
+}
+
+func (cefFormatter) MIME() string {
+	return "text/plain"
+}
+
+func (cefFormatter) eventPerLine() {}
+
+// cefExtension writes the space separated key=value pairs of the CEF
+// extension, skipping the empty values.
+type cefExtension struct {
+	res     *strings.Builder
+	written bool
+}
+
+func (e *cefExtension) add(key, value string) {
+	if value == "" {
+		return
+	}
+	if e.written {
+		e.res.WriteByte(' ')
+	}
+	e.written = true
+	e.res.WriteString(key)
+	e.res.WriteByte('=')
+	e.res.WriteString(cefValueEscaper.Replace(value))
+}
+
+func (e *cefExtension) addPort(key string, port int) {
+	if port != 0 {
+		e.add(key, strconv.Itoa(port))
+	}
+}
+
+var _ plugintypes.AuditLogFormatter = (*cefFormatter)(nil)
@@ -0,0 +1,101 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// createSIEMAuditLog returns an interrupted transaction with two matched
+// rules and an error message, which is not an event.
+func createSIEMAuditLog() *Log {
+	return &Log{
+		Transaction_: Transaction{
+			UnixTimestamp_: 1136239460000000000,
+			ID_:            "abc123",
+			ClientIP_:      "192.0.2.10",
+			ClientPort_:    51234,
+			HostIP_:        "198.51.100.1",
+			HostPort_:      443,
+			IsInterrupted_: true,
+			Request_: &TransactionRequest{
+				Method_: "GET",
+				URI_:    "/search?q=a=b|c\\d",
+			},
+			Producer_: &TransactionProducer{Version_: "1.2.3"},
+		},
+		Messages_: []plugintypes.AuditLogMessage{
+			&Message{
+				Data_: &MessageData{
+					ID_:       942100,
+					Msg_:      "SQL Injection | libinjection",
+					Data_:     "Matched Data: a=b\nfound",
+					Severity_: types.RuleSeverityCritical,
+					Ver_:      "OWASP_CRS/4.0.0",
+					Tags_:     []string{"attack-sqli", "capec/1000/152/248/66"},
+				},
+			},
+			&Message{Message_: "Access denied with code 403"},
+			&Message{
+				Data_: &MessageData{ID_: 100, Severity_: types.RuleSeverityUnset},
+			},
+		},
+	}
+}
+
+func TestCEFFormatter(t *testing.T) {
+	f := &cefFormatter{}
+	out, err := f.Format(createSIEMAuditLog())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected an event per matched rule, have %q", out)
+	}
+	checkLine(t, lines, 0, `CEF:0|OWASP|Coraza|1.2.3|942100|SQL Injection \| libinjection|8|`+
+		`rt=1136239460000 externalId=abc123 act=blocked src=192.0.2.10 spt=51234 dst=198.51.100.1 dpt=443 `+
+		`requestMethod=GET request=/search?q\=a\=b|c\\d msg=Matched Data: a\=b\nfound `+
+		`cs1Label=ruleId cs1=942100 cs2Label=ruleTags cs2=attack-sqli,capec/1000/152/248/66 cs3Label=ruleVersion cs3=OWASP_CRS/4.0.0`)
+	checkLine(t, lines, 1, `CEF:0|OWASP|Coraza|1.2.3|100|Rule 100|0|`+
+		`rt=1136239460000 externalId=abc123 act=blocked src=192.0.2.10 spt=51234 dst=198.51.100.1 dpt=443 `+
+		`requestMethod=GET request=/search?q\=a\=b|c\\d cs1Label=ruleId cs1=100`)
+
+	if f.MIME() != "text/plain" {
+		t.Errorf("unexpected MIME %q", f.MIME())
+	}
+
+	t.Run("no matched rules", func(t *testing.T) {
+		out, err := f.Format(&Log{Messages_: []plugintypes.AuditLogMessage{&Message{Message_: "error"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != nil {
+			t.Errorf("unexpected events %q", out)
+		}
+	})
+}
+
+func TestSIEMSeverity(t *testing.T) {
+	tests := map[types.RuleSeverity]int{
+		types.RuleSeverityEmergency: 10,
+		types.RuleSeverityAlert:     9,
+		types.RuleSeverityCritical:  8,
+		types.RuleSeverityError:     7,
+		types.RuleSeverityWarning:   5,
+		types.RuleSeverityNotice:    4,
+		types.RuleSeverityInfo:      2,
+		types.RuleSeverityDebug:     1,
+		types.RuleSeverityUnset:     0,
+	}
+	for severity, want := range tests {
+		if have := siemSeverity(severity); have != want {
+			t.Errorf("unexpected severity for %s, want %d, have %d", severity, want, have)
+		}
+	}
+}
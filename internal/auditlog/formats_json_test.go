@@ -158,3 +158,35 @@ func TestJSONFormatterPartJ(t *testing.T) {
 		})
 	}
 }
+
+func TestJSONFormatterPartsDG(t *testing.T) {
+	al := &Log{
+		Parts_: []types.AuditLogPart{
+			types.AuditLogPartIntermediaryResponseHeaders,
+			types.AuditLogPartResponseBody,
+		},
+		Transaction_: Transaction{
+			Response_: &TransactionResponse{
+				Status_:              200,
+				IntermediaryHeaders_: map[string][]string{"server": {"nginx"}},
+				FinalBody_:           "<html></html>",
+			},
+		},
+	}
+	data, err := (&jsonFormatter{}).Format(al)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed := &Log{}
+	if err := json.Unmarshal(data, parsed); err != nil {
+		t.Fatal(err)
+	}
+	res := parsed.Transaction().Response()
+	if h := res.IntermediaryHeaders()["server"]; len(h) != 1 || h[0] != "nginx" {
+		t.Errorf("unexpected intermediary headers %v", res.IntermediaryHeaders())
+	}
+	if res.FinalBody() != "<html></html>" {
+		t.Errorf("unexpected final body %q", res.FinalBody())
+	}
+}
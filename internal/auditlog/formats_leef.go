@@ -0,0 +1,98 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+)
+
+var (
+	leefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ", "\t", " ")
+	leefValueEscaper  = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\r", `\r`, "\n", `\n`)
+)
+
+// leefFormatter writes an IBM Log Event Extended Format (LEEF) 2.0 event for
+// every rule matched (part K), with the attributes separated by tabs:
+//
+//	LEEF:2.0|OWASP|Coraza|<version>|<rule id>|x09|<attributes>
+//
+// The attributes carry the transaction ID (transactionId), the client and
+// server addresses, the request method and URI (url) and the rule details.
+type leefFormatter struct{}
+
+func (leefFormatter) Format(al plugintypes.AuditLog) ([]byte, error) {
+	return siemEvents(al, func(res *strings.Builder, tx plugintypes.AuditLogTransaction, md plugintypes.AuditLogMessageData) {
+		res.WriteString("LEEF:2.0|")
+		for _, field := range []string{
+			siemVendor,
+			siemProduct,
+			siemDeviceVersion(tx),
+			strconv.Itoa(md.ID()),
+		} {
+			res.WriteString(leefHeaderEscaper.Replace(field))
+			res.WriteByte('|')
+		}
+		res.WriteString("x09|")
+
+		attrs := leefAttributes{res: res}
+		// devTime without devTimeFormat is in milliseconds since the epoch
+		attrs.add("devTime", strconv.FormatInt(tx.UnixTimestamp()/1e6, 10))
+		if sev := siemSeverity(md.Severity()); sev > 0 {
+			attrs.add("sev", strconv.Itoa(sev))
+		}
+		attrs.add("cat", siemRuleName(md))
+		attrs.add("transactionId", tx.ID())
+		attrs.add("action", siemAction(tx))
+		attrs.add("src", tx.ClientIP())
+		attrs.addPort("srcPort", tx.ClientPort())
+		attrs.add("dst", tx.HostIP())
+		attrs.addPort("dstPort", tx.HostPort())
+		attrs.add("dstHost", tx.ServerID())
+		if tx.HasRequest() {
+			attrs.add("method", tx.Request().Method())
+			attrs.add("url", tx.Request().URI())
+		}
+		attrs.add("ruleId", strconv.Itoa(md.ID()))
+		attrs.add("msg", md.Data())
+		attrs.add("ruleTags", strings.Join(md.Tags(), ","))
+		attrs.add("ruleVersion", md.Ver())
+	}), nil
+}
+
+func (leefFormatter) MIME() string {
+	return "text/plain"
+}
+
+func (leefFormatter) eventPerLine() {}
+
+// leefAttributes writes the tab separated key=value attributes of the LEEF
+// event, skipping the empty values.
+type leefAttributes struct {
+	res     *strings.Builder
+	written bool
+}
+
+func (a *leefAttributes) add(key, value string) {
+	if value == "" {
+		return
+	}
+	if a.written {
+		a.res.WriteByte('\t')
+	}
+	a.written = true
+	a.res.WriteString(key)
+	a.res.WriteByte('=')
+	a.res.WriteString(leefValueEscaper.Replace(value))
+}
+
+func (a *leefAttributes) addPort(key string, port int) {
+	if port != 0 {
+		a.add(key, strconv.Itoa(port))
+	}
+}
+
+var _ plugintypes.AuditLogFormatter = (*leefFormatter)(nil)
@@ -0,0 +1,71 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLEEFFormatter(t *testing.T) {
+	al := createSIEMAuditLog()
+	al.Transaction_.IsInterrupted_ = false
+	al.Transaction_.ServerID_ = "shop.example.com"
+	al.Transaction_.Request_.URI_ = "/a\tb"
+
+	f := &leefFormatter{}
+	out, err := f.Format(al)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected an event per matched rule, have %q", out)
+	}
+	checkLine(t, lines, 0, "LEEF:2.0|OWASP|Coraza|1.2.3|942100|x09|"+strings.Join([]string{
+		"devTime=1136239460000",
+		"sev=8",
+		"cat=SQL Injection | libinjection",
+		"transactionId=abc123",
+		"action=detected",
+		"src=192.0.2.10",
+		"srcPort=51234",
+		"dst=198.51.100.1",
+		"dstPort=443",
+		"dstHost=shop.example.com",
+		"method=GET",
+		`url=/a\tb`,
+		"ruleId=942100",
+		`msg=Matched Data: a=b\nfound`,
+		"ruleTags=attack-sqli,capec/1000/152/248/66",
+		"ruleVersion=OWASP_CRS/4.0.0",
+	}, "\t"))
+	// the unset severity is left out, LEEF severities range from 1 to 10
+	checkLine(t, lines, 1, "LEEF:2.0|OWASP|Coraza|1.2.3|100|x09|"+strings.Join([]string{
+		"devTime=1136239460000",
+		"cat=Rule 100",
+		"transactionId=abc123",
+		"action=detected",
+		"src=192.0.2.10",
+		"srcPort=51234",
+		"dst=198.51.100.1",
+		"dstPort=443",
+		"dstHost=shop.example.com",
+		"method=GET",
+		`url=/a\tb`,
+		"ruleId=100",
+	}, "\t"))
+
+	t.Run("header escaping", func(t *testing.T) {
+		al := createSIEMAuditLog()
+		al.Transaction_.Producer_.Version_ = "1.2|3\n"
+		out, err := f.Format(al)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(out), `LEEF:2.0|OWASP|Coraza|1.2\|3 |942100|x09|`) {
+			t.Errorf("unexpected header in %q", out)
+		}
+	})
+}
@@ -1,8 +1,6 @@
 // Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
 // SPDX-License-Identifier: Apache-2.0
 
-//go:build !tinygo
-
 // OCSF log format
 // OCSF (Open Cybersecurity Schema Framework) (https://github.com/ocsf) is an open-source framework with the goal of providing an open standard for logging security events.
 // This log format will produce a JSON log which adheres to the OCSF schema (https://schema.ocsf.io/)
@@ -12,248 +10,688 @@ package auditlog
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
-	"time"
-
-	"github.com/valllabh/ocsf-schema-golang/ocsf/v1_2_0/events/application"
-	"github.com/valllabh/ocsf-schema-golang/ocsf/v1_2_0/events/application/enums"
-	"github.com/valllabh/ocsf-schema-golang/ocsf/v1_2_0/objects"
-	ocsf_object_enums "github.com/valllabh/ocsf-schema-golang/ocsf/v1_2_0/objects/enums"
 
 	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
 	"github.com/corazawaf/coraza/v3/types"
 )
 
+// ocsfVersion is the version of the OCSF schema the events adhere to.
+const ocsfVersion = "1.2.0"
+
+const (
+	ocsfCategoryFindings        = 2
+	ocsfCategoryNetworkActivity = 4
+
+	ocsfClassDetectionFinding = 2004
+	ocsfClassHTTPActivity     = 4002
+
+	ocsfActivityUnknown = 0
+	ocsfActivityOther   = 99
+	// ocsfActivityCreate is the activity of a new Detection Finding.
+	ocsfActivityCreate = 1
+
+	ocsfActionAllowed = 1
+	ocsfActionDenied  = 2
+
+	ocsfDispositionAllowed = 1
+	ocsfDispositionBlocked = 2
+
+	ocsfFindingStatusNew = 1
+
+	ocsfAnalyticRule = 1
+
+	ocsfObservableHostname  = 1
+	ocsfObservableIPAddress = 2
+	ocsfObservableURLString = 6
+	ocsfObservableFileName  = 7
+	ocsfObservableOther     = 99
+)
+
+// ocsfHTTPActivities maps the HTTP methods to the HTTP Activity activity IDs.
+var ocsfHTTPActivities = map[string]int{
+	"CONNECT": 1,
+	"DELETE":  2,
+	"GET":     3,
+	"HEAD":    4,
+	"OPTIONS": 5,
+	"POST":    6,
+	"PUT":     7,
+	"TRACE":   8,
+}
+
+var ocsfSeverityNames = map[int]string{
+	0: "Unknown",
+	1: "Informational",
+	2: "Low",
+	3: "Medium",
+	4: "High",
+	5: "Critical",
+	6: "Fatal",
+}
+
+// ocsfSeverity maps the rule severity, which follows the syslog levels, to
+// the OCSF severity ID.
+func ocsfSeverity(s types.RuleSeverity) int {
+	switch s {
+	case types.RuleSeverityEmergency:
+		return 6
+	case types.RuleSeverityAlert:
+		return 5
+	case types.RuleSeverityCritical:
+		return 4
+	case types.RuleSeverityError:
+		return 3
+	case types.RuleSeverityWarning, types.RuleSeverityNotice:
+		return 2
+	case types.RuleSeverityInfo, types.RuleSeverityDebug:
+		return 1
+	}
+	return 0
+}
+
+var (
+	// ocsfMITRETag matches the MITRE ATT&CK tactics and techniques in rule tags
+	// such as mitre/T1190, attack.t1059.001 or mitre:TA0001.
+	ocsfMITRETag = regexp.MustCompile(`(?i)^(?:mitre|attack)[./:-](ta\d{4}|t\d{4}(?:\.\d{3})?)$`)
+	// ocsfCAPECTag matches the CAPEC hierarchy tags used by CRS, such as
+	// capec/1000/152/248/66, the last ID is the most specific one.
+	ocsfCAPECTag = regexp.MustCompile(`(?i)^capec(?:/\d+)*/(\d+)$`)
+)
+
+type ocsfEvent struct {
+	ActivityID   int    `json:"activity_id"`
+	ActivityName string `json:"activity_name"`
+	CategoryUID  int    `json:"category_uid"`
+	CategoryName string `json:"category_name"`
+	ClassUID     int    `json:"class_uid"`
+	ClassName    string `json:"class_name"`
+	TypeUID      int    `json:"type_uid"`
+	TypeName     string `json:"type_name"`
+	Time         int64  `json:"time"`
+	SeverityID   int    `json:"severity_id"`
+	Severity     string `json:"severity"`
+	Message      string `json:"message,omitempty"`
+
+	// HTTP Activity
+	ActionID      int               `json:"action_id,omitempty"`
+	Action        string            `json:"action,omitempty"`
+	DispositionID int               `json:"disposition_id,omitempty"`
+	Disposition   string            `json:"disposition,omitempty"`
+	HTTPRequest   *ocsfHTTPRequest  `json:"http_request,omitempty"`
+	HTTPResponse  *ocsfHTTPResponse `json:"http_response,omitempty"`
+	SrcEndpoint   *ocsfEndpoint     `json:"src_endpoint,omitempty"`
+	DstEndpoint   *ocsfEndpoint     `json:"dst_endpoint,omitempty"`
+
+	// Detection Finding
+	FindingInfo *ocsfFindingInfo `json:"finding_info,omitempty"`
+	Evidences   []ocsfEvidence   `json:"evidences,omitempty"`
+	StatusID    int              `json:"status_id,omitempty"`
+	Status      string           `json:"status,omitempty"`
+
+	Metadata    ocsfMetadata     `json:"metadata"`
+	Observables []ocsfObservable `json:"observables,omitempty"`
+	Unmapped    map[string]any   `json:"unmapped,omitempty"`
+}
+
+type ocsfMetadata struct {
+	Version        string      `json:"version"`
+	Product        ocsfProduct `json:"product"`
+	Profiles       []string    `json:"profiles,omitempty"`
+	UID            string      `json:"uid,omitempty"`
+	CorrelationUID string      `json:"correlation_uid,omitempty"`
+	LogProvider    string      `json:"log_provider,omitempty"`
+	LogVersion     string      `json:"log_version,omitempty"`
+}
+
+type ocsfProduct struct {
+	Name       string `json:"name"`
+	VendorName string `json:"vendor_name"`
+}
+
+type ocsfHTTPRequest struct {
+	HTTPMethod    string           `json:"http_method,omitempty"`
+	URL           *ocsfURL         `json:"url,omitempty"`
+	Version       string           `json:"version,omitempty"`
+	UserAgent     string           `json:"user_agent,omitempty"`
+	Referrer      string           `json:"referrer,omitempty"`
+	XForwardedFor []string         `json:"x_forwarded_for,omitempty"`
+	HTTPHeaders   []ocsfHTTPHeader `json:"http_headers,omitempty"`
+	Args          string           `json:"args,omitempty"`
+	Length        int32            `json:"length,omitempty"`
+	UID           string           `json:"uid,omitempty"`
+}
+
+type ocsfURL struct {
+	URLString   string `json:"url_string"`
+	Scheme      string `json:"scheme,omitempty"`
+	Hostname    string `json:"hostname,omitempty"`
+	Port        int    `json:"port,omitempty"`
+	Path        string `json:"path,omitempty"`
+	QueryString string `json:"query_string,omitempty"`
+}
+
+type ocsfHTTPHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type ocsfHTTPResponse struct {
+	Code        int              `json:"code"`
+	Status      string           `json:"status,omitempty"`
+	ContentType string           `json:"content_type,omitempty"`
+	Length      int32            `json:"length,omitempty"`
+	HTTPHeaders []ocsfHTTPHeader `json:"http_headers,omitempty"`
+}
+
+type ocsfEndpoint struct {
+	IP       string `json:"ip,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+}
+
+type ocsfObservable struct {
+	Name   string `json:"name"`
+	TypeID int    `json:"type_id"`
+	Type   string `json:"type"`
+	Value  string `json:"value"`
+}
+
+type ocsfFindingInfo struct {
+	UID         string        `json:"uid"`
+	Title       string        `json:"title"`
+	Desc        string        `json:"desc,omitempty"`
+	Types       []string      `json:"types,omitempty"`
+	Analytic    *ocsfAnalytic `json:"analytic,omitempty"`
+	Attacks     []ocsfAttack  `json:"attacks,omitempty"`
+	CreatedTime int64         `json:"created_time,omitempty"`
+}
+
+type ocsfAnalytic struct {
+	UID     string `json:"uid"`
+	Name    string `json:"name,omitempty"`
+	TypeID  int    `json:"type_id"`
+	Type    string `json:"type"`
+	Version string `json:"version,omitempty"`
+}
+
+type ocsfAttack struct {
+	Tactic       *ocsfMITRE `json:"tactic,omitempty"`
+	Technique    *ocsfMITRE `json:"technique,omitempty"`
+	SubTechnique *ocsfMITRE `json:"sub_technique,omitempty"`
+}
+
+type ocsfMITRE struct {
+	UID    string `json:"uid"`
+	SrcURL string `json:"src_url,omitempty"`
+}
+
+type ocsfEvidence struct {
+	Data        map[string]any `json:"data,omitempty"`
+	SrcEndpoint *ocsfEndpoint  `json:"src_endpoint,omitempty"`
+	DstEndpoint *ocsfEndpoint  `json:"dst_endpoint,omitempty"`
+}
+
+// ocsfFormatter writes an OCSF HTTP Activity event for the transaction,
+// followed by a Detection Finding event for every rule matched (part K), one
+// JSON object per line. The findings share the correlation UID of the
+// activity, the transaction ID.
 type ocsfFormatter struct{}
 
-func (f ocsfFormatter) getRequestArguments(al plugintypes.AuditLog) string {
-	argString := &strings.Builder{}
-	if al.Transaction().Request().Args() != nil {
-		args := al.Transaction().Request().Args().FindAll()
+func (f ocsfFormatter) Format(al plugintypes.AuditLog) ([]byte, error) {
+	tx := al.Transaction()
+	srcEndpoint, dstEndpoint := f.endpoints(tx)
+	observables := f.observables(tx)
+
+	activity := ocsfEvent{
+		CategoryUID:  ocsfCategoryNetworkActivity,
+		CategoryName: "Network Activity",
+		ClassUID:     ocsfClassHTTPActivity,
+		ClassName:    "HTTP Activity",
+		Time:         tx.UnixTimestamp() / 1e6,
+		SeverityID:   1,
+		Metadata:     f.metadata(tx, tx.ID(), "security_control"),
+		SrcEndpoint:  srcEndpoint,
+		DstEndpoint:  dstEndpoint,
+		Observables:  observables,
+	}
+	// the endpoints are required by HTTP Activity, even when unknown
+	if activity.SrcEndpoint == nil {
+		activity.SrcEndpoint = &ocsfEndpoint{}
+	}
+	if activity.DstEndpoint == nil {
+		activity.DstEndpoint = &ocsfEndpoint{}
+	}
+	activity.ActivityID, activity.ActivityName = ocsfActivityUnknown, "Unknown"
+	if tx.HasRequest() {
+		activity.ActivityID, activity.ActivityName = f.httpActivity(tx.Request().Method())
+	}
+	activity.TypeUID = ocsfClassHTTPActivity*100 + activity.ActivityID
+	activity.TypeName = "HTTP Activity: " + activity.ActivityName
+	if activity.ActivityID == ocsfActivityOther {
+		activity.TypeName = "HTTP Activity: Other"
+	}
+
+	if tx.IsInterrupted() {
+		activity.ActionID, activity.Action = ocsfActionDenied, "Denied"
+		activity.DispositionID, activity.Disposition = ocsfDispositionBlocked, "Blocked"
+	} else {
+		activity.ActionID, activity.Action = ocsfActionAllowed, "Allowed"
+		activity.DispositionID, activity.Disposition = ocsfDispositionAllowed, "Allowed"
+	}
+	// the request and response are required by HTTP Activity, the code of a
+	// response that wasn't logged is unknown
+	activity.HTTPRequest, activity.HTTPResponse = &ocsfHTTPRequest{}, &ocsfHTTPResponse{}
+	if tx.HasRequest() {
+		activity.HTTPRequest = f.httpRequest(tx)
+	}
+	if tx.HasResponse() {
+		activity.HTTPResponse = f.httpResponse(tx.Response())
+	}
+	activity.Unmapped = f.unmapped(tx)
 
-		argCount := len(args)
-		for i, arg := range al.Transaction().Request().Args().FindAll() {
-			fmt.Fprintf(argString, "%s=%s", arg.Key(), arg.Value())
-			if i < argCount {
-				argString.WriteString(",")
-			}
+	events := []ocsfEvent{activity}
+	for i, m := range al.Messages() {
+		if i == 0 {
+			events[0].Message = m.Message()
+		}
+		md := messageData(m)
+		if md == nil {
+			continue
+		}
+		finding := f.finding(tx, md, len(events))
+		finding.Observables = observables
+		finding.Evidences = []ocsfEvidence{{
+			Data:        f.evidenceData(md),
+			SrcEndpoint: srcEndpoint,
+			DstEndpoint: dstEndpoint,
+		}}
+		events = append(events, finding)
+		// the activity is as severe as its most severe finding
+		if finding.SeverityID > events[0].SeverityID {
+			events[0].SeverityID = finding.SeverityID
 		}
 	}
-	return argString.String()
-}
+	events[0].Severity = ocsfSeverityNames[events[0].SeverityID]
 
-func (f ocsfFormatter) getRequestHeaders(al plugintypes.AuditLog) []*objects.HttpHeader {
-	requestHeaders := []*objects.HttpHeader{}
-	for key, values := range al.Transaction().Request().Headers() {
-		for _, value := range values {
-			requestHeaders = append(requestHeaders, &objects.HttpHeader{
-				Name:  key,
-				Value: value,
-			})
+	var res []byte
+	for i, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			res = append(res, '\n')
 		}
+		res = append(res, data...)
 	}
-	return requestHeaders
+	return res, nil
 }
 
-func (f ocsfFormatter) getResponseHeaders(al plugintypes.AuditLog) []*objects.HttpHeader {
-	responseHeaders := []*objects.HttpHeader{}
-	for key, values := range al.Transaction().Response().Headers() {
-		for _, value := range values {
-			responseHeaders = append(responseHeaders, &objects.HttpHeader{
-				Name:  key,
-				Value: value,
-			})
-		}
+// messageData returns the data of the message, nil for the messages only
+// carrying an error message.
+func messageData(m plugintypes.AuditLogMessage) plugintypes.AuditLogMessageData {
+	md := m.Data()
+	if d, ok := md.(*MessageData); ok && d == nil {
+		return nil
 	}
-	return responseHeaders
+	return md
 }
 
-func (f ocsfFormatter) getAffectedWebResources(al plugintypes.AuditLog) []*objects.WebResource {
-	// Create an array of web Resources affected by this activity
-	webResources := []*objects.WebResource{}
-	webResources = append(webResources, &objects.WebResource{
-		UrlString: al.Transaction().Request().URI(),
-	})
+func (ocsfFormatter) httpActivity(method string) (int, string) {
+	if method == "" {
+		return ocsfActivityUnknown, "Unknown"
+	}
+	id, ok := ocsfHTTPActivities[strings.ToUpper(method)]
+	if !ok {
+		// the name of Other is the source specific activity
+		return ocsfActivityOther, method
+	}
+	name := strings.ToLower(method)
+	return id, strings.ToUpper(name[:1]) + name[1:]
+}
 
-	return webResources
+func (ocsfFormatter) metadata(tx plugintypes.AuditLogTransaction, uid string, profiles ...string) ocsfMetadata {
+	md := ocsfMetadata{
+		Version: ocsfVersion,
+		Product: ocsfProduct{
+			Name:       "Coraza Web Application Firewall",
+			VendorName: "OWASP",
+		},
+		Profiles:       profiles,
+		UID:            uid,
+		CorrelationUID: tx.ID(),
+	}
+	if p := tx.Producer(); p != nil {
+		md.LogProvider = p.Connector()
+		md.LogVersion = p.Version()
+	}
+	return md
 }
 
-// Returns an array of Enrichment objects containing the details of each message in AuditLog.Messages
-func (f ocsfFormatter) getMatchDetails(al plugintypes.AuditLog) []*objects.Enrichment {
-	matchDetails := []*objects.Enrichment{}
+func (ocsfFormatter) endpoints(tx plugintypes.AuditLogTransaction) (*ocsfEndpoint, *ocsfEndpoint) {
+	var src, dst *ocsfEndpoint
+	if tx.ClientIP() != "" || tx.ClientPort() != 0 {
+		src = &ocsfEndpoint{IP: tx.ClientIP(), Port: tx.ClientPort()}
+	}
+	if tx.HostIP() != "" || tx.HostPort() != 0 || tx.ServerID() != "" {
+		dst = &ocsfEndpoint{IP: tx.HostIP(), Port: tx.HostPort(), Hostname: tx.ServerID()}
+	}
+	return src, dst
+}
 
-	for _, match := range al.Messages() {
-		matchData, _ := json.Marshal(match.Data())
-		matchDetails = append(matchDetails, &objects.Enrichment{
-			Data:  string(matchData),
-			Name:  match.Data().Msg(),
-			Value: match.Data().Data(),
-		})
+func (f ocsfFormatter) httpRequest(tx plugintypes.AuditLogTransaction) *ocsfHTTPRequest {
+	req := tx.Request()
+	headers := req.Headers()
+	r := &ocsfHTTPRequest{
+		Version:     strings.TrimPrefix(req.Protocol(), "HTTP/"),
+		UserAgent:   firstHeader(headers, "user-agent"),
+		Referrer:    firstHeader(headers, "referer"),
+		HTTPHeaders: f.httpHeaders(headers),
+		Args:        f.requestArguments(req),
+		Length:      req.Length(),
+		UID:         tx.ID(),
+	}
+	// http_method is limited to the methods of the HTTP Activity activities
+	if method := strings.ToUpper(req.Method()); ocsfHTTPActivities[method] != 0 {
+		r.HTTPMethod = method
+	}
+	if uri := req.URI(); uri != "" {
+		r.URL = f.url(uri, firstHeader(headers, "host"))
+	}
+	for _, v := range headerValues(headers, "x-forwarded-for") {
+		for _, ip := range strings.Split(v, ",") {
+			if ip = strings.TrimSpace(ip); ip != "" {
+				r.XForwardedFor = append(r.XForwardedFor, ip)
+			}
+		}
 	}
+	return r
+}
 
-	return matchDetails
+func (ocsfFormatter) url(uri string, host string) *ocsfURL {
+	u := &ocsfURL{URLString: uri}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return u
+	}
+	u.Scheme = parsed.Scheme
+	u.Path = parsed.Path
+	u.QueryString = parsed.RawQuery
+	if parsed.Host != "" {
+		host = parsed.Host
+	}
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host = h
+		u.Port, _ = strconv.Atoi(p)
+	}
+	u.Hostname = host
+	return u
 }
 
-// Returns an array of Observable objects
-func (f ocsfFormatter) getObservables(al plugintypes.AuditLog) []*objects.Observable {
-	observables := []*objects.Observable{}
+func (ocsfFormatter) requestArguments(req plugintypes.AuditLogTransactionRequest) string {
+	args := req.Args()
+	if args == nil {
+		return ""
+	}
+	var pairs []string
+	for _, arg := range args.FindAll() {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", arg.Key(), arg.Value()))
+	}
+	return strings.Join(pairs, ",")
+}
 
-	if al.Transaction().ServerID() != "" {
-		observables = append(observables, &objects.Observable{
-			Name:   "ServerID",
-			Type:   "ServerID",
-			TypeId: ocsf_object_enums.OBSERVABLE_TYPE_ID_OBSERVABLE_TYPE_ID_OTHER,
-			Value:  al.Transaction().ServerID(),
-		})
+func (f ocsfFormatter) httpResponse(res plugintypes.AuditLogTransactionResponse) *ocsfHTTPResponse {
+	r := &ocsfHTTPResponse{
+		Code:        res.Status(),
+		Status:      http.StatusText(res.Status()),
+		ContentType: firstHeader(res.Headers(), "content-type"),
+		HTTPHeaders: f.httpHeaders(res.Headers()),
+	}
+	if body := res.FinalBody(); body != "" {
+		r.Length = int32(min(len(body), math.MaxInt32))
 	}
+	return r
+}
 
-	for _, file := range al.Transaction().Request().Files() {
-		observables = append(observables, &objects.Observable{
-			Name:   file.Name(),
-			Type:   "File Name",
-			TypeId: 7,
-			Value:  file.Name(),
+// httpHeaders returns the headers sorted by name, keeping the order of the
+// values.
+func (ocsfFormatter) httpHeaders(headers map[string][]string) []ocsfHTTPHeader {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var res []ocsfHTTPHeader
+	for _, name := range names {
+		for _, v := range headers[name] {
+			res = append(res, ocsfHTTPHeader{Name: name, Value: v})
+		}
+	}
+	return res
+}
+
+func (ocsfFormatter) observables(tx plugintypes.AuditLogTransaction) []ocsfObservable {
+	var observables []ocsfObservable
+	if ip := tx.ClientIP(); ip != "" {
+		observables = append(observables, ocsfObservable{
+			Name:   "src_endpoint.ip",
+			TypeID: ocsfObservableIPAddress,
+			Type:   "IP Address",
+			Value:  ip,
 		})
-		observables = append(observables, &objects.Observable{
-			Name:   file.Name(),
-			Type:   "Size",
-			TypeId: ocsf_object_enums.OBSERVABLE_TYPE_ID_OBSERVABLE_TYPE_ID_OTHER,
-			Value:  fmt.Sprint(file.Size()),
+	}
+	if ip := tx.HostIP(); ip != "" {
+		observables = append(observables, ocsfObservable{
+			Name:   "dst_endpoint.ip",
+			TypeID: ocsfObservableIPAddress,
+			Type:   "IP Address",
+			Value:  ip,
 		})
-		observables = append(observables, &objects.Observable{
-			Name:   file.Name(),
-			Type:   "Mime",
-			TypeId: ocsf_object_enums.OBSERVABLE_TYPE_ID_OBSERVABLE_TYPE_ID_OTHER,
-			Value:  file.Mime(),
+	}
+	if tx.ServerID() != "" {
+		observables = append(observables, ocsfObservable{
+			Name:   "dst_endpoint.hostname",
+			TypeID: ocsfObservableHostname,
+			Type:   "Hostname",
+			Value:  tx.ServerID(),
 		})
 	}
-
+	if !tx.HasRequest() {
+		return observables
+	}
+	if uri := tx.Request().URI(); uri != "" {
+		observables = append(observables, ocsfObservable{
+			Name:   "http_request.url.url_string",
+			TypeID: ocsfObservableURLString,
+			Type:   "URL String",
+			Value:  uri,
+		})
+	}
+	for _, file := range tx.Request().Files() {
+		observables = append(observables,
+			ocsfObservable{Name: file.Name(), TypeID: ocsfObservableFileName, Type: "File Name", Value: file.Name()},
+			ocsfObservable{Name: file.Name(), TypeID: ocsfObservableOther, Type: "Size", Value: strconv.FormatInt(file.Size(), 10)},
+			ocsfObservable{Name: file.Name(), TypeID: ocsfObservableOther, Type: "Mime", Value: file.Mime()},
+		)
+	}
 	return observables
 }
 
-func (f ocsfFormatter) Format(al plugintypes.AuditLog) ([]byte, error) {
+// unmapped returns the audit log parts without a place in the schema.
+func (ocsfFormatter) unmapped(tx plugintypes.AuditLogTransaction) map[string]any {
+	unmapped := map[string]any{}
+	if tx.HasRequest() && tx.Request().Body() != "" {
+		unmapped["request_body"] = tx.Request().Body()
+	}
+	if tx.HasResponse() {
+		res := tx.Response()
+		if res.Body() != "" {
+			unmapped["response_body"] = res.Body()
+		}
+		if res.IntermediaryHeaders() != nil {
+			unmapped["intermediary_response_headers"] = res.IntermediaryHeaders()
+		}
+		if res.FinalBody() != "" {
+			unmapped["final_response_body"] = res.FinalBody()
+		}
+	}
+	if len(unmapped) == 0 {
+		return nil
+	}
+	return unmapped
+}
 
-	// Determine the Action/ActionID based on whether the transaction was interrutped
-	ActionID := enums.WEB_RESOURCES_ACTIVITY_ACTION_ID_WEB_RESOURCES_ACTIVITY_ACTION_ID_ALLOWED
-	Action := "Allowed"
-	if al.Transaction().IsInterrupted() {
-		ActionID = enums.WEB_RESOURCES_ACTIVITY_ACTION_ID_WEB_RESOURCES_ACTIVITY_ACTION_ID_DENIED
-		Action = "Denied"
-
-	}
-
-	// Populate the required fields for the WebRecourcesActivity
-	webResourcesActivity := application.WebResourcesActivity{
-		ActivityId:   enums.WEB_RESOURCES_ACTIVITY_ACTIVITY_ID_WEB_RESOURCES_ACTIVITY_ACTIVITY_ID_READ,
-		ActivityName: "Read",
-		CategoryName: "Application Activity",
-		ClassName:    "Web Resources Activity",
-		CategoryUid:  enums.WEB_RESOURCES_ACTIVITY_CATEGORY_UID_WEB_RESOURCES_ACTIVITY_CATEGORY_UID_APPLICATION_ACTIVITY,
-		ClassUid:     enums.WEB_RESOURCES_ACTIVITY_CLASS_UID_WEB_RESOURCES_ACTIVITY_CLASS_UID_WEB_RESOURCES_ACTIVITY,
-		Time:         al.Transaction().UnixTimestamp(),
-		ActionId:     ActionID,
-		Action:       Action,
-		Metadata: &objects.Metadata{
-			CorrelationUid: "",
-			EventCode:      "",
-			//Labels:         [2]string{"", ""},
-			LogLevel: "",
-			LogName:  "",
-			//LogProvider: "OWASP Coraza Web Application Firewall",
-			LogProvider: al.Transaction().Producer().Connector(),
-			LogVersion:  al.Transaction().Producer().Version(),
-			LoggedTime:  time.Now().UnixMicro(),
-			Product: &objects.Product{
-				VendorName: "OWASP Coraza Web Application Firewall",
-			},
-			Version: "1.2.0",
-		},
-		TypeUid:     enums.WEB_RESOURCES_ACTIVITY_TYPE_UID_WEB_RESOURCES_ACTIVITY_TYPE_UID_WEB_RESOURCES_ACTIVITY_READ,
-		Enrichments: f.getMatchDetails(al),
-		HttpRequest: &objects.HttpRequest{
-			Version:     al.Transaction().Request().Protocol(),
-			Args:        f.getRequestArguments(al),
-			HttpMethod:  al.Transaction().Request().Method(),
-			Uid:         al.Transaction().ID(),
-			Url:         &objects.Url{UrlString: al.Transaction().Request().URI()},
-			HttpHeaders: f.getRequestHeaders(al),
-			Length:      al.Transaction().Request().Length(),
-		},
-		HttpResponse: &objects.HttpResponse{
-			Code:        int32(al.Transaction().Response().Status()),
-			HttpHeaders: f.getResponseHeaders(al),
-		},
-		SrcEndpoint: &objects.NetworkEndpoint{
-			Ip:   al.Transaction().ClientIP(),
-			Port: int32(al.Transaction().ClientPort()),
-		},
-		DstEndpoint: &objects.NetworkEndpoint{
-			Ip:   al.Transaction().HostIP(),
-			Port: int32(al.Transaction().HostPort()),
+func (f ocsfFormatter) finding(tx plugintypes.AuditLogTransaction, md plugintypes.AuditLogMessageData, n int) ocsfEvent {
+	uid := fmt.Sprintf("%s-%d", tx.ID(), n)
+	ruleID := strconv.Itoa(md.ID())
+	title := md.Msg()
+	if title == "" {
+		title = "Rule " + ruleID
+	}
+	severityID := ocsfSeverity(md.Severity())
+
+	info := &ocsfFindingInfo{
+		UID:   uid,
+		Title: title,
+		Desc:  md.Data(),
+		Analytic: &ocsfAnalytic{
+			UID:     ruleID,
+			Name:    title,
+			TypeID:  ocsfAnalyticRule,
+			Type:    "Rule",
+			Version: md.Ver(),
 		},
-		WebResources: f.getAffectedWebResources(al),
+		CreatedTime: tx.UnixTimestamp() / 1e6,
 	}
-
-	userAgent := al.Transaction().Request().Headers()["user-agent"]
-	if len(userAgent) > 0 {
-		webResourcesActivity.HttpRequest.UserAgent = userAgent[0]
+	info.Types, info.Attacks = f.classify(md.Tags())
+
+	return ocsfEvent{
+		ActivityID:   ocsfActivityCreate,
+		ActivityName: "Create",
+		CategoryUID:  ocsfCategoryFindings,
+		CategoryName: "Findings",
+		ClassUID:     ocsfClassDetectionFinding,
+		ClassName:    "Detection Finding",
+		TypeUID:      ocsfClassDetectionFinding*100 + ocsfActivityCreate,
+		TypeName:     "Detection Finding: Create",
+		Time:         tx.UnixTimestamp() / 1e6,
+		SeverityID:   severityID,
+		Severity:     ocsfSeverityNames[severityID],
+		Message:      md.Msg(),
+		FindingInfo:  info,
+		StatusID:     ocsfFindingStatusNew,
+		Status:       "New",
+		Metadata:     f.metadata(tx, uid),
 	}
+}
 
-	// Note: 'referer' is a misspelling of 'referrer' but was incorporated into the HTTP specification with this misspelling
-	// see https://en.wikipedia.org/wiki/HTTP_referer
-	referrer := al.Transaction().Request().Headers()["referer"]
-	if len(referrer) > 0 {
-		webResourcesActivity.HttpRequest.Referrer = referrer[0]
+// classify returns the finding types and the MITRE ATT&CK references of the
+// rule tags. CAPEC tags are kept as CAPEC-<id> types, the OCSF attack object
+// only references MITRE ATT&CK.
+func (ocsfFormatter) classify(tags []string) ([]string, []ocsfAttack) {
+	var (
+		findingTypes []string
+		tactics      []*ocsfMITRE
+		techniques   []ocsfAttack
+	)
+	for _, tag := range tags {
+		if m := ocsfCAPECTag.FindStringSubmatch(tag); m != nil {
+			findingTypes = append(findingTypes, "CAPEC-"+m[1])
+			continue
+		}
+		m := ocsfMITRETag.FindStringSubmatch(tag)
+		if m == nil {
+			findingTypes = append(findingTypes, tag)
+			continue
+		}
+		id := strings.ToUpper(m[1])
+		if strings.HasPrefix(id, "TA") {
+			tactics = append(tactics, &ocsfMITRE{UID: id, SrcURL: "https://attack.mitre.org/tactics/" + id + "/"})
+			continue
+		}
+		technique, sub, isSub := strings.Cut(id, ".")
+		attack := ocsfAttack{Technique: &ocsfMITRE{UID: technique, SrcURL: "https://attack.mitre.org/techniques/" + technique + "/"}}
+		if isSub {
+			attack.SubTechnique = &ocsfMITRE{UID: id, SrcURL: "https://attack.mitre.org/techniques/" + technique + "/" + sub + "/"}
+		}
+		techniques = append(techniques, attack)
 	}
 
-	xForwardedFor := al.Transaction().Request().Headers()["x-forwarded-for"]
-	if len(xForwardedFor) > 0 {
-		webResourcesActivity.HttpRequest.XForwardedFor = xForwardedFor
+	// the techniques are attributed to the first tactic of the rule, the
+	// remaining tactics are referenced on their own
+	var attacks []ocsfAttack
+	for i := range techniques {
+		if len(tactics) > 0 {
+			techniques[i].Tactic = tactics[0]
+		}
+		attacks = append(attacks, techniques[i])
 	}
-
-	if len(al.Messages()) > 0 {
-		message := al.Messages()[0]
-		webResourcesActivity.Message = message.Message()
+	for i, tactic := range tactics {
+		if i == 0 && len(techniques) > 0 {
+			continue
+		}
+		attacks = append(attacks, ocsfAttack{Tactic: tactic})
 	}
+	return findingTypes, attacks
+}
 
-	_, offset := time.Now().Zone()
-	webResourcesActivity.TimezoneOffset = int32(offset)
-
-	// The WebResource Activity Severity ID is not to be confused by the Transaction severity.  The Transaction severity has to do with Coraza error/debug severity,
-	// while WebResource Activity Severity is defined by OCSF to represent the severity of the security event.
-	// For now, we're setting severityID to 'Other' and setting Severity to the Highest severity of the matched rules.
-	// A future update should map/translate rule severity to OCSF severity if possible.
-	highestSeverity, _ := types.ParseRuleSeverity(al.Transaction().HighestSeverity())
-	webResourcesActivity.Severity = highestSeverity.String()
-	webResourcesActivity.SeverityId = enums.WEB_RESOURCES_ACTIVITY_SEVERITY_ID_WEB_RESOURCES_ACTIVITY_SEVERITY_ID_OTHER
-
-	webResourcesActivity.StartTime = al.Transaction().UnixTimestamp()
-	webResourcesActivity.TypeName = "Read"
-
-	webResourcesActivity.Observables = f.getObservables(al)
-
-	// Not implemented
-	// webResourcesActivity.Count = 0
-	// webResourcesActivity.Duration = 0
-	// webResourcesActivity.EndTime = 0
-	// webResourcesActivity.RawData = ""
-	// webResourcesActivity.Status = ""
-	// webResourcesActivity.StatusCode = ""
-	// webResourcesActivity.StatusDetail = ""
-	// webResourcesActivity.StatusId = enums.WEB_RESOURCES_ACTIVITY_STATUS_ID_WEB_RESOURCES_ACTIVITY_STATUS_ID_UNKNOWN
-	// webResourcesActivity.Tls = &objects.Tls{}
-	// webResourcesActivity.WebResourcesResult =
-	// webResourcesActivity.Unmapped = nil
+func (ocsfFormatter) evidenceData(md plugintypes.AuditLogMessageData) map[string]any {
+	data := map[string]any{"rule_id": md.ID()}
+	if md.Data() != "" {
+		data["matched_data"] = md.Data()
+	}
+	if md.Raw() != "" {
+		data["rule"] = md.Raw()
+	}
+	if md.File() != "" {
+		data["file"] = md.File()
+		data["line"] = md.Line()
+	}
+	if md.Rev() != "" {
+		data["rev"] = md.Rev()
+	}
+	if md.Maturity() != 0 {
+		data["maturity"] = md.Maturity()
+	}
+	if md.Accuracy() != 0 {
+		data["accuracy"] = md.Accuracy()
+	}
+	return data
+}
 
-	logJson, _ := json.Marshal(&webResourcesActivity)
+func headerValues(headers map[string][]string, name string) []string {
+	if v, ok := headers[name]; ok {
+		return v
+	}
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return nil
+}
 
-	return logJson, nil
+func firstHeader(headers map[string][]string, name string) string {
+	if v := headerValues(headers, name); len(v) > 0 {
+		return v[0]
+	}
+	return ""
 }
 
 func (ocsfFormatter) MIME() string {
 	return "application/json"
 }
 
+func (ocsfFormatter) eventPerLine() {}
+
 var (
 	_ plugintypes.AuditLogFormatter = (*ocsfFormatter)(nil)
 )
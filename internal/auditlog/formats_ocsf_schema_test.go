@@ -0,0 +1,192 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+
+package auditlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/kaptinlin/jsonschema"
+)
+
+// ocsfSchemaFiles are the JSON schemas of the OCSF classes, vendored verbatim
+// from https://schema.ocsf.io/schema/<ocsfVersion>/classes/<class>.
+var ocsfSchemaFiles = map[int]string{
+	ocsfClassHTTPActivity:     "http_activity.json",
+	ocsfClassDetectionFinding: "detection_finding.json",
+}
+
+// ocsfProfileAttributes are the required attributes added by the profiles.
+// The schemas are exported with every profile applied, these attributes are
+// only required when the event applies their profile.
+var ocsfProfileAttributes = map[string]string{
+	"action_id": "security_control",
+	"cloud":     "cloud",
+}
+
+var ocsfSchemas sync.Map
+
+// loadOCSFSchema compiles the schema of the class for the applied profiles.
+// OCSF places the attributes outside the schema in unmapped, so the objects
+// of the schema are closed to unknown attributes.
+func loadOCSFSchema(t *testing.T, classUID int, profiles []string) *jsonschema.Schema {
+	t.Helper()
+	key := fmt.Sprint(classUID, profiles)
+	if s, ok := ocsfSchemas.Load(key); ok {
+		return s.(*jsonschema.Schema)
+	}
+	name, ok := ocsfSchemaFiles[classUID]
+	if !ok {
+		t.Fatalf("no schema for the class %d", classUID)
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", "ocsf", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	var required []any
+	for _, attr := range schema["required"].([]any) {
+		if p, ok := ocsfProfileAttributes[attr.(string)]; ok && !slices.Contains(profiles, p) {
+			continue
+		}
+		required = append(required, attr)
+	}
+	schema["required"] = required
+	schema["additionalProperties"] = false
+	for _, def := range schema["$defs"].(map[string]any) {
+		if def := def.(map[string]any); def["additionalProperties"] == nil {
+			def["additionalProperties"] = false
+		}
+	}
+
+	if data, err = json.Marshal(schema); err != nil {
+		t.Fatal(err)
+	}
+	s, err := jsonschema.NewCompiler().Compile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ocsfSchemas.Store(key, s)
+	return s
+}
+
+// validateOCSF returns the violations of the schema of its class found in
+// the event.
+func validateOCSF(t *testing.T, event []byte) []string {
+	t.Helper()
+	var e struct {
+		ClassUID   int `json:"class_uid"`
+		ActivityID int `json:"activity_id"`
+		TypeUID    int `json:"type_uid"`
+		Metadata   struct {
+			Version  string   `json:"version"`
+			Profiles []string `json:"profiles"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(event, &e); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ocsfSchemaFiles[e.ClassUID]; !ok {
+		return []string{fmt.Sprintf("class_uid: unknown class %d", e.ClassUID)}
+	}
+
+	res := loadOCSFSchema(t, e.ClassUID, e.Metadata.Profiles).ValidateJSON(event)
+	errs := ocsfSchemaErrors(*res.ToList(), "")
+	// the schemas don't relate the attributes to each other
+	if e.TypeUID != e.ClassUID*100+e.ActivityID {
+		errs = append(errs, fmt.Sprintf("type_uid: %d is not class_uid*100+activity_id", e.TypeUID))
+	}
+	if e.Metadata.Version != ocsfVersion {
+		errs = append(errs, fmt.Sprintf("metadata.version: %q is not %s", e.Metadata.Version, ocsfVersion))
+	}
+	sort.Strings(errs)
+	return errs
+}
+
+// ocsfSchemaErrors returns the errors of the innermost failing evaluations,
+// the outer ones only report that a nested value doesn't match.
+func ocsfSchemaErrors(l jsonschema.List, location string) []string {
+	if l.InstanceLocation != "" {
+		location = l.InstanceLocation
+	}
+	var errs []string
+	for _, d := range l.Details {
+		if !d.Valid {
+			errs = append(errs, ocsfSchemaErrors(d, location)...)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	for _, msg := range l.Errors {
+		errs = append(errs, fmt.Sprintf("%s: %s", location, msg))
+	}
+	return errs
+}
+
+func TestOCSFSchemaValidation(t *testing.T) {
+	valid := map[string]any{
+		"activity_id": 3, "category_uid": 4, "class_uid": 4002, "type_uid": 400203,
+		"time": 1, "severity_id": 1,
+		"src_endpoint":  map[string]any{"ip": "10.0.0.1"},
+		"dst_endpoint":  map[string]any{"ip": "10.0.0.2"},
+		"http_request":  map[string]any{"http_method": "GET"},
+		"http_response": map[string]any{"code": 200},
+		"metadata":      map[string]any{"version": ocsfVersion, "product": map[string]any{"vendor_name": "OWASP"}},
+		"unmapped":      map[string]any{"foo": 1},
+	}
+	validate := func(e map[string]any) []string {
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return validateOCSF(t, data)
+	}
+	if errs := validate(valid); len(errs) != 0 {
+		t.Fatalf("unexpected errors %q", errs)
+	}
+
+	tests := map[string]func(e map[string]any){
+		"unknown attribute": func(e map[string]any) { e["foo"] = 1 },
+		"missing required":  func(e map[string]any) { delete(e, "time") },
+		"wrong type":        func(e map[string]any) { e["time"] = "now" },
+		"not an integer":    func(e map[string]any) { e["time"] = 1.5 },
+		"invalid enum":      func(e map[string]any) { e["severity_id"] = 7 },
+		"wrong type_uid":    func(e map[string]any) { e["type_uid"] = 400206 },
+		"wrong version":     func(e map[string]any) { e["metadata"].(map[string]any)["version"] = "1.0.0" },
+		"nested object": func(e map[string]any) {
+			e["metadata"] = map[string]any{"version": ocsfVersion, "product": map[string]any{"foo": "bar"}}
+		},
+		"expected array": func(e map[string]any) { e["observables"] = map[string]any{} },
+		"unknown class":  func(e map[string]any) { e["class_uid"] = 1 },
+		"profile attribute": func(e map[string]any) {
+			e["metadata"].(map[string]any)["profiles"] = []string{"security_control"}
+		},
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := map[string]any{}
+			for k, v := range valid {
+				e[k] = v
+			}
+			e["metadata"] = map[string]any{"version": ocsfVersion, "product": map[string]any{"vendor_name": "OWASP"}}
+			mutate(e)
+			if errs := validate(e); len(errs) == 0 {
+				t.Error("expected a schema violation")
+			}
+		})
+	}
+}
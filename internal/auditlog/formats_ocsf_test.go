@@ -6,169 +6,396 @@
 package auditlog
 
 import (
+	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
-	"github.com/valllabh/ocsf-schema-golang/ocsf/v1_2_0/events/application"
-	"github.com/valllabh/ocsf-schema-golang/ocsf/v1_2_0/events/application/enums"
-
 	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
 	"github.com/corazawaf/coraza/v3/internal/collections"
 	"github.com/corazawaf/coraza/v3/types"
 	"github.com/corazawaf/coraza/v3/types/variables"
 )
 
-func TestOCSFFormatter(t *testing.T) {
-	for _, al := range createAuditLogs() {
-		f := &ocsfFormatter{}
-		data, err := f.Format(al)
-		if err != nil {
-			t.Error(err)
-		}
-		if !strings.Contains(f.MIME(), "json") {
-			t.Errorf("failed to match MIME, expected json and got %s", f.MIME())
-		}
+var updateOCSFGolden = flag.Bool("update", false, "update the OCSF golden files")
+
+// formatOCSF formats the audit log, one event per line, and validates the
+// events against the OCSF schema.
+func formatOCSF(t *testing.T, al plugintypes.AuditLog) ([][]byte, []ocsfEvent) {
+	t.Helper()
+	data, err := (&ocsfFormatter{}).Format(al)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		var wra application.WebResourcesActivity
-		if err := json.Unmarshal(data, &wra); err != nil {
-			t.Error(err)
+	lines := bytes.Split(data, []byte("\n"))
+	var events []ocsfEvent
+	for i, line := range lines {
+		for _, e := range validateOCSF(t, line) {
+			t.Errorf("event %d: %s", i, e)
+		}
+		var event ocsfEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			t.Fatal(err)
 		}
+		events = append(events, event)
+	}
+	if events[0].ClassUID != ocsfClassHTTPActivity {
+		t.Fatalf("expected an HTTP Activity event first, have %s", data)
+	}
+	return lines, events
+}
 
-		// validate MIME type
+func TestOCSFFormatter(t *testing.T) {
+	for _, al := range createAuditLogs() {
+		f := &ocsfFormatter{}
 		if f.MIME() != "application/json" {
-			t.Errorf("failed to match ocsfFormatter MIME type, \ngot: %s\nexpected: %s", fmt.Sprint(f.MIME()), "application/json")
+			t.Errorf("failed to match ocsfFormatter MIME type, \ngot: %s\nexpected: %s", f.MIME(), "application/json")
 		}
 
-		// validate Unix Timestamp
-		if wra.Time != al.Transaction().UnixTimestamp() {
-			t.Errorf("failed to match audit log Unix Timestamp, \ngot: %s\nexpected: %s", fmt.Sprint(wra.Time), fmt.Sprint(al.Transaction().UnixTimestamp()))
+		_, events := formatOCSF(t, al)
+		activity := events[0]
+
+		// validate timestamp, in milliseconds
+		if activity.Time != al.Transaction().UnixTimestamp()/1e6 {
+			t.Errorf("failed to match audit log Unix Timestamp, \ngot: %d\nexpected: %d", activity.Time, al.Transaction().UnixTimestamp()/1e6)
 		}
 
 		// validate transaction interruption
 		if al.Transaction().IsInterrupted() {
-			if wra.Action != "Denied" {
-				t.Errorf("failed to match audit log Action, \ngot: %s\nexpected: %s", wra.Action, "Denied")
+			if activity.Action != "Denied" || activity.ActionID != ocsfActionDenied {
+				t.Errorf("failed to match audit log Action, \ngot: %d %s\nexpected: Denied", activity.ActionID, activity.Action)
 			}
-
-			if wra.ActionId != enums.WEB_RESOURCES_ACTIVITY_ACTION_ID_WEB_RESOURCES_ACTIVITY_ACTION_ID_DENIED {
-				t.Errorf("failed to match audit log Action ID, \ngot: %s\nexpected: %s", wra.ActionId, enums.WEB_RESOURCES_ACTIVITY_ACTION_ID_WEB_RESOURCES_ACTIVITY_ACTION_ID_DENIED)
-			}
-		} else {
-			if wra.Action != "Allowed" {
-				t.Errorf("failed to match audit log Action, \ngot: %s\nexpected: %s", wra.Action, "Allowed")
-			}
-
-			if wra.ActionId != enums.WEB_RESOURCES_ACTIVITY_ACTION_ID_WEB_RESOURCES_ACTIVITY_ACTION_ID_ALLOWED {
-				t.Errorf("failed to match audit log Action ID, \ngot: %s\nexpected: %s", wra.ActionId, enums.WEB_RESOURCES_ACTIVITY_ACTION_ID_WEB_RESOURCES_ACTIVITY_ACTION_ID_ALLOWED)
+			if activity.Disposition != "Blocked" {
+				t.Errorf("failed to match audit log Disposition, \ngot: %s\nexpected: Blocked", activity.Disposition)
 			}
+		} else if activity.Action != "Allowed" || activity.ActionID != ocsfActionAllowed {
+			t.Errorf("failed to match audit log Action, \ngot: %d %s\nexpected: Allowed", activity.ActionID, activity.Action)
 		}
 
 		// validate Server ID
-		for _, observable := range wra.Observables {
-			if observable.Name == "ServerID" {
-				if observable.Value != al.Transaction().ServerID() {
-					t.Errorf("failed to match audit log Server ID, \ngot: %s\nexpected: %s", observable.Value, al.Transaction().ServerID())
-				}
+		if serverID := al.Transaction().ServerID(); serverID != "" {
+			if activity.DstEndpoint == nil || activity.DstEndpoint.Hostname != serverID {
+				t.Errorf("failed to match audit log Server ID, \ngot: %v\nexpected: %s", activity.DstEndpoint, serverID)
 			}
 		}
 
 		// validate transaction requests
 		if al.Transaction().HasRequest() {
+			req := al.Transaction().Request()
 			// validate Request URI
-			if wra.HttpRequest.Url.UrlString != al.Transaction().Request().URI() {
-				t.Errorf("failed to match audit log URI, \ngot: %s\nexpected: %s", wra.HttpRequest.Url.UrlString, al.Transaction().Request().URI())
+			if uri := req.URI(); uri != "" && (activity.HTTPRequest.URL == nil || activity.HTTPRequest.URL.URLString != uri) {
+				t.Errorf("failed to match audit log URI, \ngot: %v\nexpected: %s", activity.HTTPRequest.URL, uri)
 			}
 			// validate Request Method
-			if wra.HttpRequest.HttpMethod != al.Transaction().Request().Method() {
-				t.Errorf("failed to match audit log HTTP Request Method, \ngot: %s\nexpected: %s", wra.HttpRequest.HttpMethod, al.Transaction().Request().Method())
+			if activity.HTTPRequest.HTTPMethod != req.Method() {
+				t.Errorf("failed to match audit log HTTP Request Method, \ngot: %s\nexpected: %s", activity.HTTPRequest.HTTPMethod, req.Method())
 			}
 			// validate Request Headers
-			for _, header := range wra.HttpRequest.HttpHeaders {
-				if header.Value != al.Transaction().Request().Headers()[header.Name][0] {
-					t.Errorf("failed to match audit log Request Header, \ngot: %s\nexpected: %s", header.Value, al.Transaction().Request().Headers()[header.Name][0])
+			for _, header := range activity.HTTPRequest.HTTPHeaders {
+				if header.Value != req.Headers()[header.Name][0] {
+					t.Errorf("failed to match audit log Request Header, \ngot: %s\nexpected: %s", header.Value, req.Headers()[header.Name][0])
 				}
 			}
 			// validate Request Files
-			for _, file := range al.Transaction().Request().Files() {
-				for _, observable := range wra.Observables {
-					if observable.Name == file.Name() {
-						if observable.Type == "File Name" {
-							if file.Name() != observable.Value {
-								t.Errorf("failed to match audit log Request File Name, \ngot: %s\nexpected: %s", observable.Value, file.Name())
-							}
+			for _, file := range req.Files() {
+				for _, observable := range activity.Observables {
+					if observable.Name != file.Name() {
+						continue
+					}
+					switch observable.Type {
+					case "File Name":
+						if file.Name() != observable.Value {
+							t.Errorf("failed to match audit log Request File Name, \ngot: %s\nexpected: %s", observable.Value, file.Name())
 						}
-						if observable.Type == "Mime" {
-							if file.Mime() != observable.Value {
-								t.Errorf("failed to match audit log Request File Mime, \ngot: %s\nexpected: %s", observable.Value, file.Mime())
-							}
+					case "Mime":
+						if file.Mime() != observable.Value {
+							t.Errorf("failed to match audit log Request File Mime, \ngot: %s\nexpected: %s", observable.Value, file.Mime())
 						}
-						if observable.Type == "Size" {
-							if fmt.Sprint(file.Size()) != observable.Value {
-								t.Errorf("failed to match audit log Request File Size, \ngot: %s\nexpected: %s", observable.Value, fmt.Sprint(file.Size()))
-							}
+					case "Size":
+						if fmt.Sprint(file.Size()) != observable.Value {
+							t.Errorf("failed to match audit log Request File Size, \ngot: %s\nexpected: %d", observable.Value, file.Size())
 						}
 					}
 				}
 			}
 
 			// validate Request Protocol
-			if wra.HttpRequest.Version != al.Transaction().Request().Protocol() {
-				t.Errorf("failed to match audit log HTTP Request Protocol, \ngot: %s\nexpected: %s", wra.HttpRequest.Version, al.Transaction().Request().Protocol())
+			if want := strings.TrimPrefix(req.Protocol(), "HTTP/"); activity.HTTPRequest.Version != want {
+				t.Errorf("failed to match audit log HTTP Request Protocol, \ngot: %s\nexpected: %s", activity.HTTPRequest.Version, want)
 			}
 
 			// validate Request Arguments
-			if al.Transaction().Request().Args() != nil {
-				for _, arg := range al.Transaction().Request().Args().FindAll() {
-					if strings.Contains(wra.HttpRequest.Args, fmt.Sprintf("%s=%s", arg.Key(), arg.Value())) == false {
-						t.Errorf("failed to match audit log Request arguments, \n%s not found in: %s", fmt.Sprintf("%s=%s", arg.Key(), arg.Value()), wra.HttpRequest.Args)
+			if req.Args() != nil {
+				for _, arg := range req.Args().FindAll() {
+					if !strings.Contains(activity.HTTPRequest.Args, fmt.Sprintf("%s=%s", arg.Key(), arg.Value())) {
+						t.Errorf("failed to match audit log Request arguments, \n%s=%s not found in: %s", arg.Key(), arg.Value(), activity.HTTPRequest.Args)
 					}
 				}
 			}
 
 			// validate Request UID
-			if wra.HttpRequest.Uid != al.Transaction().ID() {
-				t.Errorf("failed to match audit log HTTP Request UID, \ngot: %s\nexpected: %s", wra.HttpRequest.Uid, al.Transaction().ID())
+			if activity.HTTPRequest.UID != al.Transaction().ID() {
+				t.Errorf("failed to match audit log HTTP Request UID, \ngot: %s\nexpected: %s", activity.HTTPRequest.UID, al.Transaction().ID())
 			}
 
 			// validate Request Length
-			if wra.HttpRequest.Length != al.Transaction().Request().Length() {
-				t.Errorf("failed to match audit log HTTP Request Length, \ngot: %d\nexpected: %d", wra.HttpRequest.Length, al.Transaction().Request().Length())
+			if activity.HTTPRequest.Length != req.Length() {
+				t.Errorf("failed to match audit log HTTP Request Length, \ngot: %d\nexpected: %d", activity.HTTPRequest.Length, req.Length())
 			}
 		}
 
 		if al.Transaction().HasResponse() {
+			res := al.Transaction().Response()
 			// validate Response Status
-			if int(wra.HttpResponse.Code) != al.Transaction().Response().Status() {
-				t.Errorf("failed to match audit log HTTP Response Status, \ngot: %s\nexpected: %s", fmt.Sprint(wra.HttpResponse.Code), fmt.Sprint(al.Transaction().Response().Status()))
+			if activity.HTTPResponse.Code != res.Status() {
+				t.Errorf("failed to match audit log HTTP Response Status, \ngot: %d\nexpected: %d", activity.HTTPResponse.Code, res.Status())
 			}
 
 			// validate Response Headers
-			for _, header := range wra.HttpResponse.HttpHeaders {
-				if header.Value != al.Transaction().Response().Headers()[header.Name][0] {
-					t.Errorf("failed to match audit log Response Header, \ngot: %s\nexpected: %s", header.Value, al.Transaction().Response().Headers()[header.Name][0])
+			for _, header := range activity.HTTPResponse.HTTPHeaders {
+				if header.Value != res.Headers()[header.Name][0] {
+					t.Errorf("failed to match audit log Response Header, \ngot: %s\nexpected: %s", header.Value, res.Headers()[header.Name][0])
 				}
 			}
+		} else if activity.HTTPResponse == nil || activity.HTTPResponse.Code != 0 {
+			t.Errorf("expected an unknown http response, have %+v", activity.HTTPResponse)
 		}
 
-		// validate Enrichments (Rule Matches)
-		if wra.Enrichments[0].Name != al.Messages()[0].Data().Msg() {
-			t.Errorf("failed to match audit log data, \ngot: %s\nexpected: %s", wra.Enrichments[0].Name, al.Messages()[0].Data().Msg())
+		// validate the findings (rule matches)
+		if len(events) != len(al.Messages())+1 {
+			t.Fatalf("expected a finding per message, have %d events", len(events))
+		}
+		if activity.Message != al.Messages()[0].Message() {
+			t.Errorf("failed to match audit log message, \ngot: %s\nexpected: %s", activity.Message, al.Messages()[0].Message())
+		}
+		finding := events[1]
+		if finding.FindingInfo.Title != al.Messages()[0].Data().Msg() {
+			t.Errorf("failed to match audit log data, \ngot: %s\nexpected: %s", finding.FindingInfo.Title, al.Messages()[0].Data().Msg())
 		}
+		if finding.Metadata.CorrelationUID != al.Transaction().ID() {
+			t.Errorf("failed to match the finding correlation UID, \ngot: %s\nexpected: %s", finding.Metadata.CorrelationUID, al.Transaction().ID())
+		}
+	}
+}
 
-		// validate Schema
-		// ocsf-schema-golang appears to have a bug and is not validating against the OCSF 1.2 Schema.
-		// It would be nice to include this validation as part of the test suite, but for now it must be disabled until this bug is fixed.
-		// if ocsfvalidate_1_2.Validate("web_resources_activity", data) != nil {
-		// 	t.Errorf("failed to validate audit log schema, \ngot: %s\nexpected: %s", ocsfvalidate_1_2.Validate("web_resources_activity", data), "")
-		// }
+func TestOCSFFormatterGolden(t *testing.T) {
+	for name, al := range ocsfGoldenAuditLogs() {
+		t.Run(name, func(t *testing.T) {
+			lines, _ := formatOCSF(t, al)
+			var have bytes.Buffer
+			for _, line := range lines {
+				if err := json.Indent(&have, line, "", "  "); err != nil {
+					t.Fatal(err)
+				}
+				have.WriteByte('\n')
+			}
+
+			golden := filepath.Join("testdata", "ocsf", name+".json")
+			if *updateOCSFGolden {
+				if err := os.WriteFile(golden, have.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(have.Bytes(), want) {
+				t.Errorf("unexpected OCSF events, run the tests with -update to regenerate %s\nhave:\n%s\nwant:\n%s", golden, have.Bytes(), want)
+			}
+		})
 	}
 }
 
-func TestOCSFFormatterPartJ(t *testing.T) {
-	f := &ocsfFormatter{}
+func ocsfGoldenAuditLogs() map[string]*Log {
+	request := func(method, uri string, headers map[string][]string) *TransactionRequest {
+		return &TransactionRequest{
+			Method_:   method,
+			URI_:      uri,
+			Protocol_: "HTTP/1.1",
+			Headers_:  headers,
+		}
+	}
+	crsTags := []string{
+		"application-multi",
+		"language-multi",
+		"platform-multi",
+		"attack-sqli",
+		"paranoia-level/1",
+		"OWASP_CRS",
+		"capec/1000/152/248/66",
+		"mitre/TA0001",
+		"mitre/T1190",
+	}
+
+	return map[string]*Log{
+		"blocked_sqli": {
+			Transaction_: Transaction{
+				UnixTimestamp_: 1136239460000000000,
+				ID_:            "sqli-1",
+				ClientIP_:      "192.0.2.10",
+				ClientPort_:    51234,
+				HostIP_:        "198.51.100.1",
+				HostPort_:      443,
+				ServerID_:      "shop.example.com",
+				IsInterrupted_: true,
+				Request_: request("GET", "/search?q=1%27+OR+1%3D1", map[string][]string{
+					"host":            {"shop.example.com"},
+					"user-agent":      {"curl/8.0"},
+					"x-forwarded-for": {"203.0.113.7, 10.0.0.1"},
+				}),
+				Response_: &TransactionResponse{
+					Status_:  403,
+					Headers_: map[string][]string{"content-type": {"text/html"}},
+				},
+				Producer_: &TransactionProducer{Connector_: "coraza-caddy", Version_: "2.0.0"},
+			},
+			Messages_: []plugintypes.AuditLogMessage{
+				&Message{
+					Message_: "SQL Injection Attack Detected via libinjection",
+					Data_: &MessageData{
+						File_:     "REQUEST-942-APPLICATION-ATTACK-SQLI.conf",
+						Line_:     46,
+						ID_:       942100,
+						Rev_:      "",
+						Msg_:      "SQL Injection Attack Detected via libinjection",
+						Data_:     "Matched Data: s&1c found within ARGS:q: 1' OR 1=1",
+						Severity_: types.RuleSeverityCritical,
+						Ver_:      "OWASP_CRS/4.0.0",
+						Tags_:     crsTags,
+						Raw_:      `SecRule ARGS "@detectSQLi" "id:942100,phase:2,block"`,
+					},
+				},
+				&Message{
+					Message_: "Inbound Anomaly Score Exceeded (Total Score: 5)",
+					Data_: &MessageData{
+						ID_:       949110,
+						Msg_:      "Inbound Anomaly Score Exceeded (Total Score: 5)",
+						Severity_: types.RuleSeverityEmergency,
+						Ver_:      "OWASP_CRS/4.0.0",
+						Tags_:     []string{"anomaly-evaluation", "attack.t1059.001"},
+					},
+				},
+				// messages without data, such as errors, are not findings
+				&Message{Message_: "Access denied with code 403"},
+			},
+		},
+		"allowed": {
+			Transaction_: Transaction{
+				UnixTimestamp_: 1136239460000000000,
+				ID_:            "allowed-1",
+				ClientIP_:      "192.0.2.20",
+				ClientPort_:    40000,
+				Request_: request("PUT", "https://api.example.com:8443/items/1", map[string][]string{
+					"referer": {"https://example.com/"},
+				}),
+				Response_: &TransactionResponse{Status_: 204},
+			},
+		},
+		"parts_d_g": {
+			Parts_: []types.AuditLogPart{
+				types.AuditLogPartIntermediaryResponseHeaders,
+				types.AuditLogPartResponseBody,
+			},
+			Transaction_: Transaction{
+				UnixTimestamp_: 1136239460000000000,
+				ID_:            "dg-1",
+				Request_:       request("PURGE", "/cache", nil),
+				Response_: &TransactionResponse{
+					Status_:              200,
+					Headers_:             map[string][]string{"content-type": {"text/html"}},
+					IntermediaryHeaders_: map[string][]string{"server": {"nginx"}},
+					FinalBody_:           "<html></html>",
+				},
+			},
+		},
+	}
+}
+
+func TestOCSFSeverity(t *testing.T) {
+	tests := map[types.RuleSeverity]int{
+		types.RuleSeverityEmergency: 6,
+		types.RuleSeverityAlert:     5,
+		types.RuleSeverityCritical:  4,
+		types.RuleSeverityError:     3,
+		types.RuleSeverityWarning:   2,
+		types.RuleSeverityNotice:    2,
+		types.RuleSeverityInfo:      1,
+		types.RuleSeverityDebug:     1,
+		types.RuleSeverityUnset:     0,
+	}
+	for severity, want := range tests {
+		if have := ocsfSeverity(severity); have != want {
+			t.Errorf("unexpected OCSF severity for %s, want %d, have %d", severity, want, have)
+		}
+	}
+}
+
+func TestOCSFClassify(t *testing.T) {
+	tactic := &ocsfMITRE{UID: "TA0001", SrcURL: "https://attack.mitre.org/tactics/TA0001/"}
+	tests := []struct {
+		name    string
+		tags    []string
+		types   []string
+		attacks []ocsfAttack
+	}{
+		{
+			name:  "plain tags",
+			tags:  []string{"attack-sqli", "paranoia-level/1"},
+			types: []string{"attack-sqli", "paranoia-level/1"},
+		},
+		{
+			name:  "capec",
+			tags:  []string{"capec/1000/152/248/66", "CAPEC/272"},
+			types: []string{"CAPEC-66", "CAPEC-272"},
+		},
+		{
+			name: "technique and tactic",
+			tags: []string{"mitre/T1190", "mitre:ta0001"},
+			attacks: []ocsfAttack{{
+				Tactic:    tactic,
+				Technique: &ocsfMITRE{UID: "T1190", SrcURL: "https://attack.mitre.org/techniques/T1190/"},
+			}},
+		},
+		{
+			name: "sub technique",
+			tags: []string{"attack.t1059.001"},
+			attacks: []ocsfAttack{{
+				Technique:    &ocsfMITRE{UID: "T1059", SrcURL: "https://attack.mitre.org/techniques/T1059/"},
+				SubTechnique: &ocsfMITRE{UID: "T1059.001", SrcURL: "https://attack.mitre.org/techniques/T1059/001/"},
+			}},
+		},
+		{
+			name:    "tactic only",
+			tags:    []string{"mitre-TA0001"},
+			attacks: []ocsfAttack{{Tactic: tactic}},
+		},
+		{
+			name:  "not mitre",
+			tags:  []string{"mitre/T19", "attack-xss"},
+			types: []string{"mitre/T19", "attack-xss"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findingTypes, attacks := ocsfFormatter{}.classify(tt.tags)
+			if !reflect.DeepEqual(findingTypes, tt.types) {
+				t.Errorf("unexpected types, want %q, have %q", tt.types, findingTypes)
+			}
+			if !reflect.DeepEqual(attacks, tt.attacks) {
+				t.Errorf("unexpected attacks, want %+v, have %+v", tt.attacks, attacks)
+			}
+		})
+	}
+}
 
+func TestOCSFFormatterPartJ(t *testing.T) {
 	t.Run("uploaded files appear as observables", func(t *testing.T) {
 		al := &Log{
 			Parts_: []types.AuditLogPart{
@@ -198,15 +425,7 @@ func TestOCSFFormatterPartJ(t *testing.T) {
 			},
 		}
 
-		data, err := f.Format(al)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		var wra application.WebResourcesActivity
-		if err := json.Unmarshal(data, &wra); err != nil {
-			t.Fatal(err)
-		}
+		_, events := formatOCSF(t, al)
 
 		// Verify each file produces 3 observables: File Name, Size, Mime
 		expectedFiles := map[string]struct {
@@ -217,34 +436,36 @@ func TestOCSFFormatterPartJ(t *testing.T) {
 			"photo.jpg":  {size: "98765", mime: "image/jpeg"},
 		}
 
-		for name, expected := range expectedFiles {
-			// Count occurrences per observable type to detect both missing and duplicate entries
-			counts := map[string]int{"File Name": 0, "Size": 0, "Mime": 0}
-			for _, obs := range wra.Observables {
-				if obs.Name != name {
-					continue
-				}
-				switch obs.Type {
-				case "File Name":
-					counts["File Name"]++
-					if obs.Value != name {
-						t.Errorf("file %s: expected File Name observable value %s, got %s", name, name, obs.Value)
-					}
-				case "Size":
-					counts["Size"]++
-					if obs.Value != expected.size {
-						t.Errorf("file %s: expected Size observable value %s, got %s", name, expected.size, obs.Value)
+		for _, event := range events {
+			for name, expected := range expectedFiles {
+				// Count occurrences per observable type to detect both missing and duplicate entries
+				counts := map[string]int{"File Name": 0, "Size": 0, "Mime": 0}
+				for _, obs := range event.Observables {
+					if obs.Name != name {
+						continue
 					}
-				case "Mime":
-					counts["Mime"]++
-					if obs.Value != expected.mime {
-						t.Errorf("file %s: expected Mime observable value %s, got %s", name, expected.mime, obs.Value)
+					switch obs.Type {
+					case "File Name":
+						counts["File Name"]++
+						if obs.Value != name {
+							t.Errorf("file %s: expected File Name observable value %s, got %s", name, name, obs.Value)
+						}
+					case "Size":
+						counts["Size"]++
+						if obs.Value != expected.size {
+							t.Errorf("file %s: expected Size observable value %s, got %s", name, expected.size, obs.Value)
+						}
+					case "Mime":
+						counts["Mime"]++
+						if obs.Value != expected.mime {
+							t.Errorf("file %s: expected Mime observable value %s, got %s", name, expected.mime, obs.Value)
+						}
 					}
 				}
-			}
-			for obsType, count := range counts {
-				if count != 1 {
-					t.Errorf("file %s: expected exactly 1 %s observable, got %d", name, obsType, count)
+				for obsType, count := range counts {
+					if count != 1 {
+						t.Errorf("file %s: expected exactly 1 %s observable, got %d", name, obsType, count)
+					}
 				}
 			}
 		}
@@ -274,17 +495,8 @@ func TestOCSFFormatterPartJ(t *testing.T) {
 			},
 		}
 
-		data, err := f.Format(al)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		var wra application.WebResourcesActivity
-		if err := json.Unmarshal(data, &wra); err != nil {
-			t.Fatal(err)
-		}
-
-		for _, obs := range wra.Observables {
+		_, events := formatOCSF(t, al)
+		for _, obs := range events[0].Observables {
 			if obs.Type == "File Name" || obs.Type == "Size" || obs.Type == "Mime" {
 				t.Errorf("unexpected file observable found: %s = %s", obs.Type, obs.Value)
 			}
@@ -538,3 +750,32 @@ func createAuditLogs() []*Log {
 
 	return transactionLogs
 }
+
+func TestOCSFFormatterPartsDG(t *testing.T) {
+	al := &Log{
+		Parts_: []types.AuditLogPart{
+			types.AuditLogPartIntermediaryResponseHeaders,
+			types.AuditLogPartResponseBody,
+		},
+		Transaction_: Transaction{
+			Request_: &TransactionRequest{URI_: "/"},
+			Response_: &TransactionResponse{
+				Status_:              200,
+				IntermediaryHeaders_: map[string][]string{"server": {"nginx"}},
+				FinalBody_:           "<html></html>",
+			},
+		},
+	}
+	_, events := formatOCSF(t, al)
+	activity := events[0]
+
+	if activity.HTTPResponse.Code != 200 || activity.HTTPResponse.Length != int32(len("<html></html>")) {
+		t.Errorf("unexpected http response %+v", activity.HTTPResponse)
+	}
+	if h, _ := activity.Unmapped["intermediary_response_headers"].(map[string]any); h == nil || fmt.Sprint(h["server"]) != "[nginx]" {
+		t.Errorf("unexpected intermediary headers %v", activity.Unmapped["intermediary_response_headers"])
+	}
+	if body := activity.Unmapped["final_response_body"]; body != "<html></html>" {
+		t.Errorf("unexpected response body %q", body)
+	}
+}
@@ -627,3 +627,73 @@ func createAuditLog() *Log {
 		},
 	}
 }
+
+func TestNativeFormatterPartsDG(t *testing.T) {
+	f := &nativeFormatter{}
+	al := &Log{
+		Parts_: []types.AuditLogPart{
+			types.AuditLogPartIntermediaryResponseHeaders,
+			types.AuditLogPartResponseHeaders,
+			types.AuditLogPartResponseBody,
+		},
+		Transaction_: Transaction{
+			Response_: &TransactionResponse{
+				Status_:              200,
+				Protocol_:            "HTTP/1.1",
+				IntermediaryHeaders_: map[string][]string{"server": {"nginx"}},
+				Headers_:             map[string][]string{"x-added": {"later"}},
+				FinalBody_:           "<html></html>",
+			},
+		},
+	}
+	data, err := f.Format(al)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	separator := lines[0]
+
+	checkLine(t, lines, 0, mutateSeparator(separator, 'D'))
+	checkLine(t, lines, 1, "HTTP/1.1 200 OK")
+	checkLine(t, lines, 2, "server: nginx")
+	checkLine(t, lines, 3, "")
+	checkLine(t, lines, 4, mutateSeparator(separator, 'F'))
+	checkLine(t, lines, 5, "HTTP/1.1 200 OK")
+	checkLine(t, lines, 6, "x-added: later")
+	checkLine(t, lines, 7, "")
+	checkLine(t, lines, 8, mutateSeparator(separator, 'G'))
+	checkLine(t, lines, 9, "<html></html>")
+	checkLine(t, lines, 10, "")
+}
+
+func TestNativeFormatterRulesPerformance(t *testing.T) {
+	f := &nativeFormatter{}
+	al := &Log{
+		Parts_: []types.AuditLogPart{types.AuditLogPartAuditLogTrailer},
+		Transaction_: Transaction{
+			Producer_: &TransactionProducer{
+				RulesPerformance_: map[int]int64{942100: 1200, 920350: 350, 930120: 1200},
+			},
+		},
+	}
+	data, err := f.Format(al)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	separator := lines[0]
+
+	checkLine(t, lines, 0, mutateSeparator(separator, 'H'))
+	checkLine(t, lines, 1, `Rules-Performance-Info: "930120=1200", "942100=1200", "920350=350"`)
+	checkLine(t, lines, 2, "")
+}
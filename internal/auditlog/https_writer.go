@@ -25,6 +25,7 @@ type httpsWriter struct {
 }
 
 func (h *httpsWriter) Init(c plugintypes.AuditLogConfig) error {
+	h.Closer = NoopCloser
 	h.formatter = c.Formatter
 	h.url = c.Target
 	// now we validate h.url is a valid url
@@ -40,11 +41,21 @@ func (h *httpsWriter) Init(c plugintypes.AuditLogConfig) error {
 }
 
 func (h *httpsWriter) Write(al plugintypes.AuditLog) error {
-	body, err := h.formatter.Format(al)
+	payload, err := h.formatter.Format(al)
 	if err != nil {
 		return err
 	}
 
+	for _, body := range records(h.formatter, payload) {
+		if err := h.post(body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// post sends a record to the target URL.
+func (h *httpsWriter) post(body []byte) error {
 	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
 	if err != nil {
 		return err
@@ -7,6 +7,7 @@ package auditlog
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -100,6 +101,7 @@ func TestJSONAuditHTTP(t *testing.T) {
 func TestOCSFAuditHTTP(t *testing.T) {
 	writer := &httpsWriter{}
 	formatter := &ocsfFormatter{}
+	var events []map[string]any
 	// we create a test http server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -109,6 +111,12 @@ func TestOCSFAuditHTTP(t *testing.T) {
 		if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
 			t.Fatalf("Content-Type is not application/json, got %s", ct)
 		}
+		// every event is sent as a JSON object
+		var event map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
 	}))
 	defer server.Close()
 	if err := writer.Init(plugintypes.AuditLogConfig{
@@ -120,4 +128,41 @@ func TestOCSFAuditHTTP(t *testing.T) {
 	if err := writer.Write(sampleHttpsAuditLog); err != nil {
 		t.Fatal(err)
 	}
+	if len(events) != 2 || events[0]["class_uid"] != float64(ocsfClassHTTPActivity) || events[1]["class_uid"] != float64(ocsfClassDetectionFinding) {
+		t.Errorf("unexpected events %v", events)
+	}
+}
+
+func TestSIEMAuditHTTP(t *testing.T) {
+	writer := &httpsWriter{}
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+	if err := writer.Init(plugintypes.AuditLogConfig{
+		Target:    server.URL,
+		Formatter: &cefFormatter{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Write(createSIEMAuditLog()); err != nil {
+		t.Fatal(err)
+	}
+	// the transactions without matched rules are not sent
+	if err := writer.Write(&Log{Transaction_: Transaction{ID_: "none"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("expected a request per event, have %q", bodies)
+	}
+	for i, id := range []string{"942100", "100"} {
+		if !strings.HasPrefix(bodies[i], "CEF:0|OWASP|Coraza|1.2.3|"+id+"|") || strings.Contains(bodies[i], "\n") {
+			t.Errorf("unexpected event %q", bodies[i])
+		}
+	}
 }
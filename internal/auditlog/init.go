@@ -20,9 +20,17 @@ func init() {
 	RegisterWriter("syslog", func() plugintypes.AuditLogWriter {
 		return NewSyslogWriter()
 	})
+	RegisterWriter("socket", func() plugintypes.AuditLogWriter {
+		return newSocketWriter()
+	})
+	RegisterWriter("kafka", func() plugintypes.AuditLogWriter {
+		return newKafkaWriter()
+	})
 
 	RegisterFormatter("json", &jsonFormatter{})
 	RegisterFormatter("jsonlegacy", &legacyJSONFormatter{})
 	RegisterFormatter("native", &nativeFormatter{})
 	RegisterFormatter("ocsf", &ocsfFormatter{})
+	RegisterFormatter("cef", &cefFormatter{})
+	RegisterFormatter("leef", &leefFormatter{})
 }
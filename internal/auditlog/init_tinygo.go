@@ -20,9 +20,17 @@ func init() {
 	RegisterWriter("syslog", func() plugintypes.AuditLogWriter {
 		return noopWriter{}
 	})
+	RegisterWriter("socket", func() plugintypes.AuditLogWriter {
+		return noopWriter{}
+	})
+	RegisterWriter("kafka", func() plugintypes.AuditLogWriter {
+		return noopWriter{}
+	})
 
 	RegisterFormatter("json", &jsonFormatter{})
 	RegisterFormatter("jsonlegacy", &legacyJSONFormatter{})
 	RegisterFormatter("native", &nativeFormatter{})
-	RegisterFormatter("ocsf", &noopFormatter{})
+	RegisterFormatter("ocsf", &ocsfFormatter{})
+	RegisterFormatter("cef", &cefFormatter{})
+	RegisterFormatter("leef", &leefFormatter{})
 }
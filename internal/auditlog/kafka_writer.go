@@ -0,0 +1,483 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+
+package auditlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+)
+
+const (
+	kafkaAPIKeyProduce  = 0
+	kafkaAPIKeyMetadata = 3
+	// kafkaProduceVersion is the first version of the produce request using
+	// v2 record batches
+	kafkaProduceVersion = 3
+	// kafkaMaxResponseSize bounds the responses read from the brokers
+	kafkaMaxResponseSize = 16 << 20
+)
+
+var kafkaCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaWriter produces the audit logs to a Kafka topic, keyed by transaction
+// ID. The target is an URL like kafka://broker1:9092,broker2:9092/topic with
+// the optional query parameters:
+//
+//   - partition: partition the records are produced to, 0 by default.
+//   - acks: 0, 1 or -1 (all), 1 by default.
+//   - client_id: client ID sent to the brokers, coraza by default.
+//   - timeout: dial, write and produce timeout, 5s by default.
+//
+// Every OCSF, CEF and LEEF event is produced as a separate record. The leader
+// of the partition is looked up in the brokers listed in the target, and
+// looked up again after any failure. Batches written by the async writer are
+// produced as a single record batch.
+type kafkaWriter struct {
+	formatter plugintypes.AuditLogFormatter
+	brokers   []string
+	topic     string
+	partition int32
+	acks      int16
+	clientID  string
+	timeout   time.Duration
+	dial      func(network, address string, timeout time.Duration) (net.Conn, error)
+
+	mu            sync.Mutex
+	conn          net.Conn
+	reader        *bufio.Reader
+	correlationID int32
+}
+
+func newKafkaWriter() *kafkaWriter {
+	return &kafkaWriter{dial: net.DialTimeout}
+}
+
+func (k *kafkaWriter) Init(c plugintypes.AuditLogConfig) error {
+	u, err := url.Parse(c.Target)
+	if err != nil {
+		return fmt.Errorf("invalid kafka audit log target: %w", err)
+	}
+	if u.Scheme != "kafka" {
+		return fmt.Errorf("unsupported kafka audit log target %q, expected kafka URL", c.Target)
+	}
+	for _, b := range strings.Split(u.Host, ",") {
+		if b != "" {
+			k.brokers = append(k.brokers, b)
+		}
+	}
+	k.topic = strings.Trim(u.Path, "/")
+	if len(k.brokers) == 0 || k.topic == "" {
+		return fmt.Errorf("missing brokers or topic in kafka audit log target %q", c.Target)
+	}
+
+	q := u.Query()
+	k.acks = 1
+	if v := q.Get("acks"); v != "" {
+		switch v {
+		case "0", "1", "-1":
+			acks, _ := strconv.Atoi(v)
+			k.acks = int16(acks)
+		case "all":
+			k.acks = -1
+		default:
+			return fmt.Errorf("invalid kafka audit log acks %q", v)
+		}
+	}
+	if v := q.Get("partition"); v != "" {
+		p, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || p < 0 {
+			return fmt.Errorf("invalid kafka audit log partition %q", v)
+		}
+		k.partition = int32(p)
+	}
+	k.clientID = "coraza"
+	if v := q.Get("client_id"); v != "" {
+		k.clientID = v
+	}
+	k.timeout = 5 * time.Second
+	if v := q.Get("timeout"); v != "" {
+		if k.timeout, err = time.ParseDuration(v); err != nil {
+			return fmt.Errorf("invalid kafka audit log timeout: %w", err)
+		}
+	}
+	k.formatter = c.Formatter
+	return nil
+}
+
+func (k *kafkaWriter) Write(al plugintypes.AuditLog) error {
+	return k.WriteBatch([]plugintypes.AuditLog{al})
+}
+
+// WriteBatch produces the audit logs as a single record batch.
+func (k *kafkaWriter) WriteBatch(logs []plugintypes.AuditLog) error {
+	if k.formatter == nil {
+		return nil
+	}
+	batch := make([]kafkaRecord, 0, len(logs))
+	for _, al := range logs {
+		bts, err := k.formatter.Format(al)
+		if err != nil {
+			return err
+		}
+		key := []byte(al.Transaction().ID())
+		for _, record := range records(k.formatter, bts) {
+			batch = append(batch, kafkaRecord{key: key, value: record})
+		}
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.produce(batch); err != nil {
+		k.discard()
+		return fmt.Errorf("kafka audit log produce failure: %w", err)
+	}
+	return nil
+}
+
+func (k *kafkaWriter) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.conn == nil {
+		return nil
+	}
+	err := k.conn.Close()
+	k.conn = nil
+	return err
+}
+
+func (k *kafkaWriter) discard() {
+	if k.conn != nil {
+		_ = k.conn.Close()
+		k.conn = nil
+	}
+}
+
+func (k *kafkaWriter) produce(records []kafkaRecord) error {
+	if k.conn == nil {
+		if err := k.connectLeader(); err != nil {
+			return err
+		}
+	}
+
+	var body kafkaEncoder
+	body.putString16(nil) // transactional_id
+	body.putInt16(k.acks)
+	body.putInt32(int32(k.timeout / time.Millisecond))
+	body.putInt32(1) // topics
+	body.putString(k.topic)
+	body.putInt32(1) // partitions
+	body.putInt32(k.partition)
+	batch := encodeKafkaRecordBatch(records, time.Now())
+	body.putInt32(int32(len(batch)))
+	body.buf = append(body.buf, batch...)
+
+	if err := k.send(kafkaAPIKeyProduce, kafkaProduceVersion, body.buf); err != nil {
+		return err
+	}
+	if k.acks == 0 {
+		// the brokers don't respond to produce requests without acks
+		return nil
+	}
+
+	res, err := k.receive()
+	if err != nil {
+		return err
+	}
+	d := kafkaDecoder{buf: res}
+	for topics := d.int32(); topics > 0 && d.err == nil; topics-- {
+		d.string()
+		for partitions := d.int32(); partitions > 0 && d.err == nil; partitions-- {
+			partition := d.int32()
+			code := d.int16()
+			d.int64() // base_offset
+			d.int64() // log_append_time_ms
+			if d.err == nil && partition == k.partition && code != 0 {
+				return fmt.Errorf("broker error code %d", code)
+			}
+		}
+	}
+	return d.err
+}
+
+// connectLeader looks up the leader of the partition in the brokers of the
+// target and connects to it.
+func (k *kafkaWriter) connectLeader() error {
+	var errs []error
+	for _, broker := range k.brokers {
+		leader, err := k.lookupLeader(broker)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", broker, err))
+			continue
+		}
+		if leader == broker && k.conn != nil {
+			return nil
+		}
+		k.discard()
+		if err := k.connect(leader); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", leader, err))
+			continue
+		}
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// lookupLeader returns the address of the leader of the partition known by
+// the broker, keeping the connection to the broker open.
+func (k *kafkaWriter) lookupLeader(broker string) (string, error) {
+	k.discard()
+	if err := k.connect(broker); err != nil {
+		return "", err
+	}
+
+	var body kafkaEncoder
+	body.putInt32(1)
+	body.putString(k.topic)
+	if err := k.send(kafkaAPIKeyMetadata, 0, body.buf); err != nil {
+		return "", err
+	}
+	res, err := k.receive()
+	if err != nil {
+		return "", err
+	}
+
+	d := kafkaDecoder{buf: res}
+	brokers := map[int32]string{}
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	for topics := d.int32(); topics > 0 && d.err == nil; topics-- {
+		topicCode := d.int16()
+		name := d.string()
+		for partitions := d.int32(); partitions > 0 && d.err == nil; partitions-- {
+			partitionCode := d.int16()
+			partition := d.int32()
+			leader := d.int32()
+			d.int32Array() // replicas
+			d.int32Array() // isr
+			if d.err != nil || name != k.topic || partition != k.partition {
+				continue
+			}
+			if topicCode != 0 || partitionCode != 0 {
+				return "", fmt.Errorf("metadata error code %d for partition %d of topic %q", max(topicCode, partitionCode), partition, name)
+			}
+			if addr, ok := brokers[leader]; ok {
+				return addr, nil
+			}
+			return "", fmt.Errorf("unknown leader %d for partition %d of topic %q", leader, partition, name)
+		}
+	}
+	if d.err != nil {
+		return "", d.err
+	}
+	return "", fmt.Errorf("partition %d of topic %q not found", k.partition, k.topic)
+}
+
+func (k *kafkaWriter) connect(addr string) error {
+	conn, err := k.dial("tcp", addr, k.timeout)
+	if err != nil {
+		return err
+	}
+	k.conn = conn
+	k.reader = bufio.NewReader(conn)
+	return nil
+}
+
+// send writes a request with a v1 header, the one used by the non flexible
+// versions of the requests.
+func (k *kafkaWriter) send(apiKey, apiVersion int16, body []byte) error {
+	k.correlationID++
+	var req kafkaEncoder
+	req.putInt32(0) // size, set below
+	req.putInt16(apiKey)
+	req.putInt16(apiVersion)
+	req.putInt32(k.correlationID)
+	req.putString16([]byte(k.clientID))
+	req.buf = append(req.buf, body...)
+	binary.BigEndian.PutUint32(req.buf, uint32(len(req.buf)-4))
+
+	if err := k.conn.SetDeadline(time.Now().Add(k.timeout)); err != nil {
+		return err
+	}
+	_, err := k.conn.Write(req.buf)
+	return err
+}
+
+// receive reads the response of the last request and returns its body.
+func (k *kafkaWriter) receive() ([]byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(k.reader, header[:]); err != nil {
+		return nil, err
+	}
+	size := int32(binary.BigEndian.Uint32(header[:4]))
+	if size < 4 || size > kafkaMaxResponseSize {
+		return nil, fmt.Errorf("invalid response size %d", size)
+	}
+	if id := int32(binary.BigEndian.Uint32(header[4:])); id != k.correlationID {
+		return nil, fmt.Errorf("unexpected correlation id %d, want %d", id, k.correlationID)
+	}
+	res := make([]byte, size-4)
+	_, err := io.ReadFull(k.reader, res)
+	return res, err
+}
+
+type kafkaRecord struct {
+	key   []byte
+	value []byte
+}
+
+// encodeKafkaRecordBatch encodes the records as a v2 (magic 2) record batch.
+func encodeKafkaRecordBatch(records []kafkaRecord, now time.Time) []byte {
+	ts := now.UnixMilli()
+
+	var recs kafkaEncoder
+	for i, r := range records {
+		var rec kafkaEncoder
+		rec.buf = append(rec.buf, 0) // attributes
+		rec.putVarint(0)             // timestamp delta
+		rec.putVarint(int64(i))      // offset delta
+		rec.putVarint(int64(len(r.key)))
+		rec.buf = append(rec.buf, r.key...)
+		rec.putVarint(int64(len(r.value)))
+		rec.buf = append(rec.buf, r.value...)
+		rec.putVarint(0) // headers
+		recs.putVarint(int64(len(rec.buf)))
+		recs.buf = append(recs.buf, rec.buf...)
+	}
+
+	// the CRC covers from the attributes to the end of the batch
+	var tail kafkaEncoder
+	tail.putInt16(0) // attributes
+	tail.putInt32(int32(len(records) - 1))
+	tail.putInt64(ts)
+	tail.putInt64(ts)
+	tail.putInt64(-1) // producer id
+	tail.putInt16(-1) // producer epoch
+	tail.putInt32(-1) // base sequence
+	tail.putInt32(int32(len(records)))
+	tail.buf = append(tail.buf, recs.buf...)
+
+	var batch kafkaEncoder
+	batch.putInt64(0)                                // base offset
+	batch.putInt32(int32(4 + 1 + 4 + len(tail.buf))) // length, from the leader epoch
+	batch.putInt32(-1)                               // partition leader epoch
+	batch.buf = append(batch.buf, 2)                 // magic
+	batch.putInt32(int32(crc32.Checksum(tail.buf, kafkaCRCTable)))
+	batch.buf = append(batch.buf, tail.buf...)
+	return batch.buf
+}
+
+type kafkaEncoder struct {
+	buf []byte
+}
+
+func (e *kafkaEncoder) putInt16(v int16) {
+	e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v))
+}
+
+func (e *kafkaEncoder) putInt32(v int32) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+}
+
+func (e *kafkaEncoder) putInt64(v int64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+}
+
+func (e *kafkaEncoder) putVarint(v int64) {
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+func (e *kafkaEncoder) putString(s string) {
+	e.putInt16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// putString16 writes a nullable string, nil being null.
+func (e *kafkaEncoder) putString16(s []byte) {
+	if s == nil {
+		e.putInt16(-1)
+		return
+	}
+	e.putInt16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// kafkaDecoder reads the fields of a response, keeping the first error.
+type kafkaDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.buf) < n {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *kafkaDecoder) int32Array() {
+	if n := d.int32(); n > 0 {
+		d.next(int(n) * 4)
+	}
+}
+
+var (
+	_ plugintypes.AuditLogWriter = (*kafkaWriter)(nil)
+	_ batchWriter                = (*kafkaWriter)(nil)
+)
@@ -0,0 +1,376 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+
+package auditlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+)
+
+// fakeKafkaBroker is a single broker cluster leading every partition. It
+// answers the metadata and produce requests, keeping the produced records.
+type fakeKafkaBroker struct {
+	t         *testing.T
+	l         net.Listener
+	errorCode int16
+
+	mu        sync.Mutex
+	records   []kafkaRecord
+	batches   int
+	clientIDs []string
+}
+
+func newFakeKafkaBroker(t *testing.T) *fakeKafkaBroker {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeKafkaBroker{t: t, l: l}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *fakeKafkaBroker) addr() string {
+	return b.l.Addr().String()
+}
+
+func (b *fakeKafkaBroker) produced() []kafkaRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]kafkaRecord(nil), b.records...)
+}
+
+func (b *fakeKafkaBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, req); err != nil {
+			return
+		}
+		d := kafkaDecoder{buf: req}
+		apiKey, apiVersion, correlationID := d.int16(), d.int16(), d.int32()
+		clientID := d.string()
+		b.mu.Lock()
+		b.clientIDs = append(b.clientIDs, clientID)
+		b.mu.Unlock()
+
+		var res kafkaEncoder
+		res.putInt32(correlationID)
+		switch {
+		case apiKey == kafkaAPIKeyMetadata && apiVersion == 0:
+			topic := ""
+			if d.int32() == 1 {
+				topic = d.string()
+			}
+			host, port, _ := net.SplitHostPort(b.addr())
+			p, _ := strconv.Atoi(port)
+			res.putInt32(1)
+			res.putInt32(7)
+			res.putString(host)
+			res.putInt32(int32(p))
+			res.putInt32(1)
+			res.putInt16(0)
+			res.putString(topic)
+			res.putInt32(2)
+			for partition := int32(0); partition < 2; partition++ {
+				res.putInt16(0)
+				res.putInt32(partition)
+				res.putInt32(7)
+				res.putInt32(1)
+				res.putInt32(7)
+				res.putInt32(1)
+				res.putInt32(7)
+			}
+		case apiKey == kafkaAPIKeyProduce && apiVersion == kafkaProduceVersion:
+			d.string() // transactional_id
+			acks := d.int16()
+			d.int32() // timeout
+			d.int32() // topics
+			topic := d.string()
+			d.int32() // partitions
+			partition := d.int32()
+			batch := d.next(int(d.int32()))
+			if d.err != nil {
+				b.t.Errorf("invalid produce request: %v", d.err)
+				return
+			}
+			records, err := decodeKafkaRecordBatch(batch)
+			if err != nil {
+				b.t.Errorf("invalid record batch: %v", err)
+				return
+			}
+			b.mu.Lock()
+			if b.errorCode == 0 {
+				b.records = append(b.records, records...)
+				b.batches++
+			}
+			b.mu.Unlock()
+			if acks == 0 {
+				continue
+			}
+			res.putInt32(1)
+			res.putString(topic)
+			res.putInt32(1)
+			res.putInt32(partition)
+			res.putInt16(b.errorCode)
+			res.putInt64(0)
+			res.putInt64(-1)
+			res.putInt32(0) // throttle_time_ms
+		default:
+			b.t.Errorf("unexpected request %d v%d", apiKey, apiVersion)
+			return
+		}
+
+		out := binary.BigEndian.AppendUint32(nil, uint32(len(res.buf)))
+		if _, err := conn.Write(append(out, res.buf...)); err != nil {
+			return
+		}
+	}
+}
+
+// decodeKafkaRecordBatch decodes a v2 record batch, checking its length and CRC.
+func decodeKafkaRecordBatch(batch []byte) ([]kafkaRecord, error) {
+	d := kafkaDecoder{buf: batch}
+	d.int64() // base offset
+	if length := d.int32(); int(length) != len(d.buf) {
+		return nil, errors.New("invalid batch length")
+	}
+	d.int32() // partition leader epoch
+	if magic := d.next(1); d.err != nil || magic[0] != 2 {
+		return nil, errors.New("invalid magic")
+	}
+	crc := uint32(d.int32())
+	if crc32.Checksum(d.buf, crc32.MakeTable(crc32.Castagnoli)) != crc {
+		return nil, errors.New("invalid crc")
+	}
+	d.int16() // attributes
+	lastOffsetDelta := d.int32()
+	d.next(8 + 8 + 8 + 2 + 4)
+	count := d.int32()
+	if lastOffsetDelta != count-1 {
+		return nil, errors.New("invalid last offset delta")
+	}
+
+	varint := func() int64 {
+		v, n := binary.Varint(d.buf)
+		if n <= 0 {
+			d.err = io.ErrUnexpectedEOF
+			return 0
+		}
+		d.buf = d.buf[n:]
+		return v
+	}
+	var records []kafkaRecord
+	for i := int32(0); i < count && d.err == nil; i++ {
+		varint() // length
+		d.next(1)
+		varint() // timestamp delta
+		if offsetDelta := varint(); offsetDelta != int64(i) {
+			return nil, errors.New("invalid offset delta")
+		}
+		key := d.next(int(varint()))
+		value := d.next(int(varint()))
+		varint() // headers
+		records = append(records, kafkaRecord{key: key, value: value})
+	}
+	if d.err == nil && len(d.buf) != 0 {
+		return nil, errors.New("trailing bytes in batch")
+	}
+	return records, d.err
+}
+
+func newKafkaWriterForTest(t *testing.T, target string) *kafkaWriter {
+	t.Helper()
+	w := newKafkaWriter()
+	config := NewConfig()
+	config.Target = target
+	config.Formatter = &jsonFormatter{}
+	if err := w.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return w
+}
+
+func TestKafkaWriterInit(t *testing.T) {
+	w := newKafkaWriterForTest(t, "kafka://b1:9092,b2:9092/audit?partition=3&acks=all&client_id=waf&timeout=1s")
+	if len(w.brokers) != 2 || w.topic != "audit" || w.partition != 3 || w.acks != -1 || w.clientID != "waf" || w.timeout != time.Second {
+		t.Errorf("unexpected writer config %+v", w)
+	}
+
+	for _, target := range []string{
+		"",
+		"tcp://b1:9092/audit",
+		"kafka://b1:9092",
+		"kafka:///audit",
+		"kafka://b1:9092/audit?acks=2",
+		"kafka://b1:9092/audit?partition=-1",
+		"kafka://b1:9092/audit?timeout=x",
+	} {
+		t.Run(target, func(t *testing.T) {
+			config := NewConfig()
+			config.Target = target
+			if err := newKafkaWriter().Init(config); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestKafkaWriterProduce(t *testing.T) {
+	broker := newFakeKafkaBroker(t)
+	// the first broker is unreachable, the leader is looked up in the second one
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := l.Addr().String()
+	l.Close()
+	w := newKafkaWriterForTest(t, "kafka://"+down+","+broker.addr()+"/audit?partition=1&client_id=waf")
+
+	if err := w.Write(testLog("tx1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteBatch([]plugintypes.AuditLog{testLog("tx2"), testLog("tx3")}); err != nil {
+		t.Fatal(err)
+	}
+
+	records := broker.produced()
+	if len(records) != 3 {
+		t.Fatalf("unexpected records %d", len(records))
+	}
+	for i, id := range []string{"tx1", "tx2", "tx3"} {
+		if string(records[i].key) != id {
+			t.Errorf("unexpected key %q, want %q", records[i].key, id)
+		}
+	}
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if broker.batches != 2 {
+		t.Errorf("unexpected batches %d", broker.batches)
+	}
+	if broker.clientIDs[0] != "waf" {
+		t.Errorf("unexpected client id %q", broker.clientIDs[0])
+	}
+}
+
+func TestKafkaWriterEvents(t *testing.T) {
+	broker := newFakeKafkaBroker(t)
+	w := newKafkaWriterForTest(t, "kafka://"+broker.addr()+"/audit")
+	w.formatter = &cefFormatter{}
+
+	// the transactions without matched rules produce no records
+	if err := w.Write(&Log{Transaction_: Transaction{ID_: "empty"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteBatch([]plugintypes.AuditLog{createSIEMAuditLog(), &Log{Transaction_: Transaction{ID_: "empty"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	records := broker.produced()
+	if len(records) != 2 {
+		t.Fatalf("expected a record per event, have %d", len(records))
+	}
+	for i, id := range []string{"942100", "100"} {
+		r := records[i]
+		if string(r.key) != "abc123" {
+			t.Errorf("unexpected key %q", r.key)
+		}
+		if !strings.HasPrefix(string(r.value), "CEF:0|OWASP|Coraza|1.2.3|"+id+"|") || strings.Contains(string(r.value), "\n") {
+			t.Errorf("unexpected record %q", r.value)
+		}
+	}
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if broker.batches != 1 {
+		t.Errorf("unexpected batches %d", broker.batches)
+	}
+}
+
+func TestKafkaWriterReconnects(t *testing.T) {
+	broker := newFakeKafkaBroker(t)
+	w := newKafkaWriterForTest(t, "kafka://"+broker.addr()+"/audit")
+	if err := w.Write(testLog("tx1")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the broker drops the connection
+	w.mu.Lock()
+	w.conn.Close()
+	w.mu.Unlock()
+	if err := w.Write(testLog("tx2")); err == nil {
+		t.Fatal("expected error writing to the closed connection")
+	}
+	if err := w.Write(testLog("tx3")); err != nil {
+		t.Fatal(err)
+	}
+	if records := broker.produced(); len(records) != 2 || string(records[1].key) != "tx3" {
+		t.Errorf("unexpected records %v", records)
+	}
+}
+
+func TestKafkaWriterBrokerError(t *testing.T) {
+	broker := newFakeKafkaBroker(t)
+	broker.errorCode = 6 // NOT_LEADER_OR_FOLLOWER
+	w := newKafkaWriterForTest(t, "kafka://"+broker.addr()+"/audit")
+	if err := w.Write(testLog("tx1")); err == nil {
+		t.Error("expected error")
+	}
+	if w.conn != nil {
+		t.Error("expected the connection to be discarded")
+	}
+}
+
+func TestKafkaWriterWithoutAcks(t *testing.T) {
+	broker := newFakeKafkaBroker(t)
+	w := newKafkaWriterForTest(t, "kafka://"+broker.addr()+"/audit?acks=0")
+	for _, id := range []string{"tx1", "tx2"} {
+		if err := w.Write(testLog(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(broker.produced()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if records := broker.produced(); len(records) != 2 {
+		t.Errorf("unexpected records %d", len(records))
+	}
+}
+
+func TestKafkaWriterUnknownPartition(t *testing.T) {
+	broker := newFakeKafkaBroker(t)
+	w := newKafkaWriterForTest(t, "kafka://"+broker.addr()+"/audit?partition=5")
+	if err := w.Write(testLog("tx1")); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+)
+
+// rotatedFileTimeFormat is the suffix of the rotated files, sorting them by
+// rotation time.
+const rotatedFileTimeFormat = "20060102-150405.000000000"
+
+// rotatingFile is a file opened in append mode, renamed with the rotation time
+// as suffix when it reaches the size or age set in the rotation config. Rotated
+// files are optionally compressed and removed once they exceed the retention.
+type rotatingFile struct {
+	path     string
+	mode     fs.FileMode
+	rotation plugintypes.AuditLogRotation
+	now      func() time.Time
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+
+	// background serializes the compression and cleanup of the rotated files
+	background sync.Mutex
+	pending    sync.WaitGroup
+}
+
+func openRotatingFile(path string, mode fs.FileMode, rotation plugintypes.AuditLogRotation) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, mode: mode, rotation: rotation, now: time.Now}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, rf.mode)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f = f
+	rf.size = info.Size()
+	rf.opened = rf.now()
+	return nil
+}
+
+// Write writes p to the file, rotating it before if needed. Entries are never
+// split across files.
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return 0, os.ErrClosed
+	}
+	if rf.shouldRotate(len(p)) {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *rotatingFile) shouldRotate(n int) bool {
+	if rf.size == 0 {
+		return false
+	}
+	if rf.rotation.MaxSize > 0 && rf.size+int64(n) > rf.rotation.MaxSize {
+		return true
+	}
+	return rf.rotation.Interval > 0 && rf.now().Sub(rf.opened) >= rf.rotation.Interval
+}
+
+// rotate renames the current file and opens a new one.
+func (rf *rotatingFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+	rf.f = nil
+	rotated := rf.path + "." + rf.now().Format(rotatedFileTimeFormat)
+	if err := os.Rename(rf.path, rotated); err != nil {
+		// the file is reopened even if it can't be renamed so writes don't stop
+		return errors.Join(err, rf.open())
+	}
+	if err := rf.open(); err != nil {
+		return err
+	}
+
+	rf.pending.Add(1)
+	go func() {
+		defer rf.pending.Done()
+		rf.background.Lock()
+		defer rf.background.Unlock()
+		if rf.rotation.Compress {
+			// a failed compression keeps the uncompressed file
+			_ = compressFile(rotated, rf.mode)
+		}
+		rf.removeExpired()
+	}()
+	return nil
+}
+
+// Reopen closes and opens the file, which may have been moved.
+func (rf *rotatingFile) Reopen() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f != nil {
+		if err := rf.f.Close(); err != nil {
+			return err
+		}
+		rf.f = nil
+	}
+	return rf.open()
+}
+
+// Close closes the file, waiting for the rotated files being compressed.
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	var err error
+	if rf.f != nil {
+		err = rf.f.Close()
+		rf.f = nil
+	}
+	rf.mu.Unlock()
+	rf.pending.Wait()
+	return err
+}
+
+// removeExpired removes the rotated files exceeding the retention.
+func (rf *rotatingFile) removeExpired() {
+	if rf.rotation.MaxFiles <= 0 && rf.rotation.MaxAge <= 0 {
+		return
+	}
+	dir, prefix := filepath.Dir(rf.path), filepath.Base(rf.path)+"."
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var rotated []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.Type().IsRegular() && strings.HasPrefix(name, prefix) && isRotatedSuffix(name[len(prefix):]) {
+			rotated = append(rotated, name)
+		}
+	}
+	// newest first
+	sort.Sort(sort.Reverse(sort.StringSlice(rotated)))
+
+	now := rf.now()
+	for i, name := range rotated {
+		p := filepath.Join(dir, name)
+		if rf.rotation.MaxFiles > 0 && i >= rf.rotation.MaxFiles {
+			_ = os.Remove(p)
+			continue
+		}
+		if rf.rotation.MaxAge > 0 {
+			if info, err := os.Stat(p); err == nil && now.Sub(info.ModTime()) > rf.rotation.MaxAge {
+				_ = os.Remove(p)
+			}
+		}
+	}
+}
+
+func isRotatedSuffix(s string) bool {
+	s = strings.TrimSuffix(s, ".gz")
+	_, err := time.Parse(rotatedFileTimeFormat, s)
+	return err == nil
+}
+
+// compressFile gzips the file into file.gz and removes it.
+func compressFile(path string, mode fs.FileMode) (err error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(path + ".gz")
+		}
+	}()
+
+	zw := gzip.NewWriter(out)
+	if _, err = io.Copy(zw, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(path)
+}
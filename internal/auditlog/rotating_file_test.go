@@ -0,0 +1,235 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+
+package auditlog
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+)
+
+// rotatedFiles returns the names of the rotated files of path, oldest first.
+func rotatedFiles(t *testing.T, path string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func newRotatingFileForTest(t *testing.T, rotation plugintypes.AuditLogRotation, clock *time.Time) (*rotatingFile, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	rf := &rotatingFile{path: path, mode: 0600, rotation: rotation, now: func() time.Time { return *clock }}
+	if err := rf.open(); err != nil {
+		t.Fatal(err)
+	}
+	return rf, path
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	clock := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rf, path := newRotatingFileForTest(t, plugintypes.AuditLogRotation{MaxSize: 10}, &clock)
+
+	for _, entry := range []string{"0123456\n", "abc\n", "def\n", "0123456789abcdef\n"} {
+		clock = clock.Add(time.Second)
+		if _, err := rf.Write([]byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := rotatedFiles(t, path)
+	if len(rotated) != 2 {
+		t.Fatalf("unexpected rotated files %q", rotated)
+	}
+	for i, want := range []string{"0123456\n", "abc\ndef\n"} {
+		data, err := os.ReadFile(rotated[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("unexpected content of %s, want %q, have %q", rotated[i], want, data)
+		}
+	}
+	if !strings.HasSuffix(rotated[0], ".20240102-030407.000000000") {
+		t.Errorf("unexpected rotated file name %s", rotated[0])
+	}
+	if data, _ := os.ReadFile(path); string(data) != "0123456789abcdef\n" {
+		t.Errorf("unexpected current file %q", data)
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	clock := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rf, path := newRotatingFileForTest(t, plugintypes.AuditLogRotation{Interval: time.Hour}, &clock)
+	defer rf.Close()
+
+	write := func(s string) {
+		t.Helper()
+		if _, err := rf.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("first\n")
+	clock = clock.Add(30 * time.Minute)
+	write("second\n")
+	if rotated := rotatedFiles(t, path); len(rotated) != 0 {
+		t.Errorf("unexpected rotation before the interval %q", rotated)
+	}
+	clock = clock.Add(30 * time.Minute)
+	write("third\n")
+	if rotated := rotatedFiles(t, path); len(rotated) != 1 {
+		t.Errorf("expected a rotation after the interval, have %q", rotated)
+	}
+}
+
+func TestRotatingFileCompressAndRetention(t *testing.T) {
+	clock := time.Now()
+	rf, path := newRotatingFileForTest(t, plugintypes.AuditLogRotation{MaxSize: 1, Compress: true, MaxFiles: 2}, &clock)
+
+	for _, entry := range []string{"a\n", "b\n", "c\n", "d\n", "e\n"} {
+		clock = clock.Add(time.Second)
+		if _, err := rf.Write([]byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := rotatedFiles(t, path)
+	if len(rotated) != 2 {
+		t.Fatalf("unexpected rotated files %q", rotated)
+	}
+	for i, want := range []string{"c\n", "d\n"} {
+		if !strings.HasSuffix(rotated[i], ".gz") {
+			t.Fatalf("expected a compressed file, have %s", rotated[i])
+		}
+		f, err := os.Open(rotated[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(zr)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("unexpected content of %s, want %q, have %q", rotated[i], want, data)
+		}
+	}
+}
+
+func TestRotatingFileMaxAge(t *testing.T) {
+	clock := time.Now()
+	rf, path := newRotatingFileForTest(t, plugintypes.AuditLogRotation{MaxSize: 1, MaxAge: time.Hour}, &clock)
+	defer rf.Close()
+
+	// unrelated files sharing the prefix are kept
+	unrelated := path + ".backup"
+	if err := os.WriteFile(unrelated, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := path + "." + clock.Add(-48*time.Hour).Format(rotatedFileTimeFormat)
+	if err := os.WriteFile(old, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(old, clock.Add(-48*time.Hour), clock.Add(-48*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range []string{"a\n", "b\n"} {
+		clock = clock.Add(time.Second)
+		if _, err := rf.Write([]byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rf.pending.Wait()
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("expected the expired file to be removed")
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Error("expected the unrelated file to be kept")
+	}
+	if rotated := rotatedFiles(t, path); len(rotated) != 2 {
+		t.Errorf("unexpected rotated files %q", rotated)
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	clock := time.Now()
+	rf, path := newRotatingFileForTest(t, plugintypes.AuditLogRotation{}, &clock)
+	defer rf.Close()
+
+	if _, err := rf.Write([]byte("before\n")); err != nil {
+		t.Fatal(err)
+	}
+	// logrotate moves the file away and asks to reopen it
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rf.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rf.Write([]byte("after\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(path + ".1"); string(data) != "before\n" {
+		t.Errorf("unexpected moved file %q", data)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "after\n" {
+		t.Errorf("unexpected reopened file %q", data)
+	}
+}
+
+func TestSerialWriterRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	config := NewConfig()
+	config.Target = path
+	config.Formatter = &jsonFormatter{}
+	config.Rotation.MaxSize = 1
+	writer := &serialWriter{}
+	if err := writer.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"tx1", "tx2"} {
+		if err := writer.Write(testLog(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := rotatedFiles(t, path)
+	if len(rotated) != 1 {
+		t.Fatalf("unexpected rotated files %q", rotated)
+	}
+	if data, _ := os.ReadFile(rotated[0]); !strings.Contains(string(data), "tx1") {
+		t.Errorf("unexpected rotated file %q", data)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "tx2") {
+		t.Errorf("unexpected current file %q", data)
+	}
+}
@@ -16,6 +16,7 @@ type serialWriter struct {
 	io.Closer
 	logger    log.Logger
 	formatter plugintypes.AuditLogFormatter
+	file      *rotatingFile
 }
 
 func (sl *serialWriter) Init(c plugintypes.AuditLogConfig) error {
@@ -31,12 +32,13 @@ func (sl *serialWriter) Init(c plugintypes.AuditLogConfig) error {
 	case "/dev/stderr":
 		f = os.Stderr
 	default:
-		ff, err := os.OpenFile(c.Target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, c.FileMode)
+		ff, err := openRotatingFile(c.Target, c.FileMode, c.Rotation)
 		if err != nil {
 			return err
 		}
 		f = ff
 		sl.Closer = ff
+		sl.file = ff
 	}
 
 	sl.formatter = c.Formatter
@@ -63,4 +65,15 @@ func (sl *serialWriter) Write(al plugintypes.AuditLog) error {
 	return nil
 }
 
-var _ plugintypes.AuditLogWriter = (*serialWriter)(nil)
+// Reopen reopens the log file, standard streams are not reopened.
+func (sl *serialWriter) Reopen() error {
+	if sl.file == nil {
+		return nil
+	}
+	return sl.file.Reopen()
+}
+
+var (
+	_ plugintypes.AuditLogWriter   = (*serialWriter)(nil)
+	_ plugintypes.AuditLogReopener = (*serialWriter)(nil)
+)
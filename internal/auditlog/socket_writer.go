@@ -0,0 +1,136 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+
+package auditlog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+)
+
+// socketWriter sends the audit logs to a TCP, UDP or Unix socket, one entry per
+// line. Entries formatted as JSON make a NDJSON stream, every OCSF, CEF and
+// LEEF event is a separate entry. The target is an URL
+// like tcp://collector:5170, udp://collector:5170, unix:///var/run/audit.sock
+// or unixgram:///var/run/audit.sock, the timeout query parameter sets the dial
+// and write timeout. The connection is dialed on the first write and dialed
+// again after any failure.
+type socketWriter struct {
+	formatter plugintypes.AuditLogFormatter
+	network   string
+	address   string
+	timeout   time.Duration
+	dial      func(network, address string, timeout time.Duration) (net.Conn, error)
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newSocketWriter() *socketWriter {
+	return &socketWriter{dial: net.DialTimeout}
+}
+
+func (s *socketWriter) Init(c plugintypes.AuditLogConfig) error {
+	u, err := url.Parse(c.Target)
+	if err != nil {
+		return fmt.Errorf("invalid socket audit log target: %w", err)
+	}
+	switch u.Scheme {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6":
+		s.address = u.Host
+	case "unix", "unixgram":
+		s.address = u.Path
+	default:
+		return fmt.Errorf("unsupported socket audit log target %q, expected tcp, udp, unix or unixgram URL", c.Target)
+	}
+	if s.address == "" {
+		return fmt.Errorf("missing address in socket audit log target %q", c.Target)
+	}
+	s.network = u.Scheme
+	s.timeout = time.Second
+	if t := u.Query().Get("timeout"); t != "" {
+		if s.timeout, err = time.ParseDuration(t); err != nil {
+			return fmt.Errorf("invalid socket audit log timeout: %w", err)
+		}
+	}
+	s.formatter = c.Formatter
+	return nil
+}
+
+func (s *socketWriter) Write(al plugintypes.AuditLog) error {
+	if s.formatter == nil {
+		return nil
+	}
+	bts, err := s.formatter.Format(al)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range records(s.formatter, bts) {
+		// entries must take a single line
+		entry := append(bytes.TrimRight(record, "\n"), '\n')
+		reused := s.conn != nil
+		err = s.write(entry)
+		if err != nil && reused {
+			// the connection may have been closed by the peer since the last write
+			err = s.write(entry)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write sends the entry, dialing when there is no connection. The connection
+// is discarded on failure.
+func (s *socketWriter) write(bts []byte) error {
+	if s.conn == nil {
+		conn, err := s.dial(s.network, s.address, s.timeout)
+		if err != nil {
+			return fmt.Errorf("socket audit log dial failure: %w", err)
+		}
+		s.conn = conn
+	}
+	if err := s.conn.SetWriteDeadline(time.Now().Add(s.timeout)); err != nil {
+		s.discard()
+		return err
+	}
+	if _, err := s.conn.Write(bts); err != nil {
+		s.discard()
+		return fmt.Errorf("socket audit log write failure: %w", err)
+	}
+	return nil
+}
+
+func (s *socketWriter) discard() {
+	_ = s.conn.Close()
+	s.conn = nil
+}
+
+func (s *socketWriter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+var _ plugintypes.AuditLogWriter = (*socketWriter)(nil)
@@ -0,0 +1,255 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+
+package auditlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSocketWriterInit(t *testing.T) {
+	tests := map[string]struct {
+		target  string
+		network string
+		address string
+		timeout time.Duration
+	}{
+		"tcp":      {target: "tcp://127.0.0.1:5170", network: "tcp", address: "127.0.0.1:5170", timeout: time.Second},
+		"udp":      {target: "udp://[::1]:5170?timeout=3s", network: "udp", address: "[::1]:5170", timeout: 3 * time.Second},
+		"unix":     {target: "unix:///var/run/audit.sock", network: "unix", address: "/var/run/audit.sock", timeout: time.Second},
+		"unixgram": {target: "unixgram:///var/run/audit.sock", network: "unixgram", address: "/var/run/audit.sock", timeout: time.Second},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := newSocketWriter()
+			config := NewConfig()
+			config.Target = tc.target
+			if err := w.Init(config); err != nil {
+				t.Fatal(err)
+			}
+			if w.network != tc.network || w.address != tc.address || w.timeout != tc.timeout {
+				t.Errorf("unexpected writer config %q %q %s", w.network, w.address, w.timeout)
+			}
+		})
+	}
+
+	for _, target := range []string{"", "http://127.0.0.1:80", "tcp://", "unix://", "tcp://127.0.0.1:1?timeout=x"} {
+		t.Run(target, func(t *testing.T) {
+			config := NewConfig()
+			config.Target = target
+			if err := newSocketWriter().Init(config); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// readLines accepts the connections of l and sends every line read to the
+// returned channel, closing each connection after max lines.
+func readLines(t *testing.T, l net.Listener, maxLines int) <-chan string {
+	t.Helper()
+	lines := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s := bufio.NewScanner(conn)
+			for n := 0; n < maxLines && s.Scan(); n++ {
+				lines <- s.Text()
+			}
+			conn.Close()
+		}
+	}()
+	return lines
+}
+
+func receiveLine(t *testing.T, lines <-chan string) string {
+	t.Helper()
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the audit log")
+	}
+	return ""
+}
+
+func TestSocketWriterStream(t *testing.T) {
+	networks := map[string]func() (net.Listener, string){
+		"tcp": func() (net.Listener, string) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return l, "tcp://" + l.Addr().String()
+		},
+	}
+	if runtime.GOOS != "windows" {
+		networks["unix"] = func() (net.Listener, string) {
+			path := filepath.Join(t.TempDir(), "audit.sock")
+			l, err := net.Listen("unix", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return l, "unix://" + path
+		}
+	}
+
+	for name, listen := range networks {
+		t.Run(name, func(t *testing.T) {
+			l, target := listen()
+			defer l.Close()
+			// the connection is closed after every entry to exercise the reconnects
+			lines := readLines(t, l, 1)
+
+			w := newSocketWriter()
+			config := NewConfig()
+			config.Target = target
+			config.Formatter = &jsonFormatter{}
+			if err := w.Init(config); err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+
+			if err := w.Write(testLog("first")); err != nil {
+				t.Fatal(err)
+			}
+			var entry map[string]any
+			if err := json.Unmarshal([]byte(receiveLine(t, lines)), &entry); err != nil {
+				t.Fatal(err)
+			}
+			if id := entry["transaction"].(map[string]any)["id"]; id != "first" {
+				t.Errorf("unexpected transaction id %v", id)
+			}
+
+			// writes to the closed connection may succeed until the peer resets it,
+			// the entries keep being sent until one reaches the new connection
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				_ = w.Write(testLog("second"))
+				select {
+				case line := <-lines:
+					if err := json.Unmarshal([]byte(line), &entry); err != nil {
+						t.Fatal(err)
+					}
+					return
+				case <-time.After(50 * time.Millisecond):
+				}
+			}
+			t.Fatal("the writer did not reconnect")
+		})
+	}
+}
+
+func TestSocketWriterDatagram(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w := newSocketWriter()
+	config := NewConfig()
+	config.Target = "udp://" + conn.LocalAddr().String()
+	config.Formatter = &jsonFormatter{}
+	if err := w.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.Write(testLog("datagram")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 65536)
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf[n-1] != '\n' {
+		t.Error("expected the entry to end with a new line")
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(buf[:n], &entry); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSocketWriterEvents(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w := newSocketWriter()
+	config := NewConfig()
+	config.Target = "udp://" + conn.LocalAddr().String()
+	config.Formatter = &cefFormatter{}
+	if err := w.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.Write(createSIEMAuditLog()); err != nil {
+		t.Fatal(err)
+	}
+	// the transactions without matched rules are not sent
+	if err := w.Write(&Log{Transaction_: Transaction{ID_: "empty"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []string
+	buf := make([]byte, 65536)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		entries = append(entries, string(buf[:n]))
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected a datagram per event, have %q", entries)
+	}
+	for i, id := range []string{"942100", "100"} {
+		if e := entries[i]; !strings.HasPrefix(e, "CEF:0|OWASP|Coraza|1.2.3|"+id+"|") || strings.Count(e, "\n") != 1 || !strings.HasSuffix(e, "\n") {
+			t.Errorf("unexpected entry %q", e)
+		}
+	}
+}
+
+func TestSocketWriterDialFailure(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	w := newSocketWriter()
+	config := NewConfig()
+	config.Target = "tcp://" + addr
+	config.Formatter = &jsonFormatter{}
+	if err := w.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(testLog("lost")); err == nil {
+		t.Error("expected error")
+	}
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -63,16 +63,17 @@ func (s *syslogWriter) Write(al plugintypes.AuditLog) error {
 		return fmt.Errorf("auditlog format failure: %w", err)
 	}
 
-	if al.Transaction().IsInterrupted() {
-		if err := s.Err(string(payload)); err != nil {
-			return fmt.Errorf("error write failure: %w", err)
+	for _, record := range records(s.formatter, payload) {
+		if al.Transaction().IsInterrupted() {
+			if err := s.Err(string(record)); err != nil {
+				return fmt.Errorf("error write failure: %w", err)
+			}
+			continue
 		}
 
-		return nil
-	}
-
-	if err := s.Info(string(payload)); err != nil {
-		return fmt.Errorf("info write failure: %w", err)
+		if err := s.Info(string(record)); err != nil {
+			return fmt.Errorf("info write failure: %w", err)
+		}
 	}
 
 	return nil
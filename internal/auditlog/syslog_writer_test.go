@@ -8,8 +8,10 @@ package auditlog
 import (
 	"log/syslog"
 	"net"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
 )
@@ -169,7 +171,7 @@ func Test_syslogWriter_Write(t *testing.T) {
 		{
 			name: "Interrupted",
 			fields: fields{
-				formatter: new(noopFormatter),
+				formatter: new(jsonFormatter),
 			},
 			args: args{
 				al: &Log{
@@ -180,13 +182,27 @@ func Test_syslogWriter_Write(t *testing.T) {
 			},
 			want: want{
 				priority: syslog.LOG_ERR,
+				message:  `{"transaction":{"timestamp":"","unix_timestamp":0,"id":"","client_ip":"","client_port":0,"host_ip":"","host_port":0,"server_id":"","highest_severity":"","is_interrupted":true}}`,
 			},
 		},
 		{
-			name: "FailureDefault",
+			name: "Empty",
 			fields: fields{
 				formatter: new(noopFormatter),
 			},
+			args: args{
+				al: &Log{
+					Transaction_: Transaction{
+						IsInterrupted_: true,
+					},
+				},
+			},
+		},
+		{
+			name: "FailureDefault",
+			fields: fields{
+				formatter: new(jsonFormatter),
+			},
 			args: args{
 				al: &Log{},
 			},
@@ -195,7 +211,7 @@ func Test_syslogWriter_Write(t *testing.T) {
 		{
 			name: "FailureInterrupted",
 			fields: fields{
-				formatter: new(noopFormatter),
+				formatter: new(jsonFormatter),
 			},
 			args: args{
 				al: &Log{
@@ -245,6 +261,58 @@ func Test_syslogWriter_Write(t *testing.T) {
 	}
 }
 
+func TestSyslogWriterRoundTrip(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w := NewSyslogWriter()
+	w.dialer = func(network, raddr string, p syslog.Priority, tag string) (Syslog, error) {
+		return syslog.Dial(network, raddr, p, tag)
+	}
+	if err := w.Init(plugintypes.AuditLogConfig{
+		Target:    "udp://" + conn.LocalAddr().String(),
+		Formatter: &cefFormatter{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := w.Write(createSIEMAuditLog()); err != nil {
+		t.Fatal(err)
+	}
+	// the transactions without matched rules are not sent
+	if err := w.Write(&Log{Transaction_: Transaction{IsInterrupted_: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []string
+	buf := make([]byte, 4096)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		records = append(records, string(buf[:n]))
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected a record per event, have %q", records)
+	}
+	for i, id := range []string{"942100", "100"} {
+		r := records[i]
+		// local0.err, the transaction is interrupted
+		if !strings.HasPrefix(r, "<131>") || !strings.Contains(r, " com.coraza.waf[") {
+			t.Errorf("unexpected syslog header %q", r)
+		}
+		if !strings.Contains(r, ": CEF:0|OWASP|Coraza|1.2.3|"+id+"|") || strings.Count(r, "\n") != 1 {
+			t.Errorf("unexpected record %q", r)
+		}
+	}
+}
+
 type syslogDialerStub struct {
 	sync.Mutex
 	wantErr bool
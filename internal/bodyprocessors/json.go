@@ -4,12 +4,13 @@
 package bodyprocessors
 
 import (
+	"bufio"
 	"errors"
 	"io"
 	"strconv"
 	"strings"
-
-	"github.com/tidwall/gjson"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
 )
@@ -19,30 +20,27 @@ type jsonBodyProcessor struct{}
 var _ plugintypes.BodyProcessor = &jsonBodyProcessor{}
 
 func (js *jsonBodyProcessor) ProcessRequest(reader io.Reader, v plugintypes.TransactionVariables, bpo plugintypes.BodyProcessorOptions) error {
-	// Read the entire body into memory for two purposes:
-	// 1. Store raw JSON in TX variables for operators like @validateSchema
-	// 2. Parse and flatten for ARGS_POST collection
-	s := strings.Builder{}
-	if _, err := io.Copy(&s, reader); err != nil {
-		return err
+	// The raw JSON is only kept for operators validating the whole document,
+	// like @validateSchema
+	var raw *strings.Builder
+	if bpo.KeepRawBody {
+		raw = &strings.Builder{}
+		reader = io.TeeReader(reader, raw)
 	}
-	ss := s.String()
-	// Process with recursion limit
 	col := v.ArgsPost()
-	data, err := readJSON(ss, bpo.RequestBodyRecursionLimit)
-	// The collection is populated before checking the error to still perform a best effort inspection of the payload
-	for key, value := range data {
+	// The collection is populated while reading to still perform a best effort
+	// inspection of the payload when the body is invalid or over the limits
+	err := readJSON(reader, bpo.RequestBodyRecursionLimit, bpo.ArgumentsLimit, func(key, value string) {
 		col.SetIndex(key, 0, value)
-	}
+	})
 	if err != nil {
 		return err
 	}
 
 	// Store the raw JSON in the TX variable for validateSchema
 	// This is needed because RequestBody is a Single interface without a Set method
-	if txVar := v.TX(); txVar != nil {
-		// Store the content type and raw body
-		txVar.Set("json_request_body", []string{ss})
+	if txVar := v.TX(); txVar != nil && raw != nil {
+		txVar.Set("json_request_body", []string{raw.String()})
 	}
 
 	return nil
@@ -50,103 +48,426 @@ func (js *jsonBodyProcessor) ProcessRequest(reader io.Reader, v plugintypes.Tran
 
 const ignoreJSONRecursionLimit = -1
 
-func (js *jsonBodyProcessor) ProcessResponse(reader io.Reader, v plugintypes.TransactionVariables, _ plugintypes.BodyProcessorOptions) error {
-	// Read the entire body to store it and process it
-	s := strings.Builder{}
-	if _, err := io.Copy(&s, reader); err != nil {
-		return err
+func (js *jsonBodyProcessor) ProcessResponse(reader io.Reader, v plugintypes.TransactionVariables, bpo plugintypes.BodyProcessorOptions) error {
+	var raw *strings.Builder
+	if bpo.KeepRawBody {
+		raw = &strings.Builder{}
+		reader = io.TeeReader(reader, raw)
 	}
-	ss := s.String()
-	// Process with no recursion limit as we don't have a directive for response body
 	col := v.ResponseArgs()
-	data, err := readJSON(ss, ignoreJSONRecursionLimit)
-	// The collection is populated before checking the error to still perform a best effort inspection of the payload
-	for key, value := range data {
+	// Process with no recursion nor arguments limit as we don't have a directive for response body
+	err := readJSON(reader, ignoreJSONRecursionLimit, 0, func(key, value string) {
 		col.SetIndex(key, 0, value)
-	}
+	})
 	if err != nil {
 		return err
 	}
 
 	// Store the raw JSON in the TX variable for validateSchema
 	// This is needed because ResponseBody is a Single interface without a Set method
-	if txVar := v.TX(); txVar != nil && v.ResponseBody() != nil {
-		// Store the content type and raw body
-		txVar.Set("json_response_body", []string{ss})
+	if txVar := v.TX(); txVar != nil && raw != nil && v.ResponseBody() != nil {
+		txVar.Set("json_response_body", []string{raw.String()})
 	}
 
 	return nil
 }
 
-func readJSON(s string, maxRecursion int) (map[string]string, error) {
-	res := make(map[string]string)
-	key := []byte("json")
+var (
+	errInvalidJSON       = errors.New("invalid JSON")
+	errJSONMaxRecursion  = errors.New("max recursion reached while reading json object")
+	errJSONArgumentLimit = errors.New("arguments limit reached while reading json object")
+)
 
-	json := gjson.Parse(s)
-	err := readItems(json, key, maxRecursion, res)
-	if err != nil {
-		return res, err
+// readJSON flattens the JSON document read from r calling set with every
+// value, as it is read. Nested values are keyed by their path and arrays by
+// their length, the reading stops at the first error.
+// maxRecursion is the maximum nesting of objects and arrays, a negative
+// value means unlimited. maxArgs is the maximum number of values set, 0
+// means unlimited.
+// Example input: {"data": {"name": "John", "age": 30}, "items": [1,2,3]}
+// Example output: json.data.name=John, json.data.age=30, json.items.0=1, json.items.1=2, json.items.2=3, json.items=3
+// Example input: [{"data": {"name": "John", "age": 30}, "items": [1,2,3]}]
+// Example output: json.0.data.name=John, json.0.data.age=30, json.0.items.0=1, json.0.items.1=2, json.0.items.2=3, json.0.items=3, json=1
+func readJSON(r io.Reader, maxRecursion int, maxArgs int, set func(key, value string)) error {
+	jr := &jsonReader{
+		r:            bufio.NewReader(r),
+		key:          []byte("json"),
+		maxRecursion: maxRecursion,
+		maxArgs:      maxArgs,
+		set:          set,
 	}
-	if !gjson.Valid(s) {
-		return res, errors.New("invalid JSON")
+	if err := jr.readValue(); err != nil {
+		return err
 	}
-	return res, nil
+	// Only whitespace may follow the document
+	if _, err := jr.nextToken(); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		return errInvalidJSON
+	}
+	return nil
 }
 
-// Transform JSON to a map[string]string
-// This function is recursive and will call itself for nested objects.
-// The limit in recursion is defined by maxItems.
-// Example input: {"data": {"name": "John", "age": 30}, "items": [1,2,3]}
-// Example output: map[string]string{"json.data.name": "John", "json.data.age": "30", "json.items.0": "1", "json.items.1": "2", "json.items.2": "3"}
-// Example input: [{"data": {"name": "John", "age": 30}, "items": [1,2,3]}]
-// Example output: map[string]string{"json.0.data.name": "John", "json.0.data.age": "30", "json.0.items.0": "1", "json.0.items.1": "2", "json.0.items.2": "3"}
-func readItems(json gjson.Result, objKey []byte, maxRecursion int, res map[string]string) error {
-	arrayLen := 0
-	var iterationError error
-	if maxRecursion == 0 {
-		// We reached the limit of nesting we want to handle. This protects against
-		// DoS attacks using deeply nested JSON structures (e.g., {"a":{"a":{"a":...}}}).
-		return errors.New("max recursion reached while reading json object")
-	}
-	json.ForEach(func(key, value gjson.Result) bool {
-		// Avoid string concatenation to maintain a single buffer for key aggregation.
-		prevParentLength := len(objKey)
-		objKey = append(objKey, '.')
-		if key.Type == gjson.String {
-			objKey = append(objKey, key.Str...)
+// jsonReader is a tokenizer reading a JSON document without buffering it.
+type jsonReader struct {
+	r *bufio.Reader
+	// key holds the path of the value being read, a single buffer is kept
+	// for the whole document to avoid string concatenation
+	key          []byte
+	maxRecursion int
+	maxArgs      int
+	args         int
+	set          func(key, value string)
+	// buf is the scratch buffer for strings and numbers
+	buf []byte
+}
+
+// readByte returns the next byte, an EOF is an invalid document as it is only
+// expected between tokens.
+func (jr *jsonReader) readByte() (byte, error) {
+	c, err := jr.r.ReadByte()
+	if err == io.EOF {
+		return 0, errInvalidJSON
+	}
+	return c, err
+}
+
+// nextToken returns the first byte that is not whitespace, or io.EOF.
+func (jr *jsonReader) nextToken() (byte, error) {
+	for {
+		c, err := jr.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch c {
+		case ' ', '\t', '\n', '\r':
+		default:
+			return c, nil
+		}
+	}
+}
+
+// next is nextToken inside of the document, where an EOF makes it invalid.
+func (jr *jsonReader) next() (byte, error) {
+	c, err := jr.nextToken()
+	if err == io.EOF {
+		return 0, errInvalidJSON
+	}
+	return c, err
+}
+
+func (jr *jsonReader) setValue(value string) error {
+	if jr.maxArgs > 0 && jr.args >= jr.maxArgs {
+		return errJSONArgumentLimit
+	}
+	jr.args++
+	jr.set(string(jr.key), value)
+	return nil
+}
+
+// readValue reads the value keyed by jr.key.
+func (jr *jsonReader) readValue() error {
+	c, err := jr.next()
+	if err != nil {
+		return err
+	}
+	switch {
+	case c == '{' || c == '[':
+		// The nesting limit protects against DoS attacks using deeply nested
+		// JSON structures (e.g., {"a":{"a":{"a":...}}}).
+		if jr.maxRecursion == 0 {
+			return errJSONMaxRecursion
+		}
+		jr.maxRecursion--
+		if c == '{' {
+			err = jr.readObject()
 		} else {
-			objKey = strconv.AppendInt(objKey, int64(key.Num), 10)
-			arrayLen++
+			err = jr.readArray()
+		}
+		jr.maxRecursion++
+		return err
+	case c == '"':
+		s, err := jr.readString()
+		if err != nil {
+			return err
+		}
+		return jr.setValue(s)
+	case c == 't':
+		return jr.readLiteral("true")
+	case c == 'f':
+		return jr.readLiteral("false")
+	case c == 'n':
+		return jr.readLiteral("null")
+	case c == '-' || (c >= '0' && c <= '9'):
+		// Numbers keep their literal representation
+		n, err := jr.readNumber(c)
+		if err != nil {
+			return err
+		}
+		return jr.setValue(n)
+	}
+	return errInvalidJSON
+}
+
+func (jr *jsonReader) readObject() error {
+	c, err := jr.next()
+	if err != nil {
+		return err
+	}
+	if c == '}' {
+		return nil
+	}
+	for {
+		if c != '"' {
+			return errInvalidJSON
+		}
+		name, err := jr.readString()
+		if err != nil {
+			return err
+		}
+		if c, err = jr.next(); err != nil {
+			return err
+		}
+		if c != ':' {
+			return errInvalidJSON
 		}
 
-		var val string
-		switch value.Type {
-		case gjson.JSON:
-			// call recursively with one less item to avoid doing infinite recursion
-			iterationError = readItems(value, objKey, maxRecursion-1, res)
-			if iterationError != nil {
-				return false
+		prevParentLength := len(jr.key)
+		jr.key = append(append(jr.key, '.'), name...)
+		err = jr.readValue()
+		jr.key = jr.key[:prevParentLength]
+		if err != nil {
+			return err
+		}
+
+		if c, err = jr.next(); err != nil {
+			return err
+		}
+		switch c {
+		case '}':
+			return nil
+		case ',':
+			if c, err = jr.next(); err != nil {
+				return err
 			}
-			objKey = objKey[:prevParentLength]
-			return true
-		case gjson.String:
-			val = value.Str
-		case gjson.Null:
-			val = ""
 		default:
-			// For all other types, raw JSON is what we need
-			val = value.Raw
+			return errInvalidJSON
+		}
+	}
+}
+
+func (jr *jsonReader) readArray() error {
+	c, err := jr.next()
+	if err != nil {
+		return err
+	}
+	if c == ']' {
+		return nil
+	}
+	if err := jr.r.UnreadByte(); err != nil {
+		return err
+	}
+	n := 0
+	for {
+		prevParentLength := len(jr.key)
+		jr.key = strconv.AppendInt(append(jr.key, '.'), int64(n), 10)
+		err := jr.readValue()
+		jr.key = jr.key[:prevParentLength]
+		if err != nil {
+			return jr.truncatedArray(n, err)
+		}
+		n++
+
+		if c, err = jr.next(); err != nil {
+			return jr.truncatedArray(n, err)
+		}
+		switch c {
+		case ']':
+			// Arrays are also keyed by their length
+			return jr.setValue(strconv.Itoa(n))
+		case ',':
+		default:
+			return jr.truncatedArray(n, errInvalidJSON)
 		}
+	}
+}
 
-		res[string(objKey)] = val
-		objKey = objKey[:prevParentLength]
+// truncatedArray keys an array by the number of elements read before err,
+// as a best effort inspection of truncated bodies.
+func (jr *jsonReader) truncatedArray(n int, err error) error {
+	if n > 0 && err != errJSONArgumentLimit {
+		_ = jr.setValue(strconv.Itoa(n))
+	}
+	return err
+}
 
-		return true
-	})
-	if arrayLen > 0 {
-		res[string(objKey)] = strconv.Itoa(arrayLen)
+// readLiteral reads the rest of true, false or null, null values are set
+// empty.
+func (jr *jsonReader) readLiteral(literal string) error {
+	for i := 1; i < len(literal); i++ {
+		c, err := jr.readByte()
+		if err != nil {
+			return err
+		}
+		if c != literal[i] {
+			return errInvalidJSON
+		}
+	}
+	if literal == "null" {
+		return jr.setValue("")
+	}
+	return jr.setValue(literal)
+}
+
+// readNumber reads a number starting by first as defined in RFC 8259.
+func (jr *jsonReader) readNumber(first byte) (string, error) {
+	jr.buf = append(jr.buf[:0], first)
+	for {
+		c, err := jr.r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if (c < '0' || c > '9') && c != '.' && c != 'e' && c != 'E' && c != '+' && c != '-' {
+			if err := jr.r.UnreadByte(); err != nil {
+				return "", err
+			}
+			break
+		}
+		jr.buf = append(jr.buf, c)
+	}
+	if !validJSONNumber(jr.buf) {
+		return "", errInvalidJSON
+	}
+	return string(jr.buf), nil
+}
+
+func validJSONNumber(n []byte) bool {
+	i := 0
+	if n[i] == '-' {
+		i++
+	}
+	digits := func() int {
+		start := i
+		for i < len(n) && n[i] >= '0' && n[i] <= '9' {
+			i++
+		}
+		return i - start
+	}
+	switch {
+	case i < len(n) && n[i] == '0':
+		i++
+	case digits() == 0:
+		return false
+	}
+	if i < len(n) && n[i] == '.' {
+		i++
+		if digits() == 0 {
+			return false
+		}
+	}
+	if i < len(n) && (n[i] == 'e' || n[i] == 'E') {
+		i++
+		if i < len(n) && (n[i] == '+' || n[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+	return i == len(n)
+}
+
+// readString reads a string whose opening quote was already read, decoding
+// its escape sequences.
+func (jr *jsonReader) readString() (string, error) {
+	jr.buf = jr.buf[:0]
+	for {
+		c, err := jr.readByte()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case c == '"':
+			return string(jr.buf), nil
+		case c < 0x20:
+			return "", errInvalidJSON
+		case c != '\\':
+			jr.buf = append(jr.buf, c)
+			continue
+		}
+
+		if c, err = jr.readByte(); err != nil {
+			return "", err
+		}
+		switch c {
+		case '"', '\\', '/':
+			jr.buf = append(jr.buf, c)
+		case 'b':
+			jr.buf = append(jr.buf, '\b')
+		case 'f':
+			jr.buf = append(jr.buf, '\f')
+		case 'n':
+			jr.buf = append(jr.buf, '\n')
+		case 'r':
+			jr.buf = append(jr.buf, '\r')
+		case 't':
+			jr.buf = append(jr.buf, '\t')
+		case 'u':
+			r, err := jr.readHex4()
+			if err != nil {
+				return "", err
+			}
+			if utf16.IsSurrogate(r) {
+				// The low surrogate is expected right after the high one,
+				// invalid pairs are replaced as encoding/json does
+				if b, err := jr.r.Peek(2); err == nil && b[0] == '\\' && b[1] == 'u' {
+					_, _ = jr.r.Discard(2)
+					r2, err := jr.readHex4()
+					if err != nil {
+						return "", err
+					}
+					if dec := utf16.DecodeRune(r, r2); dec != utf8.RuneError {
+						r = dec
+					} else {
+						jr.buf = utf8.AppendRune(jr.buf, utf8.RuneError)
+						r = r2
+					}
+				}
+				if utf16.IsSurrogate(r) {
+					r = utf8.RuneError
+				}
+			}
+			jr.buf = utf8.AppendRune(jr.buf, r)
+		default:
+			return "", errInvalidJSON
+		}
+	}
+}
+
+func (jr *jsonReader) readHex4() (rune, error) {
+	var r rune
+	for i := 0; i < 4; i++ {
+		c, err := jr.readByte()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, errInvalidJSON
+		}
+		r = r<<4 | rune(c)
 	}
-	return iterationError
+	return r, nil
 }
 
 func init() {
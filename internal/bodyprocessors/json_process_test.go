@@ -75,6 +75,7 @@ func TestJSONProcessRequestStoresRawBodyInTX(t *testing.T) {
 	body := `{"user": "coraza"}`
 	if err := bp.ProcessRequest(strings.NewReader(body), v, plugintypes.BodyProcessorOptions{
 		RequestBodyRecursionLimit: jsonRecursionLimit,
+		KeepRawBody:               true,
 	}); err != nil {
 		t.Fatal(err)
 	}
@@ -88,6 +89,20 @@ func TestJSONProcessRequestStoresRawBodyInTX(t *testing.T) {
 	}
 }
 
+func TestJSONProcessRequestDoesNotKeepRawBodyByDefault(t *testing.T) {
+	bp := jsonProcessor(t)
+	v := corazawaf.NewTransactionVariables()
+
+	if err := bp.ProcessRequest(strings.NewReader(`{"user": "coraza"}`), v, plugintypes.BodyProcessorOptions{
+		RequestBodyRecursionLimit: jsonRecursionLimit,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := v.TX().Get("json_request_body"); len(got) != 0 {
+		t.Errorf("expected no raw body in TX, got %v", got)
+	}
+}
+
 func TestJSONProcessRequestInvalidJSONReturnsError(t *testing.T) {
 	bp := jsonProcessor(t)
 	v := corazawaf.NewTransactionVariables()
@@ -132,6 +147,62 @@ func TestJSONProcessRequestRecursionLimit(t *testing.T) {
 	}
 }
 
+// limitedReader fails when the body is read past n bytes, it makes sure the
+// processor stops reading when a limit is reached.
+type limitedReader struct {
+	r io.Reader
+	n int
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, errors.New("read past the limit")
+	}
+	p = p[:min(len(p), l.n)]
+	n, err := l.r.Read(p)
+	l.n -= n
+	return n, err
+}
+
+func TestJSONProcessRequestArgumentsLimit(t *testing.T) {
+	bp := jsonProcessor(t)
+	v := corazawaf.NewTransactionVariables()
+
+	// The second chunk of the body is never read
+	body := `{"a": 1, "b": [2, 3], "c": 4` + strings.Repeat(" ", 8192) + `, "d": 5}`
+	err := bp.ProcessRequest(&limitedReader{r: strings.NewReader(body), n: 4096}, v, plugintypes.BodyProcessorOptions{
+		RequestBodyRecursionLimit: jsonRecursionLimit,
+		ArgumentsLimit:            3,
+	})
+	if err == nil || !strings.Contains(err.Error(), "arguments limit reached") {
+		t.Fatalf("expected an arguments limit error, got %v", err)
+	}
+	argsPost := v.ArgsPost()
+	for _, key := range []string{"json.a", "json.b.0", "json.b.1"} {
+		if len(argsPost.Get(key)) == 0 {
+			t.Errorf("missing ARGS_POST key %q", key)
+		}
+	}
+	for _, key := range []string{"json.b", "json.c", "json.d"} {
+		if got := argsPost.Get(key); len(got) != 0 {
+			t.Errorf("unexpected ARGS_POST key %q=%v", key, got)
+		}
+	}
+}
+
+func TestJSONProcessRequestRecursionLimitStopsReading(t *testing.T) {
+	bp := jsonProcessor(t)
+	v := corazawaf.NewTransactionVariables()
+
+	body := strings.Repeat(`[`, 10000) + strings.Repeat(`]`, 10000)
+	err := bp.ProcessRequest(&limitedReader{r: strings.NewReader(body), n: 4096}, v, plugintypes.BodyProcessorOptions{
+		RequestBodyRecursionLimit: jsonRecursionLimit,
+	})
+	if err == nil || !strings.Contains(err.Error(), "max recursion reached") {
+		t.Errorf("expected max recursion error, got %v", err)
+	}
+}
+
 func TestJSONProcessRequestReaderError(t *testing.T) {
 	bp := jsonProcessor(t)
 	v := corazawaf.NewTransactionVariables()
@@ -151,6 +222,7 @@ func TestJSONProcessRequestEmptyObject(t *testing.T) {
 
 	if err := bp.ProcessRequest(strings.NewReader(`{}`), v, plugintypes.BodyProcessorOptions{
 		RequestBodyRecursionLimit: jsonRecursionLimit,
+		KeepRawBody:               true,
 	}); err != nil {
 		t.Fatal(err)
 	}
@@ -193,7 +265,7 @@ func TestJSONProcessResponseStoresRawBodyInTX(t *testing.T) {
 	v := corazawaf.NewTransactionVariables()
 
 	body := `{"user": "coraza"}`
-	if err := bp.ProcessResponse(strings.NewReader(body), v, plugintypes.BodyProcessorOptions{}); err != nil {
+	if err := bp.ProcessResponse(strings.NewReader(body), v, plugintypes.BodyProcessorOptions{KeepRawBody: true}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -263,7 +335,7 @@ func TestJSONProcessResponseEmptyObject(t *testing.T) {
 	bp := jsonProcessor(t)
 	v := corazawaf.NewTransactionVariables()
 
-	if err := bp.ProcessResponse(strings.NewReader(`{}`), v, plugintypes.BodyProcessorOptions{}); err != nil {
+	if err := bp.ProcessResponse(strings.NewReader(`{}`), v, plugintypes.BodyProcessorOptions{KeepRawBody: true}); err != nil {
 		t.Fatal(err)
 	}
 	if got := v.TX().Get("json_response_body"); len(got) != 1 || got[0] != `{}` {
@@ -7,8 +7,6 @@ import (
 	"errors"
 	"strings"
 	"testing"
-
-	"github.com/tidwall/gjson"
 )
 
 const (
@@ -170,6 +168,30 @@ var jsonTests = []struct {
 			"json.false": "false",
 		},
 	},
+	{
+		name: "escapes_and_numbers",
+		json: `{"a\"b": "\u00e9\n\ud83d\ude00\/", "n": -1.5e+3, "z": 0}`,
+		want: map[string]string{
+			`json.a"b`: "é\n😀/",
+			"json.n":   "-1.5e+3",
+			"json.z":   "0",
+		},
+	},
+	{
+		name: "trailing_comma",
+		json: `{"a": 1,}`,
+		err:  errors.New("invalid JSON"),
+	},
+	{
+		name: "leading_zero",
+		json: `[01]`,
+		err:  errors.New("invalid JSON"),
+	},
+	{
+		name: "unterminated_string",
+		json: `["abc`,
+		err:  errors.New("invalid JSON"),
+	},
 	// For this test we won't validate keys since the implementation
 	// might process empty objects/arrays differently
 	{
@@ -179,11 +201,20 @@ var jsonTests = []struct {
 	},
 }
 
+// readJSONMap reads the JSON document in s into a map
+func readJSONMap(s string, maxRecursion int) (map[string]string, error) {
+	res := make(map[string]string)
+	err := readJSON(strings.NewReader(s), maxRecursion, 0, func(key, value string) {
+		res[key] = value
+	})
+	return res, err
+}
+
 func TestReadJSON(t *testing.T) {
 	for _, tc := range jsonTests {
 		tt := tc
 		t.Run(tt.name, func(t *testing.T) {
-			jsonMap, err := readJSON(tt.json, maxRecursion)
+			jsonMap, err := readJSONMap(tt.json, maxRecursion)
 
 			// Special case for nested_empty - just check that the function doesn't error
 			if tt.name == "nested_empty" {
@@ -230,7 +261,7 @@ func mapKeys(m map[string]string) []string {
 }
 
 func TestInvalidJSON(t *testing.T) {
-	_, err := readJSON(`{invalid json`, maxRecursion)
+	_, err := readJSONMap(`{invalid json`, maxRecursion)
 	if err == nil {
 		// We expect an error for invalid JSON since we now validate
 		t.Error("Expected error for invalid JSON, got nil")
@@ -242,7 +273,7 @@ func BenchmarkReadJSON(b *testing.B) {
 		tt := tc
 		b.Run(tt.name, func(b *testing.B) {
 			for i := 0; i < b.N; i++ {
-				_, err := readJSON(tt.json, maxRecursion)
+				_, err := readJSONMap(tt.json, maxRecursion)
 				if err != nil {
 					b.Error(err)
 				}
@@ -251,21 +282,9 @@ func BenchmarkReadJSON(b *testing.B) {
 	}
 }
 
-// readJSONNoValidation is readJSON without the gjson.Valid pre-check.
-// Used only in benchmarks to measure the overhead of validation.
-func readJSONNoValidation(s string, maxRecursion int) (map[string]string, error) {
-	json := gjson.Parse(s)
-	res := make(map[string]string)
-	key := []byte("json")
-	err := readItems(json, key, maxRecursion, res)
-	return res, err
-}
-
-// BenchmarkValidationOverhead measures the cost of pre-validating JSON with gjson.Valid
-// in the context of the full readJSON pipeline (Valid + Parse + readItems).
-// gjson.Parse is lazy (~9ns regardless of input size), so the real overhead is
-// gjson.Valid vs the readItems traversal that does the actual parsing work.
-func BenchmarkValidationOverhead(b *testing.B) {
+// BenchmarkReadJSONDocuments measures the tokenizer on documents of different
+// shapes, as the whole document is read byte by byte.
+func BenchmarkReadJSONDocuments(b *testing.B) {
 	benchCases := []struct {
 		name string
 		json string
@@ -300,18 +319,10 @@ func BenchmarkValidationOverhead(b *testing.B) {
 	}
 
 	for _, bc := range benchCases {
-		b.Run("WithValidation/"+bc.name, func(b *testing.B) {
-			b.SetBytes(int64(len(bc.json)))
-			for i := 0; i < b.N; i++ {
-				if _, err := readJSON(bc.json, maxRecursion); err != nil {
-					b.Fatal(err)
-				}
-			}
-		})
-		b.Run("WithoutValidation/"+bc.name, func(b *testing.B) {
+		b.Run(bc.name, func(b *testing.B) {
 			b.SetBytes(int64(len(bc.json)))
 			for i := 0; i < b.N; i++ {
-				if _, err := readJSONNoValidation(bc.json, maxRecursion); err != nil {
+				if err := readJSON(strings.NewReader(bc.json), maxRecursion, 0, func(string, string) {}); err != nil {
 					b.Fatal(err)
 				}
 			}
@@ -6,57 +6,147 @@ package bodyprocessors
 import (
 	"encoding/xml"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
 
+	"github.com/corazawaf/coraza/v3/collection"
 	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/xpath"
 )
 
 type xmlBodyProcessor struct {
 }
 
 func (*xmlBodyProcessor) ProcessRequest(reader io.Reader, v plugintypes.TransactionVariables, options plugintypes.BodyProcessorOptions) error {
-	values, contents, err := readXML(reader)
+	return processXML(reader, v.RequestXML(), options)
+}
+
+func (*xmlBodyProcessor) ProcessResponse(reader io.Reader, v plugintypes.TransactionVariables, options plugintypes.BodyProcessorOptions) error {
+	return processXML(reader, v.ResponseXML(), options)
+}
+
+// xmlDocumentSetter is implemented by the collections able to evaluate
+// XPath expressions.
+type xmlDocumentSetter interface {
+	SetDocument(doc *xpath.Node)
+}
+
+func processXML(reader io.Reader, col collection.Map, options plugintypes.BodyProcessorOptions) error {
+	doc, err := readXML(reader, xmlLimits{depth: options.XMLDepthLimit, nodes: options.XMLNodeLimit})
 	if err != nil {
 		return err
 	}
-	col := v.RequestXML()
-	col.Set("//@*", values)
-	col.Set("/*", contents)
+	col.Set("//@*", doc.attrs)
+	col.Set("/*", doc.contents)
+	if c, ok := col.(xmlDocumentSetter); ok {
+		c.SetDocument(doc.root)
+	}
 	return nil
 }
 
-func (*xmlBodyProcessor) ProcessResponse(reader io.Reader, v plugintypes.TransactionVariables, options plugintypes.BodyProcessorOptions) error {
-	return nil
+// xmlLimits bounds the documents built by readXML, zero means unlimited.
+type xmlLimits struct {
+	depth int
+	nodes int
 }
 
-func readXML(reader io.Reader) ([]string, []string, error) {
-	var attrs []string
-	var content []string
+type xmlDocument struct {
+	root *xpath.Node
+	// attrs are the values of all the attributes
+	attrs []string
+	// contents are the non blank text nodes, trimmed
+	contents []string
+}
+
+var (
+	errXMLDepthLimit = errors.New("xml: depth limit exceeded")
+	errXMLNodeLimit  = errors.New("xml: node limit exceeded")
+)
+
+func readXML(reader io.Reader, limits xmlLimits) (xmlDocument, error) {
+	doc := xmlDocument{root: xpath.NewDocument()}
 	dec := xml.NewDecoder(reader)
 	dec.Strict = false
 	dec.AutoClose = xml.HTMLAutoClose
 	dec.Entity = xml.HTMLEntity
+
+	current := doc.root
+	depth, nodes := 0, 0
+	appendNode := func(parent *xpath.Node, n *xpath.Node) error {
+		nodes++
+		if limits.nodes > 0 && nodes > limits.nodes {
+			return fmt.Errorf("%w: %d", errXMLNodeLimit, limits.nodes)
+		}
+		if n.Type == xpath.AttributeNode {
+			parent.AppendAttribute(n)
+		} else {
+			parent.AppendChild(n)
+		}
+		return nil
+	}
+
 	for {
 		token, err := dec.Token()
 		if err != nil && err != io.EOF && !isUnexpectedEOFXMLSyntaxError(err) {
-			return nil, nil, err
+			return xmlDocument{}, err
 		}
 		if token == nil {
 			break
 		}
 		switch tok := token.(type) {
 		case xml.StartElement:
+			depth++
+			if limits.depth > 0 && depth > limits.depth {
+				return xmlDocument{}, fmt.Errorf("%w: %d", errXMLDepthLimit, limits.depth)
+			}
+			el := &xpath.Node{Type: xpath.ElementNode, Name: tok.Name.Local, Space: tok.Name.Space}
+			if err := appendNode(current, el); err != nil {
+				return xmlDocument{}, err
+			}
 			for _, attr := range tok.Attr {
-				attrs = append(attrs, attr.Value)
+				doc.attrs = append(doc.attrs, attr.Value)
+				// namespace declarations are not attributes in the XPath data model
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					continue
+				}
+				a := &xpath.Node{Type: xpath.AttributeNode, Name: attr.Name.Local, Space: attr.Name.Space, Data: attr.Value}
+				if err := appendNode(el, a); err != nil {
+					return xmlDocument{}, err
+				}
+			}
+			current = el
+		case xml.EndElement:
+			if current.Parent != nil {
+				current = current.Parent
+				depth--
 			}
 		case xml.CharData:
 			if c := strings.TrimSpace(string(tok)); c != "" {
-				content = append(content, c)
+				doc.contents = append(doc.contents, c)
+			}
+			if current == doc.root {
+				// text outside of the root element is not part of the document
+				continue
+			}
+			if err := appendNode(current, &xpath.Node{Type: xpath.TextNode, Data: string(tok)}); err != nil {
+				return xmlDocument{}, err
+			}
+		case xml.Comment:
+			if err := appendNode(current, &xpath.Node{Type: xpath.CommentNode, Data: string(tok)}); err != nil {
+				return xmlDocument{}, err
+			}
+		case xml.ProcInst:
+			if tok.Target == "xml" {
+				continue
+			}
+			if err := appendNode(current, &xpath.Node{Type: xpath.ProcessingInstructionNode, Name: tok.Target, Data: string(tok.Inst)}); err != nil {
+				return xmlDocument{}, err
 			}
 		}
 	}
-	return attrs, content, nil
+	doc.root.Index()
+	return doc, nil
 }
 
 func isUnexpectedEOFXMLSyntaxError(err error) bool {
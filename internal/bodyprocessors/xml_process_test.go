@@ -0,0 +1,76 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package bodyprocessors_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/bodyprocessors"
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+)
+
+const xmlBody = `<employees>
+  <employee id="1"><name>Fred</name><salary>1000</salary></employee>
+  <employee id="2"><name>Jane</name><salary>2000</salary></employee>
+</employees>`
+
+func TestXMLProcessXPath(t *testing.T) {
+	bp, err := bodyprocessors.GetBodyProcessor("xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		process func(v plugintypes.TransactionVariables) error
+		col     func(v plugintypes.TransactionVariables) collection.Map
+	}{
+		{
+			name: "request",
+			process: func(v plugintypes.TransactionVariables) error {
+				return bp.ProcessRequest(strings.NewReader(xmlBody), v, plugintypes.BodyProcessorOptions{})
+			},
+			col: plugintypes.TransactionVariables.RequestXML,
+		},
+		{
+			name: "response",
+			process: func(v plugintypes.TransactionVariables) error {
+				return bp.ProcessResponse(strings.NewReader(xmlBody), v, plugintypes.BodyProcessorOptions{})
+			},
+			col: plugintypes.TransactionVariables.ResponseXML,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			v := corazawaf.NewTransactionVariables()
+			if err := tc.process(v); err != nil {
+				t.Fatal(err)
+			}
+			col := tc.col(v)
+
+			if got := col.Get("//@*"); len(got) != 2 {
+				t.Errorf("expected 2 attribute values, got %v", got)
+			}
+			if got := col.Get("/*"); len(got) != 4 {
+				t.Errorf("expected 4 text values, got %v", got)
+			}
+			if got := col.Get("/employees/employee/name"); len(got) != 2 || got[0] != "Fred" || got[1] != "Jane" {
+				t.Errorf("unexpected names %v", got)
+			}
+			if got := col.Get("//employee[salary > 1500]/@id"); len(got) != 1 || got[0] != "2" {
+				t.Errorf("unexpected ids %v", got)
+			}
+			m := col.FindString("sum(//salary)")
+			if len(m) != 1 || m[0].Value() != "3000" || m[0].Key() != "sum(//salary)" {
+				t.Errorf("unexpected matches %v", m)
+			}
+			if got := col.Get("/missing"); len(got) != 0 {
+				t.Errorf("expected no values, got %v", got)
+			}
+		})
+	}
+}
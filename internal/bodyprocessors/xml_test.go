@@ -5,6 +5,7 @@ package bodyprocessors
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 
 	"github.com/corazawaf/coraza/v3/internal/strings"
@@ -24,10 +25,11 @@ func TestXMLAttribures(t *testing.T) {
 </book>
 
 </bookstore>`
-	attrs, contents, err := readXML(bytes.NewReader([]byte(xmldoc)))
+	doc, err := readXML(bytes.NewReader([]byte(xmldoc)), xmlLimits{})
 	if err != nil {
 		t.Error(err)
 	}
+	attrs, contents := doc.attrs, doc.contents
 	if len(attrs) != 3 {
 		t.Errorf("Expected 3 attributes, got %d", len(attrs))
 	}
@@ -55,10 +57,11 @@ func TestXMLPayloadFlexibility(t *testing.T) {
 			<heading>Reminder</heading>
 			<body>Don't forget me this weekend!
 		</note>`
-	_, contents, err := readXML(bytes.NewReader([]byte(xmldoc)))
+	doc, err := readXML(bytes.NewReader([]byte(xmldoc)), xmlLimits{})
 	if err != nil {
 		t.Error(err)
 	}
+	contents := doc.contents
 	for _, content := range []string{"Tove", "Jani", "Reminder", "Don't forget me this weekend!"} {
 		if !strings.InSlice(content, contents) {
 			t.Errorf("Expected content %s, got %v", content, contents)
@@ -105,10 +108,11 @@ func TestXMLUnexpectedEOF(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
 			xmldoc := tc.Input
-			_, contents, err := readXML(bytes.NewReader([]byte(xmldoc)))
+			doc, err := readXML(bytes.NewReader([]byte(xmldoc)), xmlLimits{})
 			if err != nil {
 				t.Error(err)
 			}
+			contents := doc.contents
 			if got, want := len(contents), len(tc.Want); got != want {
 				t.Errorf("contents count mismatch, got=%d, want=%d", got, want)
 			}
@@ -120,3 +124,58 @@ func TestXMLUnexpectedEOF(t *testing.T) {
 		})
 	}
 }
+
+func TestXMLDocument(t *testing.T) {
+	xmldoc := `<?xml version="1.0"?>
+<!-- employees -->
+<employees xmlns:h="http://www.w3.org/TR/html4/">
+  <employee id="1"><name>Fred</name><h:td>x</h:td></employee>
+  <?audit pending?>
+</employees>`
+	doc, err := readXML(bytes.NewReader([]byte(xmldoc)), xmlLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(doc.root.Children); got != 2 {
+		t.Fatalf("expected a comment and the root element, got %d children", got)
+	}
+	employees := doc.root.Children[1]
+	if employees.Name != "employees" || len(employees.Attributes) != 0 {
+		t.Errorf("unexpected root element %q with %d attributes", employees.Name, len(employees.Attributes))
+	}
+	employee := employees.Children[1]
+	if employee.Attributes[0].Name != "id" || employee.Attributes[0].Data != "1" {
+		t.Errorf("unexpected attribute %q=%q", employee.Attributes[0].Name, employee.Attributes[0].Data)
+	}
+	if got, want := employee.StringValue(), "Fredx"; got != want {
+		t.Errorf("unexpected string value, got %q, want %q", got, want)
+	}
+	if got, want := employee.Children[1].Space, "http://www.w3.org/TR/html4/"; got != want {
+		t.Errorf("unexpected namespace, got %q, want %q", got, want)
+	}
+	if pi := employees.Children[3]; pi.Name != "audit" || pi.Data != "pending" {
+		t.Errorf("unexpected processing instruction %q %q", pi.Name, pi.Data)
+	}
+}
+
+func TestXMLLimits(t *testing.T) {
+	xmldoc := `<a><b><c x="1">text</c></b></a>`
+	tests := []struct {
+		name   string
+		limits xmlLimits
+		err    error
+	}{
+		{"unlimited", xmlLimits{}, nil},
+		{"within limits", xmlLimits{depth: 3, nodes: 5}, nil},
+		{"depth", xmlLimits{depth: 2}, errXMLDepthLimit},
+		{"nodes", xmlLimits{nodes: 4}, errXMLNodeLimit},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readXML(bytes.NewReader([]byte(xmldoc)), tc.limits)
+			if !errors.Is(err, tc.err) {
+				t.Errorf("unexpected error, got %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
@@ -93,6 +93,20 @@ func (c *ConcatKeyed) FindAll() []types.MatchData {
 	return res
 }
 
+// Len returns the sum of the lengths of the collections. Collections not
+// reporting their length count as one, as they may not be empty.
+func (c *ConcatKeyed) Len() int {
+	n := 0
+	for _, d := range c.data {
+		if l, ok := d.(interface{ Len() int }); ok {
+			n += l.Len()
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
 // Name returns the name for the current CollectionconcatCollection
 func (c *ConcatKeyed) Name() string {
 	return c.variable.Name()
@@ -27,8 +27,14 @@ func TestConcatKeyed(t *testing.T) {
 	}
 
 	assertValuesMatch(t, c.FindAll())
+	if c.Len() != 0 {
+		t.Errorf("expected an empty collection, got length %d", c.Len())
+	}
 
 	c1.Add("animal", "cat")
+	if c.Len() != 1 {
+		t.Errorf("expected length 1, got %d", c.Len())
+	}
 
 	assertValuesMatch(t, c.FindAll(), "cat")
 	assertValuesMatch(t, c.FindString("animal"), "cat")
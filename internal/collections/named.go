@@ -177,6 +177,11 @@ func (c *NamedCollectionNames) FindAll() []types.MatchData {
 	return res
 }
 
+// Len returns the number of keys of the underlying collection.
+func (c *NamedCollectionNames) Len() int {
+	return len(c.collection.data)
+}
+
 func (c *NamedCollectionNames) Name() string {
 	return c.variable.Name()
 }
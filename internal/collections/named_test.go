@@ -98,6 +98,9 @@ func TestNames(t *testing.T) {
 	c.Set("key2", []string{"value2", "value3"})
 
 	names := c.Names(variables.ArgsPostNames)
+	if l := names.(*NamedCollectionNames).Len(); l != 2 {
+		t.Errorf("Error getting length, got %d instead of 2", l)
+	}
 
 	r := names.FindString("key2")
 
@@ -0,0 +1,109 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package collections
+
+import (
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/internal/corazarules"
+	"github.com/corazawaf/coraza/v3/internal/xpath"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// XML is a collection.Map whose keys are XPath 1.0 expressions evaluated
+// against the document set by the XML body processor. Keys stored in the
+// map, like the //@* and /* keys set by the body processor, take precedence
+// over the evaluation of the expression.
+type XML struct {
+	*Map
+	doc *xpath.Node
+}
+
+var _ collection.Map = &XML{}
+
+// NewXML creates a new XML collection.
+func NewXML(variable variables.RuleVariable) *XML {
+	return &XML{
+		Map: NewCaseSensitiveKeyMap(variable),
+	}
+}
+
+// SetDocument sets the document the keys are evaluated against.
+func (c *XML) SetDocument(doc *xpath.Node) {
+	c.doc = doc
+}
+
+// Document returns the document the keys are evaluated against, nil if none was set.
+func (c *XML) Document() *xpath.Node {
+	return c.doc
+}
+
+// Get returns the values stored for key, or the result of evaluating key
+// as an XPath expression.
+func (c *XML) Get(key string) []string {
+	if values := c.Map.Get(key); values != nil {
+		return values
+	}
+	return c.evaluate(key)
+}
+
+// FindString returns the elements stored for key, or the result of evaluating
+// key as an XPath expression, using the expression as key.
+func (c *XML) FindString(key string) []types.MatchData {
+	if key == "" {
+		return c.FindAll()
+	}
+	if res := c.Map.FindString(key); res != nil {
+		return res
+	}
+	return c.matchData(key, c.evaluate(key))
+}
+
+// FindExpr is FindString for an expression compiled beforehand, like the keys
+// of the rule variables compiled when the rules are parsed.
+func (c *XML) FindExpr(e *xpath.Expr) []types.MatchData {
+	key := e.String()
+	if res := c.Map.FindString(key); res != nil {
+		return res
+	}
+	if c.doc == nil {
+		return nil
+	}
+	return c.matchData(key, e.Evaluate(c.doc))
+}
+
+func (c *XML) matchData(key string, values []string) []types.MatchData {
+	if len(values) == 0 {
+		return nil
+	}
+	buf := make([]corazarules.MatchData, len(values))
+	result := make([]types.MatchData, len(values))
+	for i, v := range values {
+		buf[i] = corazarules.MatchData{
+			Variable_: c.variable,
+			Key_:      key,
+			Value_:    v,
+		}
+		result[i] = &buf[i]
+	}
+	return result
+}
+
+// Reset removes all key/value pairs and the document.
+func (c *XML) Reset() {
+	c.Map.Reset()
+	c.doc = nil
+}
+
+func (c *XML) evaluate(key string) []string {
+	if c.doc == nil {
+		return nil
+	}
+	e, err := xpath.Compile(key)
+	if err != nil {
+		// Keys are validated when rules are parsed
+		return nil
+	}
+	return e.Evaluate(c.doc)
+}
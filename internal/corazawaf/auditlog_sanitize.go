@@ -0,0 +1,378 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/internal/auditlog"
+	"github.com/corazawaf/coraza/v3/internal/collections"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// AuditLogSanitizer lists the data masked in the audit logs before they are
+// handed to the formatters. Names are matched case insensitively and masked
+// values are replaced by as many asterisks as bytes they have.
+type AuditLogSanitizer struct {
+	// Args are the names of the arguments whose values are masked, in the
+	// args, the query string and the urlencoded and JSON bodies
+	Args []string
+	// RequestHeaders are the names of the request headers whose values are masked
+	RequestHeaders []string
+	// ResponseHeaders are the names of the response headers whose values are masked
+	ResponseHeaders []string
+	// JSONPaths are dot separated paths of the JSON body values to mask, *
+	// matches any key or index, e.g. user.password or cards.*.number
+	JSONPaths []string
+	// Matches are the expressions whose matches are masked everywhere
+	Matches []*regexp.Regexp
+}
+
+// SanitizeArg masks the values of the argument in the audit log of the
+// transaction.
+func (tx *Transaction) SanitizeArg(name string) {
+	tx.auditLogSanitizer.Args = append(tx.auditLogSanitizer.Args, name)
+}
+
+// SanitizeRequestHeader masks the values of the request header in the audit
+// log of the transaction.
+func (tx *Transaction) SanitizeRequestHeader(name string) {
+	tx.auditLogSanitizer.RequestHeaders = append(tx.auditLogSanitizer.RequestHeaders, name)
+}
+
+// SanitizeResponseHeader masks the values of the response header in the
+// audit log of the transaction.
+func (tx *Transaction) SanitizeResponseHeader(name string) {
+	tx.auditLogSanitizer.ResponseHeaders = append(tx.auditLogSanitizer.ResponseHeaders, name)
+}
+
+// SanitizeMatched masks the variable matched last, MATCHED_VAR_NAME, in the
+// audit log of the transaction. Arguments and headers are masked by name, and
+// the matched value wherever it appears.
+func (tx *Transaction) SanitizeMatched() {
+	name, key, _ := strings.Cut(tx.variables.matchedVarName.Get(), ":")
+	if v, err := variables.Parse(name); err == nil && key != "" {
+		switch v {
+		case variables.Args, variables.ArgsGet, variables.ArgsPost, variables.ArgsPath:
+			tx.SanitizeArg(key)
+		case variables.RequestHeaders:
+			tx.SanitizeRequestHeader(key)
+		case variables.ResponseHeaders:
+			tx.SanitizeResponseHeader(key)
+		}
+	}
+	tx.SanitizeValue(tx.variables.matchedVar.Get())
+}
+
+// SanitizeValue masks the value wherever it appears in the audit log of the
+// transaction.
+func (tx *Transaction) SanitizeValue(value string) {
+	if value != "" {
+		tx.sanitizedValues = append(tx.sanitizedValues, value)
+	}
+}
+
+// auditLogSanitization holds the lookups to sanitize the audit log of a
+// transaction, merging the WAF configuration and the sanitise actions.
+type auditLogSanitization struct {
+	args            map[string]bool
+	requestHeaders  map[string]bool
+	responseHeaders map[string]bool
+	jsonPaths       [][]string
+	matches         []*regexp.Regexp
+	// values are masked wherever they are found
+	values []string
+}
+
+func (tx *Transaction) newAuditLogSanitization() *auditLogSanitization {
+	waf, local := &tx.WAF.AuditLogSanitizer, &tx.auditLogSanitizer
+	s := &auditLogSanitization{
+		args:            lowercaseSet(waf.Args, local.Args),
+		requestHeaders:  lowercaseSet(waf.RequestHeaders, local.RequestHeaders),
+		responseHeaders: lowercaseSet(waf.ResponseHeaders, local.ResponseHeaders),
+		matches:         waf.Matches,
+		values:          tx.sanitizedValues,
+	}
+	for _, p := range waf.JSONPaths {
+		s.jsonPaths = append(s.jsonPaths, strings.Split(p, "."))
+	}
+	if len(s.args)+len(s.requestHeaders)+len(s.responseHeaders)+len(s.jsonPaths)+len(s.matches)+len(s.values) == 0 {
+		return nil
+	}
+
+	// The values of the sanitized arguments and headers are also masked in
+	// the bodies of other types and in the messages
+	for _, md := range tx.variables.args.FindAll() {
+		if s.isArg(md.Key()) && md.Value() != "" {
+			s.values = append(s.values, md.Value())
+		}
+	}
+	for name, values := range tx.variables.requestHeaders.Data() {
+		if s.requestHeaders[name] {
+			for _, v := range values {
+				if v != "" {
+					s.values = append(s.values, v)
+				}
+			}
+		}
+	}
+	return s
+}
+
+func lowercaseSet(lists ...[]string) map[string]bool {
+	set := map[string]bool{}
+	for _, l := range lists {
+		for _, n := range l {
+			set[strings.ToLower(n)] = true
+		}
+	}
+	return set
+}
+
+func mask(s string) string {
+	return strings.Repeat("*", len(s))
+}
+
+// isArg returns true if the argument is sanitized by name or, for the
+// arguments of JSON bodies, by path.
+func (s *auditLogSanitization) isArg(name string) bool {
+	name = strings.ToLower(name)
+	if s.args[name] {
+		return true
+	}
+	if path, ok := strings.CutPrefix(name, "json."); ok && len(s.jsonPaths) > 0 {
+		return s.isJSONPath(strings.Split(path, "."))
+	}
+	return false
+}
+
+func (s *auditLogSanitization) isJSONPath(path []string) bool {
+	if s.args["json."+strings.ToLower(strings.Join(path, "."))] {
+		return true
+	}
+	for _, p := range s.jsonPaths {
+		if len(p) != len(path) {
+			continue
+		}
+		match := true
+		for i := range p {
+			if p[i] != "*" && !strings.EqualFold(p[i], path[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// text masks the expressions and the sanitized values found in s.
+func (s *auditLogSanitization) text(str string) string {
+	if str == "" {
+		return str
+	}
+	for _, re := range s.matches {
+		str = re.ReplaceAllStringFunc(str, mask)
+	}
+	for _, v := range s.values {
+		str = strings.ReplaceAll(str, v, mask(v))
+	}
+	return str
+}
+
+func (s *auditLogSanitization) headers(headers map[string][]string, names map[string]bool) map[string][]string {
+	for name, values := range headers {
+		for i, v := range values {
+			if names[name] {
+				values[i] = mask(v)
+			} else {
+				values[i] = s.text(v)
+			}
+		}
+	}
+	return headers
+}
+
+// uri masks the sanitized arguments of the query string of the URI.
+func (s *auditLogSanitization) uri(uri string) string {
+	path, query, ok := strings.Cut(uri, "?")
+	if !ok {
+		return s.text(uri)
+	}
+	return s.text(path) + "?" + s.urlencoded(query)
+}
+
+func (s *auditLogSanitization) urlencoded(body string) string {
+	pairs := strings.Split(body, "&")
+	for i, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if s.isArg(key) {
+			pairs[i] = pair[:len(pair)-len(value)] + mask(value)
+		}
+	}
+	return s.text(strings.Join(pairs, "&"))
+}
+
+// json masks the values of the sanitized paths of a JSON document, keeping
+// the document as it is otherwise. Scalars are masked as strings.
+func (s *auditLogSanitization) json(body string) string {
+	type frame struct {
+		array     bool
+		index     int
+		key       string
+		expectKey bool
+	}
+	var (
+		stack []frame
+		spans [][2]int
+		prev  int
+	)
+	path := func() []string {
+		p := make([]string, len(stack))
+		for i, f := range stack {
+			if f.array {
+				p[i] = strconv.Itoa(f.index)
+			} else {
+				p[i] = f.key
+			}
+		}
+		return p
+	}
+	advance := func() {
+		if len(stack) == 0 {
+			return
+		}
+		top := &stack[len(stack)-1]
+		if top.array {
+			top.index++
+		} else {
+			top.expectKey = true
+		}
+	}
+
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			// invalid documents are masked up to the error
+			break
+		}
+		start, end := prev, int(dec.InputOffset())
+		prev = end
+		for start < end && strings.IndexByte(" \t\r\n:,", body[start]) != -1 {
+			start++
+		}
+
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{':
+				stack = append(stack, frame{expectKey: true})
+			case '[':
+				stack = append(stack, frame{array: true})
+			default:
+				stack = stack[:len(stack)-1]
+				advance()
+			}
+			continue
+		case string:
+			if len(stack) > 0 && stack[len(stack)-1].expectKey {
+				stack[len(stack)-1].key = t
+				stack[len(stack)-1].expectKey = false
+				continue
+			}
+		}
+		if s.isJSONPath(path()) {
+			spans = append(spans, [2]int{start, end})
+		}
+		advance()
+	}
+
+	if len(spans) == 0 {
+		return s.text(body)
+	}
+	res := strings.Builder{}
+	last := 0
+	for _, span := range spans {
+		res.WriteString(body[last:span[0]])
+		value := body[span[0]:span[1]]
+		if strings.HasPrefix(value, `"`) {
+			value = value[1 : len(value)-1]
+		}
+		res.WriteString(`"` + mask(value) + `"`)
+		last = span[1]
+	}
+	res.WriteString(body[last:])
+	return s.text(res.String())
+}
+
+// body masks a body processed by the body processor.
+func (s *auditLogSanitization) body(body string, processor string) string {
+	switch processor {
+	case "URLENCODED":
+		return s.urlencoded(body)
+	case "JSON":
+		return s.json(body)
+	}
+	return s.text(body)
+}
+
+// argsCollection returns a copy of ARGS with the sanitized values masked.
+func (s *auditLogSanitization) argsCollection(args *collections.ConcatKeyed) *collections.ConcatKeyed {
+	res := collections.NewMap(variables.Args)
+	for _, md := range args.FindAll() {
+		if s.isArg(md.Key()) {
+			res.Add(md.Key(), mask(md.Value()))
+		} else {
+			res.Add(md.Key(), s.text(md.Value()))
+		}
+	}
+	return collections.NewConcatKeyed(variables.Args, res)
+}
+
+// sanitize masks the data of the audit log in place.
+func (s *auditLogSanitization) sanitize(al *auditlog.Log, requestBodyProcessor, responseBodyProcessor string) {
+	if req := al.Transaction_.Request_; req != nil {
+		req.URI_ = s.uri(req.URI_)
+		req.Headers_ = s.headers(req.Headers_, s.requestHeaders)
+		req.Body_ = s.body(req.Body_, requestBodyProcessor)
+		if req.Args_ != nil {
+			req.Args_ = s.argsCollection(req.Args_)
+		}
+	}
+	if res := al.Transaction_.Response_; res != nil {
+		res.Headers_ = s.headers(res.Headers_, s.responseHeaders)
+		res.Body_ = s.body(res.Body_, responseBodyProcessor)
+		res.IntermediaryHeaders_ = s.headers(res.IntermediaryHeaders_, s.responseHeaders)
+		res.FinalBody_ = s.body(res.FinalBody_, responseBodyProcessor)
+	}
+	for i, msg := range al.Messages_ {
+		m, ok := msg.(auditlog.Message)
+		if !ok {
+			continue
+		}
+		m.Message_ = s.text(m.Message_)
+		m.ErrorMessage_ = s.text(m.ErrorMessage_)
+		if m.Data_ != nil {
+			data := *m.Data_
+			data.Msg_ = s.text(data.Msg_)
+			data.Data_ = s.text(data.Data_)
+			m.Data_ = &data
+		}
+		al.Messages_[i] = m
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/internal/auditlog"
+	"github.com/corazawaf/coraza/v3/internal/collections"
+	"github.com/corazawaf/coraza/v3/internal/corazarules"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+func TestAuditLogSanitizeJSON(t *testing.T) {
+	s := &auditLogSanitization{
+		args:      map[string]bool{"json.pin": true},
+		jsonPaths: [][]string{{"user", "password"}, {"cards", "*", "number"}},
+	}
+	tests := map[string]string{
+		`{"user":{"name":"jane","password":"hunter2"}}`:                   `{"user":{"name":"jane","password":"*******"}}`,
+		`{ "cards" : [ {"number": 4111111111111111}, {"number":"42"} ] }`: `{ "cards" : [ {"number": "****************"}, {"number":"**"} ] }`,
+		`{"pin": null, "user": {"password": {"nested": true}}}`:           `{"pin": "****", "user": {"password": {"nested": true}}}`,
+		`{"user": {"password": "abc"`:                                     `{"user": {"password": "***"`,
+		`not json`:                                                        `not json`,
+	}
+	for body, want := range tests {
+		if have := s.json(body); have != want {
+			t.Errorf("unexpected masked document for %s\nwant: %s\nhave: %s", body, want, have)
+		}
+	}
+}
+
+func TestAuditLogSanitizeURLEncoded(t *testing.T) {
+	s := &auditLogSanitization{args: map[string]bool{"pass word": true}}
+	if want, have := "a=1&pass%20word=******&b&pass+word=**", s.urlencoded("a=1&pass%20word=s3cr3t&b&pass+word=ab"); want != have {
+		t.Errorf("unexpected masked body, want %q, have %q", want, have)
+	}
+}
+
+func TestAuditLogSanitize(t *testing.T) {
+	waf := NewWAF()
+	waf.RequestBodyAccess = true
+	waf.AuditLogParts = types.AuditLogParts("ABCFHKZ")
+	waf.AuditLogSanitizer = AuditLogSanitizer{
+		Args:            []string{"password"},
+		RequestHeaders:  []string{"Authorization"},
+		ResponseHeaders: []string{"Set-Cookie"},
+		JSONPaths:       []string{"card.number"},
+		Matches:         []*regexp.Regexp{regexp.MustCompile(`\d{3}-\d{2}-\d{4}`)},
+	}
+
+	tests := map[string]struct {
+		processor string
+		body      string
+		userArg   string
+		secrets   []string
+	}{
+		"urlencoded": {
+			processor: "URLENCODED",
+			body:      "user=jane&password=hunter2&ssn=123-45-6789",
+			userArg:   "user",
+			secrets:   []string{"hunter2", "123-45-6789", "Bearer t0k3n", "session=abc"},
+		},
+		"json": {
+			processor: "JSON",
+			body:      `{"user":"jane","password":"hunter2","card":{"number":"4111111111111111"}}`,
+			userArg:   "json.user",
+			secrets:   []string{"hunter2", "4111111111111111", "Bearer t0k3n", "session=abc"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tx := waf.NewTransaction()
+			defer tx.Close()
+			tx.ProcessURI("/login?password=hunter2&lang=en", "POST", "HTTP/1.1")
+			tx.AddRequestHeader("Authorization", "Bearer t0k3n")
+			tx.variables.RequestBodyProcessor().(*collections.Single).Set(tc.processor)
+			tx.ProcessRequestHeaders()
+			if _, _, err := tx.WriteRequestBody([]byte(tc.body)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tx.ProcessRequestBody(); err != nil {
+				t.Fatal(err)
+			}
+			tx.AddResponseHeader("Set-Cookie", "session=abc")
+			tx.ProcessResponseHeaders(200, "HTTP/1.1")
+
+			rule := NewRule()
+			rule.ID_ = 1
+			rule.Log = true
+			rule.Audit = true
+			tx.MatchRule(rule, []types.MatchData{
+				&corazarules.MatchData{
+					Variable_: variables.Args,
+					Key_:      "password",
+					Message_:  "Matched password hunter2",
+					Data_:     "hunter2",
+				},
+			})
+
+			al := tx.AuditLog()
+			if v := al.Transaction().Request().Args().Get(tc.userArg); len(v) != 1 || v[0] != "jane" {
+				t.Errorf("unexpected user argument %q", v)
+			}
+			if v := al.Transaction().Request().Args().Get("password"); len(v) == 0 || v[0] != "*******" {
+				t.Errorf("unexpected password argument %q", v)
+			}
+			if v := tx.variables.args.Get("password"); len(v) == 0 || v[0] != "hunter2" {
+				t.Errorf("the transaction arguments must not be masked, have %q", v)
+			}
+			if want, have := "/login?password=*******&lang=en", al.Transaction().Request().URI(); want != have {
+				t.Errorf("unexpected URI, want %q, have %q", want, have)
+			}
+			if !strings.Contains(al.Transaction().Request().Body(), "jane") {
+				t.Errorf("unexpected body %q", al.Transaction().Request().Body())
+			}
+
+			for _, name := range []string{"native", "json", "jsonlegacy", "ocsf"} {
+				f, err := auditlog.GetFormatter(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				out, err := f.Format(al)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, secret := range tc.secrets {
+					if strings.Contains(string(out), secret) {
+						t.Errorf("%s audit log contains %q:\n%s", name, secret, out)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAuditLogSanitizeMatched(t *testing.T) {
+	waf := NewWAF()
+	waf.AuditLogParts = types.AuditLogParts("ABFHZ")
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.AddRequestHeader("X-Card", "4111 1111 1111 1111")
+	tx.AddRequestHeader("Referer", "/pay?card=4111 1111 1111 1111")
+	tx.variables.matchedVarName.Set("REQUEST_HEADERS:x-card")
+	tx.variables.matchedVar.Set("4111 1111 1111 1111")
+	tx.SanitizeMatched()
+
+	headers := tx.AuditLog().Transaction().Request().Headers()
+	if want, have := strings.Repeat("*", 19), headers["x-card"][0]; want != have {
+		t.Errorf("unexpected x-card header, want %q, have %q", want, have)
+	}
+	if want, have := "/pay?card="+strings.Repeat("*", 19), headers["referer"][0]; want != have {
+		t.Errorf("unexpected referer header, want %q, have %q", want, have)
+	}
+}
+
+func TestAuditLogSanitizeValue(t *testing.T) {
+	waf := NewWAF()
+	waf.AuditLogParts = types.AuditLogParts("ABFHZ")
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.AddRequestHeader("Referer", "/orders?ssn=574-57-8065&card=4111111111111111")
+	tx.SanitizeValue("574-57-8065")
+	tx.SanitizeValue("")
+
+	headers := tx.AuditLog().Transaction().Request().Headers()
+	if want, have := "/orders?ssn=***********&card=4111111111111111", headers["referer"][0]; want != have {
+		t.Errorf("unexpected referer header, want %q, have %q", want, have)
+	}
+}
+
+func TestAuditLogSanitizeDisabled(t *testing.T) {
+	tx := NewWAF().NewTransaction()
+	defer tx.Close()
+	if tx.newAuditLogSanitization() != nil {
+		t.Error("unexpected sanitization without configuration")
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import "time"
+
+const (
+	// pcreLimitsExceeded is set when any of the inspection limits is reached,
+	// like ModSecurity does when the PCRE limits are exceeded.
+	pcreLimitsExceeded = "msc_pcre_limits_exceeded"
+	// operatorTimeLimitExceeded is set when the time budget of the operators
+	// is exhausted.
+	operatorTimeLimitExceeded = "msc_operator_time_limit_exceeded"
+)
+
+// LimitInspection returns the part of value the operators are allowed to
+// inspect, truncated to SecInspectionByteLimit bytes. It returns false once the
+// SecOperatorTimeLimit budget of the transaction is exhausted, the operator
+// must not be evaluated then.
+func (tx *Transaction) LimitInspection(value string) (string, bool) {
+	if limit := tx.WAF.OperatorTimeLimit; limit > 0 && tx.operatorTime >= limit {
+		tx.operatorSkipped = true
+		return value, false
+	}
+	if limit := tx.WAF.InspectionByteLimit; limit > 0 && len(value) > limit {
+		if !tx.limitExceeded(pcreLimitsExceeded) {
+			tx.DebugLogger().Info().
+				Int("limit", limit).
+				Int("length", len(value)).
+				Msg("Value exceeded SecInspectionByteLimit, inspecting the first bytes only")
+		}
+		value = value[:limit]
+	}
+	return value, true
+}
+
+// trackOperatorTime adds the time spent by an operator to the budget of the
+// transaction.
+func (tx *Transaction) trackOperatorTime(d time.Duration) {
+	limit := tx.WAF.OperatorTimeLimit
+	exhausted := tx.operatorTime >= limit
+	tx.operatorTime += d
+	if exhausted || tx.operatorTime < limit {
+		return
+	}
+	tx.limitExceeded(pcreLimitsExceeded)
+	tx.limitExceeded(operatorTimeLimitExceeded)
+	tx.DebugLogger().Info().
+		Int("usec", int(tx.operatorTime.Microseconds())).
+		Msg("Operators exceeded SecOperatorTimeLimit, skipping @rx for the rest of the transaction")
+}
+
+// limitExceeded sets the TX flag, it returns whether it was already set.
+func (tx *Transaction) limitExceeded(flag string) bool {
+	if v := tx.variables.tx.Get(flag); len(v) > 0 && v[0] == "1" {
+		return true
+	}
+	tx.variables.tx.Set(flag, []string{"1"})
+	return false
+}
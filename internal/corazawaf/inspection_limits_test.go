@@ -0,0 +1,123 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/operators"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+func newRxTestRule(t *testing.T, id int, function string, expr string) *Rule {
+	t.Helper()
+	op, err := operators.Get("rx", plugintypes.OperatorOptions{Arguments: expr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRule()
+	r.ID_ = id
+	r.Phase_ = types.PhaseRequestHeaders
+	r.SetOperator(op, function, expr)
+	if err := r.AddVariable(variables.ArgsGet, "", false); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func matchedRuleIDs(tx *Transaction) []int {
+	var ids []int
+	for _, mr := range tx.MatchedRules() {
+		ids = append(ids, mr.Rule().ID())
+	}
+	return ids
+}
+
+func TestInspectionByteLimit(t *testing.T) {
+	tests := []struct {
+		uri      string
+		matched  []int
+		exceeded bool
+	}{
+		{uri: "/?a=secret", matched: []int{1}},
+		{uri: "/?a=12345678secret", matched: []int{2}, exceeded: true},
+		{uri: "/?a=1234567812345678", matched: []int{2}, exceeded: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.uri, func(t *testing.T) {
+			waf := NewWAF()
+			waf.InspectionByteLimit = 8
+			for _, r := range []*Rule{
+				newRxTestRule(t, 1, "@rx", "secret"),
+				newRxTestRule(t, 2, "@rx", "^[0-9]{8}$"),
+			} {
+				if err := waf.Rules.Add(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tx := waf.NewTransaction()
+			defer tx.Close()
+			tx.ProcessURI(tc.uri, "GET", "HTTP/1.1")
+			tx.ProcessRequestHeaders()
+
+			if have := matchedRuleIDs(tx); !slices.Equal(have, tc.matched) {
+				t.Errorf("unexpected matched rules, want %v, have %v", tc.matched, have)
+			}
+			if have := tx.variables.tx.Get(pcreLimitsExceeded); (len(have) == 1 && have[0] == "1") != tc.exceeded {
+				t.Errorf("unexpected TX:MSC_PCRE_LIMITS_EXCEEDED %q", have)
+			}
+		})
+	}
+}
+
+func TestOperatorTimeLimit(t *testing.T) {
+	waf := NewWAF()
+	waf.OperatorTimeLimit = time.Nanosecond
+	for _, r := range []*Rule{
+		newRxTestRule(t, 1, "@rx", "^a"),
+		// skipped once the budget is exhausted, even if negated
+		newRxTestRule(t, 2, "@rx", "^a"),
+		newRxTestRule(t, 3, "!@rx", "^b"),
+	} {
+		if err := waf.Rules.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the budget is per transaction
+	for i := 0; i < 2; i++ {
+		tx := waf.NewTransaction()
+		tx.ProcessURI("/?q=abc", "GET", "HTTP/1.1")
+		tx.ProcessRequestHeaders()
+
+		if have := matchedRuleIDs(tx); !slices.Equal(have, []int{1}) {
+			t.Errorf("unexpected matched rules %v", have)
+		}
+		for _, flag := range []string{pcreLimitsExceeded, operatorTimeLimitExceeded} {
+			if have := tx.variables.tx.Get(flag); len(have) != 1 || have[0] != "1" {
+				t.Errorf("unexpected TX:%s %q", flag, have)
+			}
+		}
+		if err := tx.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInspectionLimitsDisabled(t *testing.T) {
+	tx := NewWAF().NewTransaction()
+	defer tx.Close()
+	value := string(make([]byte, 1<<20))
+	if have, ok := tx.LimitInspection(value); !ok || have != value {
+		t.Error("unexpected limited inspection")
+	}
+	if have := tx.variables.tx.Get(pcreLimitsExceeded); len(have) != 0 {
+		t.Errorf("unexpected TX:MSC_PCRE_LIMITS_EXCEEDED %q", have)
+	}
+}
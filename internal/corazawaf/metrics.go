@@ -0,0 +1,72 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/metrics"
+	"github.com/corazawaf/coraza/v3/internal/auditlog"
+	"github.com/corazawaf/coraza/v3/internal/corazarules"
+)
+
+// SetMetrics sets the recorder of the activity of the WAF. The counters of
+// the asynchronous audit log writer are exposed too when the recorder
+// observes them, until the WAF is closed. It must be called once the writer
+// is set.
+func (w *WAF) SetMetrics(r metrics.Recorder) {
+	w.Metrics = r
+	if w.unobserveAuditLogStats != nil {
+		w.unobserveAuditLogStats()
+		w.unobserveAuditLogStats = nil
+	}
+	o, ok := r.(metrics.AuditLogStatsObserver)
+	if !ok {
+		return
+	}
+	if a, ok := w.auditLogWriter.(*auditlog.AsyncWriter); ok {
+		w.unobserveAuditLogStats = o.ObserveAuditLogStats(func() metrics.AuditLogStats {
+			s := a.Stats()
+			return metrics.AuditLogStats{
+				Queued:  s.Queued,
+				Written: s.Written,
+				Retried: s.Retried,
+				Spilled: s.Spilled,
+				Dropped: s.Dropped,
+			}
+		})
+	}
+}
+
+// recordMetrics reports the activity of the transaction, it is called when
+// the transaction is closed.
+func (tx *Transaction) recordMetrics(m metrics.Recorder) {
+	m.TransactionClosed()
+	if tx.interruption != nil {
+		m.Interruption(tx.interruption.Action, tx.interruption.RuleID)
+	}
+	for _, mr := range tx.matchedRules {
+		if mrWithLog, ok := mr.(*corazarules.MatchedRule); ok && !mrWithLog.Log() {
+			continue
+		}
+		r := mr.Rule()
+		m.RuleMatched(r.ID(), r.Severity(), r.Tags())
+	}
+	for phase, ns := range tx.stopWatches {
+		m.PhaseDuration(phase, time.Duration(ns))
+	}
+
+	if tx.variables.inboundDataError.Get() == "1" {
+		m.BodyLimitExceeded(metrics.BodyRequest)
+	}
+	if tx.variables.outboundDataError.Get() == "1" {
+		m.BodyLimitExceeded(metrics.BodyResponse)
+	}
+	if tx.variables.reqbodyError.Get() == "1" {
+		m.BodyProcessorError(metrics.BodyRequest, tx.variables.reqbodyProcessor.Get())
+	}
+	if tx.variables.resBodyError.Get() == "1" {
+		m.BodyProcessorError(metrics.BodyResponse, tx.variables.resBodyProcessor.Get())
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/experimental/metrics"
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/auditlog"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+type metricsTestWriter struct {
+	err error
+}
+
+func (w *metricsTestWriter) Init(plugintypes.AuditLogConfig) error { return nil }
+func (w *metricsTestWriter) Write(plugintypes.AuditLog) error      { return w.err }
+func (w *metricsTestWriter) Close() error                          { return nil }
+
+func newMetricsTestWAF(t *testing.T, writer plugintypes.AuditLogWriter, registry *metrics.Registry) *WAF {
+	t.Helper()
+	waf := NewWAF()
+	waf.AuditEngine = types.AuditEngineOn
+	waf.SetAuditLogWriter(writer)
+	if err := waf.InitAuditLogWriter(); err != nil {
+		t.Fatal(err)
+	}
+	waf.SetMetrics(registry)
+	return waf
+}
+
+func assertExposition(t *testing.T, registry *metrics.Registry, want ...string) {
+	t.Helper()
+	var sb strings.Builder
+	if err := registry.WriteText(&sb); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range want {
+		if !strings.Contains(sb.String(), line+"\n") {
+			t.Errorf("missing %q in the exposition:\n%s", line, sb.String())
+		}
+	}
+}
+
+func TestMetricsAuditLogWriteError(t *testing.T) {
+	registry := metrics.NewRegistry()
+	waf := newMetricsTestWAF(t, &metricsTestWriter{err: errors.New("disk full")}, registry)
+	waf.ResponseBodyAccess = true
+	waf.ResponseBodyLimit = 4
+	waf.ResponseBodyLimitAction = types.BodyLimitActionProcessPartial
+
+	tx := waf.NewTransaction()
+	tx.ProcessRequestHeaders()
+	tx.AddResponseHeader("Content-Type", "text/html")
+	tx.ProcessResponseHeaders(200, "HTTP/1.1")
+	if _, _, err := tx.WriteResponseBody([]byte("<html></html>")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ProcessResponseBody(); err != nil {
+		t.Fatal(err)
+	}
+	tx.ProcessLogging()
+	if err := tx.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertExposition(t, registry,
+		"coraza_transactions_total 1",
+		"coraza_audit_log_write_errors_total 1",
+		`coraza_body_limit_exceeded_total{body="response"} 1`,
+		`coraza_phase_duration_seconds_count{phase="5"} 1`,
+	)
+}
+
+func TestMetricsAsyncAuditLogStats(t *testing.T) {
+	registry := metrics.NewRegistry()
+	// the WAF replaced by a reload is closed, the counters of its writer are kept
+	for i, want := range []string{"2", "4"} {
+		writer, err := auditlog.NewAsyncWriter(&metricsTestWriter{}, auditlog.AsyncWriterConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		waf := newMetricsTestWAF(t, writer, registry)
+
+		for j := 0; j < 2; j++ {
+			tx := waf.NewTransaction()
+			tx.ProcessLogging()
+			if err := tx.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// the queued entries are written before closing
+		if err := waf.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		assertExposition(t, registry,
+			"coraza_transactions_total "+want,
+			"coraza_audit_log_write_errors_total 0",
+			"coraza_audit_log_queued_entries 0",
+			"coraza_audit_log_written_total "+want,
+			"coraza_audit_log_dropped_total 0",
+		)
+		if i == 0 && waf.unobserveAuditLogStats == nil {
+			t.Fatal("expected the writer to be observed")
+		}
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/debuglog"
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/corazarules"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// Built-in keys of the persistent collections. Keys are stored lowercased as
+// the rest of the variables, lookups are case-insensitive.
+const (
+	persistentKeyKey            = "key"
+	persistentKeyCreateTime     = "create_time"
+	persistentKeyLastUpdateTime = "last_update_time"
+	persistentKeyTimeout        = "timeout"
+	persistentKeyUpdateCounter  = "update_counter"
+	persistentKeyUpdateRate     = "update_rate"
+	persistentKeyIsNew          = "is_new"
+)
+
+var errPersistentCollectionNotInitialized = errors.New("persistent collection is not initialized")
+
+// persistentCollection is a collection.Map whose variables live in the WAF
+// persistent store. It is empty until it is initialized with a key by
+// initcol, setsid or setuid, after that every change is written through to
+// the store so concurrent transactions see each other updates.
+type persistentCollection struct {
+	variable variables.RuleVariable
+	store    plugintypes.PersistentStore
+	// logger is the debug logger of the transaction owning the collection
+	logger debuglog.Logger
+	// id is the record key in the store, prefixed by the web application id
+	// for the collections that are separated per application.
+	id    string
+	isNew bool
+	// dirty is true when the transaction changed the record, in which case
+	// the update counters are refreshed once the transaction finishes.
+	dirty bool
+}
+
+var _ collection.Map = &persistentCollection{}
+
+func newPersistentCollection(variable variables.RuleVariable) *persistentCollection {
+	return &persistentCollection{variable: variable, logger: debuglog.Noop()}
+}
+
+// isScopedByWebAppID returns true for the collections whose records are
+// separated by SecWebAppId.
+func isScopedByWebAppID(v variables.RuleVariable) bool {
+	return v == variables.Session || v == variables.User || v == variables.Resource
+}
+
+// init binds the collection to the record of key, creating the record with
+// the built-in variables if it does not exist yet.
+func (c *persistentCollection) init(store plugintypes.PersistentStore, webAppID string, key string, timeout int) error {
+	c.Reset()
+
+	id := key
+	if isScopedByWebAppID(c.variable) && webAppID != "" {
+		id = webAppID + "_" + key
+	}
+
+	current, err := store.All(c.variable.Name(), id)
+	if err != nil {
+		return err
+	}
+
+	c.store = store
+	c.id = id
+	c.isNew = len(current) == 0
+	if !c.isNew {
+		return nil
+	}
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	for _, kv := range [][2]string{
+		{persistentKeyKey, key},
+		{persistentKeyCreateTime, now},
+		{persistentKeyLastUpdateTime, now},
+		{persistentKeyTimeout, strconv.Itoa(timeout)},
+		{persistentKeyUpdateCounter, "0"},
+		{persistentKeyUpdateRate, "0"},
+	} {
+		if err := store.Set(c.variable.Name(), id, kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// persist refreshes UPDATE_COUNTER, LAST_UPDATE_TIME and UPDATE_RATE if the
+// record was changed during the transaction. UPDATE_RATE is the average
+// number of updates per minute since the record was created.
+func (c *persistentCollection) persist() error {
+	if c.store == nil || !c.dirty {
+		return nil
+	}
+	c.dirty = false
+
+	name := c.variable.Name()
+	counter, err := c.store.Sum(name, c.id, persistentKeyUpdateCounter, 1)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	if err := c.store.Set(name, c.id, persistentKeyLastUpdateTime, strconv.FormatInt(now, 10)); err != nil {
+		return err
+	}
+
+	createTime, ok, err := c.store.Get(name, c.id, persistentKeyCreateTime)
+	if err != nil || !ok {
+		return err
+	}
+	created, err := strconv.ParseInt(createTime, 10, 64)
+	if err != nil {
+		return nil
+	}
+	if elapsed := now - created; elapsed > 0 {
+		rate := int64(counter) * 60 / elapsed
+		return c.store.Set(name, c.id, persistentKeyUpdateRate, strconv.FormatInt(rate, 10))
+	}
+	return nil
+}
+
+func (c *persistentCollection) logError(err error, msg string) {
+	c.logger.Error().Str("collection", c.variable.Name()).Err(err).Msg(msg)
+}
+
+// all returns the variables of the record including IS_NEW, sorted by key.
+func (c *persistentCollection) all() ([]string, map[string]string) {
+	if c.store == nil {
+		return nil, nil
+	}
+	data, err := c.store.All(c.variable.Name(), c.id)
+	if err != nil {
+		c.logError(err, "Failed to read persistent collection")
+		return nil, nil
+	}
+	data[persistentKeyIsNew] = c.isNewValue()
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, data
+}
+
+func (c *persistentCollection) isNewValue() string {
+	if c.isNew {
+		return "1"
+	}
+	return "0"
+}
+
+func (c *persistentCollection) matchData(key, value string) types.MatchData {
+	return &corazarules.MatchData{
+		Variable_: c.variable,
+		Key_:      key,
+		Value_:    value,
+	}
+}
+
+// Get returns the value of a variable in the record.
+func (c *persistentCollection) Get(key string) []string {
+	if c.store == nil {
+		return nil
+	}
+	key = strings.ToLower(key)
+	if key == persistentKeyIsNew {
+		return []string{c.isNewValue()}
+	}
+	v, ok, err := c.store.Get(c.variable.Name(), c.id, key)
+	if err != nil {
+		c.logError(err, "Failed to read persistent collection")
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	return []string{v}
+}
+
+// FindRegex returns all the variables whose key matches the regular expression.
+func (c *persistentCollection) FindRegex(key *regexp.Regexp) []types.MatchData {
+	keys, data := c.all()
+	var res []types.MatchData
+	for _, k := range keys {
+		if key.MatchString(k) {
+			res = append(res, c.matchData(k, data[k]))
+		}
+	}
+	return res
+}
+
+// FindString returns the variable whose key matches the string.
+func (c *persistentCollection) FindString(key string) []types.MatchData {
+	if key == "" {
+		return c.FindAll()
+	}
+	key = strings.ToLower(key)
+	if v := c.Get(key); len(v) > 0 {
+		return []types.MatchData{c.matchData(key, v[0])}
+	}
+	return nil
+}
+
+// FindAll returns all the variables of the record.
+func (c *persistentCollection) FindAll() []types.MatchData {
+	keys, data := c.all()
+	if len(keys) == 0 {
+		return nil
+	}
+	res := make([]types.MatchData, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, c.matchData(k, data[k]))
+	}
+	return res
+}
+
+// Name returns the name of the collection.
+func (c *persistentCollection) Name() string {
+	return c.variable.Name()
+}
+
+// Add stores the value of a variable. Persistent variables hold a single
+// value, so Add replaces any previous value.
+func (c *persistentCollection) Add(key string, value string) {
+	c.set(key, value)
+}
+
+// Set stores the first value of values, persistent variables hold a single value.
+func (c *persistentCollection) Set(key string, values []string) {
+	if len(values) == 0 {
+		c.Remove(key)
+		return
+	}
+	c.set(key, values[0])
+}
+
+// SetIndex stores the value of a variable, the index is ignored as persistent
+// variables hold a single value.
+func (c *persistentCollection) SetIndex(key string, _ int, value string) {
+	c.set(key, value)
+}
+
+func (c *persistentCollection) set(key string, value string) {
+	if c.store == nil {
+		c.logError(errPersistentCollectionNotInitialized, "Failed to set variable")
+		return
+	}
+	if err := c.store.Set(c.variable.Name(), c.id, strings.ToLower(key), value); err != nil {
+		c.logError(err, "Failed to set variable")
+		return
+	}
+	c.dirty = true
+}
+
+// Remove deletes a variable from the record.
+func (c *persistentCollection) Remove(key string) {
+	if c.store == nil {
+		return
+	}
+	if err := c.store.Remove(c.variable.Name(), c.id, strings.ToLower(key)); err != nil {
+		c.logError(err, "Failed to remove variable")
+		return
+	}
+	c.dirty = true
+}
+
+// Sum atomically adds delta to the integer value of a variable, so concurrent
+// transactions updating the same counter don't lose increments.
+func (c *persistentCollection) Sum(key string, delta int) {
+	if c.store == nil {
+		c.logError(errPersistentCollectionNotInitialized, "Failed to set variable")
+		return
+	}
+	if _, err := c.store.Sum(c.variable.Name(), c.id, strings.ToLower(key), delta); err != nil {
+		c.logError(err, "Failed to set variable")
+		return
+	}
+	c.dirty = true
+}
+
+// SetTTL makes a variable expire after ttl.
+func (c *persistentCollection) SetTTL(key string, ttl time.Duration) {
+	if c.store == nil {
+		c.logError(errPersistentCollectionNotInitialized, "Failed to expire variable")
+		return
+	}
+	if err := c.store.SetTTL(c.variable.Name(), c.id, strings.ToLower(key), ttl); err != nil {
+		c.logError(err, "Failed to expire variable")
+		return
+	}
+	c.dirty = true
+}
+
+// Reset detaches the collection from its record, the record is kept in the store.
+func (c *persistentCollection) Reset() {
+	c.store = nil
+	c.id = ""
+	c.isNew = false
+	c.dirty = false
+}
+
+// Format updates the passed strings.Builder with the formatted variables.
+func (c *persistentCollection) Format(res *strings.Builder) {
+	res.WriteString(c.variable.Name())
+	res.WriteString(":\n")
+	keys, data := c.all()
+	for _, k := range keys {
+		res.WriteString("    ")
+		res.WriteString(k)
+		res.WriteString(": ")
+		res.WriteString(data[k])
+		res.WriteByte('\n')
+	}
+}
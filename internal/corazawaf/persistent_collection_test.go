@@ -0,0 +1,159 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+func TestPersistentCollectionNotInitialized(t *testing.T) {
+	waf := NewWAF()
+	tx := waf.NewTransaction()
+	defer tx.Close()
+
+	ip := tx.variables.ip
+	if v := ip.Get("foo"); v != nil {
+		t.Errorf("unexpected value %v", v)
+	}
+	if v := ip.FindAll(); v != nil {
+		t.Errorf("unexpected values %v", v)
+	}
+	// Writes are ignored until initcol is used
+	ip.Set("foo", []string{"bar"})
+	if ip.dirty {
+		t.Error("uninitialized collection should not be modified")
+	}
+}
+
+func TestPersistentCollectionBuiltins(t *testing.T) {
+	waf := NewWAF()
+	tx := waf.NewTransaction()
+	if err := tx.InitPersistentCollection(variables.IP, "1.2.3.4"); err != nil {
+		t.Fatal(err)
+	}
+
+	ip := tx.Collection(variables.IP).(*persistentCollection)
+	for key, want := range map[string]string{
+		"KEY":            "1.2.3.4",
+		"IS_NEW":         "1",
+		"TIMEOUT":        "3600",
+		"UPDATE_COUNTER": "0",
+		"UPDATE_RATE":    "0",
+	} {
+		if v := ip.Get(key); len(v) != 1 || v[0] != want {
+			t.Errorf("unexpected %s, want %q, have %v", key, want, v)
+		}
+	}
+	for _, key := range []string{"create_time", "last_update_time"} {
+		if v := ip.Get(key); len(v) != 1 || v[0] == "" {
+			t.Errorf("expected %s to be set", key)
+		}
+	}
+
+	ip.Set("score", []string{"5"})
+	if m := ip.FindRegex(regexp.MustCompile("^sc")); len(m) != 1 || m[0].Value() != "5" {
+		t.Errorf("unexpected regex match %v", m)
+	}
+	if m := ip.FindString("SCORE"); len(m) != 1 || m[0].Key() != "score" {
+		t.Errorf("unexpected string match %v", m)
+	}
+	// 6 stored built-ins, IS_NEW and score
+	if n := len(ip.FindAll()); n != 8 {
+		t.Errorf("unexpected number of variables %d", n)
+	}
+	if err := tx.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx = waf.NewTransaction()
+	defer tx.Close()
+	if err := tx.InitPersistentCollection(variables.IP, "1.2.3.4"); err != nil {
+		t.Fatal(err)
+	}
+	ip = tx.Collection(variables.IP).(*persistentCollection)
+	if v := ip.Get("is_new"); v[0] != "0" {
+		t.Error("expected record to exist")
+	}
+	if v := ip.Get("update_counter"); v[0] != "1" {
+		t.Errorf("unexpected update counter %v", v)
+	}
+	if v := ip.Get("score"); len(v) != 1 || v[0] != "5" {
+		t.Errorf("unexpected score %v", v)
+	}
+}
+
+func TestPersistentCollectionUnchangedIsNotUpdated(t *testing.T) {
+	waf := NewWAF()
+	for i := 0; i < 2; i++ {
+		tx := waf.NewTransaction()
+		if err := tx.InitPersistentCollection(variables.Global, "global"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	_ = tx.InitPersistentCollection(variables.Global, "global")
+	if v := tx.Collection(variables.Global).(*persistentCollection).Get("update_counter"); v[0] != "0" {
+		t.Errorf("unexpected update counter %v", v)
+	}
+}
+
+func TestPersistentCollectionWebAppID(t *testing.T) {
+	waf := NewWAF()
+	waf.WebAppID = "app1"
+	tx := waf.NewTransaction()
+	_ = tx.InitPersistentCollection(variables.Session, "abc")
+	_ = tx.InitPersistentCollection(variables.IP, "abc")
+	tx.variables.session.Set("foo", []string{"bar"})
+	tx.variables.ip.Set("foo", []string{"bar"})
+	_ = tx.Close()
+
+	waf.WebAppID = "app2"
+	tx = waf.NewTransaction()
+	defer tx.Close()
+	_ = tx.InitPersistentCollection(variables.Session, "abc")
+	_ = tx.InitPersistentCollection(variables.IP, "abc")
+	if v := tx.variables.session.Get("foo"); v != nil {
+		t.Error("sessions should be separated per web application")
+	}
+	if v := tx.variables.ip.Get("foo"); len(v) != 1 {
+		t.Error("IP records should be shared across web applications")
+	}
+}
+
+func TestPersistentCollectionSumAndTTL(t *testing.T) {
+	waf := NewWAF()
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	_ = tx.InitPersistentCollection(variables.User, "admin")
+
+	user := tx.variables.user
+	user.Sum("attempts", 2)
+	user.Sum("attempts", 3)
+	if v := user.Get("attempts"); v[0] != "5" {
+		t.Errorf("unexpected sum %v", v)
+	}
+	user.SetTTL("attempts", 0)
+	if v := user.Get("attempts"); v != nil {
+		t.Errorf("expected variable to be expired, have %v", v)
+	}
+	user.SetTTL("unknown", time.Second)
+}
+
+func TestInitPersistentCollectionInvalid(t *testing.T) {
+	waf := NewWAF()
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	if err := tx.InitPersistentCollection(variables.TX, "foo"); err == nil {
+		t.Error("expected error for non persistent collection")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/corazawaf/coraza/v3/debuglog"
@@ -15,6 +16,7 @@ import (
 	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
 	"github.com/corazawaf/coraza/v3/internal/corazarules"
 	utils "github.com/corazawaf/coraza/v3/internal/strings"
+	"github.com/corazawaf/coraza/v3/internal/xpath"
 	"github.com/corazawaf/coraza/v3/types"
 	"github.com/corazawaf/coraza/v3/types/variables"
 )
@@ -40,6 +42,9 @@ type ruleOperatorParams struct {
 	Data string
 	// If true, rule will match if op.Evaluate returns false
 	Negation bool
+	// If true, the operator validates the raw body (ex @validateSchema)
+	// instead of the arguments of the body processor
+	KeepRawJSONBody bool
 }
 
 type ruleVariableException struct {
@@ -70,6 +75,10 @@ type ruleVariableParams struct {
 	// If KeyRx is not nil, KeyStr is ignored
 	KeyStr string
 
+	// The XPath expression of the key of the XML variables, compiled
+	// when the rule is parsed
+	KeyXPath *xpath.Expr
+
 	// A slice of key exceptions
 	Exceptions []ruleVariableException
 }
@@ -137,6 +146,11 @@ type Rule struct {
 
 	HasChain bool
 
+	// InheritsDisruptiveAction is true when the rule takes the disruptive action of
+	// SecDefaultAction, using block or not declaring any. The configuration blocks can
+	// replace it.
+	InheritsDisruptiveAction bool
+
 	// inferredPhases is the inferred phases the rule is relevant for
 	// based on the processed variables.
 	// Multiphase specific field
@@ -238,6 +252,9 @@ func (r *Rule) doEvaluate(logger debuglog.Logger, phase types.RulePhase, tx *Tra
 			rid = r.ParentID_
 		}
 		ecol := tx.ruleRemoveTargetByID[rid]
+		if tx.scope != nil {
+			ecol = tx.scope.appendRemovedTargets(ecol, rid)
+		}
 		for _, v := range r.variables {
 			if multiphaseEvaluation && multiphaseSkipVariable(r, v.Variable, phase) {
 				continue
@@ -262,6 +279,11 @@ func (r *Rule) doEvaluate(logger debuglog.Logger, phase types.RulePhase, tx *Tra
 			var errs []error
 			var argsLen int
 			for i, arg := range values {
+				if limit := tx.WAF.InspectionByteLimit; limit > 0 && len(arg.Value()) > limit {
+					// the transformations and the operator inspect up to SecInspectionByteLimit bytes
+					value, _ := tx.LimitInspection(arg.Value())
+					arg = &corazarules.MatchData{Variable_: arg.Variable(), Key_: arg.Key(), Value_: value}
+				}
 				if r.MultiMatch {
 					args, errs = r.transformMultiMatchArg(arg)
 					argsLen = len(args)
@@ -387,6 +409,8 @@ func (r *Rule) doEvaluate(logger debuglog.Logger, phase types.RulePhase, tx *Tra
 			}
 		}
 
+		// the configuration block can replace the disruptive action inherited from SecDefaultAction
+		scopeAction, scoped := tx.scope.defaultAction(r)
 		for _, a := range r.actions {
 			// All actions are evaluated independently from the engine being On or in DetectionOnly.
 			// The action evaluation is responsible of checking the engine mode and decide if the disruptive action
@@ -396,6 +420,9 @@ func (r *Rule) doEvaluate(logger debuglog.Logger, phase types.RulePhase, tx *Tra
 			case plugintypes.ActionTypeFlow:
 				logger.Debug().Str("action", a.Name).Int("phase", int(phase)).Msg("Evaluating flow action for rule")
 			case plugintypes.ActionTypeDisruptive:
+				if scoped {
+					continue
+				}
 				// The parser enforces that the disruptive action is just one per rule (if more than one, only the last one is kept)
 				logger.Debug().Str("action", a.Name).Int("phase", int(phase)).Msg("Executing disruptive action for rule")
 			default:
@@ -405,6 +432,10 @@ func (r *Rule) doEvaluate(logger debuglog.Logger, phase types.RulePhase, tx *Tra
 			}
 			a.Function.Evaluate(r, tx)
 		}
+		if scoped {
+			logger.Debug().Str("action", scopeAction.name).Int("phase", int(phase)).Msg("Executing disruptive action of the configuration block for rule")
+			scopeAction.action.Evaluate(scopedRule{Rule: r, status: scopeAction.status}, tx)
+		}
 		if r.ID_ != noID {
 			// we avoid matching chains and secmarkers
 			tx.MatchRule(r, matchedValues)
@@ -550,12 +581,18 @@ func caseSensitiveVariable(v variables.RuleVariable) bool {
 	switch v {
 	case variables.Args, variables.ArgsNames,
 		variables.ArgsGet, variables.ArgsPost,
-		variables.ArgsGetNames, variables.ArgsPostNames:
+		variables.ArgsGetNames, variables.ArgsPostNames,
+		variables.XML, variables.RequestXML, variables.ResponseXML:
 		res = true
 	}
 	return res
 }
 
+// isXMLVariable returns true if the keys of the variable are XPath expressions
+func isXMLVariable(v variables.RuleVariable) bool {
+	return v == variables.XML || v == variables.RequestXML || v == variables.ResponseXML
+}
+
 // newRuleVariableParams creates a new ruleVariableParams
 // knows if a key needs to be lowercased. This probably should not be here,
 // but the knowledge of the type of the Map it not here also, so let's start with this.
@@ -581,6 +618,7 @@ func (r *Rule) AddVariable(v variables.RuleVariable, key string, iscount bool) e
 		return fmt.Errorf("cannot add a variable to an undefined rule")
 	}
 	var re *regexp.Regexp
+	var expr *xpath.Expr
 	if isRegex, rx := hasRegex(key); isRegex {
 		if !caseSensitiveVariable(v) {
 			rx = strings.ToLower(rx)
@@ -590,6 +628,12 @@ func (r *Rule) AddVariable(v variables.RuleVariable, key string, iscount bool) e
 		} else {
 			re = vare.(*regexp.Regexp)
 		}
+	} else if key != "" && isXMLVariable(v) {
+		if e, err := r.memoizeDo("xpath:"+key, func() (any, error) { return xpath.Compile(key) }); err != nil {
+			return err
+		} else {
+			expr = e.(*xpath.Expr)
+		}
 	}
 
 	if multiphaseEvaluation {
@@ -606,7 +650,9 @@ func (r *Rule) AddVariable(v variables.RuleVariable, key string, iscount bool) e
 			return nil
 		}
 	}
-	r.variables = append(r.variables, newRuleVariableParams(v, key, re, iscount))
+	rv := newRuleVariableParams(v, key, re, iscount)
+	rv.KeyXPath = expr
+	r.variables = append(r.variables, rv)
 	return nil
 }
 
@@ -707,11 +753,35 @@ func (r *Rule) SetOperator(operator plugintypes.Operator, functionName string, p
 		Function: functionName,
 		Data:     params,
 		Negation: len(functionName) > 0 && functionName[0] == '!',
+		// @validateSchema validates the raw body, not the flattened arguments
+		KeepRawJSONBody: strings.TrimPrefix(functionName, "!") == "@validateSchema",
 	}
 }
 
+// keepsRawJSONBody returns true if the rule or one of its chained rules
+// validates the raw JSON body.
+func (r *Rule) keepsRawJSONBody() bool {
+	for ; r != nil; r = r.Chain {
+		if r.operator != nil && r.operator.KeepRawJSONBody {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *Rule) executeOperator(data string, tx *Transaction) (result bool) {
-	result = r.operator.Operator.Evaluate(tx, data)
+	if tx.WAF.OperatorTimeLimit > 0 {
+		tx.operatorSkipped = false
+		start := time.Now()
+		result = r.operator.Operator.Evaluate(tx, data)
+		tx.trackOperatorTime(time.Since(start))
+		if tx.operatorSkipped {
+			// the operator was not evaluated, it doesn't match even if negated
+			return false
+		}
+	} else {
+		result = r.operator.Operator.Evaluate(tx, data)
+	}
 	if r.operator.Negation {
 		result = !result
 	}
@@ -37,6 +37,19 @@ func (p *inferredPhases) set(phase types.RulePhase) {
 	*p |= 1 << phase
 }
 
+// InferredPhases returns the phases the rule is relevant for, the phase set
+// for the rule and the earliest phases its variables may be populated in.
+// Phases are inferred when the rule is added to a RuleGroup.
+func (p *inferredPhases) InferredPhases() []types.RulePhase {
+	var phases []types.RulePhase
+	for phase := types.PhaseRequestHeaders; phase <= types.PhaseLogging; phase++ {
+		if p.has(phase) {
+			phases = append(phases, phase)
+		}
+	}
+	return phases
+}
+
 // minPhase returns the earliest phase a variable may be populated.
 // NOTE: variables.Args and variables.ArgsNames should ideally be evaluated
 // both in phase 1 and 2, but rules can set state in the transaction, e.g. a
@@ -97,6 +110,8 @@ func minPhase(v variables.RuleVariable) types.RulePhase {
 		return types.PhaseRequestHeaders
 	case variables.RemotePort:
 		return types.PhaseRequestHeaders
+	case variables.PeerAddr:
+		return types.PhaseRequestHeaders
 	case variables.ReqbodyError:
 		return types.PhaseRequestBody
 	case variables.ReqbodyErrorMsg:
@@ -147,10 +162,11 @@ func minPhase(v variables.RuleVariable) types.RulePhase {
 		// Result of matching, not used in phaes
 		return types.PhaseUnknown
 	case variables.StatusLine:
+		// Built from the arguments of ProcessResponseHeaders
 		return types.PhaseResponseHeaders
 	case variables.Duration:
-		// If used in matching, would need to be defined for multiple inferredPhases to make sense
-		return types.PhaseUnknown
+		// Computed when read, it is available since the transaction starts
+		return types.PhaseRequestHeaders
 	case variables.ResponseHeadersNames:
 		return types.PhaseResponseHeaders
 	case variables.RequestHeadersNames:
@@ -205,6 +221,16 @@ func minPhase(v variables.RuleVariable) types.RulePhase {
 		return types.PhaseRequestBody
 	case variables.TX:
 		return types.PhaseUnknown
+	case variables.IP, variables.Session, variables.User, variables.Global, variables.Resource:
+		// Persistent collections are initialized by actions, same as TX
+		return types.PhaseUnknown
+	case variables.Sessionid, variables.Userid:
+		// Set by the setsid and setuid actions
+		return types.PhaseUnknown
+	case variables.PerfRules, variables.PerfPhase1, variables.PerfPhase2, variables.PerfPhase3,
+		variables.PerfPhase4, variables.PerfPhase5, variables.PerfCombined, variables.PerfAll:
+		// Updated as the rules and the phases are evaluated
+		return types.PhaseUnknown
 	case variables.Rule:
 		// Shouldn't be used in phases
 		return types.PhaseUnknown
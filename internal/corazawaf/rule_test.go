@@ -5,6 +5,7 @@ package corazawaf
 
 import (
 	"errors"
+	"slices"
 	"strconv"
 	"testing"
 
@@ -393,6 +394,25 @@ func TestVariablesRxAreLowercasedForCaseInsensitiveCollections(t *testing.T) {
 	}
 }
 
+func TestXMLVariableKeysAreCompiled(t *testing.T) {
+	rule := NewRule()
+	if err := rule.AddVariable(variables.RequestXML, "//Book/@id", false); err != nil {
+		t.Fatal(err)
+	}
+	if e := rule.variables[0].KeyXPath; e == nil || e.String() != "//Book/@id" {
+		t.Errorf("expected the compiled XPath expression, got %v", e)
+	}
+	if err := rule.AddVariable(variables.ArgsGet, "//Book", false); err != nil {
+		t.Fatal(err)
+	}
+	if rule.variables[1].KeyXPath != nil {
+		t.Error("unexpected XPath expression for a non XML variable")
+	}
+	if err := rule.AddVariable(variables.XML, "//Book[", false); err == nil {
+		t.Error("expected an error for an invalid XPath expression")
+	}
+}
+
 func TestVariableNegationRxLowercasedForCaseInsensitiveCollections(t *testing.T) {
 	rule := NewRule()
 	if err := rule.AddVariable(variables.TX, "", false); err != nil {
@@ -442,6 +462,11 @@ func TestInferredPhase(t *testing.T) {
 	if !b.has(types.PhaseResponseBody) {
 		t.Error("Expected to have phase")
 	}
+
+	want := []types.RulePhase{types.PhaseRequestHeaders, types.PhaseRequestBody, types.PhaseResponseHeaders, types.PhaseResponseBody}
+	if have := b.InferredPhases(); !slices.Equal(want, have) {
+		t.Errorf("unexpected inferred phases, want %v, have %v", want, have)
+	}
 }
 
 type dummyDenyAction struct{}
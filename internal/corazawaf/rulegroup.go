@@ -20,13 +20,22 @@ import (
 type RuleGroup struct {
 	rules    []Rule
 	observer func(rule types.RuleMetadata)
+	// index is nil until BuildIndex is called and after the rules change
+	index *ruleIndex
+	// keepRawJSONBody is set once a rule validates the raw JSON body, so the
+	// body processors keep a copy of it
+	keepRawJSONBody bool
 }
 
 // Add a rule to the collection
 // Will return an error if the ID is already used
 func (rg *RuleGroup) Add(rule *Rule) error {
 	if rule == nil {
-		// this is an ugly solution but chains should not return rules
+		// this is an ugly solution but chains should not return rules,
+		// the chained rule was just appended to the last rule
+		if last := len(rg.rules) - 1; last >= 0 && rg.rules[last].keepsRawJSONBody() {
+			rg.keepRawJSONBody = true
+		}
 		return nil
 	}
 
@@ -62,6 +71,10 @@ func (rg *RuleGroup) Add(rule *Rule) error {
 	}
 
 	rg.rules = append(rg.rules, *rule)
+	rg.index = nil
+	if rule.keepsRawJSONBody() {
+		rg.keepRawJSONBody = true
+	}
 
 	if rg.observer != nil {
 		rg.observer(rule)
@@ -97,6 +110,7 @@ func (rg *RuleGroup) DiscardPendingChain() {
 	last := len(rg.rules) - 1
 	if last >= 0 && rg.rules[last].HasChain {
 		rg.rules = rg.rules[:last]
+		rg.index = nil
 	}
 }
 
@@ -105,6 +119,7 @@ func (rg *RuleGroup) DeleteByID(id int) {
 	for i, r := range rg.rules {
 		if r.ID_ == id {
 			rg.rules = append(rg.rules[:i], rg.rules[i+1:]...)
+			rg.index = nil
 			return
 		}
 	}
@@ -119,6 +134,7 @@ func (rg *RuleGroup) DeleteByRange(start, end int) {
 		}
 	}
 	rg.rules = kept
+	rg.index = nil
 }
 
 // DeleteByMsg deletes rules with the given message.
@@ -130,6 +146,7 @@ func (rg *RuleGroup) DeleteByMsg(msg string) {
 		}
 	}
 	rg.rules = kept
+	rg.index = nil
 }
 
 // DeleteByTag deletes rules with the given tag.
@@ -141,6 +158,7 @@ func (rg *RuleGroup) DeleteByTag(tag string) {
 		}
 	}
 	rg.rules = kept
+	rg.index = nil
 }
 
 // Count returns the count of rules
@@ -148,6 +166,17 @@ func (rg *RuleGroup) Count() int {
 	return len(rg.rules)
 }
 
+// BuildIndex buckets the rules by phase and by the collections they target,
+// so Eval only walks the rules of the phase and skips the rules whose
+// collections are all empty. It must be called once all the rules are added
+// and updated, adding or deleting rules discards the index.
+func (rg *RuleGroup) BuildIndex() {
+	if !shouldBuildRuleIndex {
+		return
+	}
+	rg.index = newRuleIndex(rg.rules)
+}
+
 // Eval rules for the specified phase, between 1 and 5
 // Rules are evaluated in syntactic order and the evaluation finishes
 // as soon as an interruption has been triggered.
@@ -164,9 +193,21 @@ func (rg *RuleGroup) Eval(phase types.RulePhase, tx *Transaction) bool {
 	for k := range transformationCache {
 		delete(transformationCache, k)
 	}
+	// Without index every rule is walked
+	n := len(rg.rules)
+	var indexed []indexedRule
+	if rg.index != nil {
+		indexed = rg.index.phases[phase]
+		n = len(indexed)
+	}
 RulesLoop:
-	for i := range rg.rules {
+	for i := 0; i < n; i++ {
+		var ir *indexedRule
 		r := &rg.rules[i]
+		if rg.index != nil {
+			ir = &indexed[i]
+			r = &rg.rules[ir.rule]
+		}
 		// if there is already an interruption and the phase isn't logging
 		// we break the loop
 		if tx.IsInterrupted() && phase != types.PhaseLogging {
@@ -189,11 +230,13 @@ RulesLoop:
 		}
 
 		// we skip the rule in case it's in the excluded list
-		if _, skip := tx.ruleRemoveByID[r.ID_]; skip {
-			tx.DebugLogger().Debug().
-				Int("rule_id", r.ID_).
-				Msg("Skipping rule")
-			continue RulesLoop
+		if len(tx.ruleRemoveByID) > 0 {
+			if _, skip := tx.ruleRemoveByID[r.ID_]; skip {
+				tx.DebugLogger().Debug().
+					Int("rule_id", r.ID_).
+					Msg("Skipping rule")
+				continue RulesLoop
+			}
 		}
 		for _, rng := range tx.ruleRemoveByIDRanges {
 			if r.ID_ >= rng[0] && r.ID_ <= rng[1] {
@@ -203,6 +246,12 @@ RulesLoop:
 				continue RulesLoop
 			}
 		}
+		if tx.scope != nil && tx.scope.removesRule(r) {
+			tx.DebugLogger().Debug().
+				Int("rule_id", r.ID_).
+				Msg("Skipping rule removed by the configuration block")
+			continue
+		}
 
 		// we always evaluate secmarkers
 		if tx.SkipAfter != "" {
@@ -258,7 +307,22 @@ RulesLoop:
 			tx.variables.matchedVars.Reset()
 		}
 
-		r.Evaluate(phase, tx, transformationCache)
+		// The rule is skipped only once skip and skipAfter were applied, as
+		// they count it like any other rule
+		if ir != nil && !ir.mayMatch(tx) {
+			tx.DebugLogger().Debug().
+				Int("rule_id", r.ID_).
+				Msg("Skipping rule because its variables are empty")
+			continue
+		}
+
+		if tx.WAF.RulePerfTime > 0 {
+			start := time.Now()
+			r.Evaluate(phase, tx, transformationCache)
+			tx.recordRulePerf(r.ID_, time.Since(start))
+		} else {
+			r.Evaluate(phase, tx, transformationCache)
+		}
 		tx.Capture = false // we reset captures
 		usedRules++
 	}
@@ -0,0 +1,106 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// ruleIndex buckets the rules of a RuleGroup by the phase they may run in,
+// keeping their syntactic order so skip, skipAfter and SecMarker behave as
+// when walking every rule. It is immutable once built.
+type ruleIndex struct {
+	phases [types.PhaseLogging + 1][]indexedRule
+}
+
+type indexedRule struct {
+	// rule is the position of the rule in RuleGroup.rules
+	rule int
+	// variables are the collections targeted by the rule. It is nil when the
+	// rule must always be evaluated, for example SecAction, SecMarker or rules
+	// counting a collection.
+	variables []variables.RuleVariable
+}
+
+// lenCollection is implemented by the collections that have no values at all
+// when their length is zero.
+type lenCollection interface {
+	Len() int
+}
+
+func newRuleIndex(rules []Rule) *ruleIndex {
+	idx := &ruleIndex{}
+	for i := range rules {
+		r := &rules[i]
+		ir := indexedRule{rule: i, variables: indexedVariables(r)}
+		for phase := types.PhaseRequestHeaders; phase <= types.PhaseLogging; phase++ {
+			if mayRunInPhase(r, phase) {
+				idx.phases[phase] = append(idx.phases[phase], ir)
+			}
+		}
+	}
+	return idx
+}
+
+// mayRunInPhase returns false if Eval would always skip the rule in the phase.
+// With multiphase evaluation it is an approximation, the exact check is still
+// done by Eval.
+func mayRunInPhase(r *Rule, phase types.RulePhase) bool {
+	if r.Phase_ == 0 || r.Phase_ == phase {
+		return true
+	}
+	if !multiphaseEvaluation {
+		return false
+	}
+	if r.HasChain {
+		return phase <= r.Phase_
+	}
+	return r.has(phase)
+}
+
+// indexedVariables returns the distinct collections targeted by the rule, or
+// nil if the rule can match when all of them are empty.
+func indexedVariables(r *Rule) []variables.RuleVariable {
+	// Chains evaluated across phases collect the matches of previous phases
+	if multiphaseEvaluation || r.operator == nil || len(r.variables) == 0 {
+		return nil
+	}
+	var res []variables.RuleVariable
+	for _, v := range r.variables {
+		// A count is a value even for empty collections, and RULE is
+		// populated right before the rule is evaluated.
+		if v.Count || v.Variable == variables.Rule {
+			return nil
+		}
+		if !containsVariable(res, v.Variable) {
+			res = append(res, v.Variable)
+		}
+	}
+	return res
+}
+
+func containsVariable(vs []variables.RuleVariable, v variables.RuleVariable) bool {
+	for _, vv := range vs {
+		if vv == v {
+			return true
+		}
+	}
+	return false
+}
+
+// mayMatch returns false if all the collections targeted by the rule are
+// empty, so the rule can't match for the transaction.
+func (ir *indexedRule) mayMatch(tx *Transaction) bool {
+	if ir.variables == nil {
+		return true
+	}
+	for _, v := range ir.variables {
+		col, ok := tx.Collection(v).(lenCollection)
+		if !ok || col.Len() > 0 {
+			return true
+		}
+	}
+	return false
+}
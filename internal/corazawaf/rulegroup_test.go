@@ -4,9 +4,17 @@
 package corazawaf
 
 import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/corazawaf/coraza/v3/experimental/plugins/macro"
+	"github.com/corazawaf/coraza/v3/internal/collections"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
 )
 
 func newTestRule(id int) *Rule {
@@ -97,3 +105,203 @@ func TestRuleGroupDeleteByID(t *testing.T) {
 		t.Fatal("Unexpected remaining rule in the rulegroup")
 	}
 }
+
+func TestRuleGroupKeepsRawJSONBody(t *testing.T) {
+	rg := NewRuleGroup()
+	r := newTestRule(1)
+	r.SetOperator(&dummyEqOperator{}, "@eq", "0")
+	if err := rg.Add(r); err != nil {
+		t.Fatal(err)
+	}
+	if rg.keepRawJSONBody {
+		t.Error("unexpected raw JSON body kept without @validateSchema")
+	}
+
+	// the rules added programmatically carry their chain
+	r = newTestRule(2)
+	r.SetOperator(&dummyEqOperator{}, "@eq", "0")
+	r.HasChain = true
+	r.Chain = NewRule()
+	r.Chain.SetOperator(&dummyEqOperator{}, "!@validateSchema", "schema.json")
+	if err := rg.Add(r); err != nil {
+		t.Fatal(err)
+	}
+	if !rg.keepRawJSONBody {
+		t.Error("expected the raw JSON body to be kept for @validateSchema")
+	}
+
+	// the parser links the chained rules after adding their parent
+	rg = NewRuleGroup()
+	r = newTestRule(3)
+	r.HasChain = true
+	if err := rg.Add(r); err != nil {
+		t.Fatal(err)
+	}
+	rg.GetRules()[0].Chain = NewRule()
+	rg.GetRules()[0].Chain.SetOperator(&dummyEqOperator{}, "@validateSchema", "schema.json")
+	if err := rg.Add(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !rg.keepRawJSONBody {
+		t.Error("expected the raw JSON body to be kept for a chained @validateSchema")
+	}
+}
+
+func newIndexTestRule(t *testing.T, id int, phase types.RulePhase, vars ...ruleVariableParams) *Rule {
+	t.Helper()
+	r := NewRule()
+	r.ID_ = id
+	r.LogID_ = strconv.Itoa(id)
+	r.Phase_ = phase
+	r.Log = true
+	r.operator = &ruleOperatorParams{
+		Operator: newTestUnconditionalMatch(t),
+		Function: "@unconditionalMatch",
+	}
+	r.variables = vars
+	return r
+}
+
+func TestRuleGroupBuildIndex(t *testing.T) {
+	if !shouldBuildRuleIndex {
+		t.Skip("rule index disabled")
+	}
+	rg := NewRuleGroup()
+	marker := NewRule()
+	marker.SecMark_ = "END"
+	marker.Phase_ = 0
+	for _, r := range []*Rule{
+		newIndexTestRule(t, 1, types.PhaseRequestHeaders,
+			ruleVariableParams{Variable: variables.ArgsGet, KeyStr: "a"},
+			ruleVariableParams{Variable: variables.ArgsGet, KeyStr: "b"},
+			ruleVariableParams{Variable: variables.RequestHeaders}),
+		newIndexTestRule(t, 2, types.PhaseRequestBody, ruleVariableParams{Variable: variables.ArgsPost, Count: true}),
+		marker,
+	} {
+		if err := rg.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rg.BuildIndex()
+	if rg.index == nil {
+		t.Fatal("expected the index to be built")
+	}
+	phase1 := rg.index.phases[types.PhaseRequestHeaders]
+	if len(phase1) != 2 || phase1[0].rule != 0 || phase1[1].rule != 2 {
+		t.Errorf("unexpected rules in phase 1: %v", phase1)
+	}
+	if !multiphaseEvaluation {
+		if len(rg.index.phases[types.PhaseRequestBody]) != 2 {
+			t.Errorf("unexpected rules in phase 2: %v", rg.index.phases[types.PhaseRequestBody])
+		}
+		if vs := phase1[0].variables; len(vs) != 2 || vs[0] != variables.ArgsGet || vs[1] != variables.RequestHeaders {
+			t.Errorf("unexpected indexed variables %v", vs)
+		}
+		if vs := rg.index.phases[types.PhaseRequestBody][0].variables; vs != nil {
+			t.Errorf("expected counted collections to be always evaluated, got %v", vs)
+		}
+	}
+	if vs := phase1[1].variables; vs != nil {
+		t.Errorf("expected markers to be always evaluated, got %v", vs)
+	}
+
+	rg.DeleteByID(2)
+	if rg.index != nil {
+		t.Error("expected the index to be discarded when rules change")
+	}
+}
+
+func TestRuleGroupIndexSkipsEmptyCollections(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("indexed=%t", indexed), func(t *testing.T) {
+			waf := NewWAF()
+			for _, r := range []*Rule{
+				newIndexTestRule(t, 1, types.PhaseRequestHeaders, ruleVariableParams{Variable: variables.ArgsGet}),
+				newIndexTestRule(t, 2, types.PhaseRequestHeaders, ruleVariableParams{Variable: variables.RequestCookies}),
+				newIndexTestRule(t, 3, types.PhaseRequestHeaders,
+					ruleVariableParams{Variable: variables.RequestCookies},
+					ruleVariableParams{Variable: variables.RequestHeaders}),
+				newIndexTestRule(t, 4, types.PhaseRequestHeaders, ruleVariableParams{Variable: variables.RequestCookies, Count: true}),
+				newIndexTestRule(t, 5, types.PhaseRequestBody, ruleVariableParams{Variable: variables.RequestHeaders}),
+			} {
+				if err := waf.Rules.Add(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if indexed {
+				waf.Rules.BuildIndex()
+			}
+
+			tx := waf.NewTransaction()
+			defer tx.Close()
+			tx.ProcessURI("/test?a=1", "GET", "HTTP/1.1")
+			tx.AddRequestHeader("Host", "example.com")
+			tx.ProcessRequestHeaders()
+
+			var got []int
+			for _, mr := range tx.MatchedRules() {
+				got = append(got, mr.Rule().ID())
+			}
+			want := []int{1, 3, 4}
+			if multiphaseEvaluation {
+				// REQUEST_HEADERS are evaluated as soon as they are available
+				want = append(want, 5)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("unexpected matched rules, got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRuleGroupRulePerfTime(t *testing.T) {
+	tests := []struct {
+		perfTime time.Duration
+		recorded bool
+	}{
+		{perfTime: 0, recorded: false},
+		{perfTime: time.Nanosecond, recorded: true},
+		{perfTime: time.Hour, recorded: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.perfTime.String(), func(t *testing.T) {
+			waf := NewWAF()
+			waf.RulePerfTime = tc.perfTime
+			waf.AuditLogParts = types.AuditLogParts("ABHZ")
+			if err := waf.Rules.Add(newIndexTestRule(t, 1, types.PhaseRequestHeaders, ruleVariableParams{Variable: variables.RequestURI})); err != nil {
+				t.Fatal(err)
+			}
+
+			tx := waf.NewTransaction()
+			defer tx.Close()
+			tx.ProcessURI("/test", "GET", "HTTP/1.1")
+			tx.ProcessRequestHeaders()
+
+			perf := tx.rulesPerformance()
+			if _, ok := perf[1]; ok != tc.recorded {
+				t.Errorf("unexpected PERF_RULES %v", perf)
+			}
+			if _, ok := tx.AuditLog().Transaction().Producer().RulesPerformance()[1]; ok != tc.recorded {
+				t.Error("unexpected rules performance in the audit log")
+			}
+
+			// the phase performance variables are computed when read
+			if tx.variables.perfPhase1.Get() != "" {
+				t.Errorf("unexpected PERF_PHASE1 before being read %q", tx.variables.perfPhase1.Get())
+			}
+			perfVar := func(v variables.RuleVariable) string {
+				return tx.Collection(v).(*collections.Single).Get()
+			}
+			if perfVar(variables.PerfPhase1) == "" || perfVar(variables.PerfCombined) == "" {
+				t.Error("expected the phase performance variables to be set")
+			}
+			if have := perfVar(variables.PerfPhase2); have != "" {
+				t.Errorf("unexpected PERF_PHASE2 %q", have)
+			}
+			if have := perfVar(variables.PerfAll); !strings.HasPrefix(have, "combined=") {
+				t.Errorf("unexpected PERF_ALL %q", have)
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"net"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	utils "github.com/corazawaf/coraza/v3/internal/strings"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// Scope is a configuration block overriding the configuration of the WAF for
+// the requests to some hosts and paths, see the <VirtualHost> and <Location>
+// blocks of SecLang. The scopes share the rules of the WAF: they change how
+// the rules apply to the transactions, not the rules themselves.
+type Scope struct {
+	// Hosts are the server names the scope applies to, a leading "*." matches
+	// the subdomains. Empty matches any host.
+	Hosts []string
+
+	// Path is the prefix of the request paths the scope applies to, matched at
+	// a segment boundary, empty matches any path.
+	Path string
+
+	// The overrides of the WAF configuration, nil when not overridden
+	RuleEngine         *types.RuleEngineStatus
+	RequestBodyAccess  *bool
+	RequestBodyLimit   *int64
+	ResponseBodyAccess *bool
+	ResponseBodyLimit  *int64
+	WebAppID           *string
+
+	// parent is the enclosing scope of a location inside a virtual host, its
+	// configuration applies first.
+	parent *Scope
+
+	ruleRemoveByID       map[int]struct{}
+	ruleRemoveByIDRanges [][2]int
+	ruleRemoveByTag      []string
+	ruleRemoveByMsg      []string
+	ruleRemoveTargets    []scopeTargetRemoval
+
+	// defaultActions replace, by phase, the disruptive actions the rules
+	// inherited from SecDefaultAction.
+	defaultActions map[types.RulePhase]scopeDefaultAction
+}
+
+type scopeTargetRemoval struct {
+	start, end int
+	target     ruleVariableParams
+}
+
+type scopeDefaultAction struct {
+	name   string
+	action plugintypes.Action
+	status int
+}
+
+// NewScope returns a scope for the given hosts and path prefix.
+func NewScope(hosts []string, path string) *Scope {
+	for i, h := range hosts {
+		hosts[i] = strings.ToLower(h)
+	}
+	return &Scope{Hosts: hosts, Path: path}
+}
+
+// NewLocation returns a scope for the path prefix nested in s, it applies to
+// the hosts of s and inherits its configuration.
+func (s *Scope) NewLocation(path string) *Scope {
+	return &Scope{Hosts: s.Hosts, Path: path, parent: s}
+}
+
+// Parent returns the scope enclosing s, nil if it is not nested.
+func (s *Scope) Parent() *Scope {
+	return s.parent
+}
+
+// RemoveRuleByID removes the rule from the transactions of the scope.
+func (s *Scope) RemoveRuleByID(id int) {
+	if s.ruleRemoveByID == nil {
+		s.ruleRemoveByID = map[int]struct{}{}
+	}
+	s.ruleRemoveByID[id] = struct{}{}
+}
+
+// RemoveRuleByIDRange removes the rules in the inclusive range from the
+// transactions of the scope.
+func (s *Scope) RemoveRuleByIDRange(start, end int) {
+	s.ruleRemoveByIDRanges = append(s.ruleRemoveByIDRanges, [2]int{start, end})
+}
+
+// RemoveRuleByTag removes the rules with the tag from the transactions of the
+// scope, including the rules loaded later.
+func (s *Scope) RemoveRuleByTag(tag string) {
+	s.ruleRemoveByTag = append(s.ruleRemoveByTag, tag)
+}
+
+// RemoveRuleByMsg removes the rules with the message from the transactions of
+// the scope, including the rules loaded later.
+func (s *Scope) RemoveRuleByMsg(msg string) {
+	s.ruleRemoveByMsg = append(s.ruleRemoveByMsg, msg)
+}
+
+// RemoveRuleTargetByID removes a target from the rules in the inclusive range
+// for the transactions of the scope, like ctl:ruleRemoveTargetById.
+func (s *Scope) RemoveRuleTargetByID(start, end int, variable variables.RuleVariable, key string, keyRx *regexp.Regexp) {
+	for _, c := range removedTargetParams(variable, key, keyRx) {
+		s.ruleRemoveTargets = append(s.ruleRemoveTargets, scopeTargetRemoval{start: start, end: end, target: c})
+	}
+}
+
+// SetDefaultAction replaces the disruptive action the rules of the phase
+// inherited from SecDefaultAction, status overrides the one of the rules when
+// not 0.
+func (s *Scope) SetDefaultAction(phase types.RulePhase, name string, action plugintypes.Action, status int) {
+	if s.defaultActions == nil {
+		s.defaultActions = map[types.RulePhase]scopeDefaultAction{}
+	}
+	s.defaultActions[phase] = scopeDefaultAction{name: name, action: action, status: status}
+}
+
+// matchesHost returns true if the scope applies to the host, the port is
+// ignored.
+func (s *Scope) matchesHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	for _, h := range s.Hosts {
+		if suffix, ok := strings.CutPrefix(h, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+		} else if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// apply sets the overrides of the scope, and of its parent first, to the
+// transaction.
+func (s *Scope) apply(tx *Transaction) {
+	if s.parent != nil {
+		s.parent.apply(tx)
+	}
+	if s.RuleEngine != nil {
+		tx.RuleEngine = *s.RuleEngine
+	}
+	if s.RequestBodyAccess != nil {
+		tx.RequestBodyAccess = *s.RequestBodyAccess
+	}
+	// the bodies are buffered with the limits of the WAF, the scopes can only
+	// lower them
+	if s.RequestBodyLimit != nil {
+		tx.RequestBodyLimit = min(*s.RequestBodyLimit, tx.WAF.RequestBodyLimit)
+	}
+	if s.ResponseBodyAccess != nil {
+		tx.ResponseBodyAccess = *s.ResponseBodyAccess
+	}
+	if s.ResponseBodyLimit != nil {
+		tx.ResponseBodyLimit = min(*s.ResponseBodyLimit, tx.WAF.ResponseBodyLimit)
+	}
+}
+
+// removesRule returns true if the scope, or its parent, removed the rule.
+func (s *Scope) removesRule(r *Rule) bool {
+	for ; s != nil; s = s.parent {
+		if _, ok := s.ruleRemoveByID[r.ID_]; ok {
+			return true
+		}
+		for _, rng := range s.ruleRemoveByIDRanges {
+			if r.ID_ >= rng[0] && r.ID_ <= rng[1] {
+				return true
+			}
+		}
+		for _, tag := range s.ruleRemoveByTag {
+			if utils.InSlice(tag, r.Tags_) {
+				return true
+			}
+		}
+		for _, msg := range s.ruleRemoveByMsg {
+			if r.Msg != nil && r.Msg.String() == msg {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// appendRemovedTargets appends the targets the scope, or its parent, removed
+// from the rule to ecol.
+func (s *Scope) appendRemovedTargets(ecol []ruleVariableParams, id int) []ruleVariableParams {
+	for ; s != nil; s = s.parent {
+		for _, tr := range s.ruleRemoveTargets {
+			if id >= tr.start && id <= tr.end {
+				// ecol belongs to the transaction, it must not be modified
+				ecol = append(slices.Clip(ecol), tr.target)
+			}
+		}
+	}
+	return ecol
+}
+
+// defaultAction returns the disruptive action replacing the one the rule
+// inherited from SecDefaultAction, if any.
+func (s *Scope) defaultAction(r *Rule) (scopeDefaultAction, bool) {
+	if !r.InheritsDisruptiveAction {
+		return scopeDefaultAction{}, false
+	}
+	for ; s != nil; s = s.parent {
+		if da, ok := s.defaultActions[r.Phase_]; ok {
+			return da, true
+		}
+	}
+	return scopeDefaultAction{}, false
+}
+
+// webAppID returns the application ID of the scope, empty if not overridden.
+func (s *Scope) webAppID() string {
+	for ; s != nil; s = s.parent {
+		if s.WebAppID != nil {
+			return *s.WebAppID
+		}
+	}
+	return ""
+}
+
+// scopeFor returns the most specific scope for the request, nil if none
+// applies: the scopes of the host are preferred, then the longest path.
+func (w *WAF) scopeFor(host, p string) *Scope {
+	p = cleanPath(p)
+	var best *Scope
+	for _, s := range w.Scopes {
+		if !s.matchesPath(p) {
+			continue
+		}
+		if len(s.Hosts) > 0 && !s.matchesHost(host) {
+			continue
+		}
+		if best == nil || s.moreSpecific(best) {
+			best = s
+		}
+	}
+	return best
+}
+
+// cleanPath returns the path without dot segments nor repeated slashes, as
+// resolved by the servers, so they can't be used to reach a location through
+// another one, e.g. /static/../admin. The trailing slash is kept.
+func cleanPath(p string) string {
+	if p == "" {
+		return ""
+	}
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// matchesPath returns true if the cleaned path is in the location of the
+// scope, matched at a segment boundary: /api matches /api and /api/users but
+// not /apifoo. The scopes without location match any path.
+func (s *Scope) matchesPath(p string) bool {
+	if s.Path == "" {
+		return true
+	}
+	return p == s.Path || strings.HasPrefix(p, strings.TrimSuffix(s.Path, "/")+"/")
+}
+
+// moreSpecific returns true if s is more specific than o: the scopes of hosts
+// are preferred over the ones of any host, then the longest paths.
+func (s *Scope) moreSpecific(o *Scope) bool {
+	if sh, oh := len(s.Hosts) > 0, len(o.Hosts) > 0; sh != oh {
+		return sh
+	}
+	return len(s.Path) > len(o.Path)
+}
+
+// scopedRule is the metadata of a rule evaluated with the default action of a
+// scope, which can override its status.
+type scopedRule struct {
+	*Rule
+	status int
+}
+
+func (r scopedRule) Status() int {
+	if r.status != 0 {
+		return r.status
+	}
+	return r.Rule.Status()
+}
+
+// selectScope applies the configuration of the scope of the server name and
+// the path of the request, it is called as soon as any of them is known.
+func (tx *Transaction) selectScope() {
+	if len(tx.WAF.Scopes) == 0 {
+		return
+	}
+	s := tx.WAF.scopeFor(tx.variables.serverName.Get(), tx.variables.requestFilename.Get())
+	if s == tx.scope {
+		return
+	}
+
+	w := tx.WAF
+	tx.RuleEngine = w.RuleEngine
+	tx.RequestBodyAccess = w.RequestBodyAccess
+	tx.RequestBodyLimit = w.RequestBodyLimit
+	tx.ResponseBodyAccess = w.ResponseBodyAccess
+	tx.ResponseBodyLimit = w.ResponseBodyLimit
+	tx.scope = s
+	if s == nil {
+		return
+	}
+	s.apply(tx)
+	tx.debugLogger.Debug().
+		Str("hosts", strings.Join(s.Hosts, " ")).
+		Str("path", s.Path).
+		Msg("Applying the configuration block")
+}
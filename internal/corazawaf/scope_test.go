@@ -0,0 +1,265 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+func TestScopeFor(t *testing.T) {
+	vhost := NewScope([]string{"Example.com", "*.example.com"}, "")
+	scopes := []*Scope{
+		NewScope(nil, "/"),
+		NewScope(nil, "/api"),
+		vhost,
+		vhost.NewLocation("/api/admin"),
+		NewScope([]string{"other.com"}, ""),
+	}
+	waf := NewWAF()
+	waf.Scopes = scopes
+
+	tests := []struct {
+		host, path string
+		want       int
+	}{
+		{host: "unknown.com", path: "/index.html", want: 0},
+		{host: "unknown.com", path: "/api/users", want: 1},
+		{host: "example.com", path: "/api/users", want: 2},
+		{host: "EXAMPLE.com:8443", path: "/api/users", want: 2},
+		{host: "www.example.com", path: "/api/admin/users", want: 3},
+		{host: "unknown.com", path: "/api/admin/users", want: 1},
+		{host: "notexample.com", path: "/api", want: 1},
+		{host: "other.com", path: "/api/admin", want: 4},
+		// the locations are matched at a segment boundary
+		{host: "unknown.com", path: "/apifoo", want: 0},
+		{host: "unknown.com", path: "/api/", want: 1},
+		{host: "www.example.com", path: "/api/adminfoo", want: 2},
+		// the dot segments and repeated slashes are resolved
+		{host: "unknown.com", path: "/api/../index.html", want: 0},
+		{host: "unknown.com", path: "//api//users", want: 1},
+		{host: "www.example.com", path: "/index.html/../api/./admin/users", want: 3},
+		{host: "www.example.com", path: "/api/admin/../../api/users", want: 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.host+tc.path, func(t *testing.T) {
+			if have := waf.scopeFor(tc.host, tc.path); have != scopes[tc.want] {
+				t.Errorf("unexpected scope, want %d, have %+v", tc.want, have)
+			}
+		})
+	}
+
+	if have := waf.scopeFor("", ""); have != nil {
+		t.Errorf("unexpected scope %+v", have)
+	}
+}
+
+func TestScopeMatchesHost(t *testing.T) {
+	s := NewScope([]string{"*.example.com", "[::1]"}, "")
+	tests := map[string]bool{
+		"www.example.com":      true,
+		"a.b.example.com:8080": true,
+		"example.com":          false,
+		"badexample.com":       false,
+		".example.com":         false,
+		"[::1]:8080":           false,
+		"::1":                  false,
+	}
+	for host, want := range tests {
+		if have := s.matchesHost(host); have != want {
+			t.Errorf("unexpected match for %q, want %t, have %t", host, want, have)
+		}
+	}
+}
+
+func TestTransactionScope(t *testing.T) {
+	off := types.RuleEngineOff
+	access := true
+	limit := int64(10)
+	hugeLimit := int64(1 << 40)
+
+	vhost := NewScope([]string{"example.com"}, "")
+	vhost.RuleEngine = &off
+	api := vhost.NewLocation("/api")
+	api.RequestBodyAccess = &access
+	api.RequestBodyLimit = &limit
+	upload := NewScope(nil, "/upload")
+	upload.RequestBodyLimit = &hugeLimit
+
+	waf := NewWAF()
+	waf.RuleEngine = types.RuleEngineOn
+	waf.Scopes = []*Scope{vhost, api, upload}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+
+	tx.ProcessURI("/api/users", "POST", "HTTP/1.1")
+	if tx.scope != nil {
+		t.Fatalf("unexpected scope before the server name is known")
+	}
+	tx.SetServerName("example.com:8080")
+	if tx.scope != api {
+		t.Fatalf("unexpected scope %+v", tx.scope)
+	}
+	if tx.RuleEngine != types.RuleEngineOff || !tx.RequestBodyAccess || tx.RequestBodyLimit != limit {
+		t.Errorf("unexpected configuration, engine %s, body access %t, body limit %d",
+			tx.RuleEngine, tx.RequestBodyAccess, tx.RequestBodyLimit)
+	}
+
+	// the configuration of the WAF is restored before applying another block
+	tx.SetServerName("other.com")
+	tx.ProcessURI("/upload/file", "POST", "HTTP/1.1")
+	if tx.scope != upload {
+		t.Fatalf("unexpected scope %+v", tx.scope)
+	}
+	if tx.RuleEngine != types.RuleEngineOn || tx.RequestBodyAccess != waf.RequestBodyAccess {
+		t.Errorf("unexpected configuration, engine %s, body access %t", tx.RuleEngine, tx.RequestBodyAccess)
+	}
+	// the bodies are buffered with the limits of the WAF
+	if tx.RequestBodyLimit != waf.RequestBodyLimit {
+		t.Errorf("unexpected request body limit %d", tx.RequestBodyLimit)
+	}
+}
+
+func TestTransactionScopeEncodedPath(t *testing.T) {
+	off := types.RuleEngineOff
+	static := NewScope(nil, "/static")
+	static.RuleEngine = &off
+
+	waf := NewWAF()
+	waf.RuleEngine = types.RuleEngineOn
+	waf.Scopes = []*Scope{static}
+
+	for _, uri := range []string{
+		"/static/../admin/login",
+		"/static/%2e%2e/admin/login",
+		"/static/%2E%2E%2Fadmin/login",
+		"/static%2f..%2fadmin/login",
+		"/staticfoo/login",
+	} {
+		tx := waf.NewTransaction()
+		tx.ProcessURI(uri, "GET", "HTTP/1.1")
+		if tx.scope != nil || tx.RuleEngine != types.RuleEngineOn {
+			t.Errorf("unexpected scope for %s, engine %s", uri, tx.RuleEngine)
+		}
+		tx.Close()
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.ProcessURI("/static/css/../main.css", "GET", "HTTP/1.1")
+	if tx.scope != static {
+		t.Errorf("unexpected scope %+v", tx.scope)
+	}
+}
+
+func TestScopeRemoveRules(t *testing.T) {
+	uri := ruleVariableParams{Variable: variables.RequestURI}
+	tagged := newIndexTestRule(t, 3, types.PhaseRequestHeaders, uri)
+	tagged.Tags_ = []string{"attack-sqli"}
+	rules := []*Rule{
+		newIndexTestRule(t, 1, types.PhaseRequestHeaders, uri),
+		newIndexTestRule(t, 2, types.PhaseRequestHeaders, uri),
+		tagged,
+		newIndexTestRule(t, 10, types.PhaseRequestHeaders, uri),
+		newIndexTestRule(t, 20, types.PhaseRequestHeaders, ruleVariableParams{Variable: variables.ArgsGet}),
+	}
+
+	vhost := NewScope([]string{"example.com"}, "")
+	vhost.RemoveRuleByID(1)
+	vhost.RemoveRuleByTag("attack-sqli")
+	api := vhost.NewLocation("/api")
+	api.RemoveRuleByIDRange(5, 15)
+	api.RemoveRuleTargetByID(20, 20, variables.ArgsGet, "token", nil)
+
+	waf := NewWAF()
+	for _, r := range rules {
+		if err := waf.Rules.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waf.Scopes = []*Scope{vhost, api}
+
+	tests := []struct {
+		host, uri string
+		want      []int
+	}{
+		{host: "other.com", uri: "/api?token=1", want: []int{1, 2, 3, 10, 20}},
+		{host: "example.com", uri: "/?token=1", want: []int{2, 10, 20}},
+		{host: "example.com", uri: "/api?token=1", want: []int{2}},
+		{host: "example.com", uri: "/api?token=1&id=1", want: []int{2, 20}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.host+tc.uri, func(t *testing.T) {
+			tx := waf.NewTransaction()
+			defer tx.Close()
+			tx.SetServerName(tc.host)
+			tx.ProcessURI(tc.uri, "GET", "HTTP/1.1")
+			tx.ProcessRequestHeaders()
+			if have := matchedRuleIDs(tx); !slices.Equal(have, tc.want) {
+				t.Errorf("unexpected matched rules, want %v, have %v", tc.want, have)
+			}
+		})
+	}
+}
+
+func TestScopeDefaultAction(t *testing.T) {
+	uri := ruleVariableParams{Variable: variables.RequestURI}
+	inherited := newIndexTestRule(t, 1, types.PhaseRequestHeaders, uri)
+	inherited.InheritsDisruptiveAction = true
+	own := newIndexTestRule(t, 2, types.PhaseRequestHeaders, uri)
+
+	admin := NewScope(nil, "/admin")
+	admin.SetDefaultAction(types.PhaseRequestHeaders, "deny", &dummyDenyAction{}, 418)
+	// the default actions are looked up by phase
+	other := NewScope(nil, "/other")
+	other.SetDefaultAction(types.PhaseRequestBody, "deny", &dummyDenyAction{}, 418)
+
+	waf := NewWAF()
+	waf.RuleEngine = types.RuleEngineOn
+	for _, r := range []*Rule{own, inherited} {
+		if err := waf.Rules.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waf.Scopes = []*Scope{admin, other}
+
+	for _, path := range []string{"/", "/other"} {
+		tx := waf.NewTransaction()
+		tx.ProcessURI(path, "GET", "HTTP/1.1")
+		if it := tx.ProcessRequestHeaders(); it != nil {
+			t.Errorf("unexpected interruption for %s: %+v", path, it)
+		}
+		tx.Close()
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.ProcessURI("/admin/users", "GET", "HTTP/1.1")
+	it := tx.ProcessRequestHeaders()
+	if it == nil {
+		t.Fatal("expected an interruption")
+	}
+	if it.RuleID != 1 || it.Status != 418 || it.Action != "deny" {
+		t.Errorf("unexpected interruption %+v", it)
+	}
+}
+
+func TestScopeWebAppID(t *testing.T) {
+	id := "admin"
+	vhost := NewScope([]string{"example.com"}, "")
+	vhost.WebAppID = &id
+	api := vhost.NewLocation("/api")
+
+	var none *Scope
+	if have := none.webAppID(); have != "" {
+		t.Errorf("unexpected application ID %q", have)
+	}
+	if have := api.webAppID(); have != id {
+		t.Errorf("unexpected application ID %q", have)
+	}
+}
@@ -11,10 +11,12 @@ import (
 	"io"
 	"math"
 	"mime"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +31,8 @@ import (
 	"github.com/corazawaf/coraza/v3/internal/corazarules"
 	"github.com/corazawaf/coraza/v3/internal/corazatypes"
 	"github.com/corazawaf/coraza/v3/internal/environment"
+	"github.com/corazawaf/coraza/v3/internal/mmdb"
+	"github.com/corazawaf/coraza/v3/internal/ratelimit"
 	stringsutil "github.com/corazawaf/coraza/v3/internal/strings"
 	urlutil "github.com/corazawaf/coraza/v3/internal/url"
 	"github.com/corazawaf/coraza/v3/types"
@@ -122,6 +126,18 @@ type Transaction struct {
 	// Contains duration in useconds per phase
 	stopWatches map[types.RulePhase]int64
 
+	// operatorTime is the time spent evaluating the operators, only measured
+	// when WAF.OperatorTimeLimit is set
+	operatorTime time.Duration
+
+	// operatorSkipped is set when the operator being evaluated was skipped
+	// because of the inspection limits
+	operatorSkipped bool
+
+	// scope is the configuration block applying to the request, selected with
+	// the server name and the path
+	scope *Scope
+
 	// Contains a WAF instance for the current transaction
 	WAF *WAF
 
@@ -134,6 +150,17 @@ type Transaction struct {
 	// it will write to the audit log
 	audit bool
 
+	// auditLogSanitizer holds the data masked by the sanitise actions, on top
+	// of the WAF AuditLogSanitizer
+	auditLogSanitizer AuditLogSanitizer
+
+	// sanitizedValues are the values matched by rules with sanitiseMatched
+	sanitizedValues []string
+
+	// intermediaryResponseHeaders are the response headers inspected by the
+	// response headers phase, kept for the audit log part D
+	intermediaryResponseHeaders map[string][]string
+
 	variables TransactionVariables
 
 	transformationCache map[transformationKey]transformationValue
@@ -177,6 +204,8 @@ func (tx *Transaction) Collection(idx variables.RuleVariable) collection.Collect
 		return tx.variables.remoteHost
 	case variables.RemotePort:
 		return tx.variables.remotePort
+	case variables.PeerAddr:
+		return tx.variables.peerAddr
 	case variables.ReqbodyError:
 		return tx.variables.reqbodyError
 	case variables.ReqbodyErrorMsg:
@@ -224,6 +253,7 @@ func (tx *Transaction) Collection(idx variables.RuleVariable) collection.Collect
 	case variables.StatusLine:
 		return tx.variables.statusLine
 	case variables.Duration:
+		tx.updateDuration()
 		return tx.variables.duration
 	case variables.ResponseHeadersNames:
 		return tx.variables.responseHeadersNames
@@ -314,11 +344,80 @@ func (tx *Transaction) Collection(idx variables.RuleVariable) collection.Collect
 		return tx.variables.timeWday
 	case variables.TimeYear:
 		return tx.variables.timeYear
+	case variables.Sessionid:
+		return tx.variables.sessionid
+	case variables.Userid:
+		return tx.variables.userid
+	case variables.IP:
+		return tx.variables.ip
+	case variables.Session:
+		return tx.variables.session
+	case variables.User:
+		return tx.variables.user
+	case variables.Global:
+		return tx.variables.global
+	case variables.Resource:
+		return tx.variables.resource
+	case variables.PerfRules:
+		return tx.variables.perfRules
+	case variables.PerfPhase1:
+		tx.updatePerf()
+		return tx.variables.perfPhase1
+	case variables.PerfPhase2:
+		tx.updatePerf()
+		return tx.variables.perfPhase2
+	case variables.PerfPhase3:
+		tx.updatePerf()
+		return tx.variables.perfPhase3
+	case variables.PerfPhase4:
+		tx.updatePerf()
+		return tx.variables.perfPhase4
+	case variables.PerfPhase5:
+		tx.updatePerf()
+		return tx.variables.perfPhase5
+	case variables.PerfCombined:
+		tx.updatePerf()
+		return tx.variables.perfCombined
+	case variables.PerfAll:
+		tx.updatePerf()
+		return tx.variables.perfAll
 	}
 
 	return collections.Noop
 }
 
+// InitPersistentCollection binds a persistent collection (IP, SESSION, USER,
+// GLOBAL or RESOURCE) to the record identified by key, loading it from the
+// WAF persistent store or creating it if it does not exist.
+func (tx *Transaction) InitPersistentCollection(v variables.RuleVariable, key string) error {
+	var col *persistentCollection
+	for _, c := range tx.variables.persistentCollections() {
+		if c.variable == v {
+			col = c
+			break
+		}
+	}
+	if col == nil {
+		return fmt.Errorf("%s is not a persistent collection", v.Name())
+	}
+
+	store, err := tx.WAF.PersistentStore()
+	if err != nil {
+		return err
+	}
+
+	// A collection can be initialized more than once, so the changes to the
+	// previous record are persisted first.
+	if err := col.persist(); err != nil {
+		return err
+	}
+	webAppID := tx.WAF.WebAppID
+	if id := tx.scope.webAppID(); id != "" {
+		webAppID = id
+	}
+	return col.init(store, webAppID, key, tx.WAF.CollectionTimeout)
+}
+
 // Interrupt sets the interruption for the transaction.
 // It complies with DetectionOnly definition which requires that disruptive actions are not executed.
 // Depending on the RuleEngine mode:
@@ -429,6 +528,16 @@ func (tx *Transaction) AddResponseHeader(key string, value string) {
 	}
 }
 
+// GeoLookupDBs returns the geolocation databases configured for the WAF.
+func (tx *Transaction) GeoLookupDBs() []*mmdb.Reader {
+	return tx.WAF.GeoLookupDBs
+}
+
+// RateLimitZone returns the rate limit zone defined with the name, or nil.
+func (tx *Transaction) RateLimitZone(name string) *ratelimit.Zone {
+	return tx.WAF.RateLimitZones[strings.ToLower(name)]
+}
+
 func (tx *Transaction) Capturing() bool {
 	return tx.Capture
 }
@@ -606,6 +715,86 @@ func (tx *Transaction) MatchRule(r *Rule, mds []types.MatchData) {
 	}
 }
 
+// updateDuration sets DURATION to the microseconds elapsed since the
+// transaction started.
+func (tx *Transaction) updateDuration() {
+	tx.variables.duration.Set(strconv.FormatInt(time.Since(time.Unix(0, tx.Timestamp)).Microseconds(), 10))
+}
+
+// statusLine returns the status line of a response, e.g. HTTP/1.1 200 OK.
+func statusLine(proto string, code int) string {
+	line := strconv.Itoa(code)
+	if proto != "" {
+		line = proto + " " + line
+	}
+	if reason := http.StatusText(code); reason != "" {
+		line += " " + reason
+	}
+	return line
+}
+
+// updatePerf sets the PERF_* variables from the duration of the evaluated
+// phases. They are only computed when read, most rules don't use them.
+func (tx *Transaction) updatePerf() {
+	phaseVars := [types.PhaseLogging + 1]*collections.Single{
+		types.PhaseRequestHeaders:  tx.variables.perfPhase1,
+		types.PhaseRequestBody:     tx.variables.perfPhase2,
+		types.PhaseResponseHeaders: tx.variables.perfPhase3,
+		types.PhaseResponseBody:    tx.variables.perfPhase4,
+		types.PhaseLogging:         tx.variables.perfPhase5,
+	}
+	var p [types.PhaseLogging + 1]int64
+	combined := int64(0)
+	for ph := types.PhaseRequestHeaders; ph <= types.PhaseLogging; ph++ {
+		// the stopwatch of a phase is set once it was evaluated
+		if tx.stopWatches[ph] == 0 {
+			continue
+		}
+		p[ph] = tx.stopWatches[ph] / 1e3
+		combined += p[ph]
+		phaseVars[ph].Set(strconv.FormatInt(p[ph], 10))
+	}
+	tx.variables.perfCombined.Set(strconv.FormatInt(combined, 10))
+	tx.variables.perfAll.Set(fmt.Sprintf("combined=%d, p1=%d, p2=%d, p3=%d, p4=%d, p5=%d",
+		combined, p[1], p[2], p[3], p[4], p[5]))
+}
+
+// recordRulePerf adds the evaluation time of a rule to PERF_RULES when it
+// reaches the SecRulePerfTime threshold. Rules evaluated in several phases
+// are reported with their total time.
+func (tx *Transaction) recordRulePerf(id int, d time.Duration) {
+	key := strconv.Itoa(id)
+	usec := d.Microseconds()
+	if prev := tx.variables.perfRules.Get(key); len(prev) > 0 {
+		n, _ := strconv.ParseInt(prev[0], 10, 64)
+		usec += n
+	} else if d < tx.WAF.RulePerfTime {
+		return
+	}
+	tx.variables.perfRules.Set(key, []string{strconv.FormatInt(usec, 10)})
+	tx.DebugLogger().Info().
+		Int("rule_id", id).
+		Int("usec", int(usec)).
+		Msg("Rule evaluation exceeded SecRulePerfTime")
+}
+
+// rulesPerformance returns the content of PERF_RULES by rule ID.
+func (tx *Transaction) rulesPerformance() map[int]int64 {
+	if tx.variables.perfRules.Len() == 0 {
+		return nil
+	}
+	res := make(map[int]int64, tx.variables.perfRules.Len())
+	for _, md := range tx.variables.perfRules.FindAll() {
+		id, err := strconv.Atoi(md.Key())
+		if err != nil {
+			continue
+		}
+		usec, _ := strconv.ParseInt(md.Value(), 10, 64)
+		res[id] = usec
+	}
+	return res
+}
+
 // GetStopWatch is used to debug phase durations
 // Normally it should be named StopWatch() but it would be confusing
 func (tx *Transaction) GetStopWatch() string {
@@ -630,6 +819,7 @@ func (tx *Transaction) GetField(rv ruleVariableParams) []types.MatchData {
 	}
 
 	var matches []types.MatchData
+	xml, isXML := col.(*collections.XML)
 	// Now that we have access to the collection, we can apply the exceptions
 	switch {
 	case rv.KeyRx != nil:
@@ -639,6 +829,8 @@ func (tx *Transaction) GetField(rv ruleVariableParams) []types.MatchData {
 			// This should probably never happen, selectability is checked at parsing time
 			tx.debugLogger.Error().Str("collection", rv.Variable.Name()).Msg("attempted to use regex with non-selectable collection")
 		}
+	case rv.KeyXPath != nil && isXML:
+		matches = xml.FindExpr(rv.KeyXPath)
 	case rv.KeyStr != "":
 		if m, ok := col.(collection.Keyed); ok {
 			matches = m.FindString(rv.KeyStr)
@@ -690,6 +882,11 @@ func (tx *Transaction) GetField(rv ruleVariableParams) []types.MatchData {
 // key for pattern-based matching (e.g. removing all ARGS matching
 // /^json\.\d+\.field$/ from a given rule).
 func (tx *Transaction) RemoveRuleTargetByID(id int, variable variables.RuleVariable, key string, keyRx *regexp.Regexp) {
+	tx.ruleRemoveTargetByID[id] = append(tx.ruleRemoveTargetByID[id], removedTargetParams(variable, key, keyRx)...)
+}
+
+// removedTargetParams returns the exceptions removing a target from a rule
+func removedTargetParams(variable variables.RuleVariable, key string, keyRx *regexp.Regexp) []ruleVariableParams {
 	c := ruleVariableParams{
 		Variable: variable,
 		KeyStr:   key,
@@ -700,19 +897,16 @@ func (tx *Transaction) RemoveRuleTargetByID(id int, variable variables.RuleVaria
 		// ARGS and ARGS_NAMES have to be splitted into _GET and _POST
 		switch variable {
 		case variables.Args:
-			c.Variable = variables.ArgsGet
-			tx.ruleRemoveTargetByID[id] = append(tx.ruleRemoveTargetByID[id], c)
-			c.Variable = variables.ArgsPost
-			tx.ruleRemoveTargetByID[id] = append(tx.ruleRemoveTargetByID[id], c)
+			get, post := c, c
+			get.Variable, post.Variable = variables.ArgsGet, variables.ArgsPost
+			return []ruleVariableParams{get, post}
 		case variables.ArgsNames:
-			c.Variable = variables.ArgsGetNames
-			tx.ruleRemoveTargetByID[id] = append(tx.ruleRemoveTargetByID[id], c)
-			c.Variable = variables.ArgsPostNames
-			tx.ruleRemoveTargetByID[id] = append(tx.ruleRemoveTargetByID[id], c)
+			get, post := c, c
+			get.Variable, post.Variable = variables.ArgsGetNames, variables.ArgsPostNames
+			return []ruleVariableParams{get, post}
 		}
-		return
 	}
-	tx.ruleRemoveTargetByID[id] = append(tx.ruleRemoveTargetByID[id], c)
+	return []ruleVariableParams{c}
 }
 
 // RemoveRuleByID Removes a rule from the transaction
@@ -752,11 +946,20 @@ func (tx *Transaction) ProcessConnection(client string, cPort int, server string
 	// }
 
 	tx.variables.remoteAddr.Set(client)
+	tx.variables.peerAddr.Set(client)
 	tx.variables.remotePort.Set(p)
 	tx.variables.serverAddr.Set(server)
 	tx.variables.serverPort.Set(p2)
 }
 
+// SetPeerAddress sets the address of the peer the connection was received
+// from. ProcessConnection sets it to the client address, connectors resolving
+// the client address from the headers of a trusted proxy call it afterwards
+// with the address of the proxy.
+func (tx *Transaction) SetPeerAddress(addr string) {
+	tx.variables.peerAddr.Set(addr)
+}
+
 // ExtractGetArguments transforms an url encoded string to a map and creates ARGS_GET
 func (tx *Transaction) ExtractGetArguments(uri string) {
 	data := urlutil.ParseQuery(uri, '&')
@@ -864,6 +1067,7 @@ func (tx *Transaction) ProcessURI(uri string, method string, httpVersion string)
 	tx.variables.requestFilename.Set(path)
 
 	tx.variables.queryString.Set(query)
+	tx.selectScope()
 }
 
 // SetServerName allows to set server name details.
@@ -875,6 +1079,7 @@ func (tx *Transaction) SetServerName(serverName string) {
 		tx.debugLogger.Warn().Msg("SetServerName has been called after ProcessRequestHeaders")
 	}
 	tx.variables.serverName.Set(serverName)
+	tx.selectScope()
 }
 
 // ProcessRequestHeaders performs the analysis on the request headers.
@@ -1133,6 +1338,10 @@ func (tx *Transaction) ProcessRequestBody() (*types.Interruption, error) {
 		Mime:                      mimeType,
 		StoragePath:               tx.WAF.UploadDir,
 		RequestBodyRecursionLimit: tx.WAF.RequestBodyJsonDepthLimit,
+		XMLDepthLimit:             tx.WAF.XMLDepthLimit,
+		XMLNodeLimit:              tx.WAF.XMLNodeLimit,
+		ArgumentsLimit:            tx.WAF.ArgumentLimit,
+		KeepRawBody:               tx.WAF.Rules.keepRawJSONBody,
 	}); err != nil {
 		tx.debugLogger.Error().Err(err).Msg("Failed to process request body")
 		tx.generateRequestBodyError(err)
@@ -1169,6 +1378,10 @@ func (tx *Transaction) ProcessResponseHeaders(code int, proto string) *types.Int
 	c := strconv.Itoa(code)
 	tx.variables.responseStatus.Set(c)
 	tx.variables.responseProtocol.Set(proto)
+	tx.variables.statusLine.Set(statusLine(proto, code))
+	if tx.AuditEngine != types.AuditEngineOff && slices.Contains(tx.AuditLogParts, types.AuditLogPartIntermediaryResponseHeaders) {
+		tx.intermediaryResponseHeaders = tx.variables.responseHeaders.Data()
+	}
 
 	tx.WAF.Rules.Eval(types.PhaseResponseHeaders, tx)
 	return tx.interruption
@@ -1362,7 +1575,11 @@ func (tx *Transaction) ProcessResponseBody() (*types.Interruption, error) {
 		}
 
 		tx.debugLogger.Debug().Str("body_processor", bp).Msg("Attempting to process response body")
-		if err := b.ProcessResponse(reader, tx.Variables(), plugintypes.BodyProcessorOptions{}); err != nil {
+		if err := b.ProcessResponse(reader, tx.Variables(), plugintypes.BodyProcessorOptions{
+			XMLDepthLimit: tx.WAF.XMLDepthLimit,
+			XMLNodeLimit:  tx.WAF.XMLNodeLimit,
+			KeepRawBody:   tx.WAF.Rules.keepRawJSONBody,
+		}); err != nil {
 			tx.debugLogger.Error().Err(err).Msg("Failed to process response body")
 			tx.generateResponseBodyError(err)
 		}
@@ -1432,6 +1649,9 @@ func (tx *Transaction) ProcessLogging() {
 		tx.debugLogger.Error().
 			Err(err).
 			Msg("Failed to write audit log")
+		if tx.WAF.Metrics != nil {
+			tx.WAF.Metrics.AuditLogWriteError()
+		}
 	}
 }
 
@@ -1549,6 +1769,28 @@ func (tx *Transaction) AuditLog() *auditlog.Log {
 			// file contents for multipart/form-data requests. Not implemented yet.
 		case types.AuditLogPartUploadedFiles:
 			al.Transaction_.Request_.Files_ = tx.auditLogCollectFiles()
+		case types.AuditLogPartIntermediaryResponseHeaders:
+			if al.Transaction_.Response_ == nil {
+				al.Transaction_.Response_ = &auditlog.TransactionResponse{}
+			}
+			status, _ := strconv.Atoi(tx.variables.responseStatus.Get())
+			al.Transaction_.Response_.Status_ = status
+			al.Transaction_.Response_.IntermediaryHeaders_ = copyHeaders(tx.intermediaryResponseHeaders)
+		case types.AuditLogPartResponseBody:
+			if al.Transaction_.Response_ == nil {
+				al.Transaction_.Response_ = &auditlog.TransactionResponse{}
+			}
+			// An interrupted transaction doesn't deliver the buffered body, the
+			// connector replaces it.
+			if !tx.IsInterrupted() {
+				reader, err := tx.responseBodyBuffer.Reader()
+				if err == nil {
+					content, err := io.ReadAll(reader)
+					if err == nil {
+						al.Transaction_.Response_.FinalBody_ = string(content)
+					}
+				}
+			}
 		case types.AuditLogPartIntermediaryResponseBody:
 			if al.Transaction_.Response_ == nil {
 				al.Transaction_.Response_ = &auditlog.TransactionResponse{}
@@ -1570,6 +1812,8 @@ func (tx *Transaction) AuditLog() *auditlog.Log {
 				RuleEngine_: tx.RuleEngine.String(),
 				Stopwatch_:  tx.GetStopWatch(),
 				Rulesets_:   tx.WAF.ComponentNames,
+
+				RulesPerformance_: tx.rulesPerformance(),
 			}
 		case types.AuditLogPartRulesMatched:
 			auditLogPartRulesMatchedSet = true
@@ -1628,9 +1872,45 @@ func (tx *Transaction) AuditLog() *auditlog.Log {
 
 	}
 
+	if s := tx.newAuditLogSanitization(); s != nil {
+		s.sanitize(al, tx.variables.reqbodyProcessor.Get(), tx.variables.resBodyProcessor.Get())
+	}
+
+	// Bodies are truncated once sanitized, so the body processors can still
+	// parse them.
+	if limit := tx.WAF.AuditLogBodyLimit; limit > 0 {
+		if req := al.Transaction_.Request_; req != nil {
+			req.Body_ = truncateAuditLogBody(req.Body_, limit)
+		}
+		if res := al.Transaction_.Response_; res != nil {
+			res.Body_ = truncateAuditLogBody(res.Body_, limit)
+			res.FinalBody_ = truncateAuditLogBody(res.FinalBody_, limit)
+		}
+	}
+
 	return al
 }
 
+func truncateAuditLogBody(body string, limit int64) string {
+	if int64(len(body)) <= limit {
+		return body
+	}
+	return body[:limit]
+}
+
+// copyHeaders returns a copy of headers, the audit log sanitization masks the
+// values in place.
+func copyHeaders(headers map[string][]string) map[string][]string {
+	if headers == nil {
+		return nil
+	}
+	c := make(map[string][]string, len(headers))
+	for k, v := range headers {
+		c[k] = slices.Clone(v)
+	}
+	return c
+}
+
 // auditLogCollectFiles collects uploaded file metadata from transaction variables
 // for use in audit log parts (Part J).
 func (tx *Transaction) auditLogCollectFiles() []plugintypes.AuditLogTransactionRequestFiles {
@@ -1664,7 +1944,9 @@ func (tx *Transaction) auditLogCollectFiles() []plugintypes.AuditLogTransactionR
 // This method helps the GC to clean up the transaction faster and release resources
 // It also allows caches the transaction back into the sync.Pool
 func (tx *Transaction) Close() error {
-	defer tx.WAF.txPool.Put(tx)
+	w := tx.WAF
+	defer w.transactionClosed()
+	defer w.txPool.Put(tx)
 
 	var errs []error
 	if environment.HasAccessToFS {
@@ -1686,6 +1968,16 @@ func (tx *Transaction) Close() error {
 		}
 	}
 
+	for _, c := range tx.variables.persistentCollections() {
+		if err := c.persist(); err != nil {
+			errs = append(errs, fmt.Errorf("persisting %s collection: %v", c.Name(), err))
+		}
+	}
+
+	if w.Metrics != nil {
+		tx.recordMetrics(w.Metrics)
+	}
+
 	tx.variables.reset()
 	if err := tx.requestBodyBuffer.Reset(); err != nil {
 		errs = append(errs, fmt.Errorf("reseting request body buffer: %v", err))
@@ -1800,6 +2092,7 @@ type TransactionVariables struct {
 	outboundDataError        *collections.Single
 	queryString              *collections.Single
 	remoteAddr               *collections.Single
+	peerAddr                 *collections.Single
 	remoteHost               *collections.Single
 	remotePort               *collections.Single
 	reqbodyError             *collections.Single
@@ -1820,7 +2113,7 @@ type TransactionVariables struct {
 	requestProtocol          *collections.Single
 	requestURI               *collections.Single
 	requestURIRaw            *collections.Single
-	requestXML               *collections.Map
+	requestXML               *collections.XML
 	responseBody             *collections.Single
 	responseContentLength    *collections.Single
 	responseContentType      *collections.Single
@@ -1828,7 +2121,7 @@ type TransactionVariables struct {
 	responseHeadersNames     collection.Keyed
 	responseProtocol         *collections.Single
 	responseStatus           *collections.Single
-	responseXML              *collections.Map
+	responseXML              *collections.XML
 	responseArgs             *collections.Map
 	resBodyProcessor         *collections.Single
 	rule                     *collections.Map
@@ -1839,7 +2132,7 @@ type TransactionVariables struct {
 	tx                       *collections.Map
 	uniqueID                 *collections.Single
 	urlencodedError          *collections.Single
-	xml                      *collections.Map
+	xml                      *collections.XML
 	resBodyError             *collections.Single
 	resBodyErrorMsg          *collections.Single
 	resBodyProcessorError    *collections.Single
@@ -1853,6 +2146,21 @@ type TransactionVariables struct {
 	timeSec                  *collections.Single
 	timeWday                 *collections.Single
 	timeYear                 *collections.Single
+	sessionid                *collections.Single
+	userid                   *collections.Single
+	perfRules                *collections.Map
+	perfPhase1               *collections.Single
+	perfPhase2               *collections.Single
+	perfPhase3               *collections.Single
+	perfPhase4               *collections.Single
+	perfPhase5               *collections.Single
+	perfCombined             *collections.Single
+	perfAll                  *collections.Single
+	ip                       *persistentCollection
+	session                  *persistentCollection
+	user                     *persistentCollection
+	global                   *persistentCollection
+	resource                 *persistentCollection
 }
 
 func NewTransactionVariables() *TransactionVariables {
@@ -1869,6 +2177,7 @@ func NewTransactionVariables() *TransactionVariables {
 	v.outboundDataError = collections.NewSingle(variables.OutboundDataError)
 	v.queryString = collections.NewSingle(variables.QueryString)
 	v.remoteAddr = collections.NewSingle(variables.RemoteAddr)
+	v.peerAddr = collections.NewSingle(variables.PeerAddr)
 	v.remoteHost = collections.NewSingle(variables.RemoteHost)
 	v.remotePort = collections.NewSingle(variables.RemotePort)
 	v.reqbodyError = collections.NewSingle(variables.ReqbodyError)
@@ -1921,8 +2230,8 @@ func NewTransactionVariables() *TransactionVariables {
 	v.files = collections.NewMap(variables.Files)
 	v.filesNames = collections.NewMap(variables.FilesNames)
 	v.filesTmpNames = collections.NewMap(variables.FilesTmpNames)
-	v.responseXML = collections.NewMap(variables.ResponseXML)
-	v.requestXML = collections.NewMap(variables.RequestXML)
+	v.responseXML = collections.NewXML(variables.ResponseXML)
+	v.requestXML = collections.NewXML(variables.RequestXML)
 	v.multipartPartHeaders = collections.NewMap(variables.MultipartPartHeaders)
 	v.multipartStrictError = collections.NewSingle(variables.MultipartStrictError)
 	v.time = collections.NewSingle(variables.Time)
@@ -1934,6 +2243,21 @@ func NewTransactionVariables() *TransactionVariables {
 	v.timeSec = collections.NewSingle(variables.TimeSec)
 	v.timeWday = collections.NewSingle(variables.TimeWday)
 	v.timeYear = collections.NewSingle(variables.TimeYear)
+	v.sessionid = collections.NewSingle(variables.Sessionid)
+	v.userid = collections.NewSingle(variables.Userid)
+	v.perfRules = collections.NewMap(variables.PerfRules)
+	v.perfPhase1 = collections.NewSingle(variables.PerfPhase1)
+	v.perfPhase2 = collections.NewSingle(variables.PerfPhase2)
+	v.perfPhase3 = collections.NewSingle(variables.PerfPhase3)
+	v.perfPhase4 = collections.NewSingle(variables.PerfPhase4)
+	v.perfPhase5 = collections.NewSingle(variables.PerfPhase5)
+	v.perfCombined = collections.NewSingle(variables.PerfCombined)
+	v.perfAll = collections.NewSingle(variables.PerfAll)
+	v.ip = newPersistentCollection(variables.IP)
+	v.session = newPersistentCollection(variables.Session)
+	v.user = newPersistentCollection(variables.User)
+	v.global = newPersistentCollection(variables.Global)
+	v.resource = newPersistentCollection(variables.Resource)
 
 	// XML is a pointer to RequestXML
 	v.xml = v.requestXML
@@ -2031,6 +2355,10 @@ func (v *TransactionVariables) RemotePort() collection.Single {
 	return v.remotePort
 }
 
+func (v *TransactionVariables) PeerAddr() collection.Single {
+	return v.peerAddr
+}
+
 func (v *TransactionVariables) RequestBodyError() collection.Single {
 	return v.reqbodyError
 }
@@ -2378,6 +2706,9 @@ func (v *TransactionVariables) All(f func(v variables.RuleVariable, col collecti
 	if !f(variables.RemotePort, v.remotePort) {
 		return
 	}
+	if !f(variables.PeerAddr, v.peerAddr) {
+		return
+	}
 	if !f(variables.ReqbodyError, v.reqbodyError) {
 		return
 	}
@@ -2519,6 +2850,46 @@ func (v *TransactionVariables) All(f func(v variables.RuleVariable, col collecti
 	if !f(variables.TimeYear, v.timeYear) {
 		return
 	}
+	if !f(variables.Sessionid, v.sessionid) {
+		return
+	}
+	if !f(variables.Userid, v.userid) {
+		return
+	}
+	if !f(variables.PerfRules, v.perfRules) {
+		return
+	}
+	if !f(variables.PerfPhase1, v.perfPhase1) {
+		return
+	}
+	if !f(variables.PerfPhase2, v.perfPhase2) {
+		return
+	}
+	if !f(variables.PerfPhase3, v.perfPhase3) {
+		return
+	}
+	if !f(variables.PerfPhase4, v.perfPhase4) {
+		return
+	}
+	if !f(variables.PerfPhase5, v.perfPhase5) {
+		return
+	}
+	if !f(variables.PerfCombined, v.perfCombined) {
+		return
+	}
+	if !f(variables.PerfAll, v.perfAll) {
+		return
+	}
+	for _, c := range v.persistentCollections() {
+		if !f(c.variable, c) {
+			return
+		}
+	}
+}
+
+// persistentCollections returns the collections backed by the persistent store.
+func (v *TransactionVariables) persistentCollections() [5]*persistentCollection {
+	return [5]*persistentCollection{v.ip, v.session, v.user, v.global, v.resource}
 }
 
 type formattable interface {
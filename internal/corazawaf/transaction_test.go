@@ -138,6 +138,49 @@ func TestTxMultipart(t *testing.T) {
 	}
 }
 
+func TestTxDuration(t *testing.T) {
+	waf := NewWAF()
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.Timestamp = time.Now().Add(-2 * time.Second).UnixNano()
+
+	first, err := strconv.ParseInt(tx.Collection(variables.Duration).(*collections.Single).Get(), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first < 2000000 {
+		t.Errorf("expected at least 2s in microseconds, got %d", first)
+	}
+	time.Sleep(time.Millisecond)
+	second, _ := strconv.ParseInt(tx.Collection(variables.Duration).(*collections.Single).Get(), 10, 64)
+	if second <= first {
+		t.Errorf("expected the duration to be updated when read, got %d then %d", first, second)
+	}
+}
+
+func TestTxStatusLine(t *testing.T) {
+	tests := []struct {
+		code  int
+		proto string
+		want  string
+	}{
+		{200, "HTTP/1.1", "HTTP/1.1 200 OK"},
+		{500, "HTTP/2.0", "HTTP/2.0 500 Internal Server Error"},
+		{599, "HTTP/1.1", "HTTP/1.1 599"},
+		{404, "", "404 Not Found"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.want, func(t *testing.T) {
+			tx := NewWAF().NewTransaction()
+			defer tx.Close()
+			tx.ProcessResponseHeaders(tc.code, tc.proto)
+			if have := tx.variables.statusLine.Get(); have != tc.want {
+				t.Errorf("unexpected status line, want %q, have %q", tc.want, have)
+			}
+		})
+	}
+}
+
 func TestTxResponse(t *testing.T) {
 	/*
 		tx := NewWAF().NewTransaction()
@@ -574,6 +617,73 @@ func TestAuditLogPartJFileSizeParseError(t *testing.T) {
 	}
 }
 
+func TestAuditLogPartsDG(t *testing.T) {
+	newTx := func(t *testing.T, waf *WAF) *Transaction {
+		t.Helper()
+		tx := waf.NewTransaction()
+		tx.AuditEngine = types.AuditEngineOn
+		tx.AuditLogParts = types.AuditLogParts("ADFGZ")
+		tx.ResponseBodyAccess = true
+		tx.AddResponseHeader("Content-Type", "text/html")
+		tx.ProcessResponseHeaders(200, "HTTP/1.1")
+		// added by the connector after the inspection
+		tx.AddResponseHeader("X-Added", "later")
+		if _, _, err := tx.WriteResponseBody([]byte("<html>response</html>")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.ProcessResponseBody(); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { tx.Close() })
+		return tx
+	}
+
+	t.Run("captured", func(t *testing.T) {
+		res := newTx(t, NewWAF()).AuditLog().Transaction().Response()
+		if _, ok := res.IntermediaryHeaders()["x-added"]; ok {
+			t.Error("unexpected header added after the inspection in part D")
+		}
+		if ct := res.IntermediaryHeaders()["content-type"]; len(ct) != 1 || ct[0] != "text/html" {
+			t.Errorf("unexpected intermediary headers %v", res.IntermediaryHeaders())
+		}
+		if _, ok := res.Headers()["x-added"]; !ok {
+			t.Error("expected the header added after the inspection in part F")
+		}
+		if res.FinalBody() != "<html>response</html>" {
+			t.Errorf("unexpected final body %q", res.FinalBody())
+		}
+	})
+
+	t.Run("body limit", func(t *testing.T) {
+		waf := NewWAF()
+		waf.AuditLogBodyLimit = 6
+		res := newTx(t, waf).AuditLog().Transaction().Response()
+		if res.FinalBody() != "<html>" {
+			t.Errorf("unexpected final body %q", res.FinalBody())
+		}
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		tx := newTx(t, NewWAF())
+		tx.interruption = &types.Interruption{Status: 403, Action: "deny"}
+		if body := tx.AuditLog().Transaction().Response().FinalBody(); body != "" {
+			t.Errorf("unexpected final body of an interrupted transaction %q", body)
+		}
+	})
+
+	t.Run("audit engine off", func(t *testing.T) {
+		tx := NewWAF().NewTransaction()
+		defer tx.Close()
+		tx.AuditEngine = types.AuditEngineOff
+		tx.AuditLogParts = types.AuditLogParts("ADZ")
+		tx.AddResponseHeader("Content-Type", "text/html")
+		tx.ProcessResponseHeaders(200, "HTTP/1.1")
+		if headers := tx.AuditLog().Transaction().Response().IntermediaryHeaders(); headers != nil {
+			t.Errorf("unexpected intermediary headers %v", headers)
+		}
+	})
+}
+
 var responseBodyWriters = map[string]func(tx *Transaction, body string) (*types.Interruption, int, error){
 	"WriteResponseBody": func(tx *Transaction, body string) (*types.Interruption, int, error) {
 		return tx.WriteResponseBody([]byte(body))
@@ -1193,7 +1303,7 @@ func TestRelevantAuditLoggingWithoutAuditFlag(t *testing.T) {
 // Transaction: when it does, make sure the field is reset on pool reuse, then
 // update wantFields.
 func TestTransactionFieldCount(t *testing.T) {
-	const wantFields = 35
+	const wantFields = 41
 	if got := reflect.TypeFor[Transaction]().NumField(); got != wantFields {
 		t.Fatalf("Transaction has %d fields, want %d. If you added a field, make sure it "+
 			"is reset on pool reuse in newTransaction() (or Close()), then update wantFields.", got, wantFields)
@@ -2205,6 +2315,58 @@ func TestResponseBodyForceProcessing(t *testing.T) {
 	}
 }
 
+func TestJSONResponseBodyIgnoresArgumentsLimit(t *testing.T) {
+	waf := NewWAF()
+	waf.ResponseBodyAccess = true
+	waf.ArgumentLimit = 2
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.ForceResponseBodyVariable = true
+	tx.variables.ResponseBodyProcessor().(*collections.Single).Set("JSON")
+	tx.ProcessRequestHeaders()
+	if _, err := tx.ProcessRequestBody(); err != nil {
+		t.Fatal(err)
+	}
+	tx.ProcessResponseHeaders(200, "HTTP/1.1")
+	if _, _, err := tx.WriteResponseBody([]byte(`{"a": 1, "b": 2, "c": 3}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ProcessResponseBody(); err != nil {
+		t.Fatal(err)
+	}
+	if tx.variables.resBodyError.Get() == "1" {
+		t.Errorf("unexpected RESBODY_ERROR %q", tx.variables.resBodyErrorMsg.Get())
+	}
+	if want, have := 3, tx.variables.responseArgs.Len(); want != have {
+		t.Errorf("unexpected RESPONSE_ARGS length, want %d, have %d", want, have)
+	}
+}
+
+func TestJSONRequestBodyLimits(t *testing.T) {
+	waf := NewWAF()
+	waf.RequestBodyAccess = true
+	waf.ArgumentLimit = 2
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.variables.RequestBodyProcessor().(*collections.Single).Set("JSON")
+	tx.ProcessRequestHeaders()
+	if _, _, err := tx.WriteRequestBody([]byte(`{"a": 1, "b": 2, "c": 3}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ProcessRequestBody(); err != nil {
+		t.Fatal(err)
+	}
+	if tx.variables.reqbodyError.Get() != "1" {
+		t.Error("expected REQBODY_ERROR to be set")
+	}
+	if want, have := 2, tx.variables.argsPost.Len(); want != have {
+		t.Errorf("unexpected ARGS_POST length, want %d, have %d", want, have)
+	}
+	if raw := tx.variables.tx.Get("json_request_body"); len(raw) != 0 {
+		t.Errorf("unexpected raw body %q", raw)
+	}
+}
+
 func TestForceRequestBodyOverride(t *testing.T) {
 	waf := NewWAF()
 	waf.RequestBodyAccess = true
@@ -10,6 +10,7 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"reflect"
 	"regexp"
 	"strconv"
 	gosync "sync"
@@ -17,10 +18,15 @@ import (
 	"time"
 
 	"github.com/corazawaf/coraza/v3/debuglog"
+	"github.com/corazawaf/coraza/v3/experimental/metrics"
+	"github.com/corazawaf/coraza/v3/experimental/plugins/macro"
 	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
 	"github.com/corazawaf/coraza/v3/internal/auditlog"
 	"github.com/corazawaf/coraza/v3/internal/environment"
 	"github.com/corazawaf/coraza/v3/internal/memoize"
+	"github.com/corazawaf/coraza/v3/internal/mmdb"
+	"github.com/corazawaf/coraza/v3/internal/persistence"
+	"github.com/corazawaf/coraza/v3/internal/ratelimit"
 	stringutils "github.com/corazawaf/coraza/v3/internal/strings"
 	"github.com/corazawaf/coraza/v3/internal/sync"
 	"github.com/corazawaf/coraza/v3/types"
@@ -33,6 +39,12 @@ const (
 	// DefaultRequestBodyJsonDepthLimit is the default limit for the depth of JSON objects in the request body
 	DefaultRequestBodyJsonDepthLimit = 1024
 
+	// DefaultXMLDepthLimit is the default limit for the nesting of XML elements in bodies
+	DefaultXMLDepthLimit = 256
+
+	// DefaultXMLNodeLimit is the default limit for the number of nodes of XML bodies
+	DefaultXMLNodeLimit = 100000
+
 	// defaultHighestSeverity is the default value for HIGHEST_SEVERITY when no rules
 	// with severity have been matched, aligning with ModSecurity behavior:
 	// - ModSec v2: apache2/msc_util.c highest_severity initialized to 255
@@ -64,6 +76,12 @@ type WAF struct {
 	// Request body JSON recursive depth limit
 	RequestBodyJsonDepthLimit int
 
+	// XML bodies element nesting limit
+	XMLDepthLimit int
+
+	// XML bodies node count limit
+	XMLNodeLimit int
+
 	// Request body in memory limit
 	requestBodyInMemoryLimit *int64
 
@@ -145,6 +163,13 @@ type WAF struct {
 	// Contains the regular expression for relevant status audit logging
 	AuditLogRelevantStatus *regexp.Regexp
 
+	// Data masked in the audit logs
+	AuditLogSanitizer AuditLogSanitizer
+
+	// AuditLogBodyLimit is the maximum size in bytes of the bodies written to
+	// the audit log, 0 means no limit
+	AuditLogBodyLimit int64
+
 	auditLogWriter plugintypes.AuditLogWriter
 
 	// AuditLogWriterConfig is configuration of audit logging, populated by multiple directives and consumed by
@@ -156,13 +181,65 @@ type WAF struct {
 	// Configures the maximum number of ARGS that will be accepted for processing.
 	ArgumentLimit int
 
+	// RulePerfTime is the evaluation time from which rules are reported in
+	// PERF_RULES and in the audit log, 0 disables the timing of the rules.
+	// Set by the SecRulePerfTime directive.
+	RulePerfTime time.Duration
+
+	// Metrics receives the activity of the transactions, nil when disabled.
+	// Set it with SetMetrics.
+	Metrics metrics.Recorder
+
+	// unobserveAuditLogStats unregisters the counters of the audit log writer
+	// from Metrics, see SetMetrics.
+	unobserveAuditLogStats func()
+
+	// Scopes are the configuration blocks overriding the configuration for
+	// some hosts and paths, see the <VirtualHost> and <Location> blocks.
+	Scopes []*Scope
+
+	// InspectionByteLimit is the maximum number of bytes of a variable inspected
+	// by a rule, longer values are truncated and TX:MSC_PCRE_LIMITS_EXCEEDED is
+	// set. 0 disables the limit. Set by the SecInspectionByteLimit directive.
+	InspectionByteLimit int
+
+	// OperatorTimeLimit is the time the operators can spend evaluating a
+	// transaction, @rx is no longer evaluated once it is exhausted. 0 disables
+	// the limit. Set by the SecOperatorTimeLimit directive.
+	OperatorTimeLimit time.Duration
+
 	// RxPreFilterEnabled controls whether the @rx operator uses
 	// literal pre-filtering. Set by the SecRxPreFilter directive.
 	RxPreFilterEnabled bool
 
+	// CollectionTimeout is the time in seconds a persistent collection record
+	// is kept since its last update. Set by the SecCollectionTimeout directive.
+	CollectionTimeout int
+
+	// GeoLookupDBs are the geolocation databases queried in order by @geoLookup.
+	// Set by the SecGeoLookupDb directive.
+	GeoLookupDBs []*mmdb.Reader
+
+	// RateLimitZones are the rate limiters used by @rateLimit, indexed by their
+	// lowercased name. Set by the SecRateLimitZone directive.
+	RateLimitZones map[string]*ratelimit.Zone
+
+	persistentStore plugintypes.PersistentStore
+
+	persistentStoreOnce gosync.Once
+	persistentStoreErr  error
+	// persistentStoreInherited is set once the store was taken over by the
+	// WAF replacing this one, which closes it instead.
+	persistentStoreInherited atomic.Bool
+
 	memoizerID uint64
 	memoizer   *memoize.Memoizer
 	closeOnce  gosync.Once
+
+	// inFlight counts the transactions not closed yet, so a retired WAF is
+	// closed once the last of them finishes.
+	inFlight atomic.Int64
+	retired  atomic.Bool
 }
 
 // Options is used to pass options to the WAF instance
@@ -196,6 +273,7 @@ func (w *WAF) NewTransactionWithOptions(opts Options) *Transaction {
 // NewTransactionWithID Creates a new initialized transaction for this WAF instance
 // Using the specified ID
 func (w *WAF) newTransaction(opts Options) *Transaction {
+	w.inFlight.Add(1)
 	tx := w.txPool.Get().(*Transaction)
 	tx.id = opts.ID
 	tx.context = opts.Context
@@ -207,6 +285,9 @@ func (w *WAF) newTransaction(opts Options) *Transaction {
 	tx.AuditEngine = w.AuditEngine
 	tx.AuditLogParts = w.AuditLogParts
 	tx.AuditLogFormat = w.AuditLogFormat
+	tx.auditLogSanitizer = AuditLogSanitizer{}
+	tx.sanitizedValues = nil
+	tx.intermediaryResponseHeaders = nil
 	tx.ForceRequestBodyVariable = false
 	tx.RequestBodyAccess = w.RequestBodyAccess
 	tx.RequestBodyLimit = w.RequestBodyLimit
@@ -224,6 +305,9 @@ func (w *WAF) newTransaction(opts Options) *Transaction {
 	tx.AllowType = 0
 	tx.Capture = false
 	tx.stopWatches = map[types.RulePhase]int64{}
+	tx.operatorTime = 0
+	tx.operatorSkipped = false
+	tx.scope = nil
 	tx.WAF = w
 	tx.debugLogger = w.Logger.With(debuglog.Str("tx_id", tx.id))
 	tx.Timestamp = time.Now().UnixNano()
@@ -273,6 +357,9 @@ func (w *WAF) newTransaction(opts Options) *Transaction {
 	tx.variables.duration.Set("0")
 	tx.variables.highestSeverity.Set(strconv.Itoa(defaultHighestSeverity))
 	tx.variables.uniqueID.Set(tx.id)
+	for _, c := range tx.variables.persistentCollections() {
+		c.logger = tx.debugLogger
+	}
 	tx.setTimeVariables()
 
 	tx.debugLogger.Debug().Msg("Transaction started")
@@ -322,6 +409,13 @@ func NewWAF() *WAF {
 			Msg("error creating serial log writer")
 	}
 
+	store, err := persistence.GetStore("memory")
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Msg("error creating memory persistent store")
+	}
+
 	waf := &WAF{
 		// Initializing pool for transactions
 		txPool: sync.NewPool(func() any { return new(Transaction) }),
@@ -331,6 +425,8 @@ func NewWAF() *WAF {
 		RequestBodyLimit:          134217728, // Hard limit equal to _1gib
 		RequestBodyLimitAction:    types.BodyLimitActionReject,
 		RequestBodyJsonDepthLimit: DefaultRequestBodyJsonDepthLimit,
+		XMLDepthLimit:             DefaultXMLDepthLimit,
+		XMLNodeLimit:              DefaultXMLNodeLimit,
 		ResponseBodyAccess:        false,
 		ResponseBodyLimit:         524288, // Hard limit equal to _1gib
 		ResponseBodyLimitAction:   types.BodyLimitActionProcessPartial,
@@ -347,6 +443,8 @@ func NewWAF() *WAF {
 		Logger:             logger,
 		ArgumentLimit:      1000,
 		RxPreFilterEnabled: defaultRxPreFilterEnabled,
+		CollectionTimeout:  int(persistence.DefaultTimeout / time.Second),
+		persistentStore:    store,
 	}
 
 	if environment.HasAccessToFS {
@@ -392,6 +490,15 @@ func (w *WAF) AuditLogWriter() plugintypes.AuditLogWriter {
 	return w.auditLogWriter
 }
 
+// ReopenAuditLog reopens the files of the audit log writer, e.g. after they
+// were moved by logrotate. Writers not supporting it are left untouched.
+func (w *WAF) ReopenAuditLog() error {
+	if r, ok := w.auditLogWriter.(plugintypes.AuditLogReopener); ok && w.auditLogWriterInitialized {
+		return r.Reopen()
+	}
+	return nil
+}
+
 // InitAuditLogWriter initializes the audit log writer. If the writer is already
 // initialized, it will return an error as initializing the audit log writer twice
 // seems to be a bug.
@@ -409,6 +516,23 @@ func (w *WAF) InitAuditLogWriter() error {
 	return nil
 }
 
+// SetPersistentStore sets the store backing the persistent collections. It
+// must be called before any transaction is created.
+func (w *WAF) SetPersistentStore(s plugintypes.PersistentStore) {
+	w.persistentStore = s
+}
+
+// PersistentStore returns the store backing the persistent collections,
+// initializing it on first use.
+func (w *WAF) PersistentStore() (plugintypes.PersistentStore, error) {
+	w.persistentStoreOnce.Do(func() {
+		cfg := persistence.NewConfig()
+		cfg.Timeout = time.Duration(w.CollectionTimeout) * time.Second
+		w.persistentStoreErr = w.persistentStore.Init(cfg)
+	})
+	return w.persistentStore, w.persistentStoreErr
+}
+
 // SetErrorCallback sets the callback function for error logging
 // The error callback receives all the error data and some
 // helpers to write modsecurity style logs
@@ -460,6 +584,18 @@ func (w *WAF) Validate() error {
 		return errors.New("request body json depth limit should be bigger than 0")
 	}
 
+	if w.XMLDepthLimit <= 0 {
+		return errors.New("xml depth limit should be bigger than 0")
+	}
+
+	if w.XMLNodeLimit <= 0 {
+		return errors.New("xml node limit should be bigger than 0")
+	}
+
+	if w.CollectionTimeout <= 0 {
+		return errors.New("collection timeout should be bigger than 0")
+	}
+
 	if environment.HasAccessToFS {
 		if w.UploadKeepFiles != types.UploadKeepFilesOff && w.UploadDir == "" {
 			return errors.New("SecUploadDir is required when SecUploadKeepFiles is enabled")
@@ -481,9 +617,78 @@ func (w *WAF) Memoizer() *memoize.Memoizer {
 // Close releases cached resources owned by this WAF instance.
 // Cached entries shared with other WAF instances remain until all owners release them.
 // Transactions already in-flight are unaffected as they hold their own references.
+// The persistent store and the audit log writer, flushing the entries it still
+// holds, are closed as well.
 func (w *WAF) Close() error {
+	var err error
 	w.closeOnce.Do(func() {
 		memoize.Release(w.memoizerID)
+		if w.persistentStore != nil && !w.persistentStoreInherited.Load() {
+			err = w.persistentStore.Close()
+		}
+		if w.auditLogWriterInitialized {
+			err = errors.Join(err, w.auditLogWriter.Close())
+		}
+		// the final counters of the writer are kept by the recorder
+		if w.unobserveAuditLogStats != nil {
+			w.unobserveAuditLogStats()
+		}
 	})
-	return nil
+	return err
+}
+
+// InheritState takes over the state kept by the WAF this one replaces, so it
+// survives a reload: the persistent collections when the persistence engine and
+// the collection timeout are unchanged, and the counters of the rate limit zones
+// whose configuration is unchanged. It must be called before any transaction is
+// created.
+func (w *WAF) InheritState(previous *WAF) {
+	if reflect.TypeOf(w.persistentStore) == reflect.TypeOf(previous.persistentStore) &&
+		w.CollectionTimeout == previous.CollectionTimeout {
+		if store, err := previous.PersistentStore(); err == nil {
+			w.persistentStoreOnce.Do(func() {})
+			w.persistentStore = store
+			previous.persistentStoreInherited.Store(true)
+		}
+	}
+
+	for name, zone := range w.RateLimitZones {
+		prev, ok := previous.RateLimitZones[name]
+		if !ok || prev.Limiter.Config() != zone.Limiter.Config() || macroString(prev.Key) != macroString(zone.Key) {
+			continue
+		}
+		// the zones are read-only, the limiter is shared by both WAFs
+		w.RateLimitZones[name] = prev
+	}
+}
+
+func macroString(m macro.Macro) string {
+	if m == nil {
+		return ""
+	}
+	return m.String()
+}
+
+// Retire closes the WAF once all its in-flight transactions are closed. It is
+// used when the WAF is replaced by a new instance: transactions created before
+// finish on this WAF and the cached resources are released afterwards.
+func (w *WAF) Retire() {
+	w.retired.Store(true)
+	if w.inFlight.Load() == 0 {
+		w.closeRetired()
+	}
+}
+
+// transactionClosed is called by Transaction.Close to close a retired WAF
+// after its last transaction.
+func (w *WAF) transactionClosed() {
+	if w.inFlight.Add(-1) == 0 && w.retired.Load() {
+		w.closeRetired()
+	}
+}
+
+func (w *WAF) closeRetired() {
+	if err := w.Close(); err != nil {
+		w.Logger.Error().Err(err).Msg("Failed to close retired WAF")
+	}
 }
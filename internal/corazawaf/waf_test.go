@@ -8,6 +8,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
 	"github.com/corazawaf/coraza/v3/internal/environment"
 	"github.com/corazawaf/coraza/v3/types"
 )
@@ -181,3 +182,48 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+type closeCountingStore struct {
+	plugintypes.PersistentStore
+	closed int
+}
+
+func (s *closeCountingStore) Close() error {
+	s.closed++
+	return nil
+}
+
+func TestRetire(t *testing.T) {
+	waf := NewWAF()
+	store := &closeCountingStore{}
+	waf.SetPersistentStore(store)
+
+	tx1 := waf.NewTransaction()
+	tx2 := waf.NewTransaction()
+	waf.Retire()
+	if store.closed != 0 {
+		t.Fatal("WAF closed with transactions in-flight")
+	}
+
+	if err := tx1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if store.closed != 0 {
+		t.Fatal("WAF closed with transactions in-flight")
+	}
+
+	if err := tx2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if store.closed != 1 {
+		t.Fatalf("expected WAF to be closed once, closed %d times", store.closed)
+	}
+
+	waf = NewWAF()
+	store = &closeCountingStore{}
+	waf.SetPersistentStore(store)
+	waf.Retire()
+	if store.closed != 1 {
+		t.Fatal("expected idle WAF to be closed right away")
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package mmdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Data types of the data section.
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEndMarker = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// maxDecodeDepth bounds the nesting of maps and arrays to protect against
+// malformed files.
+const maxDecodeDepth = 64
+
+var errUnexpectedEOF = errors.New("unexpected end of mmdb data")
+
+// decoder decodes values of the data section into Go values: map[string]any,
+// []any, string, []byte, float64, float32, bool, int32, uint16, uint32 and
+// uint64. uint128 values are returned as a 16 bytes []byte.
+type decoder struct {
+	buf []byte
+}
+
+func (d *decoder) decode(offset uint, depth int) (any, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, errors.New("mmdb data is nested too deeply")
+	}
+
+	typeNum, size, offset, err := d.controlByte(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typeNum == typePointer {
+		pointer, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(pointer, depth+1)
+		return v, next, err
+	}
+
+	return d.decodeFromType(typeNum, size, offset, depth)
+}
+
+// controlByte reads the type and payload size at offset and returns the
+// offset of the payload.
+func (d *decoder) controlByte(offset uint) (int, uint, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, 0, errUnexpectedEOF
+	}
+	ctrl := d.buf[offset]
+	offset++
+
+	typeNum := int(ctrl >> 5)
+	if typeNum == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return 0, 0, 0, errUnexpectedEOF
+		}
+		typeNum = int(d.buf[offset]) + 7
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if typeNum == typePointer || size < 29 {
+		return typeNum, size, offset, nil
+	}
+
+	n := size - 28
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, 0, errUnexpectedEOF
+	}
+	ext := uintFromBytes(d.buf[offset : offset+n])
+	offset += n
+	switch size {
+	case 29:
+		size = 29 + ext
+	case 30:
+		size = 285 + ext
+	default:
+		size = 65821 + ext
+	}
+	return typeNum, size, offset, nil
+}
+
+// pointer resolves a pointer whose control byte size bits are size. It
+// returns the target offset and the offset after the pointer.
+func (d *decoder) pointer(size uint, offset uint) (uint, uint, error) {
+	n := ((size >> 3) & 0x3) + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errUnexpectedEOF
+	}
+	b := d.buf[offset : offset+n]
+	var p uint
+	switch n {
+	case 1:
+		p = (size&0x7)<<8 | uint(b[0])
+	case 2:
+		p = ((size&0x7)<<16 | uintFromBytes(b)) + 2048
+	case 3:
+		p = ((size&0x7)<<24 | uintFromBytes(b)) + 526336
+	default:
+		p = uintFromBytes(b)
+	}
+	return p, offset + n, nil
+}
+
+func (d *decoder) decodeFromType(typeNum int, size uint, offset uint, depth int) (any, uint, error) {
+	switch typeNum {
+	case typeMap:
+		return d.decodeMap(size, offset, depth)
+	case typeArray:
+		return d.decodeArray(size, offset, depth)
+	case typeBool:
+		if size > 1 {
+			return nil, 0, fmt.Errorf("invalid mmdb boolean size %d", size)
+		}
+		return size == 1, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errUnexpectedEOF
+	}
+	b := d.buf[offset : offset+size]
+	next := offset + size
+
+	switch typeNum {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes:
+		return append([]byte(nil), b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid mmdb double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid mmdb float size %d", size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), next, nil
+	case typeUint16:
+		if size > 2 {
+			return nil, 0, fmt.Errorf("invalid mmdb uint16 size %d", size)
+		}
+		return uint16(uintFromBytes(b)), next, nil
+	case typeUint32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid mmdb uint32 size %d", size)
+		}
+		return uint32(uintFromBytes(b)), next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid mmdb int32 size %d", size)
+		}
+		return int32(uint32(uintFromBytes(b))), next, nil
+	case typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("invalid mmdb uint64 size %d", size)
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, next, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("invalid mmdb uint128 size %d", size)
+		}
+		v := make([]byte, 16)
+		copy(v[16-size:], b)
+		return v, next, nil
+	}
+
+	return nil, 0, fmt.Errorf("unsupported mmdb data type %d", typeNum)
+}
+
+func (d *decoder) decodeMap(size uint, offset uint, depth int) (any, uint, error) {
+	if size > uint(len(d.buf)) {
+		return nil, 0, errUnexpectedEOF
+	}
+	m := make(map[string]any, size)
+	for i := uint(0); i < size; i++ {
+		k, next, err := d.decode(offset, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, 0, errors.New("invalid mmdb map key")
+		}
+		v, next, err := d.decode(next, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		m[key] = v
+		offset = next
+	}
+	return m, offset, nil
+}
+
+func (d *decoder) decodeArray(size uint, offset uint, depth int) (any, uint, error) {
+	// Every element takes at least one byte, which bounds the allocation
+	// for malformed sizes.
+	if size > uint(len(d.buf)) {
+		return nil, 0, errUnexpectedEOF
+	}
+	a := make([]any, 0, size)
+	for i := uint(0); i < size; i++ {
+		v, next, err := d.decode(offset, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		a = append(a, v)
+		offset = next
+	}
+	return a, offset, nil
+}
+
+func uintFromBytes(b []byte) uint {
+	var v uint
+	for _, c := range b {
+		v = v<<8 | uint(c)
+	}
+	return v
+}
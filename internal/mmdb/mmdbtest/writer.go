@@ -0,0 +1,210 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package mmdbtest builds small MaxMind DB files to be used as test fixtures.
+package mmdbtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+)
+
+// Network maps a network in CIDR notation to a record. Records are
+// map[string]any trees of string, float64, uint16, uint32, uint64, bool,
+// map[string]any and []any values.
+type Network struct {
+	CIDR   string
+	Record map[string]any
+}
+
+type node struct {
+	children [2]*node
+	// data holds the index of the record for each child that is a leaf
+	data [2]int
+}
+
+func newNode() *node {
+	return &node{data: [2]int{-1, -1}}
+}
+
+// Build returns the content of an IPv6 .mmdb file with 24 bits records.
+// IPv4 networks are stored under ::/96.
+func Build(databaseType string, networks []Network) ([]byte, error) {
+	root := newNode()
+	for i, n := range networks {
+		_, ipnet, err := net.ParseCIDR(n.CIDR)
+		if err != nil {
+			return nil, err
+		}
+		ones, bits := ipnet.Mask.Size()
+		ip := ipnet.IP.To16()
+		if bits == 32 {
+			ip = append(make(net.IP, 12), ipnet.IP.To4()...)
+			ones += 96
+		}
+		cur := root
+		for b := 0; b < ones; b++ {
+			bit := (ip[b/8] >> (7 - uint(b%8))) & 1
+			if b == ones-1 {
+				cur.data[bit] = i
+				break
+			}
+			if cur.children[bit] == nil {
+				cur.children[bit] = newNode()
+			}
+			cur = cur.children[bit]
+		}
+	}
+
+	// Number the nodes breadth first
+	nodes := []*node{root}
+	ids := map[*node]int{root: 0}
+	for i := 0; i < len(nodes); i++ {
+		for _, c := range nodes[i].children {
+			if c != nil {
+				ids[c] = len(nodes)
+				nodes = append(nodes, c)
+			}
+		}
+	}
+	nodeCount := len(nodes)
+
+	// Data section
+	var data bytes.Buffer
+	offsets := make([]int, len(networks))
+	for i, n := range networks {
+		offsets[i] = data.Len()
+		if err := encode(&data, n.Record); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	for _, n := range nodes {
+		for bit := 0; bit < 2; bit++ {
+			v := nodeCount
+			switch {
+			case n.children[bit] != nil:
+				v = ids[n.children[bit]]
+			case n.data[bit] >= 0:
+				v = nodeCount + 16 + offsets[n.data[bit]]
+			}
+			out.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+
+	out.WriteString("\xAB\xCD\xEFMaxMind.com")
+	if err := encode(&out, map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"database_type":               databaseType,
+		"description":                 map[string]any{"en": "Coraza test database"},
+		"ip_version":                  uint16(6),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+	}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func writeControl(b *bytes.Buffer, typeNum int, size int) {
+	var ctrl byte
+	if typeNum <= 7 {
+		ctrl = byte(typeNum << 5)
+	}
+	switch {
+	case size < 29:
+		ctrl |= byte(size)
+	case size < 285:
+		ctrl |= 29
+	default:
+		ctrl |= 30
+	}
+	b.WriteByte(ctrl)
+	if typeNum > 7 {
+		b.WriteByte(byte(typeNum - 7))
+	}
+	switch {
+	case size < 29:
+	case size < 285:
+		b.WriteByte(byte(size - 29))
+	default:
+		s := size - 285
+		b.Write([]byte{byte(s >> 8), byte(s)})
+	}
+}
+
+func trimmedUint(v uint64, size int) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, v)
+	buf = buf[8-size:]
+	for len(buf) > 0 && buf[0] == 0 {
+		buf = buf[1:]
+	}
+	return buf
+}
+
+func encode(b *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case string:
+		writeControl(b, 2, len(v))
+		b.WriteString(v)
+	case float64:
+		writeControl(b, 3, 8)
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], math.Float64bits(v))
+		b.Write(buf[:])
+	case uint16:
+		buf := trimmedUint(uint64(v), 2)
+		writeControl(b, 5, len(buf))
+		b.Write(buf)
+	case uint32:
+		buf := trimmedUint(uint64(v), 4)
+		writeControl(b, 6, len(buf))
+		b.Write(buf)
+	case uint64:
+		buf := trimmedUint(v, 8)
+		writeControl(b, 9, len(buf))
+		b.Write(buf)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		writeControl(b, 14, size)
+	case map[string]any:
+		writeControl(b, 7, len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := encode(b, k); err != nil {
+				return err
+			}
+			if err := encode(b, v[k]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		writeControl(b, 11, len(v))
+		for _, e := range v {
+			if err := encode(b, e); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package mmdb implements a reader for the MaxMind DB file format used by
+// the MaxMind and DB-IP geolocation databases.
+// See https://maxmind.github.io/MaxMind-DB/ for the specification.
+package mmdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// metadataStartMarker precedes the metadata section at the end of the file.
+var metadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparatorSize is the size of the zeroed gap between the search
+// tree and the data section.
+const dataSectionSeparatorSize = 16
+
+// Metadata holds the fields of the database metadata used by the reader.
+type Metadata struct {
+	NodeCount    uint
+	RecordSize   uint
+	IPVersion    uint
+	DatabaseType string
+	BuildEpoch   uint64
+}
+
+// Reader performs lookups against an in-memory MaxMind DB. It is safe for
+// concurrent use.
+type Reader struct {
+	Metadata Metadata
+
+	tree      []byte
+	data      decoder
+	ipv4Start uint
+}
+
+// FromBytes creates a Reader from the content of a .mmdb file.
+func FromBytes(buf []byte) (*Reader, error) {
+	i := bytes.LastIndex(buf, metadataStartMarker)
+	if i == -1 {
+		return nil, errors.New("invalid mmdb file: metadata not found")
+	}
+
+	md := decoder{buf: buf[i+len(metadataStartMarker):]}
+	v, _, err := md.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mmdb metadata: %w", err)
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("invalid mmdb metadata: expected a map")
+	}
+
+	r := &Reader{}
+	r.Metadata.NodeCount = uintField(m, "node_count")
+	r.Metadata.RecordSize = uintField(m, "record_size")
+	r.Metadata.IPVersion = uintField(m, "ip_version")
+	r.Metadata.BuildEpoch = uint64(uintField(m, "build_epoch"))
+	r.Metadata.DatabaseType, _ = m["database_type"].(string)
+
+	switch r.Metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("invalid mmdb metadata: unsupported record size %d", r.Metadata.RecordSize)
+	}
+	if r.Metadata.IPVersion != 4 && r.Metadata.IPVersion != 6 {
+		return nil, fmt.Errorf("invalid mmdb metadata: unsupported ip version %d", r.Metadata.IPVersion)
+	}
+
+	treeSize := r.Metadata.NodeCount * r.Metadata.RecordSize / 4
+	if treeSize+dataSectionSeparatorSize > uint(i) {
+		return nil, errors.New("invalid mmdb file: search tree is bigger than the file")
+	}
+	r.tree = buf[:treeSize]
+	r.data = decoder{buf: buf[treeSize+dataSectionSeparatorSize : i]}
+
+	// IPv4 addresses are stored in IPv6 databases under ::/96
+	if r.Metadata.IPVersion == 6 {
+		node := uint(0)
+		for j := 0; j < 96 && node < r.Metadata.NodeCount; j++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+func uintField(m map[string]any, key string) uint {
+	switch v := m[key].(type) {
+	case uint64:
+		return uint(v)
+	case uint32:
+		return uint(v)
+	case uint16:
+		return uint(v)
+	}
+	return 0
+}
+
+// record returns the left (bit 0) or right (bit 1) record of a node.
+func (r *Reader) record(node uint, bit uint) uint {
+	switch r.Metadata.RecordSize {
+	case 24:
+		o := node*6 + bit*3
+		b := r.tree[o : o+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		o := node * 7
+		if bit == 0 {
+			b := r.tree[o : o+4]
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		b := r.tree[o+3 : o+7]
+		return uint(b[0]&0x0F)<<24 | uint(b[1])<<16 | uint(b[2])<<8 | uint(b[3])
+	default:
+		o := node*8 + bit*4
+		b := r.tree[o : o+4]
+		return uint(b[0])<<24 | uint(b[1])<<16 | uint(b[2])<<8 | uint(b[3])
+	}
+}
+
+// Lookup returns the decoded record for ip. The boolean is false when the
+// address is not in the database.
+func (r *Reader) Lookup(ip net.IP) (any, bool, error) {
+	node := uint(0)
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 32
+		if r.Metadata.IPVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.Metadata.IPVersion == 4 {
+		return nil, false, errors.New("cannot look up an IPv6 address in an IPv4-only database")
+	}
+	if ip == nil || len(ip)*8 != bits {
+		return nil, false, errors.New("invalid IP address")
+	}
+
+	nodeCount := r.Metadata.NodeCount
+	for i := 0; i < bits && node < nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-uint(i&7))) & 1
+		node = r.record(node, bit)
+	}
+
+	switch {
+	case node == nodeCount:
+		return nil, false, nil
+	case node < nodeCount+dataSectionSeparatorSize:
+		return nil, false, errors.New("invalid mmdb search tree")
+	}
+
+	offset := node - nodeCount - dataSectionSeparatorSize
+	v, _, err := r.data.decode(offset, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package mmdb_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/internal/mmdb"
+	"github.com/corazawaf/coraza/v3/internal/mmdb/mmdbtest"
+)
+
+func newTestReader(t *testing.T) *mmdb.Reader {
+	t.Helper()
+	buf, err := mmdbtest.Build("GeoLite2-City", []mmdbtest.Network{
+		{CIDR: "81.2.69.0/24", Record: map[string]any{
+			"country": map[string]any{
+				"iso_code": "GB",
+				"names":    map[string]any{"en": "United Kingdom"},
+			},
+			"location": map[string]any{"latitude": 51.5142, "longitude": -0.0931},
+			"flags":    []any{true, false},
+		}},
+		{CIDR: "2001:218::/32", Record: map[string]any{
+			"country":                  map[string]any{"iso_code": "JP"},
+			"autonomous_system_number": uint32(2914),
+			"big":                      uint64(1 << 40),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := mmdb.FromBytes(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestMetadata(t *testing.T) {
+	r := newTestReader(t)
+	if r.Metadata.DatabaseType != "GeoLite2-City" {
+		t.Errorf("unexpected database type %q", r.Metadata.DatabaseType)
+	}
+	if r.Metadata.IPVersion != 6 || r.Metadata.RecordSize != 24 {
+		t.Errorf("unexpected metadata %+v", r.Metadata)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	r := newTestReader(t)
+
+	tests := []struct {
+		ip      string
+		found   bool
+		country string
+	}{
+		{ip: "81.2.69.142", found: true, country: "GB"},
+		{ip: "81.2.69.0", found: true, country: "GB"},
+		{ip: "81.2.70.1", found: false},
+		{ip: "::ffff:81.2.69.10", found: true, country: "GB"},
+		{ip: "2001:218:1::1", found: true, country: "JP"},
+		{ip: "2001:219::1", found: false},
+		{ip: "127.0.0.1", found: false},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.ip, func(t *testing.T) {
+			v, ok, err := r.Lookup(net.ParseIP(tt.ip))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.found {
+				t.Fatalf("unexpected found, want %t, have %t", tt.found, ok)
+			}
+			if !ok {
+				return
+			}
+			country := v.(map[string]any)["country"].(map[string]any)
+			if have := country["iso_code"]; have != tt.country {
+				t.Errorf("unexpected country, want %q, have %q", tt.country, have)
+			}
+		})
+	}
+}
+
+func TestLookupDecodedTypes(t *testing.T) {
+	r := newTestReader(t)
+
+	v, _, err := r.Lookup(net.ParseIP("81.2.69.142"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := v.(map[string]any)
+	if lat := record["location"].(map[string]any)["latitude"]; lat != 51.5142 {
+		t.Errorf("unexpected latitude %v", lat)
+	}
+	if flags := record["flags"].([]any); len(flags) != 2 || flags[0] != true || flags[1] != false {
+		t.Errorf("unexpected flags %v", flags)
+	}
+
+	v, _, err = r.Lookup(net.ParseIP("2001:218::1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record = v.(map[string]any)
+	if asn := record["autonomous_system_number"]; asn != uint32(2914) {
+		t.Errorf("unexpected asn %v", asn)
+	}
+	if big := record["big"]; big != uint64(1<<40) {
+		t.Errorf("unexpected uint64 %v", big)
+	}
+}
+
+func TestLookupInvalidIP(t *testing.T) {
+	r := newTestReader(t)
+	if _, _, err := r.Lookup(nil); err == nil {
+		t.Error("expected error for nil IP")
+	}
+}
+
+func TestFromBytesInvalid(t *testing.T) {
+	buf, err := mmdbtest.Build("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]byte{
+		"empty":     nil,
+		"no marker": []byte("not a database"),
+		"truncated": buf[len(buf)-20:],
+	}
+	for name, b := range tests {
+		if _, err := mmdb.FromBytes(b); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.fuzzyHash
+
+package operators
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/ssdeep"
+)
+
+type fuzzyHashSignature struct {
+	digest ssdeep.Digest
+	// name is the file name of the ssdeep output, or the hash when missing
+	name string
+}
+
+type fuzzyHash struct {
+	signatures []fuzzyHashSignature
+	threshold  int
+}
+
+var _ plugintypes.Operator = (*fuzzyHash)(nil)
+
+// Description:
+// Performs ssdeep fuzzy hash matching, computing the context triggered piecewise hash of the input
+// and comparing it with the hashes loaded from a file. Matches when the similarity score with any
+// of them is at least the threshold, which makes it useful to detect variations of known malicious
+// files, like web shells, being uploaded. The file uses the format of the ssdeep tool output
+// (ssdeep -s), one hash per line optionally followed by the quoted file name. Lines starting with
+// # and the ssdeep header are ignored.
+//
+// Arguments:
+// File path containing the ssdeep hashes followed by the minimum similarity score, from 1 to 100.
+//
+// Returns:
+// true if the input is similar enough to any of the hashes, false otherwise. When capturing, the
+// file name (or hash) of the matched signature is stored in TX.0.
+//
+// Example:
+// ```
+// # Detect uploads of known web shells
+// SecRule FILES_TMP_CONTENT "@fuzzyHash webshells.txt 60" "id:180,phase:2,deny,log,capture,msg:'Web shell %{TX.0} uploaded'"
+// ```
+func newFuzzyHash(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	i := strings.LastIndexByte(options.Arguments, ' ')
+	if i == -1 {
+		return nil, errors.New("fuzzyHash: expected a file and a threshold")
+	}
+	filepath := strings.TrimSpace(options.Arguments[:i])
+	threshold, err := strconv.Atoi(options.Arguments[i+1:])
+	if err != nil || threshold < 1 || threshold > 100 {
+		return nil, fmt.Errorf("fuzzyHash: invalid threshold %q, expected a number from 1 to 100", options.Arguments[i+1:])
+	}
+
+	signatures, err := memoizeDo(options.Memoizer, "fuzzyHash:"+strings.Join(options.Path, ",")+filepath, func() (any, error) {
+		data, err := loadFromFile(filepath, options.Path, options.Root)
+		if err != nil {
+			return nil, err
+		}
+		return parseFuzzyHashSignatures(data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &fuzzyHash{signatures: signatures.([]fuzzyHashSignature), threshold: threshold}, nil
+}
+
+func parseFuzzyHashSignatures(data []byte) ([]fuzzyHashSignature, error) {
+	var signatures []fuzzyHashSignature
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		l := strings.TrimSpace(sc.Text())
+		if len(l) == 0 || l[0] == '#' || strings.HasPrefix(l, "ssdeep,") {
+			continue
+		}
+		d, err := ssdeep.Parse(l)
+		if err != nil {
+			return nil, fmt.Errorf("fuzzyHash: line %d: %s", n, err.Error())
+		}
+		name := d.String()
+		if _, file, ok := strings.Cut(l, ","); ok {
+			if f, err := strconv.Unquote(file); err == nil {
+				name = f
+			} else if file != "" {
+				name = file
+			}
+		}
+		signatures = append(signatures, fuzzyHashSignature{digest: d, name: name})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(signatures) == 0 {
+		return nil, errors.New("fuzzyHash: no hashes found")
+	}
+	return signatures, nil
+}
+
+func (o *fuzzyHash) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	d := ssdeep.Sum([]byte(value))
+	for _, s := range o.signatures {
+		if ssdeep.Compare(d, s.digest) < o.threshold {
+			continue
+		}
+		if tx != nil && tx.Capturing() {
+			tx.CaptureField(0, s.name)
+		}
+		return true
+	}
+	return false
+}
+
+func init() {
+	Register("fuzzyHash", newFuzzyHash)
+}
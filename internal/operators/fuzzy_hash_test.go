@@ -0,0 +1,99 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package operators
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/ssdeep"
+)
+
+func webShell(cmd string) string {
+	var sb strings.Builder
+	sb.WriteString("<?php\n")
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&sb, "$v%d = base64_decode($_POST['p%d'] . '%x');\n", i, i*7, i*i)
+	}
+	fmt.Fprintf(&sb, "%s($_GET['cmd']);\n?>\n", cmd)
+	return sb.String()
+}
+
+func TestFuzzyHash(t *testing.T) {
+	shell := webShell("system")
+	hashes := fmt.Sprintf("ssdeep,1.1--blocksize:hash:hash,filename\n# known web shells\n\n%s,\"/samples/shell.php\"\n%s\n",
+		ssdeep.Sum([]byte(shell)), ssdeep.Sum([]byte("unrelated")))
+	root := fstest.MapFS{"webshells.txt": {Data: []byte(hashes)}}
+
+	op, err := newFuzzyHash(plugintypes.OperatorOptions{
+		Arguments: "webshells.txt 60",
+		Path:      []string{"."},
+		Root:      root,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]bool{
+		shell:                   true,
+		webShell("passthru"):    true,
+		strings.Repeat("a", 10): false,
+		"":                      false,
+		webShell("system")[:2000] + strings.Repeat("lorem ipsum dolor sit amet ", 200): false,
+	}
+	for input, want := range tests {
+		if got := op.Evaluate(nil, input); got != want {
+			t.Errorf("unexpected result for input of %d bytes, want %t, have %t", len(input), want, got)
+		}
+	}
+
+	t.Run("capture", func(t *testing.T) {
+		tx := newTestTx()
+		tx.Capture = true
+		if !op.Evaluate(tx, webShell("exec")) {
+			t.Fatal("expected a match")
+		}
+		if v := tx.Variables().TX().Get("0"); len(v) != 1 || v[0] != "/samples/shell.php" {
+			t.Errorf("unexpected capture %q", v)
+		}
+	})
+
+	t.Run("memoized", func(t *testing.T) {
+		memoizer := &testMemoizer{cache: map[string]any{}}
+		for _, args := range []string{"webshells.txt 60", "webshells.txt 90"} {
+			if _, err := newFuzzyHash(plugintypes.OperatorOptions{Arguments: args, Path: []string{"."}, Root: root, Memoizer: memoizer}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(memoizer.cache) != 1 {
+			t.Errorf("expected the signatures to be loaded once, have %d entries", len(memoizer.cache))
+		}
+	})
+}
+
+func TestFuzzyHashErrors(t *testing.T) {
+	root := fstest.MapFS{
+		"empty.txt":   {Data: []byte("ssdeep,1.1--blocksize:hash:hash,filename\n# nothing\n")},
+		"invalid.txt": {Data: []byte("3:abc:def\nnot a hash\n")},
+		"valid.txt":   {Data: []byte("3:abc:def\n")},
+	}
+	for _, args := range []string{
+		"valid.txt",
+		"valid.txt 0",
+		"valid.txt 101",
+		"valid.txt abc",
+		"missing.txt 50",
+		"empty.txt 50",
+		"invalid.txt 50",
+	} {
+		t.Run(args, func(t *testing.T) {
+			if _, err := newFuzzyHash(plugintypes.OperatorOptions{Arguments: args, Path: []string{"."}, Root: root}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
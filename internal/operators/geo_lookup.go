@@ -6,30 +6,154 @@
 package operators
 
 import (
+	"net"
+	"strconv"
+
 	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/mmdb"
 )
 
+// geoLookupDBs is implemented by the transactions that expose the
+// geolocation databases configured with SecGeoLookupDb.
+type geoLookupDBs interface {
+	GeoLookupDBs() []*mmdb.Reader
+}
+
 // Description:
-// Performs geolocation lookup using the IP address in input against a configured database.
-// Sets GEO collection variables (GEO:COUNTRY_CODE, GEO:REGION, etc.) for use in subsequent rules.
-// Note: Currently returns unconditionalMatch (stub implementation) - requires geolocation database configuration.
+// Performs geolocation lookup using the IP address in input against the databases configured
+// with `SecGeoLookupDb`. On success, it populates the GEO collection with the COUNTRY_CODE,
+// COUNTRY_CODE3, COUNTRY_NAME, CONTINENT_CODE, REGION, CITY, POSTAL_CODE, LATITUDE, LONGITUDE
+// and ASN variables found for the address.
 //
 // Arguments:
 // None. Operates on REMOTE_ADDR or the target variable specified in the rule.
 //
 // Returns:
-// true (always matches, allowing subsequent rules to use GEO variables)
+// true if the address was found in any of the configured databases, false otherwise
 //
 // Example:
 // ```
+// SecGeoLookupDb /usr/share/GeoIP/GeoLite2-City.mmdb
+//
 // # Perform geolocation lookup and populate GEO variables
 // SecRule REMOTE_ADDR "@geoLookup" "phase:1,id:199,nolog,pass"
 //
 // # Block requests from specific countries
 // SecRule GEO:COUNTRY_CODE "@streq CN" "id:200,deny,log"
 // ```
+type geoLookup struct{}
+
+var _ plugintypes.Operator = (*geoLookup)(nil)
+
 func newGeoLookup(plugintypes.OperatorOptions) (plugintypes.Operator, error) {
-	return &unconditionalMatch{}, nil
+	return &geoLookup{}, nil
+}
+
+func (*geoLookup) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	txDBs, ok := tx.(geoLookupDBs)
+	if !ok || len(txDBs.GeoLookupDBs()) == 0 {
+		tx.DebugLogger().Warn().Msg("Geolocation lookup skipped, no database configured with SecGeoLookupDb")
+		return false
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		tx.DebugLogger().Debug().Str("value", value).Msg("Geolocation lookup skipped, invalid IP address")
+		return false
+	}
+
+	fields := map[string]string{}
+	found := false
+	for _, db := range txDBs.GeoLookupDBs() {
+		record, ok, err := db.Lookup(ip)
+		if err != nil {
+			tx.DebugLogger().Error().Err(err).Str("value", value).Msg("Geolocation lookup failed")
+			continue
+		}
+		if !ok {
+			continue
+		}
+		found = true
+		if m, ok := record.(map[string]any); ok {
+			addGeoFields(fields, m)
+		}
+	}
+	if !found {
+		tx.DebugLogger().Debug().Str("value", value).Msg("Geolocation lookup found no record")
+		return false
+	}
+
+	geo := tx.Variables().Geo()
+	for k, v := range fields {
+		geo.Set(k, []string{v})
+	}
+	return true
+}
+
+// addGeoFields adds the GEO variables found in a MaxMind or DB-IP record to
+// fields, keeping the fields already present.
+func addGeoFields(fields map[string]string, record map[string]any) {
+	add := func(key string, v any) {
+		if _, ok := fields[key]; ok {
+			return
+		}
+		if s := geoString(v); s != "" {
+			fields[key] = s
+		}
+	}
+
+	countryCode := geoString(lookupPath(record, "country", "iso_code"))
+	add("COUNTRY_CODE", countryCode)
+	add("COUNTRY_CODE3", countryCodes3[countryCode])
+	add("COUNTRY_NAME", lookupPath(record, "country", "names", "en"))
+	add("CONTINENT_CODE", lookupPath(record, "continent", "code"))
+	add("REGION", lookupPath(record, "subdivisions", 0, "iso_code"))
+	add("CITY", lookupPath(record, "city", "names", "en"))
+	add("POSTAL_CODE", lookupPath(record, "postal", "code"))
+	add("LATITUDE", lookupPath(record, "location", "latitude"))
+	add("LONGITUDE", lookupPath(record, "location", "longitude"))
+	add("ASN", record["autonomous_system_number"])
+}
+
+// lookupPath walks the record through map keys and array indexes.
+func lookupPath(v any, path ...any) any {
+	for _, p := range path {
+		switch k := p.(type) {
+		case string:
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil
+			}
+			v = m[k]
+		case int:
+			a, ok := v.([]any)
+			if !ok || k >= len(a) {
+				return nil
+			}
+			v = a[k]
+		}
+	}
+	return v
+}
+
+func geoString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return ""
 }
 
 func init() {
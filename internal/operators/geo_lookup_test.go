@@ -0,0 +1,130 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.geoLookup
+
+package operators
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/internal/mmdb"
+	"github.com/corazawaf/coraza/v3/internal/mmdb/mmdbtest"
+)
+
+func newTestGeoDB(t *testing.T, databaseType string, networks []mmdbtest.Network) *mmdb.Reader {
+	t.Helper()
+	buf, err := mmdbtest.Build(databaseType, networks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := mmdb.FromBytes(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestGeoLookup(t *testing.T) {
+	city := newTestGeoDB(t, "GeoLite2-City", []mmdbtest.Network{
+		{CIDR: "81.2.69.0/24", Record: map[string]any{
+			"city":      map[string]any{"names": map[string]any{"en": "London"}},
+			"continent": map[string]any{"code": "EU"},
+			"country": map[string]any{
+				"iso_code": "GB",
+				"names":    map[string]any{"en": "United Kingdom", "de": "Vereinigtes Königreich"},
+			},
+			"location":     map[string]any{"latitude": 51.5142, "longitude": -0.0931},
+			"postal":       map[string]any{"code": "EC2V"},
+			"subdivisions": []any{map[string]any{"iso_code": "ENG"}},
+		}},
+		{CIDR: "2001:218::/32", Record: map[string]any{
+			"country": map[string]any{"iso_code": "JP"},
+		}},
+	})
+	asn := newTestGeoDB(t, "GeoLite2-ASN", []mmdbtest.Network{
+		{CIDR: "81.2.0.0/16", Record: map[string]any{
+			"autonomous_system_number":       uint32(20712),
+			"autonomous_system_organization": "Andrews & Arnold Ltd",
+		}},
+	})
+
+	op, err := newGeoLookup(plugintypes.OperatorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waf := corazawaf.NewWAF()
+	waf.GeoLookupDBs = []*mmdb.Reader{city, asn}
+
+	t.Run("city and asn", func(t *testing.T) {
+		tx := waf.NewTransaction()
+		defer tx.Close()
+
+		if !op.Evaluate(tx, "81.2.69.142") {
+			t.Fatal("expected address to be found")
+		}
+		geo := tx.Variables().Geo()
+		expected := map[string]string{
+			"COUNTRY_CODE":   "GB",
+			"COUNTRY_CODE3":  "GBR",
+			"COUNTRY_NAME":   "United Kingdom",
+			"CONTINENT_CODE": "EU",
+			"REGION":         "ENG",
+			"CITY":           "London",
+			"POSTAL_CODE":    "EC2V",
+			"LATITUDE":       "51.5142",
+			"LONGITUDE":      "-0.0931",
+			"ASN":            "20712",
+		}
+		for k, want := range expected {
+			if have := geo.Get(k); len(have) != 1 || have[0] != want {
+				t.Errorf("unexpected GEO:%s, want %q, have %v", k, want, have)
+			}
+		}
+	})
+
+	t.Run("ipv6", func(t *testing.T) {
+		tx := waf.NewTransaction()
+		defer tx.Close()
+
+		if !op.Evaluate(tx, "2001:218::1") {
+			t.Fatal("expected address to be found")
+		}
+		geo := tx.Variables().Geo()
+		if have := geo.Get("country_code3"); len(have) != 1 || have[0] != "JPN" {
+			t.Errorf("unexpected GEO:COUNTRY_CODE3 %v", have)
+		}
+		if have := geo.Get("city"); len(have) != 0 {
+			t.Errorf("unexpected GEO:CITY %v", have)
+		}
+	})
+
+	for _, value := range []string{"10.0.0.1", "not an ip", ""} {
+		t.Run("not found "+value, func(t *testing.T) {
+			tx := waf.NewTransaction()
+			defer tx.Close()
+
+			if op.Evaluate(tx, value) {
+				t.Error("unexpected match")
+			}
+			if len(tx.Variables().Geo().FindAll()) != 0 {
+				t.Error("unexpected GEO variables")
+			}
+		})
+	}
+}
+
+func TestGeoLookupWithoutDatabase(t *testing.T) {
+	op, err := newGeoLookup(plugintypes.OperatorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := corazawaf.NewWAF().NewTransaction()
+	defer tx.Close()
+	if op.Evaluate(tx, "81.2.69.142") {
+		t.Error("unexpected match without database")
+	}
+}
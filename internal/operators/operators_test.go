@@ -48,9 +48,6 @@ func TestOperators(t *testing.T) {
 
 	notImplemented := []string{
 		"containsWord",
-		"verifyCC",
-		"verifycpf",
-		"verifyssn",
 		"verifysvnr",
 	}
 
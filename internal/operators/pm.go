@@ -6,6 +6,7 @@
 package operators
 
 import (
+	"fmt"
 	"strings"
 
 	ahocorasick "github.com/petar-dambovaliev/aho-corasick"
@@ -16,10 +17,14 @@ import (
 // Description:
 // Performs case-insensitive pattern matching using the Aho-Corasick algorithm for efficient
 // multi-pattern searching. Matches space-separated keywords or patterns provided as arguments.
+// @pmCaseSensitive performs the same matching without ignoring the case.
 //
 // Arguments:
-// Space-separated keywords or patterns to match. Supports Snort data syntax like "A|42|C|44|F"
-// for hex notation. All patterns are converted to lowercase for case-insensitive matching.
+// Space-separated keywords or patterns to match. Supports Snort content syntax like "A|42|C|44|F"
+// for hex notation: the bytes between pipes are written as hex pairs, optionally separated by
+// spaces, so "|0d 0a|" matches a CRLF. Spaces within a hex run don't split the patterns, and pipes
+// not enclosing a valid hex run are kept as literals. Literal text is converted to lowercase for
+// case-insensitive matching, hex bytes are kept as written.
 //
 // Returns:
 // true if any of the patterns are found in the input, false otherwise
@@ -31,6 +36,9 @@ import (
 //
 // # Match multiple attack patterns
 // SecRule ARGS "@pm <script> javascript: onerror=" "id:171,deny"
+//
+// # Match a binary signature, the MZ header of Windows executables
+// SecRule FILES_TMP_CONTENT "@pmCaseSensitive |4d 5a 90 00|" "id:176,deny"
 // ```
 type pm struct {
 	matcher ahocorasick.AhoCorasick
@@ -42,20 +50,118 @@ type pm struct {
 var _ plugintypes.Operator = (*pm)(nil)
 
 func newPM(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	return newPMFromArguments(options, false)
+}
+
+func newPMCaseSensitive(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	return newPMFromArguments(options, true)
+}
+
+func newPMFromArguments(options plugintypes.OperatorOptions, caseSensitive bool) (plugintypes.Operator, error) {
 	data := options.Arguments
+	dict := parsePMPatterns(data, true, caseSensitive)
+	return buildPM(options.Memoizer, "pm:"+data, dict, caseSensitive, true), nil
+}
 
-	data = strings.ToLower(data)
-	dict := strings.Split(data, " ")
+// buildPM returns a pm operator matching the patterns. The automaton is
+// memoized by key, the case sensitivity is appended to it.
+func buildPM(memoizer plugintypes.Memoizer, key string, patterns []string, caseSensitive bool, dfa bool) *pm {
 	builder := ahocorasick.NewAhoCorasickBuilder(ahocorasick.Opts{
-		AsciiCaseInsensitive: true,
+		AsciiCaseInsensitive: !caseSensitive,
 		MatchOnlyWholeWords:  false,
 		MatchKind:            ahocorasick.LeftMostLongestMatch,
-		DFA:                  true,
+		DFA:                  dfa,
 	})
 
-	m, _ := memoizeDo(options.Memoizer, data, func() (any, error) { return builder.Build(dict), nil })
-	// TODO this operator is supposed to support snort data syntax: "@pm A|42|C|44|F"
-	return &pm{matcher: m.(ahocorasick.AhoCorasick), minLen: minPatternLen(dict)}, nil
+	key = fmt.Sprintf("%s:%v", key, caseSensitive)
+	m, _ := memoizeDo(memoizer, key, func() (any, error) { return builder.Build(patterns), nil })
+	return &pm{matcher: m.(ahocorasick.AhoCorasick), minLen: minPatternLen(patterns)}
+}
+
+// parsePMPatterns parses the patterns of the pm operators, written in the
+// Snort content syntax where bytes can be given as hex between pipes:
+// "A|42|C|44|F" is "ABCDF". Pipes not enclosing a valid hex run are kept as
+// literals. When split is set, patterns are separated by the spaces outside
+// the hex runs. Unless caseSensitive is set, the literal text is lowercased.
+func parsePMPatterns(data string, split bool, caseSensitive bool) []string {
+	var (
+		patterns []string
+		pattern  strings.Builder
+		// start is the offset of the literal text not yet written
+		start int
+	)
+	writeLiteral := func(end int) {
+		literal := data[start:end]
+		if !caseSensitive {
+			literal = strings.ToLower(literal)
+		}
+		pattern.WriteString(literal)
+	}
+	for i := 0; i < len(data); {
+		switch {
+		case data[i] == '|':
+			if bts, n := pmHexRun(data[i:]); n > 0 {
+				writeLiteral(i)
+				pattern.Write(bts)
+				i += n
+				start = i
+				continue
+			}
+		case split && data[i] == ' ':
+			writeLiteral(i)
+			patterns = append(patterns, pattern.String())
+			pattern.Reset()
+			i++
+			start = i
+			continue
+		}
+		i++
+	}
+	writeLiteral(len(data))
+	return append(patterns, pattern.String())
+}
+
+// pmHexRun decodes the hex run at the start of s, like |0d 0a|. It returns
+// the decoded bytes and the length of the run including the pipes, 0 if s
+// doesn't start with a valid run.
+func pmHexRun(s string) ([]byte, int) {
+	end := strings.IndexByte(s[1:], '|') + 1
+	if end <= 1 {
+		return nil, 0
+	}
+	var bts []byte
+	for i := 1; i < end; {
+		if s[i] == ' ' {
+			i++
+			continue
+		}
+		if i+1 >= end {
+			return nil, 0
+		}
+		hi, ok1 := hexDigit(s[i])
+		lo, ok2 := hexDigit(s[i+1])
+		if !ok1 || !ok2 {
+			return nil, 0
+		}
+		bts = append(bts, hi<<4|lo)
+		i += 2
+	}
+	if len(bts) == 0 {
+		return nil, 0
+	}
+	return bts, end + 1
+}
+
+func hexDigit(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
 }
 
 func (o *pm) Evaluate(tx plugintypes.TransactionState, value string) bool {
@@ -110,4 +216,5 @@ func pmEvaluate(matcher ahocorasick.AhoCorasick, tx plugintypes.TransactionState
 
 func init() {
 	Register("pm", newPM)
+	Register("pmCaseSensitive", newPMCaseSensitive)
 }
@@ -8,15 +8,15 @@ package operators
 import (
 	"fmt"
 
-	ahocorasick "github.com/petar-dambovaliev/aho-corasick"
-
 	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
 )
 
 // Description:
 // Performs case-insensitive pattern matching like @pmFromFile but uses an in-memory dataset
 // instead of reading from a file. The dataset must be provided at WAF initialization time.
-// Uses the Aho-Corasick algorithm for efficient multi-pattern matching.
+// Uses the Aho-Corasick algorithm for efficient multi-pattern matching. Entries support the
+// Snort content syntax of @pm. @pmFromDatasetCaseSensitive performs the same matching without
+// ignoring the case.
 //
 // Arguments:
 // Name of the dataset to use for matching. The dataset must be pre-configured and available.
@@ -33,23 +33,28 @@ import (
 // SecRule REQUEST_HEADERS:User-Agent "@pmFromDataset bot_signatures" "id:175,deny"
 // ```
 func newPMFromDataset(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	return newPMFromDatasetWithCase(options, false)
+}
+
+func newPMFromDatasetCaseSensitive(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	return newPMFromDatasetWithCase(options, true)
+}
+
+func newPMFromDatasetWithCase(options plugintypes.OperatorOptions, caseSensitive bool) (plugintypes.Operator, error) {
 	data := options.Arguments
 	dataset, ok := options.Datasets[data]
 	if !ok {
 		return nil, fmt.Errorf("dataset %q not found", data)
 	}
-	builder := ahocorasick.NewAhoCorasickBuilder(ahocorasick.Opts{
-		AsciiCaseInsensitive: true,
-		MatchOnlyWholeWords:  false,
-		MatchKind:            ahocorasick.LeftMostLongestMatch,
-		DFA:                  true,
-	})
-
-	m, _ := memoizeDo(options.Memoizer, data, func() (any, error) { return builder.Build(dataset), nil })
+	patterns := make([]string, 0, len(dataset))
+	for _, entry := range dataset {
+		patterns = append(patterns, parsePMPatterns(entry, false, caseSensitive)...)
+	}
 
-	return &pm{matcher: m.(ahocorasick.AhoCorasick), minLen: minPatternLen(dataset)}, nil
+	return buildPM(options.Memoizer, "pmFromDataset:"+data, patterns, caseSensitive, true), nil
 }
 
 func init() {
 	Register("pmFromDataset", newPMFromDataset)
+	Register("pmFromDatasetCaseSensitive", newPMFromDatasetCaseSensitive)
 }
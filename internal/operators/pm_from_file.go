@@ -10,8 +10,6 @@ import (
 	"bytes"
 	"strings"
 
-	ahocorasick "github.com/petar-dambovaliev/aho-corasick"
-
 	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
 )
 
@@ -19,7 +17,9 @@ import (
 // Performs case-insensitive pattern matching like @pm but loads keywords from file(s).
 // Each line in the file represents one keyword. Lines starting with # are treated as comments
 // and empty lines are ignored. Uses the Aho-Corasick algorithm for efficient matching.
-// Also available as @pmf (shorthand alias).
+// Also available as @pmf (shorthand alias). Keywords support the Snort content syntax of @pm,
+// "|0d 0a|" being a CRLF. @pmFromFileCaseSensitive performs the same matching without ignoring
+// the case.
 //
 // Arguments:
 // File path(s) containing keywords, one per line. Multiple files can be specified space-separated.
@@ -36,6 +36,14 @@ import (
 // SecRule ARGS "@pmf badwords.txt sqli-patterns.txt" "id:173,deny"
 // ```
 func newPMFromFile(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	return newPMFromFileWithCase(options, false)
+}
+
+func newPMFromFileCaseSensitive(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	return newPMFromFileWithCase(options, true)
+}
+
+func newPMFromFileWithCase(options plugintypes.OperatorOptions, caseSensitive bool) (plugintypes.Operator, error) {
 	filepath := options.Arguments
 
 	data, err := loadFromFile(filepath, options.Path, options.Root)
@@ -54,22 +62,14 @@ func newPMFromFile(options plugintypes.OperatorOptions) (plugintypes.Operator, e
 		if l[0] == '#' {
 			continue
 		}
-		lines = append(lines, strings.ToLower(l))
+		lines = append(lines, parsePMPatterns(l, false, caseSensitive)...)
 	}
 
-	builder := ahocorasick.NewAhoCorasickBuilder(ahocorasick.Opts{
-		AsciiCaseInsensitive: true,
-		MatchOnlyWholeWords:  false,
-		MatchKind:            ahocorasick.LeftMostLongestMatch,
-		DFA:                  false,
-	})
-
-	m, _ := memoizeDo(options.Memoizer, strings.Join(options.Path, ",")+filepath, func() (any, error) { return builder.Build(lines), nil })
-
-	return &pm{matcher: m.(ahocorasick.AhoCorasick), minLen: minPatternLen(lines)}, nil
+	return buildPM(options.Memoizer, "pmFromFile:"+strings.Join(options.Path, ",")+filepath, lines, caseSensitive, false), nil
 }
 
 func init() {
 	Register("pmFromFile", newPMFromFile)
 	Register("pmf", newPMFromFile)
+	Register("pmFromFileCaseSensitive", newPMFromFileCaseSensitive)
 }
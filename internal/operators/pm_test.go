@@ -4,7 +4,9 @@
 package operators
 
 import (
+	"reflect"
 	"testing"
+	"testing/fstest"
 
 	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
 	"github.com/corazawaf/coraza/v3/internal/corazawaf"
@@ -32,6 +34,119 @@ func TestMinPatternLen(t *testing.T) {
 	}
 }
 
+func TestParsePMPatterns(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		split         bool
+		caseSensitive bool
+		want          []string
+	}{
+		{"literals", "Foo Bar", true, false, []string{"foo", "bar"}},
+		{"case sensitive", "Foo Bar", true, true, []string{"Foo", "Bar"}},
+		{"empty patterns are kept", "a  b", true, false, []string{"a", "", "b"}},
+		{"snort syntax", "A|42|C|44|F", true, true, []string{"ABCDF"}},
+		{"hex bytes are not lowercased", "A|42|c", true, false, []string{"aBc"}},
+		{"spaces within hex runs", "|0d 0a|x y", true, false, []string{"\r\nx", "y"}},
+		{"binary bytes", "|4d 5a 90 00|", true, false, []string{"MZ\x90\x00"}},
+		{"uppercase hex digits", "|FF|", true, false, []string{"\xff"}},
+		{"adjacent runs", "|41||42|", true, true, []string{"AB"}},
+		{"not split", "a |20| b", false, false, []string{"a   b"}},
+		{"pipes without hex", "string|int|float", true, false, []string{"string|int|float"}},
+		{"odd hex digits", "a|abc|", true, false, []string{"a|abc|"}},
+		{"empty run", "a||b", true, false, []string{"a||b"}},
+		{"unterminated run", "a|41", true, false, []string{"a|41"}},
+		{"literal pipe before a run", "a|b|41|", true, true, []string{"a|bA"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parsePMPatterns(tc.data, tc.split, tc.caseSensitive); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parsePMPatterns(%q) = %q, want %q", tc.data, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPmSnortAndCaseSensitive(t *testing.T) {
+	root := fstest.MapFS{
+		"signatures.dat": {Data: []byte("# binary signatures\n|4d 5a|\nEvil|2d|Bot\n")},
+	}
+	datasets := map[string][]string{"signatures": {"|4d 5a|", "Evil|2d|Bot"}}
+
+	tests := []struct {
+		operator string
+		args     string
+	}{
+		{"pm", "|4d 5a| Evil|2d|Bot"},
+		{"pmFromFile", "signatures.dat"},
+		{"pmFromDataset", "signatures"},
+	}
+	for _, tc := range tests {
+		for _, caseSensitive := range []bool{false, true} {
+			name := tc.operator
+			if caseSensitive {
+				name += "CaseSensitive"
+			}
+			t.Run(name, func(t *testing.T) {
+				op, err := Get(name, plugintypes.OperatorOptions{
+					Arguments: tc.args,
+					Path:      []string{"."},
+					Root:      root,
+					Datasets:  datasets,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				tx := newTestTx()
+				defer tx.Close()
+
+				if !op.Evaluate(tx, "file: MZ\x90\x00") {
+					t.Error("expected the hex signature to match")
+				}
+				if !op.Evaluate(tx, "ua: Evil-Bot/1.0") {
+					t.Error("expected the mixed signature to match")
+				}
+				if op.Evaluate(tx, "ua: Evil Bot") {
+					t.Error("unexpected match")
+				}
+				if have := op.Evaluate(tx, "ua: evil-bot"); have == caseSensitive {
+					t.Errorf("unexpected result %t for a different case", have)
+				}
+			})
+		}
+	}
+}
+
+func TestPmCaseSensitivityIsMemoized(t *testing.T) {
+	memoizer := &testMemoizer{cache: map[string]any{}}
+	insensitive, _ := newPM(plugintypes.OperatorOptions{Arguments: "Attack", Memoizer: memoizer})
+	sensitive, _ := newPMCaseSensitive(plugintypes.OperatorOptions{Arguments: "Attack", Memoizer: memoizer})
+	if len(memoizer.cache) != 2 {
+		t.Fatalf("expected an automaton per case sensitivity, have %d", len(memoizer.cache))
+	}
+
+	tx := newTestTx()
+	defer tx.Close()
+	if !insensitive.Evaluate(tx, "an attack") || sensitive.Evaluate(tx, "an attack") {
+		t.Error("unexpected shared automaton")
+	}
+}
+
+type testMemoizer struct {
+	cache map[string]any
+}
+
+func (m *testMemoizer) Do(key string, fn func() (any, error)) (any, error) {
+	if v, ok := m.cache[key]; ok {
+		return v, nil
+	}
+	v, err := fn()
+	if err == nil {
+		m.cache[key] = v
+	}
+	return v, err
+}
+
 func newTestTx() *corazawaf.Transaction {
 	return corazawaf.NewWAF().NewTransaction()
 }
@@ -0,0 +1,82 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.rateLimit
+
+package operators
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/ratelimit"
+)
+
+// rateLimitZones is implemented by the transactions that expose the rate
+// limit zones configured with SecRateLimitZone.
+type rateLimitZones interface {
+	RateLimitZone(name string) *ratelimit.Zone
+}
+
+// Description:
+// Records an event in a rate limit zone defined with `SecRateLimitZone` and matches when the
+// key is over the limit of the zone. The key is the expansion of the `key` option of the zone,
+// or the input value when the zone has no key. Every evaluation counts as one event, so the
+// operator is meant to be used with a single value variable like REMOTE_ADDR.
+// Events over the limit are not counted.
+//
+// Arguments:
+// Name of the rate limit zone.
+//
+// Returns:
+// true if the key exceeded the rate limit, false otherwise
+//
+// Example:
+// ```
+// SecRateLimitZone api key=%{REMOTE_ADDR} limit=100 period=1m
+//
+// # Answer 429 to clients sending more than 100 requests per minute
+// SecRule REMOTE_ADDR "@rateLimit api" "id:180,phase:1,deny,status:429,log"
+// ```
+type rateLimit struct {
+	zone string
+}
+
+var _ plugintypes.Operator = (*rateLimit)(nil)
+
+func newRateLimit(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	zone := strings.TrimSpace(options.Arguments)
+	if zone == "" {
+		return nil, errors.New("rate limit zone name is required")
+	}
+	return &rateLimit{zone: zone}, nil
+}
+
+func (o *rateLimit) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	var zone *ratelimit.Zone
+	if txZones, ok := tx.(rateLimitZones); ok {
+		zone = txZones.RateLimitZone(o.zone)
+	}
+	if zone == nil {
+		tx.DebugLogger().Error().Str("zone", o.zone).Msg("Rate limit zone is not defined with SecRateLimitZone")
+		return false
+	}
+
+	key := value
+	if zone.Key != nil {
+		key = zone.Key.Expand(tx)
+	}
+	if zone.Limiter.Allow(key) {
+		return false
+	}
+
+	if tx.Capturing() {
+		tx.CaptureField(0, key)
+	}
+	return true
+}
+
+func init() {
+	Register("rateLimit", newRateLimit)
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.rateLimit
+
+package operators
+
+import (
+	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/macro"
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/internal/ratelimit"
+)
+
+func newTestZone(t *testing.T, name string, key string, limit int) *ratelimit.Zone {
+	t.Helper()
+	l, err := ratelimit.NewLimiter(ratelimit.Config{Limit: limit, Period: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zone := &ratelimit.Zone{Name: name, Limiter: l}
+	if key != "" {
+		if zone.Key, err = macro.NewMacro(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return zone
+}
+
+func TestRateLimit(t *testing.T) {
+	if _, err := newRateLimit(plugintypes.OperatorOptions{Arguments: " "}); err == nil {
+		t.Error("expected error for empty zone name")
+	}
+
+	waf := corazawaf.NewWAF()
+	waf.RateLimitZones = map[string]*ratelimit.Zone{
+		"byip":    newTestZone(t, "byip", "%{REMOTE_ADDR}", 2),
+		"byvalue": newTestZone(t, "byvalue", "", 1),
+	}
+
+	op, err := newRateLimit(plugintypes.OperatorOptions{Arguments: "byIP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{false, false, true, true} {
+		tx := waf.NewTransaction()
+		tx.ProcessConnection("10.0.0.1", 1234, "", 0)
+		tx.Capture = true
+		if have := op.Evaluate(tx, "ignored"); have != want {
+			t.Errorf("request %d: want %t, have %t", i, want, have)
+		}
+		if want {
+			if v := tx.Variables().TX().Get("0"); len(v) != 1 || v[0] != "10.0.0.1" {
+				t.Errorf("unexpected capture %v", v)
+			}
+		}
+		tx.Close()
+	}
+
+	op, err = newRateLimit(plugintypes.OperatorOptions{Arguments: "byvalue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	if op.Evaluate(tx, "a") || op.Evaluate(tx, "b") {
+		t.Error("expected first event of every value to be allowed")
+	}
+	if !op.Evaluate(tx, "a") {
+		t.Error("expected second event of the value to match")
+	}
+
+	op, err = newRateLimit(plugintypes.OperatorOptions{Arguments: "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Evaluate(tx, "a") {
+		t.Error("unexpected match for unknown zone")
+	}
+}
@@ -22,6 +22,8 @@ import (
 // Performs regular expression pattern matching using RE2 syntax. This is the default operator
 // if no @ prefix is specified. Supports capturing groups (up to 9) for use in rule actions.
 // By default enables dotall mode (?s) where . matches newlines for compatibility with ModSecurity.
+// The inputs are truncated to SecInspectionByteLimit bytes, and the operator is no longer evaluated
+// once the SecOperatorTimeLimit budget of the transaction is exhausted.
 //
 // Arguments:
 // Regular expression pattern following RE2 syntax. The pattern is automatically wrapped with
@@ -62,6 +64,13 @@ type rxCompiled struct {
 
 var _ plugintypes.Operator = (*rx)(nil)
 
+// inspectionLimiter is implemented by the transactions limiting the inspection
+// of the values, see SecInspectionByteLimit and SecOperatorTimeLimit. It returns
+// the part of the value to inspect, or false when it must not be inspected.
+type inspectionLimiter interface {
+	LimitInspection(value string) (string, bool)
+}
+
 func newRX(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
 	var data string
 	if shouldNotUseMultilineRegexesOperatorByDefault {
@@ -129,6 +138,12 @@ func newRX(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
 }
 
 func (o *rx) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	if l, ok := tx.(inspectionLimiter); ok {
+		var inspect bool
+		if value, inspect = l.LimitInspection(value); !inspect {
+			return false
+		}
+	}
 	// Prefiltering evaluation is performed here, skipping regex evaluation for clearly non-matching inputs.
 	if len(value) < o.minLen {
 		return false
@@ -194,6 +209,12 @@ func newBinaryRX(options plugintypes.OperatorOptions) (plugintypes.Operator, err
 }
 
 func (o *binaryRX) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	if l, ok := tx.(inspectionLimiter); ok {
+		var inspect bool
+		if value, inspect = l.LimitInspection(value); !inspect {
+			return false
+		}
+	}
 	if tx.Capturing() {
 		match := o.re.FindStringSubmatch(value)
 		if len(match) == 0 {
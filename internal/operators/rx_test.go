@@ -155,6 +155,26 @@ func TestRx(t *testing.T) {
 	}
 }
 
+func TestRxInspectionByteLimit(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	waf.InspectionByteLimit = 4
+	tx := waf.NewTransaction()
+	defer tx.Close()
+
+	for _, expr := range []string{`^abcd$`, `\xff`} {
+		rx, err := newRX(plugintypes.OperatorOptions{Arguments: expr})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rx.Evaluate(tx, "abcd\xff") != (expr == `^abcd$`) {
+			t.Errorf("unexpected result for %q, the input must be truncated", expr)
+		}
+	}
+	if v := tx.Variables().TX().Get("msc_pcre_limits_exceeded"); len(v) != 1 || v[0] != "1" {
+		t.Errorf("unexpected TX:MSC_PCRE_LIMITS_EXCEEDED %q", v)
+	}
+}
+
 func TestMatchesArbitraryBytes(t *testing.T) {
 	tests := []struct {
 		name string
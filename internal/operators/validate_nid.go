@@ -120,41 +120,6 @@ func nidCl(nid string) bool {
 	return ndv == dv
 }
 
-var nonDigit = regexp.MustCompile(`[^\d]`)
-
-func nidUs(nid string) bool {
-	nid = nonDigit.ReplaceAllString(nid, "")
-	if len(nid) < 9 {
-		return false
-	}
-	area, _ := strconv.Atoi(nid[0:3])
-	group, _ := strconv.Atoi(nid[3:5])
-	serial, _ := strconv.Atoi(nid[5:9])
-	if area == 0 || group == 0 || serial == 0 || area >= 740 || area == 666 {
-		return false
-	}
-
-	sequence := true
-	equals := true
-	prev := digitToInt(nid[0])
-	for i := 1; i < len(nid); i++ {
-		curr := digitToInt(nid[i])
-		if prev != curr {
-			equals = false
-		}
-		if curr != prev+1 {
-			sequence = false
-		}
-		prev = curr
-	}
-
-	return !sequence && !equals
-}
-
-func digitToInt(d byte) int {
-	return int(d - '0')
-}
-
 var (
 	_ plugintypes.Operator = &validateNid{}
 	_ validateNidFunction  = nidCl
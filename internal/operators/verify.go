@@ -0,0 +1,169 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package operators
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+)
+
+// valueSanitizer is implemented by the transactions able to mask values in
+// their audit log.
+type valueSanitizer interface {
+	SanitizeValue(value string)
+}
+
+// verify is the base of the data leakage operators: the candidates found by
+// the expression are checked with the validation function of the operator.
+type verify struct {
+	re    *regexp.Regexp
+	valid func(digits string) bool
+	mask  bool
+}
+
+var _ plugintypes.Operator = (*verify)(nil)
+
+// newVerify parses the arguments of the verify operators, the expression of
+// the candidates optionally prefixed by the mask keyword.
+func newVerify(options plugintypes.OperatorOptions, valid func(digits string) bool) (plugintypes.Operator, error) {
+	expr := options.Arguments
+	mask := false
+	if rest, ok := strings.CutPrefix(expr, "mask "); ok {
+		expr, mask = strings.TrimLeft(rest, " "), true
+	}
+
+	re, err := memoizeDo(options.Memoizer, expr, func() (any, error) { return regexp.Compile(expr) })
+	if err != nil {
+		return nil, err
+	}
+	return &verify{re: re.(*regexp.Regexp), valid: valid, mask: mask}, nil
+}
+
+func (o *verify) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	capturing := tx != nil && tx.Capturing()
+	var sanitizer valueSanitizer
+	if o.mask {
+		sanitizer, _ = tx.(valueSanitizer)
+	}
+
+	found := 0
+	for _, m := range o.re.FindAllString(value, -1) {
+		// the delimiters matched around the number are left out
+		start, end := strings.IndexAny(m, "0123456789"), strings.LastIndexAny(m, "0123456789")
+		if start == -1 {
+			continue
+		}
+		candidate := m[start : end+1]
+		if !o.valid(digitsOf(candidate)) {
+			continue
+		}
+		if capturing && found < 10 {
+			tx.CaptureField(found, candidate)
+		}
+		found++
+		if sanitizer != nil {
+			sanitizer.SanitizeValue(candidate)
+		} else if !capturing {
+			break
+		}
+	}
+	return found > 0
+}
+
+// digitsOf returns the digits of s, dropping the separators.
+func digitsOf(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+// luhn returns true if the digits are a valid payment card number: 12 to 19
+// digits whose Luhn checksum is valid.
+func luhn(digits string) bool {
+	if len(digits) < 12 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := digitToInt(digits[len(digits)-1-i])
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// ssn returns true if the digits are a valid US social security number, see
+// nidUs.
+func ssn(digits string) bool {
+	return len(digits) == 9 && nidUs(digits)
+}
+
+// cpf returns true if the digits are a valid Brazilian CPF: 11 digits, not all
+// the same, whose last two are the check digits of the previous ones.
+func cpf(digits string) bool {
+	if len(digits) != 11 || strings.Count(digits, digits[:1]) == len(digits) {
+		return false
+	}
+	for n := 9; n <= 10; n++ {
+		sum := 0
+		for i := 0; i < n; i++ {
+			sum += digitToInt(digits[i]) * (n + 1 - i)
+		}
+		check := 11 - sum%11
+		if check >= 10 {
+			check = 0
+		}
+		if digitToInt(digits[n]) != check {
+			return false
+		}
+	}
+	return true
+}
+
+var nonDigit = regexp.MustCompile(`[^\d]`)
+
+func nidUs(nid string) bool {
+	nid = nonDigit.ReplaceAllString(nid, "")
+	if len(nid) < 9 {
+		return false
+	}
+	area, _ := strconv.Atoi(nid[0:3])
+	group, _ := strconv.Atoi(nid[3:5])
+	serial, _ := strconv.Atoi(nid[5:9])
+	if area == 0 || group == 0 || serial == 0 || area >= 740 || area == 666 {
+		return false
+	}
+
+	sequence := true
+	equals := true
+	prev := digitToInt(nid[0])
+	for i := 1; i < len(nid); i++ {
+		curr := digitToInt(nid[i])
+		if prev != curr {
+			equals = false
+		}
+		if curr != prev+1 {
+			sequence = false
+		}
+		prev = curr
+	}
+
+	return !sequence && !equals
+}
+
+func digitToInt(d byte) int {
+	return int(d - '0')
+}
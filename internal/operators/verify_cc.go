@@ -0,0 +1,40 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.verifyCC
+
+package operators
+
+import (
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+)
+
+// Description:
+// Detects payment card numbers in the input, typically to prevent them from leaking in the
+// response body. The candidates are found with a regular expression and validated with the
+// Luhn checksum, discarding the numbers which are not 12 to 19 digits long. The non-digit
+// characters matched around the number, like delimiters, are left out and separators like
+// spaces or dashes within the number are ignored. The valid numbers are captured in TX.0 to TX.9.
+//
+// Arguments:
+// Regular expression matching the card number candidates, optionally prefixed by the mask
+// keyword to mask the numbers found in the audit log of the transaction.
+//
+// Returns:
+// true if a valid card number is found in the input, false otherwise
+//
+// Example:
+// ```
+// # Block responses leaking card numbers
+// SecRule RESPONSE_BODY "@verifyCC (?:^|[^\d])(\d{4}\-?\d{4}\-?\d{2}\-?\d{2}\-?\d{1,4})(?:[^\d]|$)" "id:199,phase:4,deny,log,capture,msg:'Credit card number detected'"
+//
+// # Log them without writing the numbers to the audit log
+// SecRule RESPONSE_BODY "@verifyCC mask (?:^|[^\d])(\d{13,16})(?:[^\d]|$)" "id:200,phase:4,pass,log"
+// ```
+func newVerifyCC(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	return newVerify(options, luhn)
+}
+
+func init() {
+	Register("verifyCC", newVerifyCC)
+}
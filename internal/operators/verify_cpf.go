@@ -0,0 +1,37 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.verifyCPF
+
+package operators
+
+import (
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+)
+
+// Description:
+// Detects Brazilian CPF numbers (Cadastro de Pessoas Físicas) in the input, typically to prevent
+// them from leaking in the response body. The candidates are found with a regular expression and
+// must have 11 digits, not all the same, the last two being the check digits of the others.
+// The non-digit characters matched around the number are left out and separators like the dots
+// and dash of the 000.000.000-00 format are ignored. The valid numbers are captured in TX.0 to
+// TX.9.
+//
+// Arguments:
+// Regular expression matching the CPF candidates, optionally prefixed by the mask keyword to
+// mask the numbers found in the audit log of the transaction.
+//
+// Returns:
+// true if a valid CPF is found in the input, false otherwise
+//
+// Example:
+// ```
+// SecRule RESPONSE_BODY "@verifyCPF mask ([0-9]{3}\.){2}[0-9]{3}-[0-9]{2}" "id:202,phase:4,deny,log,msg:'CPF number detected'"
+// ```
+func newVerifyCPF(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	return newVerify(options, cpf)
+}
+
+func init() {
+	Register("verifyCPF", newVerifyCPF)
+}
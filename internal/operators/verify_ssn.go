@@ -0,0 +1,37 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.verifySSN
+
+package operators
+
+import (
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+)
+
+// Description:
+// Detects US social security numbers in the input, typically to prevent them from leaking in
+// the response body. The candidates are found with a regular expression and must have 9 digits
+// forming a valid SSN: the area, group and serial numbers can't be zero, the area can't be 666
+// or 740 and above, and numbers made of a single digit or of sequential digits are discarded.
+// The non-digit characters matched around the number are left out and separators within the
+// number are ignored. The valid numbers are captured in TX.0 to TX.9.
+//
+// Arguments:
+// Regular expression matching the SSN candidates, optionally prefixed by the mask keyword to
+// mask the numbers found in the audit log of the transaction.
+//
+// Returns:
+// true if a valid SSN is found in the input, false otherwise
+//
+// Example:
+// ```
+// SecRule RESPONSE_BODY "@verifySSN mask \b(\d{3}-?\d{2}-?\d{4})\b" "id:201,phase:4,deny,log,msg:'Social security number detected'"
+// ```
+func newVerifySSN(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	return newVerify(options, ssn)
+}
+
+func init() {
+	Register("verifySSN", newVerifySSN)
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package operators
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+)
+
+// TestVerifyOperators runs the secrules-language-tests cases of the verify
+// operators, whose names are lowercased in some of the files.
+func TestVerifyOperators(t *testing.T) {
+	ops := map[string]string{
+		"verifyCC.json":  "verifyCC",
+		"verifyssn.json": "verifySSN",
+		"verifycpf.json": "verifyCPF",
+	}
+	for file, name := range ops {
+		data, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tc := range unmarshalTests(t, data) {
+			op, err := Get(name, plugintypes.OperatorOptions{Arguments: tc.Param})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, capturing := range []bool{true, false} {
+				tx := newTestTx()
+				tx.Capture = capturing
+				if got := op.Evaluate(tx, tc.Input); got != (tc.Ret == 1) {
+					t.Errorf("unexpected result for @%s(%q, %q), want %d", name, tc.Param, tc.Input, tc.Ret)
+				}
+			}
+		}
+	}
+}
+
+func TestVerifyCapture(t *testing.T) {
+	op, err := newVerifyCC(plugintypes.OperatorOptions{Arguments: `(?:^|[^\d])(\d{4}\-?\d{4}\-?\d{2}\-?\d{2}\-?\d{1,4})(?:[^\d]|$)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := newTestTx()
+	tx.Capture = true
+	if !op.Evaluate(tx, "cards: 4417123456789112, 5484-6050-8915-8216 and 4408041234567893.") {
+		t.Fatal("expected a match")
+	}
+	txVars := tx.Variables().TX()
+	if v := txVars.Get("0"); len(v) != 1 || v[0] != "5484-6050-8915-8216" {
+		t.Errorf("unexpected TX.0 %q", v)
+	}
+	if v := txVars.Get("1"); len(v) != 1 || v[0] != "4408041234567893" {
+		t.Errorf("unexpected TX.1 %q", v)
+	}
+}
+
+type testValueSanitizer struct {
+	plugintypes.TransactionState
+	values []string
+}
+
+func (s *testValueSanitizer) SanitizeValue(value string) {
+	s.values = append(s.values, value)
+}
+
+func TestVerifyMask(t *testing.T) {
+	input := "ssn 574-57-8065, invalid 123-45-6789, other 574578066"
+	tests := map[string][]string{
+		`\d{3}-?\d{2}-?\d{4}`:          nil,
+		`mask \d{3}-?\d{2}-?\d{4}`:     {"574-57-8065", "574578066"},
+		`mask   \b\d{3}-?\d{2}-?\d{4}`: {"574-57-8065", "574578066"},
+	}
+	for args, want := range tests {
+		t.Run(args, func(t *testing.T) {
+			op, err := newVerifySSN(plugintypes.OperatorOptions{Arguments: args})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx := &testValueSanitizer{TransactionState: newTestTx()}
+			if !op.Evaluate(tx, input) {
+				t.Fatal("expected a match")
+			}
+			if len(tx.values) != len(want) {
+				t.Fatalf("unexpected masked values %q", tx.values)
+			}
+			for i := range want {
+				if tx.values[i] != want[i] {
+					t.Errorf("unexpected masked values %q", tx.values)
+				}
+			}
+		})
+	}
+}
+
+func TestLuhn(t *testing.T) {
+	tests := map[string]bool{
+		"4417123456789113":     true,
+		"4417123456789112":     false,
+		"0":                    false,
+		"00000000000":          false,
+		"000000000000":         true,
+		"4024007182237":        true,
+		"6011000990139424":     true,
+		"6011000990139424000":  false,
+		"60110009901394240000": false,
+	}
+	for digits, want := range tests {
+		if got := luhn(digits); got != want {
+			t.Errorf("unexpected result for %q, want %t", digits, want)
+		}
+	}
+}
+
+func TestCPF(t *testing.T) {
+	tests := map[string]bool{
+		"01081751460":  true,
+		"52998224725":  true,
+		"52998224724":  false,
+		"11111111111":  false,
+		"5299822472":   false,
+		"529982247250": false,
+	}
+	for digits, want := range tests {
+		if got := cpf(digits); got != want {
+			t.Errorf("unexpected result for %q, want %t", digits, want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+)
+
+// maxSweepInterval bounds how long expired records may stay in memory
+// when the collection timeout is long.
+const maxSweepInterval = time.Minute
+
+type memoryVariable struct {
+	value string
+	// expires is zero when the variable lives as long as its record
+	expires time.Time
+}
+
+type memoryRecord struct {
+	expires   time.Time
+	variables map[string]memoryVariable
+}
+
+// memoryStore is the default persistent store. Records are kept in memory
+// and lost when the WAF is closed. Expired records are evicted lazily on
+// access and by a sweep that runs as part of the write operations, so no
+// background goroutine is needed.
+type memoryStore struct {
+	mu            sync.Mutex
+	timeout       time.Duration
+	sweepInterval time.Duration
+	lastSweep     time.Time
+	records       map[string]*memoryRecord
+	// now is overridden in tests
+	now func() time.Time
+}
+
+func (s *memoryStore) Init(cfg plugintypes.PersistentStoreConfig) error {
+	if cfg.Timeout <= 0 {
+		return errors.New("collection timeout should be bigger than 0")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timeout = cfg.Timeout
+	s.sweepInterval = min(cfg.Timeout, maxSweepInterval)
+	s.records = map[string]*memoryRecord{}
+	if s.now == nil {
+		s.now = time.Now
+	}
+	s.lastSweep = s.now()
+	return nil
+}
+
+func recordID(collection, key string) string {
+	return collection + "\x00" + key
+}
+
+// record returns the live record for collection and key. When create is true
+// a missing record is created, and the record timeout is renewed.
+func (s *memoryStore) record(collection, key string, now time.Time, create bool) *memoryRecord {
+	id := recordID(collection, key)
+	r, ok := s.records[id]
+	if ok && !now.Before(r.expires) {
+		delete(s.records, id)
+		r, ok = nil, false
+	}
+	if !create {
+		return r
+	}
+	if !ok {
+		r = &memoryRecord{variables: map[string]memoryVariable{}}
+		s.records[id] = r
+	}
+	r.expires = now.Add(s.timeout)
+	return r
+}
+
+func (s *memoryStore) maybeSweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for id, r := range s.records {
+		if !now.Before(r.expires) {
+			delete(s.records, id)
+			continue
+		}
+		for name, v := range r.variables {
+			if v.expired(now) {
+				delete(r.variables, name)
+			}
+		}
+	}
+}
+
+func (v memoryVariable) expired(now time.Time) bool {
+	return !v.expires.IsZero() && !now.Before(v.expires)
+}
+
+func (s *memoryStore) Get(collection, key, variable string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	r := s.record(collection, key, now, false)
+	if r == nil {
+		return "", false, nil
+	}
+	v, ok := r.variables[variable]
+	if !ok || v.expired(now) {
+		return "", false, nil
+	}
+	return v.value, true, nil
+}
+
+func (s *memoryStore) All(collection, key string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	res := map[string]string{}
+	r := s.record(collection, key, now, false)
+	if r == nil {
+		return res, nil
+	}
+	for name, v := range r.variables {
+		if !v.expired(now) {
+			res[name] = v.value
+		}
+	}
+	return res, nil
+}
+
+func (s *memoryStore) Set(collection, key, variable, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.maybeSweep(now)
+	r := s.record(collection, key, now, true)
+	// Setting a value keeps a previous expiration, as expirevar is meant to be
+	// used together with setvar.
+	v := r.variables[variable]
+	if v.expired(now) {
+		v.expires = time.Time{}
+	}
+	v.value = value
+	r.variables[variable] = v
+	return nil
+}
+
+func (s *memoryStore) Sum(collection, key, variable string, delta int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.maybeSweep(now)
+	r := s.record(collection, key, now, true)
+	v := r.variables[variable]
+	if v.expired(now) {
+		v = memoryVariable{}
+	}
+	// Non numeric values are overwritten, same as a missing variable.
+	current, _ := strconv.Atoi(v.value)
+	current += delta
+	v.value = strconv.Itoa(current)
+	r.variables[variable] = v
+	return current, nil
+}
+
+func (s *memoryStore) Remove(collection, key, variable string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.maybeSweep(now)
+	if r := s.record(collection, key, now, false); r != nil {
+		delete(r.variables, variable)
+		r.expires = now.Add(s.timeout)
+	}
+	return nil
+}
+
+func (s *memoryStore) SetTTL(collection, key, variable string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.maybeSweep(now)
+	r := s.record(collection, key, now, false)
+	if r == nil {
+		return nil
+	}
+	v, ok := r.variables[variable]
+	if !ok || v.expired(now) {
+		return nil
+	}
+	v.expires = now.Add(ttl)
+	r.variables[variable] = v
+	return nil
+}
+
+func (s *memoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = map[string]*memoryRecord{}
+	return nil
+}
+
+var _ plugintypes.PersistentStore = (*memoryStore)(nil)
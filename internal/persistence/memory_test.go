@@ -0,0 +1,167 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestStore(t *testing.T, timeout time.Duration) (*memoryStore, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	s := &memoryStore{now: clock.now}
+	if err := s.Init(plugintypes.PersistentStoreConfig{Timeout: timeout}); err != nil {
+		t.Fatal(err)
+	}
+	return s, clock
+}
+
+func TestMemoryStoreInit(t *testing.T) {
+	s := &memoryStore{}
+	if err := s.Init(plugintypes.PersistentStoreConfig{}); err == nil {
+		t.Error("expected error for empty timeout")
+	}
+}
+
+func TestMemoryStoreGetSet(t *testing.T) {
+	s, _ := newTestStore(t, time.Minute)
+
+	if _, ok, _ := s.Get("IP", "1.2.3.4", "foo"); ok {
+		t.Fatal("unexpected value in empty store")
+	}
+	if err := s.Set("IP", "1.2.3.4", "foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, _ := s.Get("IP", "1.2.3.4", "foo"); !ok || v != "bar" {
+		t.Errorf("unexpected value %q", v)
+	}
+	if _, ok, _ := s.Get("IP", "5.6.7.8", "foo"); ok {
+		t.Error("records must be isolated by key")
+	}
+	if _, ok, _ := s.Get("SESSION", "1.2.3.4", "foo"); ok {
+		t.Error("records must be isolated by collection")
+	}
+
+	if err := s.Remove("IP", "1.2.3.4", "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := s.Get("IP", "1.2.3.4", "foo"); ok {
+		t.Error("expected variable to be removed")
+	}
+}
+
+func TestMemoryStoreSum(t *testing.T) {
+	s, _ := newTestStore(t, time.Minute)
+
+	for i := 1; i <= 3; i++ {
+		n, err := s.Sum("IP", "k", "counter", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != i {
+			t.Errorf("unexpected sum, want %d, have %d", i, n)
+		}
+	}
+	if n, _ := s.Sum("IP", "k", "counter", -5); n != -2 {
+		t.Errorf("unexpected sum, want -2, have %d", n)
+	}
+	_ = s.Set("IP", "k", "text", "abc")
+	if n, _ := s.Sum("IP", "k", "text", 2); n != 2 {
+		t.Errorf("non numeric values should count as zero, have %d", n)
+	}
+}
+
+func TestMemoryStoreRecordTimeout(t *testing.T) {
+	s, clock := newTestStore(t, time.Minute)
+
+	_ = s.Set("IP", "k", "foo", "bar")
+	clock.advance(50 * time.Second)
+	// Updating the record renews its timeout
+	_ = s.Set("IP", "k", "other", "1")
+	clock.advance(50 * time.Second)
+	if _, ok, _ := s.Get("IP", "k", "foo"); !ok {
+		t.Fatal("record expired before its timeout")
+	}
+	clock.advance(11 * time.Second)
+	if all, _ := s.All("IP", "k"); len(all) != 0 {
+		t.Errorf("expected record to be expired, have %v", all)
+	}
+}
+
+func TestMemoryStoreVariableTTL(t *testing.T) {
+	s, clock := newTestStore(t, time.Hour)
+
+	_ = s.Set("IP", "k", "blocked", "1")
+	_ = s.Set("IP", "k", "foo", "bar")
+	if err := s.SetTTL("IP", "k", "blocked", 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// Setting the value again keeps the expiration
+	_ = s.Set("IP", "k", "blocked", "2")
+	clock.advance(10 * time.Second)
+
+	all, _ := s.All("IP", "k")
+	if _, ok := all["blocked"]; ok {
+		t.Error("expected variable to be expired")
+	}
+	if all["foo"] != "bar" {
+		t.Error("expected other variables to be kept")
+	}
+	if n, _ := s.Sum("IP", "k", "blocked", 1); n != 1 {
+		t.Errorf("expired variables should restart from zero, have %d", n)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	s, clock := newTestStore(t, 30*time.Second)
+
+	_ = s.Set("IP", "a", "foo", "bar")
+	clock.advance(31 * time.Second)
+	_ = s.Set("IP", "b", "foo", "bar")
+	s.mu.Lock()
+	n := len(s.records)
+	s.mu.Unlock()
+	if n != 1 {
+		t.Errorf("expected expired records to be swept, have %d records", n)
+	}
+}
+
+func TestMemoryStoreConcurrentSum(t *testing.T) {
+	s, _ := newTestStore(t, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = s.Sum("GLOBAL", "global", "hits", 1)
+		}()
+	}
+	wg.Wait()
+
+	if v, _, _ := s.Get("GLOBAL", "global", "hits"); v != "50" {
+		t.Errorf("unexpected counter value %q", v)
+	}
+}
+
+func TestGetStore(t *testing.T) {
+	if _, err := GetStore("MEMORY"); err != nil {
+		t.Error(err)
+	}
+	if _, err := GetStore("unknown"); err == nil {
+		t.Error("expected error for unknown store")
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package persistence contains the stores backing the persistent
+// collections (IP, SESSION, USER, GLOBAL and RESOURCE).
+package persistence
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+)
+
+// DefaultTimeout is the default lifetime of a record since its last update,
+// as defined by SecCollectionTimeout.
+const DefaultTimeout = 3600 * time.Second
+
+var stores = map[string]func() plugintypes.PersistentStore{}
+
+// RegisterStore registers a new persistent store
+// it can be used for plugins
+func RegisterStore(name string, store func() plugintypes.PersistentStore) {
+	stores[strings.ToLower(name)] = store
+}
+
+// GetStore returns a persistent store by name
+// It returns an error if it doesn't exist
+func GetStore(name string) (plugintypes.PersistentStore, error) {
+	store := stores[strings.ToLower(name)]
+	if store == nil {
+		return nil, fmt.Errorf("invalid persistent store %q", name)
+	}
+	return store(), nil
+}
+
+// NewConfig returns a PersistentStoreConfig with default values.
+func NewConfig() plugintypes.PersistentStoreConfig {
+	return plugintypes.PersistentStoreConfig{
+		Timeout: DefaultTimeout,
+	}
+}
+
+func init() {
+	RegisterStore("memory", func() plugintypes.PersistentStore {
+		return &memoryStore{}
+	})
+}
@@ -0,0 +1,203 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package ratelimit implements in-memory keyed rate limiters used by the
+// SecRateLimitZone directive and the @rateLimit operator.
+package ratelimit
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/macro"
+)
+
+// Algorithm is the algorithm used by a limiter to count events.
+type Algorithm int
+
+const (
+	// SlidingWindow allows Limit events in any window of Period. It
+	// approximates the window with the counters of the current and previous
+	// fixed windows, weighting the previous one by its overlap.
+	SlidingWindow Algorithm = iota
+	// TokenBucket allows bursts of up to Burst events, refilled at a rate of
+	// Limit events per Period.
+	TokenBucket
+)
+
+// ParseAlgorithm parses the name of an algorithm: sliding_window or token_bucket.
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch strings.ToLower(name) {
+	case "sliding_window":
+		return SlidingWindow, nil
+	case "token_bucket":
+		return TokenBucket, nil
+	}
+	return 0, fmt.Errorf("invalid rate limit algorithm %q", name)
+}
+
+// DefaultMaxKeys is the default number of keys tracked by a limiter.
+const DefaultMaxKeys = 10000
+
+// Config is the configuration of a Limiter.
+type Config struct {
+	Algorithm Algorithm
+	// Limit is the number of events allowed per Period
+	Limit  int
+	Period time.Duration
+	// Burst is the capacity of the token bucket, defaults to Limit.
+	// It is ignored by the sliding window algorithm.
+	Burst int
+	// MaxKeys bounds the memory used by the limiter. When it is reached the
+	// least recently used key is evicted.
+	MaxKeys int
+}
+
+type bucket struct {
+	key string
+
+	// sliding window counters
+	windowStart time.Time
+	current     int
+	previous    int
+
+	// token bucket state
+	tokens     float64
+	lastRefill time.Time
+}
+
+// Limiter counts events per key. It is safe for concurrent use.
+type Limiter struct {
+	cfg Config
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	// lru holds the buckets, the most recently used first
+	lru *list.List
+
+	now func() time.Time
+}
+
+// NewLimiter creates a Limiter.
+func NewLimiter(cfg Config) (*Limiter, error) {
+	if cfg.Limit <= 0 {
+		return nil, errors.New("rate limit should be bigger than 0")
+	}
+	if cfg.Period <= 0 {
+		return nil, errors.New("rate limit period should be bigger than 0")
+	}
+	if cfg.Burst < 0 {
+		return nil, errors.New("rate limit burst should not be negative")
+	}
+	if cfg.Burst == 0 {
+		cfg.Burst = cfg.Limit
+	}
+	if cfg.MaxKeys < 0 {
+		return nil, errors.New("rate limit max keys should not be negative")
+	}
+	if cfg.MaxKeys == 0 {
+		cfg.MaxKeys = DefaultMaxKeys
+	}
+	return &Limiter{
+		cfg:     cfg,
+		buckets: make(map[string]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}, nil
+}
+
+// Config returns the configuration of the limiter.
+func (l *Limiter) Config() Config {
+	return l.cfg
+}
+
+// Allow records an event for key and returns false if it exceeds the
+// limit. Rejected events are not counted, so a key is allowed again as soon
+// as its rate drops below the limit.
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b := l.bucket(key, now)
+	if l.cfg.Algorithm == TokenBucket {
+		return l.takeToken(b, now)
+	}
+	return l.countInWindow(b, now)
+}
+
+// Len returns the number of keys tracked by the limiter.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lru.Len()
+}
+
+// bucket returns the bucket for key, creating it and evicting the least
+// recently used bucket if needed.
+func (l *Limiter) bucket(key string, now time.Time) *bucket {
+	if e, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(e)
+		return e.Value.(*bucket)
+	}
+
+	if l.lru.Len() >= l.cfg.MaxKeys {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.buckets, oldest.Value.(*bucket).key)
+	}
+
+	b := &bucket{
+		key:         key,
+		windowStart: now.Truncate(l.cfg.Period),
+		tokens:      float64(l.cfg.Burst),
+		lastRefill:  now,
+	}
+	l.buckets[key] = l.lru.PushFront(b)
+	return b
+}
+
+func (l *Limiter) countInWindow(b *bucket, now time.Time) bool {
+	period := l.cfg.Period
+	start := now.Truncate(period)
+	switch elapsed := start.Sub(b.windowStart); {
+	case elapsed >= 2*period:
+		b.previous, b.current = 0, 0
+	case elapsed >= period:
+		b.previous, b.current = b.current, 0
+	}
+	b.windowStart = start
+
+	overlap := 1 - float64(now.Sub(start))/float64(period)
+	if float64(b.previous)*overlap+float64(b.current) >= float64(l.cfg.Limit) {
+		return false
+	}
+	b.current++
+	return true
+}
+
+func (l *Limiter) takeToken(b *bucket, now time.Time) bool {
+	if elapsed := now.Sub(b.lastRefill); elapsed > 0 {
+		rate := float64(l.cfg.Limit) / float64(l.cfg.Period)
+		b.tokens = min(float64(l.cfg.Burst), b.tokens+float64(elapsed)*rate)
+		b.lastRefill = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Zone is a named limiter defined with SecRateLimitZone.
+type Zone struct {
+	Name string
+	// Key is expanded for every transaction to select the bucket. When it is
+	// nil the value evaluated by the operator is used as the key.
+	Key     macro.Macro
+	Limiter *Limiter
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package ratelimit
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(t *testing.T, cfg Config) (*Limiter, *fakeClock) {
+	t.Helper()
+	l, err := NewLimiter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Start at the beginning of a minute, windows are aligned to the period
+	clock := &fakeClock{t: time.Unix(1699999980, 0)}
+	l.now = clock.now
+	return l, clock
+}
+
+func allowed(l *Limiter, key string, n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		if l.Allow(key) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestNewLimiter(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"no limit":        {Period: time.Second},
+		"no period":       {Limit: 1},
+		"negative burst":  {Limit: 1, Period: time.Second, Burst: -1},
+		"negative keys":   {Limit: 1, Period: time.Second, MaxKeys: -1},
+		"negative period": {Limit: 1, Period: -time.Second},
+	} {
+		if _, err := NewLimiter(cfg); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	l, err := NewLimiter(Config{Limit: 5, Period: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg := l.Config(); cfg.Burst != 5 || cfg.MaxKeys != DefaultMaxKeys {
+		t.Errorf("unexpected defaults %+v", cfg)
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	if a, err := ParseAlgorithm("Token_Bucket"); err != nil || a != TokenBucket {
+		t.Errorf("unexpected algorithm %v, %v", a, err)
+	}
+	if a, err := ParseAlgorithm("sliding_window"); err != nil || a != SlidingWindow {
+		t.Errorf("unexpected algorithm %v, %v", a, err)
+	}
+	if _, err := ParseAlgorithm("leaky"); err == nil {
+		t.Error("expected error for unknown algorithm")
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	l, clock := newTestLimiter(t, Config{Limit: 10, Period: time.Minute})
+
+	if n := allowed(l, "a", 15); n != 10 {
+		t.Errorf("expected 10 allowed events, have %d", n)
+	}
+	if n := allowed(l, "b", 3); n != 3 {
+		t.Errorf("keys must be counted separately, have %d", n)
+	}
+
+	// Half way through the next window half of the previous window counts
+	clock.advance(time.Minute + 30*time.Second)
+	if n := allowed(l, "a", 10); n != 5 {
+		t.Errorf("expected 5 allowed events, have %d", n)
+	}
+
+	// Two windows later the counters are reset
+	clock.advance(2 * time.Minute)
+	if n := allowed(l, "a", 15); n != 10 {
+		t.Errorf("expected 10 allowed events, have %d", n)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	l, clock := newTestLimiter(t, Config{Algorithm: TokenBucket, Limit: 10, Period: 10 * time.Second, Burst: 5})
+
+	if n := allowed(l, "a", 10); n != 5 {
+		t.Errorf("expected a burst of 5 events, have %d", n)
+	}
+
+	clock.advance(3 * time.Second)
+	if n := allowed(l, "a", 10); n != 3 {
+		t.Errorf("expected 3 refilled tokens, have %d", n)
+	}
+
+	// The bucket does not grow over the burst
+	clock.advance(time.Hour)
+	if n := allowed(l, "a", 10); n != 5 {
+		t.Errorf("expected a burst of 5 events, have %d", n)
+	}
+}
+
+func TestMaxKeys(t *testing.T) {
+	l, _ := newTestLimiter(t, Config{Limit: 1, Period: time.Minute, MaxKeys: 3})
+
+	for _, k := range []string{"a", "b", "c"} {
+		l.Allow(k)
+	}
+	// Using a makes b the least recently used key
+	l.Allow("a")
+	l.Allow("d")
+
+	if n := l.Len(); n != 3 {
+		t.Errorf("expected 3 keys, have %d", n)
+	}
+	if !l.Allow("b") {
+		t.Error("expected b to be evicted")
+	}
+	if l.Allow("a") {
+		t.Error("expected a to be kept")
+	}
+}
+
+func TestConcurrentAllow(t *testing.T) {
+	l, err := NewLimiter(Config{Limit: 100, Period: time.Hour, MaxKeys: 21})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg    sync.WaitGroup
+		count atomic.Int32
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if l.Allow("shared") {
+					count.Add(1)
+				}
+				l.Allow(strconv.Itoa(i))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := count.Load(); n != 100 {
+		t.Errorf("expected 100 allowed events, have %d", n)
+	}
+	if n := l.Len(); n != 21 {
+		t.Errorf("expected 21 keys, have %d", n)
+	}
+}
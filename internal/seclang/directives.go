@@ -9,17 +9,24 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/corazawaf/coraza/v3/debuglog"
+	"github.com/corazawaf/coraza/v3/experimental/plugins/macro"
 	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
 	"github.com/corazawaf/coraza/v3/internal/auditlog"
 	"github.com/corazawaf/coraza/v3/internal/corazawaf"
 	"github.com/corazawaf/coraza/v3/internal/environment"
+	"github.com/corazawaf/coraza/v3/internal/mmdb"
+	"github.com/corazawaf/coraza/v3/internal/persistence"
+	"github.com/corazawaf/coraza/v3/internal/ratelimit"
 	utils "github.com/corazawaf/coraza/v3/internal/strings"
 	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
 )
 
 // DirectiveOptions contains the parsed options for a directive. It is mutable and propagated
@@ -35,6 +42,10 @@ type DirectiveOptions struct {
 	// Parser is configuration of the parser, populated by multiple directives and consumed by
 	// directives that parse.
 	Parser ParserConfig
+
+	// Scope is the <VirtualHost> or <Location> block being parsed, nil outside of them. The
+	// directives allowed inside the blocks configure it instead of the WAF.
+	Scope *corazawaf.Scope
 }
 
 type directive = func(options *DirectiveOptions) error
@@ -235,6 +246,10 @@ func directiveSecResponseBodyAccess(options *DirectiveOptions) error {
 	if err != nil {
 		return err
 	}
+	if options.Scope != nil {
+		options.Scope.ResponseBodyAccess = &b
+		return nil
+	}
 	options.WAF.ResponseBodyAccess = b
 	return nil
 }
@@ -256,6 +271,13 @@ func directiveSecRequestBodyLimit(options *DirectiveOptions) error {
 	if err != nil {
 		return err
 	}
+	if options.Scope != nil {
+		if limit <= 0 {
+			return errors.New("request body limit should be bigger than 0")
+		}
+		options.Scope.RequestBodyLimit = &limit
+		return nil
+	}
 	options.WAF.RequestBodyLimit = limit
 	return nil
 }
@@ -278,6 +300,10 @@ func directiveSecRequestBodyAccess(options *DirectiveOptions) error {
 	if err != nil {
 		return err
 	}
+	if options.Scope != nil {
+		options.Scope.RequestBodyAccess = &b
+		return nil
+	}
 	options.WAF.RequestBodyAccess = b
 	return nil
 }
@@ -286,7 +312,8 @@ func directiveSecRequestBodyAccess(options *DirectiveOptions) error {
 // Default: 1024
 // Syntax: SecRequestBodyJsonDepthLimit [LIMIT]
 // ---
-// Anything over the limit will generate a REQBODY_ERROR in the JSON body processor.
+// Anything over the limit will generate a REQBODY_ERROR in the JSON body processor,
+// the rest of the body is not read.
 func directiveSecRequestBodyJsonDepthLimit(options *DirectiveOptions) error {
 	if len(options.Opts) == 0 {
 		return errEmptyOptions
@@ -305,6 +332,55 @@ func directiveSecRequestBodyJsonDepthLimit(options *DirectiveOptions) error {
 	return nil
 }
 
+// Description: Configures the maximum nesting level of elements accepted in XML bodies.
+// Default: 256
+// Syntax: SecXmlDepthLimit [LIMIT]
+// ---
+// Documents nested deeper than the limit are rejected by the XML body processor,
+// generating a REQBODY_ERROR or a RESBODY_ERROR.
+func directiveSecXmlDepthLimit(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	limit, err := strconv.Atoi(options.Opts)
+	if err != nil {
+		return err
+	}
+
+	if limit <= 0 {
+		return errors.New("limit must be a positive integer")
+	}
+
+	options.WAF.XMLDepthLimit = limit
+	return nil
+}
+
+// Description: Configures the maximum number of nodes accepted in XML bodies.
+// Default: 100000
+// Syntax: SecXmlNodeLimit [LIMIT]
+// ---
+// Elements, attributes, text, comments and processing instructions count as nodes.
+// Documents with more nodes than the limit are rejected by the XML body processor,
+// generating a REQBODY_ERROR or a RESBODY_ERROR.
+func directiveSecXmlNodeLimit(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	limit, err := strconv.Atoi(options.Opts)
+	if err != nil {
+		return err
+	}
+
+	if limit <= 0 {
+		return errors.New("limit must be a positive integer")
+	}
+
+	options.WAF.XMLNodeLimit = limit
+	return nil
+}
+
 // Description: Configures the rules engine.
 // Syntax: SecRuleEngine On|Off|DetectionOnly
 // Default: Off
@@ -314,12 +390,55 @@ func directiveSecRequestBodyJsonDepthLimit(options *DirectiveOptions) error {
 // - Off: do not process rules
 // - DetectionOnly: process rules but never executes any disruptive actions
 // (block, deny, drop, allow, proxy and redirect)
+//
+// Inside a `<VirtualHost>` or `<Location>` block it sets the mode of the requests to the
+// block only.
 func directiveSecRuleEngine(options *DirectiveOptions) error {
 	engine, err := types.ParseRuleEngineStatus(options.Opts)
+	if options.Scope != nil {
+		if err != nil {
+			return err
+		}
+		options.Scope.RuleEngine = &engine
+		return nil
+	}
 	options.WAF.RuleEngine = engine
 	return err
 }
 
+// Description: Sets the performance threshold of the rules, in microseconds.
+// Syntax: SecRulePerfTime [USECS]
+// Default: 0
+// ---
+// When set, the time spent evaluating every rule, including its transformations, operator,
+// actions and chained rules, is measured. The rules whose evaluation takes at least the
+// threshold are added to the PERF_RULES collection, logged to the debug log and listed in the
+// part H of the audit log as `Rules-Performance-Info: "id=usec", ...`, or in the producer
+// section of the JSON audit logs. 0 disables the measurement of the rules, the time spent in
+// every phase is always available in the PERF_PHASE1 to PERF_PHASE5, PERF_COMBINED and
+// PERF_ALL variables.
+//
+// Example:
+// ```apache
+// SecRulePerfTime 1000
+// SecRule PERF_RULES "@gt 10000" "phase:5,id:99,log,pass,msg:'Slow rule %{MATCHED_VAR_NAME}: %{MATCHED_VAR}us'"
+// ```
+func directiveSecRulePerfTime(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	usecs, err := strconv.ParseInt(options.Opts, 10, 64)
+	if err != nil {
+		return err
+	}
+	if usecs < 0 {
+		return errors.New("rule performance time should be a positive number")
+	}
+	options.WAF.RulePerfTime = time.Duration(usecs) * time.Microsecond
+	return nil
+}
+
 func directiveUnsupported(options *DirectiveOptions) error {
 	return nil
 }
@@ -329,6 +448,11 @@ func directiveSecWebAppID(options *DirectiveOptions) error {
 		return errEmptyOptions
 	}
 
+	if options.Scope != nil {
+		id := options.Opts
+		options.Scope.WebAppID = &id
+		return nil
+	}
 	options.WAF.WebAppID = options.Opts
 	return nil
 }
@@ -367,6 +491,10 @@ func directiveSecRuleRemoveByTag(options *DirectiveOptions) error {
 		return errEmptyOptions
 	}
 
+	if options.Scope != nil {
+		options.Scope.RemoveRuleByTag(options.Opts)
+		return nil
+	}
 	options.WAF.Rules.DeleteByTag(options.Opts)
 	return nil
 }
@@ -394,6 +522,10 @@ func directiveSecRuleRemoveByMsg(options *DirectiveOptions) error {
 		return errEmptyOptions
 	}
 
+	if options.Scope != nil {
+		options.Scope.RemoveRuleByMsg(options.Opts)
+		return nil
+	}
 	options.WAF.Rules.DeleteByMsg(options.Opts)
 	return nil
 }
@@ -415,6 +547,10 @@ func directiveSecRuleRemoveByMsg(options *DirectiveOptions) error {
 // SecRule REQUEST_URI "@rx attack" "id:1000,phase:1,log,deny"
 // SecRuleRemoveById 1000
 // ```
+//
+// Inside a `<VirtualHost>` or `<Location>` block the rules are skipped for the requests to
+// the block instead of being removed, the same applies to `SecRuleRemoveByTag` and
+// `SecRuleRemoveByMsg`. Those removals also cover the rules loaded after the block.
 func directiveSecRuleRemoveByID(options *DirectiveOptions) error {
 	if len(options.Opts) == 0 {
 		return errEmptyOptions
@@ -428,6 +564,10 @@ func directiveSecRuleRemoveByID(options *DirectiveOptions) error {
 				return err
 			}
 
+			if options.Scope != nil {
+				options.Scope.RemoveRuleByID(id)
+				continue
+			}
 			options.WAF.Rules.DeleteByID(id)
 		} else {
 			if idx == 0 {
@@ -447,6 +587,10 @@ func directiveSecRuleRemoveByID(options *DirectiveOptions) error {
 				return fmt.Errorf("invalid range: %s", idOrRange)
 			}
 
+			if options.Scope != nil {
+				options.Scope.RemoveRuleByIDRange(start, end)
+				continue
+			}
 			options.WAF.Rules.DeleteByRange(start, end)
 		}
 	}
@@ -534,6 +678,13 @@ func directiveSecResponseBodyLimit(options *DirectiveOptions) error {
 	if err != nil {
 		return err
 	}
+	if options.Scope != nil {
+		if limit <= 0 {
+			return errors.New("response body limit should be bigger than 0")
+		}
+		options.Scope.ResponseBodyLimit = &limit
+		return nil
+	}
 	options.WAF.ResponseBodyLimit = limit
 	return nil
 }
@@ -616,11 +767,94 @@ func directiveSecConnReadStateLimit(options *DirectiveOptions) error {
 	return nil
 }
 
+// Description: Accepted for compatibility with ModSecurity, it has no effect.
+// Syntax: SecPcreMatchLimitRecursion [LIMIT]
+// Default: 0
+// ---
+// RE2, the regular expression engine of Coraza, doesn't use recursion. The inspection is
+// limited by SecInspectionByteLimit and SecOperatorTimeLimit instead.
 func directiveSecPcreMatchLimitRecursion(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	if _, err := strconv.ParseUint(options.Opts, 10, 64); err != nil {
+		return err
+	}
 	return nil
 }
 
+// Description: Accepted for compatibility with ModSecurity, it has no effect.
+// Syntax: SecPcreMatchLimit [LIMIT]
+// Default: 0
+// ---
+// RE2, the regular expression engine of Coraza, runs in linear time and has no match steps to
+// limit. The inspection is limited by SecInspectionByteLimit and SecOperatorTimeLimit instead.
 func directiveSecPcreMatchLimit(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	if _, err := strconv.ParseUint(options.Opts, 10, 64); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Description: Sets the maximum number of bytes of a variable inspected by a rule.
+// Syntax: SecInspectionByteLimit [LIMIT]
+// Default: 0
+// ---
+// The values longer than the limit are truncated before the transformations and the operator,
+// and the transformed values are truncated again before @rx. When it happens,
+// TX:MSC_PCRE_LIMITS_EXCEEDED is set to 1 so the rules can react. The limit accepts the K, M
+// and G units. 0 disables the limit.
+//
+// Example:
+// ```apache
+// SecInspectionByteLimit 128K
+// SecRule TX:MSC_PCRE_LIMITS_EXCEEDED "@eq 1" "id:200005,phase:2,t:none,log,deny,msg:'Inspection limits exceeded'"
+// ```
+func directiveSecInspectionByteLimit(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	limit, err := parseSize(options.Opts)
+	if err != nil {
+		return err
+	}
+	options.WAF.InspectionByteLimit = int(limit)
+	return nil
+}
+
+// Description: Sets the time the operators can spend evaluating a transaction, in milliseconds.
+// Syntax: SecOperatorTimeLimit [MSECS]
+// Default: 0
+// ---
+// Once the operators of the rules spent the limit evaluating a transaction, @rx is no longer
+// evaluated for the rest of the transaction and does not match, even when negated. The
+// TX:MSC_PCRE_LIMITS_EXCEEDED and TX:MSC_OPERATOR_TIME_LIMIT_EXCEEDED variables are set to 1
+// so the rules can react. The other operators are still evaluated. 0 disables the limit.
+//
+// Example:
+// ```apache
+// SecOperatorTimeLimit 50
+// SecRule TX:MSC_OPERATOR_TIME_LIMIT_EXCEEDED "@eq 1" "id:200006,phase:2,t:none,log,deny,msg:'Operator time limit exceeded'"
+// ```
+func directiveSecOperatorTimeLimit(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	msecs, err := strconv.ParseInt(options.Opts, 10, 64)
+	if err != nil {
+		return err
+	}
+	if msecs < 0 {
+		return errors.New("operator time limit should be a positive number")
+	}
+	options.WAF.OperatorTimeLimit = time.Duration(msecs) * time.Millisecond
 	return nil
 }
 
@@ -628,6 +862,141 @@ func directiveSecHTTPBlKey(options *DirectiveOptions) error {
 	return nil
 }
 
+// Description: Defines the path to the geolocation database used by the `@geoLookup` operator.
+// Syntax: SecGeoLookupDb [PATH_TO_DATABASE]
+// ---
+// Databases must use the MaxMind DB format (`.mmdb`), as the MaxMind GeoIP2/GeoLite2 and the
+// DB-IP databases. Relative paths are resolved from the directory of the configuration file and
+// then from the working directory.
+//
+// The directive can be used more than once to combine databases, for example a City database
+// and an ASN database. Databases are queried in the order they are declared and the first
+// database providing a field wins.
+//
+// Example:
+// ```apache
+// SecGeoLookupDb /usr/share/GeoIP/GeoLite2-City.mmdb
+// SecGeoLookupDb /usr/share/GeoIP/GeoLite2-ASN.mmdb
+// ```
+func directiveSecGeoLookupDb(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	p := strings.Trim(options.Opts, `"`)
+	dirs := []string{""}
+	if !path.IsAbs(p) {
+		dirs = []string{options.Parser.ConfigDir}
+		if wd := options.Parser.WorkingDir; wd != "" {
+			dirs = append(dirs, wd)
+		}
+	}
+
+	var (
+		content []byte
+		err     error
+	)
+	for _, dir := range dirs {
+		content, err = fs.ReadFile(options.Parser.Root, path.Join(dir, p))
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read geolocation database: %w", err)
+	}
+
+	db, err := mmdb.FromBytes(content)
+	if err != nil {
+		return fmt.Errorf("failed to load geolocation database %q: %w", p, err)
+	}
+	options.WAF.GeoLookupDBs = append(options.WAF.GeoLookupDBs, db)
+	return nil
+}
+
+// Description: Defines a rate limit zone used by the `@rateLimit` operator.
+// Syntax: SecRateLimitZone [NAME] [OPTIONS]
+// ---
+// A zone keeps a counter, or bucket, for every key. The options are space separated `name=value` pairs:
+//
+// - `limit`: number of events allowed per period. Required.
+// - `period`: duration of the period, in seconds or as a duration like `1m`. Required.
+// - `key`: macro expanded for every transaction to select the bucket, for example `%{REMOTE_ADDR}`.
+// When it is not set, the value evaluated by `@rateLimit` is used as the key.
+// - `algorithm`: `sliding_window` (default) or `token_bucket`.
+// - `burst`: size of the token bucket, defaults to the limit.
+// - `max_keys`: maximum number of keys kept in memory, the least recently used key is evicted
+// when the limit is reached. Defaults to 10000.
+//
+// Zones live in memory and are shared by all the transactions of the WAF instance.
+//
+// Example:
+// ```apache
+// SecRateLimitZone login key=%{REMOTE_ADDR} limit=5 period=1m
+// SecRule REQUEST_FILENAME "@streq /login" "id:10,phase:1,chain,deny,status:429"
+// SecRule REMOTE_ADDR "@rateLimit login"
+// ```
+func directiveSecRateLimitZone(options *DirectiveOptions) error {
+	fields := strings.Fields(options.Opts)
+	if len(fields) == 0 {
+		return errEmptyOptions
+	}
+
+	name := strings.ToLower(fields[0])
+	if _, ok := options.WAF.RateLimitZones[name]; ok {
+		return fmt.Errorf("rate limit zone %q already defined", fields[0])
+	}
+
+	zone := &ratelimit.Zone{Name: name}
+	var cfg ratelimit.Config
+	for _, field := range fields[1:] {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok || v == "" {
+			return fmt.Errorf("invalid rate limit zone option %q", field)
+		}
+		var err error
+		switch strings.ToLower(k) {
+		case "key":
+			zone.Key, err = macro.NewMacro(v)
+		case "limit":
+			cfg.Limit, err = strconv.Atoi(v)
+		case "period":
+			cfg.Period, err = parseRateLimitPeriod(v)
+		case "algorithm":
+			cfg.Algorithm, err = ratelimit.ParseAlgorithm(v)
+		case "burst":
+			cfg.Burst, err = strconv.Atoi(v)
+		case "max_keys":
+			cfg.MaxKeys, err = strconv.Atoi(v)
+		default:
+			return fmt.Errorf("unknown rate limit zone option %q", k)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid rate limit zone option %q: %w", k, err)
+		}
+	}
+
+	limiter, err := ratelimit.NewLimiter(cfg)
+	if err != nil {
+		return err
+	}
+	zone.Limiter = limiter
+
+	if options.WAF.RateLimitZones == nil {
+		options.WAF.RateLimitZones = map[string]*ratelimit.Zone{}
+	}
+	options.WAF.RateLimitZones[name] = zone
+	return nil
+}
+
+// parseRateLimitPeriod parses a period expressed in seconds or as a duration.
+func parseRateLimitPeriod(v string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(v)
+}
+
 func directiveSecGsbLookupDb(options *DirectiveOptions) error {
 	return nil
 }
@@ -669,16 +1038,73 @@ func directiveSecHashEngine(options *DirectiveOptions) error {
 //
 // Important: Every `SecDefaultAction` directive must specify a disruptive action and a processing
 // phase and cannot contain metadata actions.
+//
+// Inside a `<VirtualHost>` or `<Location>` block it replaces, for the requests to the block,
+// the disruptive action of the rules of the phase relying on the default one (no disruptive
+// action or `block`). Only the phase, a disruptive action other than `block` and `status`
+// are accepted there:
+//
+// ```seclang
+// <VirtualHost admin.example.com>
+// SecDefaultAction "phase:2,deny,status:403"
+// </VirtualHost>
+// ```
 func directiveSecDefaultAction(options *DirectiveOptions) error {
 	if len(options.Opts) == 0 {
 		return errEmptyOptions
 	}
 
+	if options.Scope != nil {
+		return setScopeDefaultAction(options)
+	}
+
 	options.Parser.RuleDefaultActions = append(options.Parser.RuleDefaultActions, options.Opts)
 	options.Parser.HasRuleDefaultActions = true
 	return nil
 }
 
+// setScopeDefaultAction replaces, inside a configuration block, the disruptive action
+// the rules of the phase inherited from SecDefaultAction. The rules are shared by all
+// the blocks so only the phase, the disruptive action and the status are accepted.
+func setScopeDefaultAction(options *DirectiveOptions) error {
+	acts, err := parseActions(options.WAF.Logger, options.Opts)
+	if err != nil {
+		return err
+	}
+
+	phase := types.RulePhase(0)
+	var disruptive *ruleAction
+	// the actions are initialized against a rule used to read the status
+	rule := corazawaf.NewRule()
+	for i, a := range acts {
+		switch {
+		case a.Key == "phase":
+			if phase, err = types.ParseRulePhase(a.Value); err != nil {
+				return err
+			}
+		case a.Key == "status":
+			if err := a.F.Init(rule, a.Value); err != nil {
+				return err
+			}
+		case a.Atype == plugintypes.ActionTypeDisruptive && a.Key != "block":
+			disruptive = &acts[i]
+		default:
+			return fmt.Errorf("action %q is not allowed in SecDefaultAction inside configuration blocks", a.Key)
+		}
+	}
+	if phase == 0 {
+		return errors.New("SecDefaultAction inside configuration blocks must specify a phase")
+	}
+	if disruptive == nil {
+		return errors.New("SecDefaultAction inside configuration blocks must specify a disruptive action other than block")
+	}
+	if err := disruptive.F.Init(rule, disruptive.Value); err != nil {
+		return err
+	}
+	options.Scope.SetDefaultAction(phase, disruptive.Key, disruptive.F, rule.DisruptiveStatus)
+	return nil
+}
+
 func directiveSecConnEngine(options *DirectiveOptions) error {
 	/*
 		switch opts{
@@ -697,8 +1123,56 @@ func directiveSecConnEngine(options *DirectiveOptions) error {
 	return nil
 }
 
+// Description: Specifies the collections timeout.
+// Syntax: SecCollectionTimeout [SECONDS]
+// Default: 3600
+// ---
+// Records of the persistent collections (IP, SESSION, USER, GLOBAL and RESOURCE)
+// are removed from the storage when they are not updated for the configured time.
+// The value is available in the TIMEOUT built-in variable of each record.
+//
+// Example:
+// ```apache
+// SecCollectionTimeout 600
+// ```
 func directiveSecCollectionTimeout(options *DirectiveOptions) error {
-	// w.CollectionTimeout, _ = strconv.Atoi(opts)
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	timeout, err := strconv.Atoi(options.Opts)
+	if err != nil {
+		return err
+	}
+	if timeout <= 0 {
+		return errors.New("collection timeout should be bigger than 0")
+	}
+	options.WAF.CollectionTimeout = timeout
+	return nil
+}
+
+// Description: Configures the storage used by the persistent collections.
+// Syntax: SecPersistenceEngine [ENGINE]
+// Default: memory
+// ---
+// The default `memory` engine keeps the collections in memory, shared by all the
+// transactions of the WAF instance. Other engines can be registered by plugins
+// with `plugins.RegisterPersistentStore`.
+//
+// Example:
+// ```apache
+// SecPersistenceEngine memory
+// ```
+func directiveSecPersistenceEngine(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	store, err := persistence.GetStore(options.Opts)
+	if err != nil {
+		return err
+	}
+	options.WAF.SetPersistentStore(store)
 	return nil
 }
 
@@ -726,7 +1200,7 @@ func directiveSecAuditLog(options *DirectiveOptions) error {
 }
 
 // Description: Configures the type of audit logging mechanism to be used.
-// Syntax: SecAuditLogType Serial|Concurrent|HTTPS|Syslog
+// Syntax: SecAuditLogType [Async] Serial|Concurrent|HTTPS|Syslog|Socket|Kafka [OPTIONS]
 // ---
 // The possible values are:
 //
@@ -738,29 +1212,132 @@ func directiveSecAuditLog(options *DirectiveOptions) error {
 //   - HTTPS : Audit log entries will be sent to the target URL, specified by SecAuditLog.
 //   - Syslog : Audit log entries will be sent to the syslog server, specified by SecAuditLog
 //     in one of formats: "ADDRESS:PORT" (TCP), "udp://ADDRESS:PORT", or "unixgram:///var/run/syslog".
+//   - Socket : Audit log entries will be sent one per line, NDJSON with the JSON format, to the socket
+//     specified by SecAuditLog as "tcp://ADDRESS:PORT", "udp://ADDRESS:PORT", "unix:///path/to/socket" or
+//     "unixgram:///path/to/socket". The `timeout` query parameter sets the dial and write timeout, 1s by
+//     default. The connection is established again after any failure.
+//   - Kafka : Audit log entries will be produced to the Kafka topic specified by SecAuditLog as
+//     "kafka://BROKER:PORT[,BROKER:PORT]/TOPIC", keyed by transaction ID. The `partition` (0 by default),
+//     `acks` (0, 1 or all, 1 by default), `client_id` and `timeout` (5s by default) query parameters
+//     configure the producer.
+//
+// Prefixing the type with `Async` writes the entries in the background: they are queued and
+// written by a pool of workers, so slow outputs don't add latency to the transactions. Failed
+// writes are retried with exponential backoff, and the entries that don't fit in the queue or
+// keep failing are appended to a spill file or dropped. The queued entries are flushed when
+// the WAF is closed. The async writer is tuned with space separated `name=value` options:
+//
+// - `queue_size`: number of queued entries, 1024 by default.
+// - `workers`: number of workers, 1 by default.
+// - `batch_size`: maximum number of entries written at once by a worker, 1 by default.
+// - `max_retries`: number of retries of a failed write, 3 by default, -1 disables them.
+// - `backoff`: wait before the first retry, doubled on every retry, 100ms by default.
+// - `max_backoff`: maximum wait between retries, 5s by default.
+// - `spill_file`: file the formatted entries are appended to when they can't be written.
+// When it is not set those entries are dropped.
+// - `flush_timeout`: maximum time spent flushing the queue on close, 5s by default.
 //
 // Example:
 // ```apache
 // SecAuditLogType Serial
+// SecAuditLogType Async HTTPS workers=4 queue_size=10000 spill_file=/var/log/coraza/spill.log
+//
+// SecAuditLog kafka://kafka-1:9092,kafka-2:9092/coraza-audit?acks=all
+// SecAuditLogFormat JSON
+// SecAuditLogType Async Kafka batch_size=100
 // ```
 func directiveSecAuditLogType(options *DirectiveOptions) error {
-	if len(options.Opts) == 0 {
+	fields := strings.Fields(options.Opts)
+	if len(fields) == 0 {
 		return errEmptyOptions
 	}
 
-	writer, err := auditlog.GetWriter(options.Opts)
+	if !strings.EqualFold(fields[0], "async") {
+		if len(fields) > 1 {
+			return fmt.Errorf("unexpected audit log type options %q", strings.Join(fields[1:], " "))
+		}
+		writer, err := auditlog.GetWriter(fields[0])
+		if err != nil {
+			return err
+		}
+		options.WAF.SetAuditLogWriter(writer)
+		return nil
+	}
+
+	if len(fields) < 2 {
+		return errors.New("missing audit log type for the async writer")
+	}
+	writer, err := auditlog.GetWriter(fields[1])
 	if err != nil {
 		return err
 	}
-	options.WAF.SetAuditLogWriter(writer)
-
+	cfg, err := parseAsyncWriterConfig(fields[2:])
+	if err != nil {
+		return err
+	}
+	asyncWriter, err := auditlog.NewAsyncWriter(writer, cfg)
+	if err != nil {
+		return err
+	}
+	options.WAF.SetAuditLogWriter(asyncWriter)
 	return nil
 }
 
+// parseAsyncWriterConfig parses the name=value options of an async audit log writer.
+func parseAsyncWriterConfig(fields []string) (auditlog.AsyncWriterConfig, error) {
+	var cfg auditlog.AsyncWriterConfig
+	for _, field := range fields {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok || v == "" {
+			return cfg, fmt.Errorf("invalid async audit log option %q", field)
+		}
+		var err error
+		switch strings.ToLower(k) {
+		case "queue_size":
+			cfg.QueueSize, err = strconv.Atoi(v)
+		case "workers":
+			cfg.Workers, err = strconv.Atoi(v)
+		case "batch_size":
+			cfg.BatchSize, err = strconv.Atoi(v)
+		case "max_retries":
+			cfg.MaxRetries, err = strconv.Atoi(v)
+		case "backoff":
+			cfg.Backoff, err = time.ParseDuration(v)
+		case "max_backoff":
+			cfg.MaxBackoff, err = time.ParseDuration(v)
+		case "spill_file":
+			cfg.SpillFile = v
+		case "flush_timeout":
+			cfg.FlushTimeout, err = time.ParseDuration(v)
+		default:
+			return cfg, fmt.Errorf("unknown async audit log option %q", k)
+		}
+		if err != nil {
+			return cfg, fmt.Errorf("invalid async audit log option %q: %w", k, err)
+		}
+	}
+	return cfg, nil
+}
+
 // Description: Select the output format of the AuditLogs. The format can be
-// the native AuditLogs format, JSON, or OCSF (Open CyberSecurity Schema Framework).
-// Syntax: SecAuditLogFormat JSON|JsonLegacy|Native|OCSF
+// the native AuditLogs format, JSON, OCSF (Open CyberSecurity Schema Framework),
+// ArcSight CEF or IBM LEEF 2.0.
+// Syntax: SecAuditLogFormat JSON|JsonLegacy|Native|OCSF|CEF|LEEF
 // Default: Native
+// ---
+// The OCSF format writes, for every transaction, an OCSF 1.2.0 HTTP Activity event followed
+// by a Detection Finding event per matched rule (part K), one JSON object per line. Unlike
+// previous versions, an entry can span several lines, one per event.
+// Findings reference the MITRE ATT&CK techniques (tags such as `mitre/T1190`) and the
+// CAPEC patterns (tags such as `capec/1000/152/248/66`) found in the rule tags.
+//
+// The CEF and LEEF formats write a line per matched rule (part K), with the rule ID as
+// event ID, the rule severity mapped to the 0-10 scale, and the transaction ID, client
+// IP, request URI and rule ID as extension fields. Transactions without matched rules
+// produce no events.
+//
+// The syslog, HTTPS, socket and Kafka audit log types send every OCSF, CEF and LEEF event
+// as a separate record and send nothing for transactions without events.
 func directiveSecAuditLogFormat(options *DirectiveOptions) error {
 	if len(options.Opts) == 0 {
 		return errEmptyOptions
@@ -845,6 +1422,212 @@ func directiveSecAuditLogFileMode(options *DirectiveOptions) error {
 	return nil
 }
 
+// Description: Rotates the audit log file once it reaches the given size.
+// Syntax: SecAuditLogRotateSize [SIZE]
+// Default: 0 (disabled)
+// ---
+// The size is a number of bytes, optionally followed by the K, M or G unit. It applies to the
+// file of the serial audit log and to the index file of the concurrent audit log. The rotated
+// files are renamed with their rotation time as suffix, e.g. `audit.log.20240102-150405.000000000`.
+// Entries are never split across files.
+//
+// Example:
+// ```apache
+// SecAuditLogRotateSize 100M
+// ```
+func directiveSecAuditLogRotateSize(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	size, err := parseSize(options.Opts)
+	if err != nil {
+		return err
+	}
+	options.WAF.AuditLogWriterConfig.Rotation.MaxSize = size
+	return nil
+}
+
+// Description: Rotates the audit log file at the given interval.
+// Syntax: SecAuditLogRotateInterval [DURATION]
+// Default: 0 (disabled)
+// ---
+// The interval is a duration like `24h` or `30m`. The file is rotated on the first write after the
+// interval elapsed since it was opened, so empty files are never rotated.
+//
+// Example:
+// ```apache
+// SecAuditLogRotateInterval 24h
+// ```
+func directiveSecAuditLogRotateInterval(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	interval, err := time.ParseDuration(options.Opts)
+	if err != nil {
+		return err
+	}
+	if interval < 0 {
+		return errors.New("audit log rotation interval must not be negative")
+	}
+	options.WAF.AuditLogWriterConfig.Rotation.Interval = interval
+	return nil
+}
+
+// Description: Compresses the rotated audit log files with gzip.
+// Syntax: SecAuditLogRotateCompress On|Off
+// Default: Off
+// ---
+// The rotated files are compressed in the background and get the `.gz` extension.
+//
+// Example:
+// ```apache
+// SecAuditLogRotateCompress On
+// ```
+func directiveSecAuditLogRotateCompress(options *DirectiveOptions) error {
+	b, err := parseBoolean(options.Opts)
+	if err != nil {
+		return err
+	}
+	options.WAF.AuditLogWriterConfig.Rotation.Compress = b
+	return nil
+}
+
+// Description: Configures the number of rotated audit log files kept.
+// Syntax: SecAuditLogMaxFiles [NUMBER]
+// Default: 0 (unlimited)
+// ---
+// The oldest rotated files are removed after every rotation. The transaction files of the
+// concurrent audit log are kept according to `SecAuditLogStorageMaxFiles`.
+//
+// Example:
+// ```apache
+// SecAuditLogMaxFiles 10
+// ```
+func directiveSecAuditLogMaxFiles(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	n, err := strconv.Atoi(options.Opts)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return errors.New("audit log max files must not be negative")
+	}
+	options.WAF.AuditLogWriterConfig.Rotation.MaxFiles = n
+	return nil
+}
+
+// Description: Configures the maximum age of the rotated audit log files.
+// Syntax: SecAuditLogMaxAge [DURATION]
+// Default: 0 (unlimited)
+// ---
+// The rotated files modified before the given duration, e.g. `168h`, are removed after every
+// rotation. The transaction files of the concurrent audit log are kept according to
+// `SecAuditLogStorageMaxAge`.
+//
+// Example:
+// ```apache
+// SecAuditLogMaxAge 720h
+// ```
+func directiveSecAuditLogMaxAge(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	age, err := time.ParseDuration(options.Opts)
+	if err != nil {
+		return err
+	}
+	if age < 0 {
+		return errors.New("audit log max age must not be negative")
+	}
+	options.WAF.AuditLogWriterConfig.Rotation.MaxAge = age
+	return nil
+}
+
+// Description: Configures the number of transaction files kept in `SecAuditLogStorageDir`.
+// Syntax: SecAuditLogStorageMaxFiles [NUMBER]
+// Default: 0 (unlimited)
+// ---
+// Only used by the concurrent audit log. The oldest transaction files are removed in the
+// background, at most once a minute.
+//
+// Example:
+// ```apache
+// SecAuditLogStorageMaxFiles 100000
+// ```
+func directiveSecAuditLogStorageMaxFiles(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	n, err := strconv.Atoi(options.Opts)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return errors.New("audit log storage max files must not be negative")
+	}
+	options.WAF.AuditLogWriterConfig.StorageRetention.MaxFiles = n
+	return nil
+}
+
+// Description: Configures the maximum age of the transaction files kept in `SecAuditLogStorageDir`.
+// Syntax: SecAuditLogStorageMaxAge [DURATION]
+// Default: 0 (unlimited)
+// ---
+// Only used by the concurrent audit log. The transaction files older than the given duration,
+// e.g. `168h`, are removed in the background, at most once a minute. Their age is given by the
+// minute directory they are stored in.
+//
+// Example:
+// ```apache
+// SecAuditLogStorageMaxAge 720h
+// ```
+func directiveSecAuditLogStorageMaxAge(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	age, err := time.ParseDuration(options.Opts)
+	if err != nil {
+		return err
+	}
+	if age < 0 {
+		return errors.New("audit log storage max age must not be negative")
+	}
+	options.WAF.AuditLogWriterConfig.StorageRetention.MaxAge = age
+	return nil
+}
+
+// parseSize parses a number of bytes with an optional K, M or G unit.
+func parseSize(v string) (int64, error) {
+	multiplier := int64(1)
+	switch strings.ToUpper(v[len(v)-1:]) {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		v = v[:len(v)-1]
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", v)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("invalid size %q, must not be negative", v)
+	}
+	return n * multiplier, nil
+}
+
 // Description: Configures which response status code is to be considered relevant
 // for the purpose of audit logging.
 // Syntax: SecAuditLogRelevantStatus [REGEX]
@@ -880,6 +1663,103 @@ func directiveSecAuditLogRelevantStatus(options *DirectiveOptions) error {
 	return nil
 }
 
+// Description: Masks the values of the listed arguments in the audit logs.
+// Syntax: SecAuditLogSanitizeArgs [ARG NAMES]
+// ---
+// Argument names are separated by spaces and matched case insensitively. The values are
+// masked with asterisks in the ARGS of the log, in the query string of the URI and in
+// the `application/x-www-form-urlencoded` and JSON request bodies, as well as anywhere
+// else they show up, e.g. in the messages of the matched rules. JSON arguments are
+// named as in ARGS, e.g. `json.user.password`.
+//
+// Masking is done before the audit log is handed to the formatters, so it applies to
+// every audit log format. Use the `sanitiseArg` action to mask arguments from a rule.
+//
+// Example:
+// ```apache
+// SecAuditLogSanitizeArgs password passwd json.user.password
+// ```
+func directiveSecAuditLogSanitizeArgs(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	options.WAF.AuditLogSanitizer.Args = append(options.WAF.AuditLogSanitizer.Args, strings.Fields(options.Opts)...)
+	return nil
+}
+
+// Description: Masks the values of the listed request and response headers in the
+// audit logs.
+// Syntax: SecAuditLogSanitizeHeaders [HEADER NAMES]
+// ---
+// Header names are separated by spaces and matched case insensitively. The values of
+// request headers are also masked anywhere else they show up, e.g. in the messages of
+// the matched rules.
+//
+// Use the `sanitiseRequestHeader` and `sanitiseResponseHeader` actions to mask headers
+// from a rule.
+//
+// Example:
+// ```apache
+// SecAuditLogSanitizeHeaders Authorization Cookie Set-Cookie
+// ```
+func directiveSecAuditLogSanitizeHeaders(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	names := strings.Fields(options.Opts)
+	options.WAF.AuditLogSanitizer.RequestHeaders = append(options.WAF.AuditLogSanitizer.RequestHeaders, names...)
+	options.WAF.AuditLogSanitizer.ResponseHeaders = append(options.WAF.AuditLogSanitizer.ResponseHeaders, names...)
+	return nil
+}
+
+// Description: Masks the values of the listed paths of the JSON request and response
+// bodies in the audit logs.
+// Syntax: SecAuditLogSanitizeJSONPaths [PATHS]
+// ---
+// Paths are separated by spaces, their keys are separated by dots and `*` matches
+// any key or array index. The masked values are replaced by a string of asterisks,
+// keeping the document valid. The matching JSON arguments are masked as well.
+//
+// Example:
+// ```apache
+// SecAuditLogSanitizeJSONPaths user.password cards.*.number
+// ```
+func directiveSecAuditLogSanitizeJSONPaths(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	options.WAF.AuditLogSanitizer.JSONPaths = append(options.WAF.AuditLogSanitizer.JSONPaths, strings.Fields(options.Opts)...)
+	return nil
+}
+
+// Description: Masks the matches of a regular expression anywhere in the audit logs.
+// Syntax: SecAuditLogSanitizeMatches [REGEX]
+// ---
+// The directive can be used several times, every expression is applied to the URI,
+// headers, bodies, arguments and messages of the log. Each match is replaced by as many
+// asterisks as bytes it has.
+//
+// Example:
+// ```apache
+// SecAuditLogSanitizeMatches "\b(?:\d[ -]?){13,16}\b"
+// ```
+func directiveSecAuditLogSanitizeMatches(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	re, err := options.WAF.Memoizer().Do(options.Opts, func() (any, error) { return regexp.Compile(options.Opts) })
+	if err != nil {
+		return err
+	}
+
+	options.WAF.AuditLogSanitizer.Matches = append(options.WAF.AuditLogSanitizer.Matches, re.(*regexp.Regexp))
+	return nil
+}
+
 // Description: Defines which parts of each transaction are going to be recorded
 // in the audit log. Each part is assigned a single letter; when a letter appears
 // in the list then the equivalent part will be recorded. See below for the list of
@@ -898,23 +1778,29 @@ func directiveSecAuditLogRelevantStatus(options *DirectiveOptions) error {
 // - B: Request headers.
 // - C: Request body (present only if the request body exists and Coraza is configured
 // to intercept it. This would require `SecRequestBodyAccess` to be set to on).
-// - D: Reserved for intermediary response headers; not implemented yet.
+// - D: Intermediary response headers, the status line and headers of the response as inspected
+// by the response headers phase. Headers added afterwards only show in part F.
 // - E: Intermediary response body (present only if Coraza is configured to intercept
 // response bodies, and if the audit log engine is configured to record it. Intercepting
 // response bodies requires `SecResponseBodyAccess` to be enabled). Intermediary response
 // body is the same as the actual response body unless Coraza intercepts the intermediary
 // response body, in which case the actual response body will contain the error message.
 // - F: Final response headers.
-// - G: Reserved for the actual response body; not implemented yet.
+// - G: Actual response body, the body delivered to the client. It is present only if Coraza buffers
+// the response body (`SecResponseBodyAccess` set to on) and the transaction was not interrupted, as
+// connectors replace the body of interrupted transactions.
 // - H: Audit log trailer.
 // - I: This part is a replacement for part C. It will log the same data as C in all cases except when
 // `multipart/form-data` encoding in used. In this case, it will log a fake `application/x-www-form-urlencoded`
 // body that contains the information about parameters but not about the files. This is handy if
 // you don’t want to have (often large) files stored in your audit logs; not implemented yet.
-// - J: This part contains information about the files uploaded using `multipart/form-data` encoding. Available from Coraza v3.7.0.
+// - J: This part contains information about the files uploaded using `multipart/form-data` encoding
+// (name, size and content type). Available from Coraza v3.7.0.
 // - K: This part contains a full list of every rule that matched (one per line) in the order they were
 // matched. The rules are fully qualified and will thus show inherited actions and default operators.
 // - Z: Final boundary, signifies the end of the entry (mandatory).
+//
+// The bodies of parts C, E and G are truncated to `SecAuditLogBodyLimit` bytes.
 func directiveSecAuditLogParts(options *DirectiveOptions) error {
 	if len(options.Opts) == 0 {
 		return errEmptyOptions
@@ -925,6 +1811,32 @@ func directiveSecAuditLogParts(options *DirectiveOptions) error {
 	return err
 }
 
+// Description: Limits the size of the bodies written to the audit log.
+// Syntax: SecAuditLogBodyLimit [SIZE]
+// Default: 0 (no limit)
+// ---
+// The size is a number of bytes, optionally followed by the K, M or G unit. The request body
+// (part C), the intermediary response body (part E) and the actual response body (part G) are
+// truncated to this size, after being sanitized. Bodies are already bounded by the request and
+// response body limits, this directive keeps large payloads from bloating the audit log.
+//
+// Example:
+// ```apache
+// SecAuditLogBodyLimit 64K
+// ```
+func directiveSecAuditLogBodyLimit(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	limit, err := parseSize(options.Opts)
+	if err != nil {
+		return err
+	}
+	options.WAF.AuditLogBodyLimit = limit
+	return nil
+}
+
 // Description: Configures the audit logging engine.
 // Syntax: SecAuditEngine RelevantOnly
 // Default: Off
@@ -1114,6 +2026,8 @@ func directiveSecDebugLogLevel(options *DirectiveOptions) error {
 // ---
 // This directive will append variables to the specified rule with the targets provided in the second parameter.
 // The rule ID can be single IDs or ranges of IDs. The targets are separated by a pipe character.
+// Inside a `<VirtualHost>` or `<Location>` block only exclusions like `!ARGS:password` are
+// accepted, they apply to the requests to the block.
 func directiveSecRuleUpdateTargetByID(options *DirectiveOptions) error {
 	if len(options.Opts) == 0 {
 		return errEmptyOptions
@@ -1126,6 +2040,9 @@ func directiveSecRuleUpdateTargetByID(options *DirectiveOptions) error {
 	}
 	// The last element is expected to be the variable(s)
 	variables := idsOrRanges[length-1]
+	if options.Scope != nil {
+		return updateScopeTargets(idsOrRanges[:length-1], variables, options)
+	}
 	for _, idOrRange := range idsOrRanges[:length-1] {
 		if idx := strings.Index(idOrRange, "-"); idx == -1 {
 			id, err := strconv.Atoi(idOrRange)
@@ -1172,6 +2089,91 @@ func directiveSecRuleUpdateTargetByID(options *DirectiveOptions) error {
 	return nil
 }
 
+// updateScopeTargets removes targets from the rules for the transactions of a
+// configuration block, the shared rules cannot get new targets.
+func updateScopeTargets(idsOrRanges []string, targets string, options *DirectiveOptions) error {
+	var ranges [][2]int
+	for _, idOrRange := range idsOrRanges {
+		start, end, err := parseIDOrRange(idOrRange)
+		if err != nil {
+			return err
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+
+	for _, target := range splitTargets(strings.Trim(targets, "\"")) {
+		target, ok := strings.CutPrefix(target, "!")
+		if !ok {
+			return fmt.Errorf("target %q cannot be added inside configuration blocks, only exclusions are allowed", target)
+		}
+		name, key, _ := strings.Cut(target, ":")
+		v, err := variables.Parse(name)
+		if err != nil {
+			return err
+		}
+		var keyRx *regexp.Regexp
+		if isRegex, rxPattern := utils.HasRegex(key); isRegex {
+			re, err := options.WAF.Memoizer().Do(rxPattern, func() (any, error) { return regexp.Compile(rxPattern) })
+			if err != nil {
+				return err
+			}
+			keyRx, key = re.(*regexp.Regexp), ""
+		} else {
+			key = strings.ToLower(key)
+		}
+		for _, rng := range ranges {
+			options.Scope.RemoveRuleTargetByID(rng[0], rng[1], v, key, keyRx)
+		}
+	}
+	return nil
+}
+
+// parseIDOrRange parses a rule ID or an inclusive range of IDs like 1000-1999.
+func parseIDOrRange(idOrRange string) (int, int, error) {
+	idx := strings.Index(idOrRange, "-")
+	if idx == -1 {
+		id, err := strconv.Atoi(idOrRange)
+		return id, id, err
+	}
+	if idx == 0 {
+		return 0, 0, fmt.Errorf("invalid negative id: %s", idOrRange)
+	}
+	start, err := strconv.Atoi(idOrRange[:idx])
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := strconv.Atoi(idOrRange[idx+1:])
+	if err != nil {
+		return 0, 0, err
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("invalid range: %s", idOrRange)
+	}
+	return start, end, nil
+}
+
+// splitTargets splits the targets separated by pipes, the pipes of the regular
+// expression keys are kept.
+func splitTargets(targets string) []string {
+	var res []string
+	inRegex := false
+	last := 0
+	for i := 0; i < len(targets); i++ {
+		switch targets[i] {
+		case '/':
+			if i == 0 || targets[i-1] != '\\' {
+				inRegex = !inRegex
+			}
+		case '|':
+			if !inRegex {
+				res = append(res, targets[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(res, targets[last:])
+}
+
 func updateTargetBySingleID(id int, variables string, options *DirectiveOptions) error {
 
 	rule := options.WAF.Rules.FindByID(id)
@@ -1407,7 +2409,8 @@ func directiveSecDataset(options *DirectiveOptions) error {
 // Syntax: SecArgumentsLimit [LIMIT]
 // ---
 // Exceeding the limit will not be included.
-// With JSON body processing, there is nothing to do when exceed the limit.
+// The JSON body processor stops reading the body when the limit is reached
+// and generates a REQBODY_ERROR.
 // Example:
 // ```apache
 // SecArgumentsLimit 1000
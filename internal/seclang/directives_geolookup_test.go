@@ -0,0 +1,65 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.geoLookup
+
+package seclang
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/internal/mmdb/mmdbtest"
+)
+
+func TestSecGeoLookupDb(t *testing.T) {
+	db, err := mmdbtest.Build("GeoLite2-Country", []mmdbtest.Network{
+		{CIDR: "81.2.69.0/24", Record: map[string]any{
+			"country": map[string]any{"iso_code": "GB"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := fstest.MapFS{
+		"conf/geo.conf":        {Data: []byte("SecGeoLookupDb country.mmdb\n")},
+		"conf/country.mmdb":    {Data: db},
+		"conf/invalid.mmdb":    {Data: []byte("not a database")},
+		"conf/invalid.conf":    {Data: []byte("SecGeoLookupDb invalid.mmdb\n")},
+		"conf/unexisting.conf": {Data: []byte("SecGeoLookupDb unexisting.mmdb\n")},
+	}
+
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	p.SetRoot(root)
+	if err := p.FromString(`
+Include conf/geo.conf
+SecRule REMOTE_ADDR "@geoLookup" "id:1,phase:1,pass,nolog"
+SecRule GEO:COUNTRY_CODE3 "@streq GBR" "id:2,phase:1,deny"
+`); err != nil {
+		t.Fatal(err)
+	}
+	if len(waf.GeoLookupDBs) != 1 {
+		t.Fatalf("expected 1 geolocation database, have %d", len(waf.GeoLookupDBs))
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.ProcessConnection("81.2.69.142", 1234, "", 0)
+	if it := tx.ProcessRequestHeaders(); it == nil {
+		t.Error("expected transaction to be interrupted by the GEO rule")
+	}
+
+	for _, directive := range []string{
+		"SecGeoLookupDb",
+		"Include conf/invalid.conf",
+		"Include conf/unexisting.conf",
+	} {
+		p := NewParser(corazawaf.NewWAF())
+		p.SetRoot(root)
+		if err := p.FromString(directive); err == nil {
+			t.Errorf("expected error for %q", directive)
+		}
+	}
+}
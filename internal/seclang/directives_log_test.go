@@ -64,6 +64,113 @@ func TestSecAuditLogDirectivesConcurrent(t *testing.T) {
 	}
 }
 
+func TestSecAuditLogDirectivesAsync(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	parser := NewParser(waf)
+
+	auditFile := filepath.Join(t.TempDir(), "audit.log")
+	if err := parser.FromString(fmt.Sprintf(`
+	SecAuditLog %s
+	SecAuditLogFormat json
+	SecAuditLogType Async Serial workers=2 queue_size=10 backoff=10ms
+	`, auditFile)); err != nil {
+		t.Fatal(err)
+	}
+	if err := waf.InitAuditLogWriter(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := waf.NewTransaction()
+	tx.AuditLogParts = types.AuditLogParts("ABZ")
+	tx.AddGetRequestArgument("async", "value")
+	if err := waf.AuditLogWriter().Write(tx.AuditLog()); err != nil {
+		t.Fatal(err)
+	}
+	id := tx.ID()
+	tx.Close()
+
+	// closing the WAF flushes the queue
+	if err := waf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(auditFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), id) {
+		t.Errorf("failed to write audit log, got %q", data)
+	}
+}
+
+func TestSecAuditLogRotationDirectives(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	parser := NewParser(waf)
+
+	auditFile := filepath.Join(t.TempDir(), "audit.log")
+	if err := parser.FromString(fmt.Sprintf(`
+	SecAuditLog %s
+	SecAuditLogFormat json
+	SecAuditLogType Serial
+	SecAuditLogRotateSize 1
+	SecAuditLogMaxFiles 1
+	`, auditFile)); err != nil {
+		t.Fatal(err)
+	}
+	if err := waf.InitAuditLogWriter(); err != nil {
+		t.Fatal(err)
+	}
+	defer waf.Close()
+
+	for _, id := range []string{"tx1", "tx2"} {
+		if err := waf.AuditLogWriter().Write(&auditlog.Log{Transaction_: auditlog.Transaction{ID_: id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rotated, err := filepath.Glob(auditFile + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 1 {
+		t.Fatalf("unexpected rotated files %q", rotated)
+	}
+
+	// external rotation, the file is moved away and the writer asked to reopen it
+	if err := os.Rename(auditFile, auditFile+".moved"); err != nil {
+		t.Fatal(err)
+	}
+	if err := waf.ReopenAuditLog(); err != nil {
+		t.Fatal(err)
+	}
+	if err := waf.AuditLogWriter().Write(&auditlog.Log{Transaction_: auditlog.Transaction{ID_: "tx3"}}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(auditFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "tx3") {
+		t.Errorf("unexpected audit log after reopen %q", data)
+	}
+}
+
+func TestSecAuditLogTypeErrors(t *testing.T) {
+	for _, opts := range []string{
+		"Serial workers=2",
+		"Async",
+		"Async unknown",
+		"Async Serial workers",
+		"Async Serial workers=-1",
+		"Async Serial backoff=1x",
+		"Async Serial unknown=1",
+	} {
+		t.Run(opts, func(t *testing.T) {
+			if err := directiveSecAuditLogType(&DirectiveOptions{WAF: corazawaf.NewWAF(), Opts: opts}); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
 func TestDebugDirectives(t *testing.T) {
 	waf := corazawaf.NewWAF()
 	tmp := filepath.Join(t.TempDir(), "tmp.log")
@@ -0,0 +1,41 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.rateLimit
+
+package seclang
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+)
+
+func TestSecRateLimitZone(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	if err := p.FromString(`
+SecRateLimitZone login key=%{REMOTE_ADDR} limit=2 period=60
+SecRule REQUEST_FILENAME "@streq /login" "id:1,phase:1,chain,deny,status:429"
+SecRule REMOTE_ADDR "@rateLimit login"
+`); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.FromString("SecRateLimitZone LOGIN limit=1 period=1"); err == nil {
+		t.Error("expected error for duplicated zone")
+	}
+
+	for i, want := range []int{0, 0, 429} {
+		tx := waf.NewTransaction()
+		tx.ProcessConnection("10.0.0.1", 1234, "", 0)
+		tx.ProcessURI("/login", "POST", "HTTP/1.1")
+		it := tx.ProcessRequestHeaders()
+		switch {
+		case want == 0 && it != nil:
+			t.Errorf("request %d: unexpected interruption", i)
+		case want != 0 && (it == nil || it.Status != want):
+			t.Errorf("request %d: expected interruption with status %d, have %v", i, want, it)
+		}
+		tx.Close()
+	}
+}
@@ -8,9 +8,11 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/corazawaf/coraza/v3/internal/corazawaf"
 	"github.com/corazawaf/coraza/v3/internal/environment"
+	"github.com/corazawaf/coraza/v3/internal/ratelimit"
 	"github.com/corazawaf/coraza/v3/types"
 )
 
@@ -424,6 +426,69 @@ func TestDirectives(t *testing.T) {
 		"SecAuditLog": {
 			{"", expectErrorOnDirective},
 		},
+		"SecCollectionTimeout": {
+			{"", expectErrorOnDirective},
+			{"abc", expectErrorOnDirective},
+			{"0", expectErrorOnDirective},
+			{"600", func(w *corazawaf.WAF) bool { return w.CollectionTimeout == 600 }},
+		},
+		"SecRulePerfTime": {
+			{"", expectErrorOnDirective},
+			{"abc", expectErrorOnDirective},
+			{"-1", expectErrorOnDirective},
+			{"1000", func(w *corazawaf.WAF) bool { return w.RulePerfTime == time.Millisecond }},
+		},
+		"SecPcreMatchLimit": {
+			{"", expectErrorOnDirective},
+			{"abc", expectErrorOnDirective},
+			{"-1", expectErrorOnDirective},
+			// the ModSecurity match limits don't limit the inspected bytes
+			{"1000", func(w *corazawaf.WAF) bool { return w.InspectionByteLimit == 0 }},
+		},
+		"SecInspectionByteLimit": {
+			{"", expectErrorOnDirective},
+			{"abc", expectErrorOnDirective},
+			{"-1", expectErrorOnDirective},
+			{"131072", func(w *corazawaf.WAF) bool { return w.InspectionByteLimit == 131072 }},
+			{"128K", func(w *corazawaf.WAF) bool { return w.InspectionByteLimit == 131072 }},
+		},
+		"SecPcreMatchLimitRecursion": {
+			{"", expectErrorOnDirective},
+			{"abc", expectErrorOnDirective},
+			{"1000", func(w *corazawaf.WAF) bool { return true }},
+		},
+		"SecOperatorTimeLimit": {
+			{"", expectErrorOnDirective},
+			{"abc", expectErrorOnDirective},
+			{"-1", expectErrorOnDirective},
+			{"50", func(w *corazawaf.WAF) bool { return w.OperatorTimeLimit == 50*time.Millisecond }},
+		},
+		"SecPersistenceEngine": {
+			{"", expectErrorOnDirective},
+			{"unknown", expectErrorOnDirective},
+			{"memory", func(w *corazawaf.WAF) bool {
+				s, err := w.PersistentStore()
+				return err == nil && s != nil
+			}},
+		},
+		"SecRateLimitZone": {
+			{"", expectErrorOnDirective},
+			{"api", expectErrorOnDirective},
+			{"api limit=10", expectErrorOnDirective},
+			{"api limit=0 period=60", expectErrorOnDirective},
+			{"api limit=10 period=1x", expectErrorOnDirective},
+			{"api limit=10 period=60 algorithm=leaky", expectErrorOnDirective},
+			{"api limit=10 period=60 unknown=1", expectErrorOnDirective},
+			{"api limit=10 period=60 key", expectErrorOnDirective},
+			{"API key=%{REMOTE_ADDR} limit=10 period=1m", func(w *corazawaf.WAF) bool {
+				z := w.RateLimitZones["api"]
+				return z != nil && z.Key.String() == "%{REMOTE_ADDR}" && z.Limiter.Config().Period == time.Minute
+			}},
+			{"api limit=10 period=60 algorithm=token_bucket burst=20 max_keys=100", func(w *corazawaf.WAF) bool {
+				cfg := w.RateLimitZones["api"].Limiter.Config()
+				return cfg.Algorithm == ratelimit.TokenBucket && cfg.Burst == 20 && cfg.MaxKeys == 100 && cfg.Period == time.Minute
+			}},
+		},
 		"SecArgumentsLimit": {
 			{"", expectErrorOnDirective},
 			{"0", expectErrorOnDirective},
@@ -431,6 +496,84 @@ func TestDirectives(t *testing.T) {
 			// according to modsec docs SecArgumentsLimit 1000
 			{"1000", func(waf *corazawaf.WAF) bool { return waf.ArgumentLimit == 1000 }},
 		},
+		"SecAuditLogSanitizeArgs": {
+			{"", expectErrorOnDirective},
+			{"password  json.user.pin", func(w *corazawaf.WAF) bool {
+				return strings.Join(w.AuditLogSanitizer.Args, ",") == "password,json.user.pin"
+			}},
+		},
+		"SecAuditLogSanitizeHeaders": {
+			{"", expectErrorOnDirective},
+			{"Authorization Set-Cookie", func(w *corazawaf.WAF) bool {
+				return len(w.AuditLogSanitizer.RequestHeaders) == 2 && len(w.AuditLogSanitizer.ResponseHeaders) == 2
+			}},
+		},
+		"SecAuditLogSanitizeJSONPaths": {
+			{"", expectErrorOnDirective},
+			{"user.password cards.*.number", func(w *corazawaf.WAF) bool {
+				return strings.Join(w.AuditLogSanitizer.JSONPaths, ",") == "user.password,cards.*.number"
+			}},
+		},
+		"SecAuditLogSanitizeMatches": {
+			{"", expectErrorOnDirective},
+			{"(", expectErrorOnDirective},
+			{`\d{3}-\d{2}-\d{4}`, func(w *corazawaf.WAF) bool {
+				return len(w.AuditLogSanitizer.Matches) == 1 && w.AuditLogSanitizer.Matches[0].MatchString("123-45-6789")
+			}},
+		},
+		"SecAuditLogBodyLimit": {
+			{"", expectErrorOnDirective},
+			{"abc", expectErrorOnDirective},
+			{"64K", func(w *corazawaf.WAF) bool { return w.AuditLogBodyLimit == 64<<10 }},
+		},
+		"SecAuditLogRotateSize": {
+			{"", expectErrorOnDirective},
+			{"abc", expectErrorOnDirective},
+			{"-1", expectErrorOnDirective},
+			{"10M", func(w *corazawaf.WAF) bool { return w.AuditLogWriterConfig.Rotation.MaxSize == 10<<20 }},
+		},
+		"SecAuditLogRotateInterval": {
+			{"", expectErrorOnDirective},
+			{"x", expectErrorOnDirective},
+			{"-1h", expectErrorOnDirective},
+			{"24h", func(w *corazawaf.WAF) bool { return w.AuditLogWriterConfig.Rotation.Interval == 24*time.Hour }},
+		},
+		"SecAuditLogRotateCompress": {
+			{"", expectErrorOnDirective},
+			{"Maybe", expectErrorOnDirective},
+			{"On", func(w *corazawaf.WAF) bool { return w.AuditLogWriterConfig.Rotation.Compress }},
+		},
+		"SecAuditLogMaxFiles": {
+			{"", expectErrorOnDirective},
+			{"-1", expectErrorOnDirective},
+			{"5", func(w *corazawaf.WAF) bool { return w.AuditLogWriterConfig.Rotation.MaxFiles == 5 }},
+		},
+		"SecAuditLogMaxAge": {
+			{"", expectErrorOnDirective},
+			{"1x", expectErrorOnDirective},
+			{"168h", func(w *corazawaf.WAF) bool { return w.AuditLogWriterConfig.Rotation.MaxAge == 168*time.Hour }},
+		},
+		"SecAuditLogStorageMaxFiles": {
+			{"", expectErrorOnDirective},
+			{"-1", expectErrorOnDirective},
+			{"100", func(w *corazawaf.WAF) bool { return w.AuditLogWriterConfig.StorageRetention.MaxFiles == 100 }},
+		},
+		"SecAuditLogStorageMaxAge": {
+			{"", expectErrorOnDirective},
+			{"1x", expectErrorOnDirective},
+			{"168h", func(w *corazawaf.WAF) bool { return w.AuditLogWriterConfig.StorageRetention.MaxAge == 168*time.Hour }},
+		},
+		"SecXmlDepthLimit": {
+			{"", expectErrorOnDirective},
+			{"0", expectErrorOnDirective},
+			{"abc", expectErrorOnDirective},
+			{"64", func(waf *corazawaf.WAF) bool { return waf.XMLDepthLimit == 64 }},
+		},
+		"SecXmlNodeLimit": {
+			{"", expectErrorOnDirective},
+			{"-1", expectErrorOnDirective},
+			{"5000", func(waf *corazawaf.WAF) bool { return waf.XMLNodeLimit == 5000 }},
+		},
 	}
 	if environment.HasAccessToFS {
 		directiveCases["SecUploadDir"] = []directiveCase{
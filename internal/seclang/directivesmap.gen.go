@@ -14,7 +14,10 @@ var (
 	_ directive = directiveSecRequestBodyLimit
 	_ directive = directiveSecRequestBodyAccess
 	_ directive = directiveSecRequestBodyJsonDepthLimit
+	_ directive = directiveSecXmlDepthLimit
+	_ directive = directiveSecXmlNodeLimit
 	_ directive = directiveSecRuleEngine
+	_ directive = directiveSecRulePerfTime
 	_ directive = directiveSecWebAppID
 	_ directive = directiveSecServerSignature
 	_ directive = directiveSecRuleRemoveByTag
@@ -33,7 +36,11 @@ var (
 	_ directive = directiveSecConnReadStateLimit
 	_ directive = directiveSecPcreMatchLimitRecursion
 	_ directive = directiveSecPcreMatchLimit
+	_ directive = directiveSecInspectionByteLimit
+	_ directive = directiveSecOperatorTimeLimit
 	_ directive = directiveSecHTTPBlKey
+	_ directive = directiveSecGeoLookupDb
+	_ directive = directiveSecRateLimitZone
 	_ directive = directiveSecGsbLookupDb
 	_ directive = directiveSecHashMethodPm
 	_ directive = directiveSecHashMethodRx
@@ -43,14 +50,27 @@ var (
 	_ directive = directiveSecDefaultAction
 	_ directive = directiveSecConnEngine
 	_ directive = directiveSecCollectionTimeout
+	_ directive = directiveSecPersistenceEngine
 	_ directive = directiveSecAuditLog
 	_ directive = directiveSecAuditLogType
 	_ directive = directiveSecAuditLogFormat
 	_ directive = directiveSecAuditLogStorageDir
 	_ directive = directiveSecAuditLogDirMode
 	_ directive = directiveSecAuditLogFileMode
+	_ directive = directiveSecAuditLogRotateSize
+	_ directive = directiveSecAuditLogRotateInterval
+	_ directive = directiveSecAuditLogRotateCompress
+	_ directive = directiveSecAuditLogMaxFiles
+	_ directive = directiveSecAuditLogMaxAge
+	_ directive = directiveSecAuditLogStorageMaxFiles
+	_ directive = directiveSecAuditLogStorageMaxAge
 	_ directive = directiveSecAuditLogRelevantStatus
+	_ directive = directiveSecAuditLogSanitizeArgs
+	_ directive = directiveSecAuditLogSanitizeHeaders
+	_ directive = directiveSecAuditLogSanitizeJSONPaths
+	_ directive = directiveSecAuditLogSanitizeMatches
 	_ directive = directiveSecAuditLogParts
+	_ directive = directiveSecAuditLogBodyLimit
 	_ directive = directiveSecAuditEngine
 	_ directive = directiveSecDataDir
 	_ directive = directiveSecUploadKeepFiles
@@ -78,7 +98,10 @@ var directivesMap = map[string]directive{
 	"secrequestbodylimit":            directiveSecRequestBodyLimit,
 	"secrequestbodyaccess":           directiveSecRequestBodyAccess,
 	"secrequestbodyjsondepthlimit":   directiveSecRequestBodyJsonDepthLimit,
+	"secxmldepthlimit":               directiveSecXmlDepthLimit,
+	"secxmlnodelimit":                directiveSecXmlNodeLimit,
 	"secruleengine":                  directiveSecRuleEngine,
+	"secruleperftime":                directiveSecRulePerfTime,
 	"secwebappid":                    directiveSecWebAppID,
 	"secserversignature":             directiveSecServerSignature,
 	"secruleremovebytag":             directiveSecRuleRemoveByTag,
@@ -97,7 +120,11 @@ var directivesMap = map[string]directive{
 	"secconnreadstatelimit":          directiveSecConnReadStateLimit,
 	"secpcrematchlimitrecursion":     directiveSecPcreMatchLimitRecursion,
 	"secpcrematchlimit":              directiveSecPcreMatchLimit,
+	"secinspectionbytelimit":         directiveSecInspectionByteLimit,
+	"secoperatortimelimit":           directiveSecOperatorTimeLimit,
 	"sechttpblkey":                   directiveSecHTTPBlKey,
+	"secgeolookupdb":                 directiveSecGeoLookupDb,
+	"secratelimitzone":               directiveSecRateLimitZone,
 	"secgsblookupdb":                 directiveSecGsbLookupDb,
 	"sechashmethodpm":                directiveSecHashMethodPm,
 	"sechashmethodrx":                directiveSecHashMethodRx,
@@ -107,14 +134,27 @@ var directivesMap = map[string]directive{
 	"secdefaultaction":               directiveSecDefaultAction,
 	"secconnengine":                  directiveSecConnEngine,
 	"seccollectiontimeout":           directiveSecCollectionTimeout,
+	"secpersistenceengine":           directiveSecPersistenceEngine,
 	"secauditlog":                    directiveSecAuditLog,
 	"secauditlogtype":                directiveSecAuditLogType,
 	"secauditlogformat":              directiveSecAuditLogFormat,
 	"secauditlogstoragedir":          directiveSecAuditLogStorageDir,
 	"secauditlogdirmode":             directiveSecAuditLogDirMode,
 	"secauditlogfilemode":            directiveSecAuditLogFileMode,
+	"secauditlogrotatesize":          directiveSecAuditLogRotateSize,
+	"secauditlogrotateinterval":      directiveSecAuditLogRotateInterval,
+	"secauditlogrotatecompress":      directiveSecAuditLogRotateCompress,
+	"secauditlogmaxfiles":            directiveSecAuditLogMaxFiles,
+	"secauditlogmaxage":              directiveSecAuditLogMaxAge,
+	"secauditlogstoragemaxfiles":     directiveSecAuditLogStorageMaxFiles,
+	"secauditlogstoragemaxage":       directiveSecAuditLogStorageMaxAge,
 	"secauditlogrelevantstatus":      directiveSecAuditLogRelevantStatus,
+	"secauditlogsanitizeargs":        directiveSecAuditLogSanitizeArgs,
+	"secauditlogsanitizeheaders":     directiveSecAuditLogSanitizeHeaders,
+	"secauditlogsanitizejsonpaths":   directiveSecAuditLogSanitizeJSONPaths,
+	"secauditlogsanitizematches":     directiveSecAuditLogSanitizeMatches,
 	"secauditlogparts":               directiveSecAuditLogParts,
+	"secauditlogbodylimit":           directiveSecAuditLogBodyLimit,
 	"secauditengine":                 directiveSecAuditEngine,
 	"secdatadir":                     directiveSecDataDir,
 	"secuploadkeepfiles":             directiveSecUploadKeepFiles,
@@ -137,7 +177,6 @@ var directivesMap = map[string]directive{
 	"seccookieformat":          directiveUnsupported,
 	"secruleupdatetargetbymsg": directiveUnsupported,
 	"secrulescript":            directiveUnsupported,
-	"secruleperftime":          directiveUnsupported,
 	"secunicodemap":            directiveUnsupported,
 	"sectmpdir":                directiveUnsupported,
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/corazawaf/coraza/v3/internal/corazawaf"
 	"github.com/corazawaf/coraza/v3/internal/environment"
 	"github.com/corazawaf/coraza/v3/internal/io"
+	utils "github.com/corazawaf/coraza/v3/internal/strings"
 )
 
 // maxIncludeRecursion is used to avoid DDOS by including files that include
@@ -37,7 +38,7 @@ type Parser struct {
 // files in the directory matching the pattern.
 // It will return an error if there are no files matching the pattern.
 func (p *Parser) FromFile(profilePath string) error {
-	originalDir := p.currentDir
+	originalDir, originalFile := p.currentDir, p.currentFile
 
 	var files []string
 	if strings.Contains(profilePath, "*") {
@@ -59,6 +60,7 @@ func (p *Parser) FromFile(profilePath string) error {
 		if !strings.HasPrefix(profilePath, "/") {
 			profilePath = filepath.Join(p.currentDir, profilePath)
 		}
+		lastLine := p.currentLine
 		p.currentFile = profilePath
 		lastDir := p.currentDir
 		p.currentDir = filepath.Dir(profilePath)
@@ -66,23 +68,28 @@ func (p *Parser) FromFile(profilePath string) error {
 		if err != nil {
 			// we don't use defer for this as tinygo does not seem to like it
 			p.currentDir = originalDir
-			p.currentFile = ""
+			p.currentFile = originalFile
 			return fmt.Errorf("failed to readfile: %s", err.Error())
 		}
 
+		// line numbers are relative to the file being parsed
+		p.currentLine = 0
 		err = p.parseString(string(file))
 		if err != nil {
 			// we don't use defer for this as tinygo does not seem to like it
 			p.currentDir = originalDir
-			p.currentFile = ""
-			return fmt.Errorf("failed to parse string: %s", err.Error())
+			p.currentFile = originalFile
+			p.currentLine = lastLine
+			return fmt.Errorf("failed to parse string: %w", err)
 		}
-		// restore the lastDir post processing all includes
+		// restore the lastDir and lastLine post processing all includes
 		p.currentDir = lastDir
+		p.currentFile = originalFile
+		p.currentLine = lastLine
 	}
 	// we don't use defer for this as tinygo does not seem to like it
 	p.currentDir = originalDir
-	p.currentFile = ""
+	p.currentFile = originalFile
 
 	return nil
 }
@@ -91,17 +98,23 @@ func (p *Parser) FromFile(profilePath string) error {
 // It will return error if any directive fails to parse
 // or arguments are invalid
 func (p *Parser) FromString(data string) error {
-	oldCurrentFile := p.currentFile
+	oldCurrentFile, oldCurrentLine := p.currentFile, p.currentLine
 	p.currentFile = "_inline_"
+	p.currentLine = 0
 	err := p.parseString(data)
 	p.currentFile = oldCurrentFile
+	p.currentLine = oldCurrentLine
 	return err
 }
 
 func (p *Parser) parseString(data string) error {
+	// blocks must be closed in the file they are opened
+	scope := p.options.Scope
 	scanner := bufio.NewScanner(strings.NewReader(data))
 	var linebuffer strings.Builder
 	inBackticks := false
+	// directiveLine is the line the directive being buffered starts at
+	directiveLine := 0
 	for scanner.Scan() {
 		p.currentLine++
 		line := strings.TrimSpace(scanner.Text())
@@ -113,6 +126,9 @@ func (p *Parser) parseString(data string) error {
 		if line[0] == '#' {
 			continue
 		}
+		if linebuffer.Len() == 0 {
+			directiveLine = p.currentLine
+		}
 
 		// Looks for a line like "SecDataset test `". The backtick starts an action list.
 		// The list will be closed only with a single "`" line.
@@ -133,20 +149,60 @@ func (p *Parser) parseString(data string) error {
 			linebuffer.WriteString(strings.TrimSuffix(line, "\\"))
 		} else {
 			linebuffer.WriteString(line)
-			err := p.evaluateLine(linebuffer.String())
+			err := p.evaluateLine(linebuffer.String(), directiveLine)
 			if err != nil {
-				return err
+				p.options.Scope = scope
+				return p.newParseError(directiveLine, err)
 			}
 			linebuffer.Reset()
 		}
 	}
 	if inBackticks {
-		return errors.New("backticks left open")
+		return p.newParseError(directiveLine, errors.New("backticks left open"))
+	}
+	if p.options.Scope != scope {
+		p.options.Scope = scope
+		return p.newParseError(p.currentLine, errors.New("configuration block left open"))
 	}
 	return nil
 }
 
-func (p *Parser) evaluateLine(l string) error {
+// ParseError is returned by the parser when a directive fails to compile,
+// it holds the position of the directive.
+type ParseError struct {
+	// File is the file containing the directive, _inline_ for directives
+	// parsed from strings.
+	File string
+	// Line is the line the directive starts at, relative to File.
+	Line int
+	// Directive is the lowercased name of the directive that failed to
+	// compile, empty if the line could not be evaluated at all.
+	Directive string
+	Err       error
+}
+
+func (e *ParseError) Error() string {
+	if e.Directive != "" {
+		return fmt.Sprintf("%s:%d: failed to compile the directive %q: %s", e.File, e.Line, e.Directive, e.Err.Error())
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err.Error())
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// newParseError wraps err with the current position, errors coming from
+// included files already hold the position of the failing directive.
+func (p *Parser) newParseError(line int, err error) error {
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		return err
+	}
+	return &ParseError{File: p.currentFile, Line: line, Err: err}
+}
+
+func (p *Parser) evaluateLine(l string, line int) error {
 	if l == "" || l[0] == '#' {
 		return errors.New("invalid line")
 	}
@@ -160,6 +216,10 @@ func (p *Parser) evaluateLine(l string) error {
 		opts = strings.Trim(opts, `"`)
 	}
 
+	if directive[0] == '<' {
+		return p.evaluateBlock(directive, opts)
+	}
+
 	if directive == "include" {
 		// this is a special hardcoded case
 		// we cannot add it as a directive type because there are recursion issues
@@ -176,6 +236,9 @@ func (p *Parser) evaluateLine(l string) error {
 	if !ok || d == nil {
 		return p.logAndReturnErr(fmt.Sprintf("unknown directive %q", directive))
 	}
+	if p.options.Scope != nil && !scopeDirectives[directive] {
+		return p.logAndReturnErr(fmt.Sprintf("directive %q is not allowed inside configuration blocks", directive))
+	}
 
 	p.options.Raw = l
 	p.options.Opts = opts
@@ -192,9 +255,111 @@ func (p *Parser) evaluateLine(l string) error {
 	}
 
 	if err := d(p.options); err != nil {
-		return fmt.Errorf("failed to compile the directive %q: %w", directive, err)
+		return &ParseError{File: p.currentFile, Line: line, Directive: directive, Err: err}
+	}
+
+	return nil
+}
+
+// scopeDirectives are the directives allowed inside configuration blocks, the
+// rules and the rest of the configuration are shared by all the blocks.
+var scopeDirectives = map[string]bool{
+	"secruleengine":           true,
+	"secrequestbodyaccess":    true,
+	"secrequestbodylimit":     true,
+	"secresponsebodyaccess":   true,
+	"secresponsebodylimit":    true,
+	"secruleremovebyid":       true,
+	"secruleremovebytag":      true,
+	"secruleremovebymsg":      true,
+	"secruleupdatetargetbyid": true,
+	"secdefaultaction":        true,
+	"secwebappid":             true,
+}
+
+// evaluateBlock opens or closes a configuration block. The blocks override part
+// of the configuration for the requests to some hosts or paths, sharing the
+// rules of the WAF:
+//
+//	<VirtualHost example.com *.example.com>
+//	    SecRuleEngine DetectionOnly
+//	    <Location /api>
+//	        SecRuleRemoveById 920350
+//	    </Location>
+//	</VirtualHost>
+//	<Location /uploads>
+//	    SecRequestBodyLimit 1048576
+//	</Location>
+//
+// <VirtualHost> takes the server names, a leading "*." matching the subdomains,
+// and <Location> a path prefix, matched at a segment boundary against the path
+// without its dot segments. A <Location> inside a <VirtualHost> applies to
+// its hosts and inherits its configuration. Only the most specific block applies
+// to a transaction: the blocks of the host win over the ones of any host, then
+// the longest path. The block is selected once the server name or the URI is
+// known, so the phase 1 rules see the overridden configuration.
+func (p *Parser) evaluateBlock(directive string, opts string) error {
+	cur := p.options.Scope
+	if strings.HasPrefix(directive, "</") {
+		if opts != "" || !strings.HasSuffix(directive, ">") {
+			return p.logAndReturnErr(fmt.Sprintf("invalid closing block %q", directive))
+		}
+		switch strings.TrimSuffix(directive[2:], ">") {
+		case "virtualhost":
+			if cur == nil || cur.Path != "" {
+				return p.logAndReturnErr("unexpected </VirtualHost>")
+			}
+			p.options.Scope = nil
+		case "location":
+			if cur == nil || cur.Path == "" {
+				return p.logAndReturnErr("unexpected </Location>")
+			}
+			p.options.Scope = cur.Parent()
+		default:
+			return p.logAndReturnErr(fmt.Sprintf("unknown directive %q", directive))
+		}
+		return nil
+	}
+
+	args, ok := strings.CutSuffix(strings.TrimSpace(directive[1:]+" "+opts), ">")
+	if !ok {
+		return p.logAndReturnErr(fmt.Sprintf("block %q is not closed with >", directive))
+	}
+	name, args, _ := strings.Cut(args, " ")
+	fields := strings.Fields(args)
+
+	var scope *corazawaf.Scope
+	switch name {
+	case "virtualhost":
+		if cur != nil {
+			return p.logAndReturnErr("<VirtualHost> cannot be nested")
+		}
+		if len(fields) == 0 {
+			return p.logAndReturnErr("<VirtualHost> requires at least one host")
+		}
+		scope = corazawaf.NewScope(fields, "")
+	case "location":
+		if len(fields) != 1 {
+			return p.logAndReturnErr("<Location> requires a path")
+		}
+		path := utils.MaybeRemoveQuotes(fields[0])
+		if !strings.HasPrefix(path, "/") {
+			return p.logAndReturnErr(fmt.Sprintf("invalid <Location> path %q, it must start with /", path))
+		}
+		switch {
+		case cur == nil:
+			scope = corazawaf.NewScope(nil, path)
+		case cur.Path == "":
+			scope = cur.NewLocation(path)
+		default:
+			return p.logAndReturnErr("<Location> cannot be nested in another <Location>")
+		}
+	default:
+		return p.logAndReturnErr(fmt.Sprintf("unknown directive %q", directive))
 	}
 
+	p.options.WAF.Scopes = append(p.options.WAF.Scopes, scope)
+	p.options.Scope = scope
 	return nil
 }
 
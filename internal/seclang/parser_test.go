@@ -7,6 +7,7 @@ import (
 	"bufio"
 	"bytes"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -417,7 +418,7 @@ func TestSelect(t *testing.T) {
 		{
 			name:          "IP",
 			rule:          `SecRule IP:foo "bar" "id:22"`,
-			expectedError: true,
+			expectedError: false,
 		},
 		{
 			name:          "JSON",
@@ -843,3 +844,162 @@ func TestSelect(t *testing.T) {
 		})
 	}
 }
+
+func TestParseErrorPosition(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "child.conf"), []byte(`# child
+SecRule ARGS "@rx abc" \
+	"id:2,phase:2,pass"
+
+SecRule ARGS "@unknown abc" \
+	"id:3,phase:2,pass"
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "parent.conf"), []byte(`SecRuleEngine On
+Include child.conf
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("included file", func(t *testing.T) {
+		p := NewParser(coraza.NewWAF())
+		err := p.FromFile(filepath.Join(dir, "parent.conf"))
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Fatalf("expected a parse error, got %v", err)
+		}
+		if want, have := filepath.Join(dir, "child.conf"), perr.File; want != have {
+			t.Errorf("unexpected file, want %q, have %q", want, have)
+		}
+		if want, have := 5, perr.Line; want != have {
+			t.Errorf("unexpected line, want %d, have %d", want, have)
+		}
+		if want, have := "secrule", perr.Directive; want != have {
+			t.Errorf("unexpected directive, want %q, have %q", want, have)
+		}
+		if !strings.Contains(err.Error(), fmt.Sprintf("%s:5: failed to compile the directive", perr.File)) {
+			t.Errorf("unexpected error message %q", err.Error())
+		}
+	})
+
+	t.Run("inline", func(t *testing.T) {
+		waf := coraza.NewWAF()
+		p := NewParser(waf)
+		if err := p.FromFile(filepath.Join(dir, "child.conf")); err == nil {
+			t.Fatal("expected an error")
+		}
+		// line numbers restart for every string and file
+		err := p.FromString("SecRuleEngine On\nSecFoo bar\n")
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Fatalf("expected a parse error, got %v", err)
+		}
+		if perr.File != "_inline_" || perr.Line != 2 || perr.Directive != "" {
+			t.Errorf("unexpected position %s:%d (%q)", perr.File, perr.Line, perr.Directive)
+		}
+		if rule := waf.Rules.FindByID(2); rule == nil || rule.Line_ != 3 {
+			t.Errorf("expected rule 2 to be defined at line 3 of its file")
+		}
+	})
+}
+
+func TestConfigurationBlocks(t *testing.T) {
+	waf := coraza.NewWAF()
+	p := NewParser(waf)
+	if err := p.FromString(`SecRuleEngine On
+SecDefaultAction "phase:1,log,pass"
+SecRule REQUEST_URI "@contains attack" "id:1,phase:1,block,log"
+SecRule ARGS "@contains evil" "id:2,phase:1,deny,status:403,log"
+SecRule ARGS "@contains bad" "id:3,phase:1,log,tag:noisy"
+
+<VirtualHost example.com *.example.com>
+	SecDefaultAction "phase:1,deny,status:418"
+	SecRuleRemoveByTag noisy
+	SecRuleUpdateTargetById 2 "!ARGS:/^(pass|pwd)$/|!ARGS:Token"
+	<Location /api>
+		SecRuleEngine DetectionOnly
+	</Location>
+</VirtualHost>
+
+<Location "/public">
+	SecRuleRemoveById 2
+	SecRuleUpdateTargetById 1 "!REQUEST_URI"
+</Location>
+`); err != nil {
+		t.Fatal(err)
+	}
+	if len(waf.Scopes) != 3 {
+		t.Fatalf("unexpected number of configuration blocks %d", len(waf.Scopes))
+	}
+	if rules := waf.Rules.Count(); rules != 3 {
+		t.Fatalf("the configuration blocks must not change the rules, have %d", rules)
+	}
+
+	tests := []struct {
+		host, uri string
+		status    int
+		matched   int
+	}{
+		{host: "other.com", uri: "/attack", matched: 1},
+		{host: "example.com", uri: "/attack", status: 418, matched: 1},
+		{host: "www.example.com:8080", uri: "/?q=bad"},
+		{host: "other.com", uri: "/?q=bad", matched: 1},
+		{host: "other.com", uri: "/?q=evil", status: 403, matched: 1},
+		{host: "example.com", uri: "/?q=evil", status: 403, matched: 1},
+		{host: "example.com", uri: "/?pwd=evil&token=evil"},
+		{host: "example.com", uri: "/api/attack", matched: 1},
+		{host: "other.com", uri: "/public/attack?q=evil"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.host+tc.uri, func(t *testing.T) {
+			tx := waf.NewTransaction()
+			defer tx.Close()
+			tx.SetServerName(tc.host)
+			tx.ProcessURI(tc.uri, "GET", "HTTP/1.1")
+			status := 0
+			if it := tx.ProcessRequestHeaders(); it != nil {
+				status = it.Status
+			}
+			if status != tc.status {
+				t.Errorf("unexpected interruption status, want %d, have %d", tc.status, status)
+			}
+			if have := len(tx.MatchedRules()); have != tc.matched {
+				t.Errorf("unexpected number of matched rules, want %d, have %d", tc.matched, have)
+			}
+		})
+	}
+}
+
+func TestConfigurationBlocksErrors(t *testing.T) {
+	tests := map[string]string{
+		"nested virtual hosts":  "<VirtualHost a.com>\n<VirtualHost b.com>\n</VirtualHost>\n</VirtualHost>",
+		"virtual host no hosts": "<VirtualHost>\n</VirtualHost>",
+		"block not closed":      "<VirtualHost a.com\n</VirtualHost>",
+		"nested locations":      "<Location /a>\n<Location /a/b>\n</Location>\n</Location>",
+		"relative location":     "<Location a>\n</Location>",
+		"location no path":      "<Location>\n</Location>",
+		"left open":             "<Location /a>\nSecRuleEngine Off",
+		"unexpected close":      "</Location>",
+		"mismatched close":      "<VirtualHost a.com>\n</Location>",
+		"unknown block":         "<Directory /a>\n</Directory>",
+		"rule inside block":     "<Location /a>\nSecRule ARGS \"@rx a\" \"id:1,phase:1,pass\"\n</Location>",
+		"target added":          "<Location /a>\nSecRuleUpdateTargetById 1 ARGS\n</Location>",
+		"default metadata":      "<Location /a>\nSecDefaultAction \"phase:1,log,deny\"\n</Location>",
+		"default no phase":      "<Location /a>\nSecDefaultAction \"deny\"\n</Location>",
+		"default block":         "<Location /a>\nSecDefaultAction \"phase:1,block\"\n</Location>",
+		"zero body limit":       "<Location /a>\nSecRequestBodyLimit 0\n</Location>",
+	}
+	for name, config := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := NewParser(coraza.NewWAF())
+			if err := p.FromString(config); err == nil {
+				t.Fatal("expected an error")
+			}
+			// a failed configuration does not leave the block open
+			if p.options.Scope != nil {
+				t.Error("unexpected open configuration block")
+			}
+		})
+	}
+}
@@ -62,8 +62,9 @@ func (rp *RuleParser) ParseVariables(vars string) error {
 				// we don't want to miss the last character
 				if curr == 0 {
 					curVar = append(curVar, c)
-				} else if curr != 2 && c != '/' {
-					// we don't want the last slash if it's a regex
+				} else if curr == 3 || (curr != 2 && c != '/') {
+					// we don't want the last slash if it's a regex, xpath
+					// keys are kept as they are
 					curKey = append(curKey, c)
 				}
 			}
@@ -124,7 +125,7 @@ func (rp *RuleParser) ParseVariables(vars string) error {
 			}
 		case 1:
 			switch {
-			case len(curKey) == 0 && (string(curVar) == "XML" || string(curVar) == "JSON"):
+			case len(curKey) == 0 && isXPathVariable(string(curVar)):
 				// We are starting a XPATH
 				curr = 3
 				curKey = append(curKey, c)
@@ -161,6 +162,16 @@ func (rp *RuleParser) ParseVariables(vars string) error {
 	return nil
 }
 
+// isXPathVariable returns true if the keys of the variable are XPath
+// expressions which must not be split on regex delimiters.
+func isXPathVariable(name string) bool {
+	switch name {
+	case "XML", "REQUEST_XML", "RESPONSE_XML", "JSON":
+		return true
+	}
+	return false
+}
+
 // ParseOperator parses a seclang formatted operator string
 // A operator must begin with @ (like @rx), if no operator is specified, rx
 // will be used. Everything after the operator will be used as operator argument
@@ -303,6 +314,12 @@ func (rp *RuleParser) applyParsedActions(act []ruleAction) error {
 		}
 	}
 
+	for _, a := range act {
+		if a.Atype == plugintypes.ActionTypeDisruptive {
+			rp.rule.InheritsDisruptiveAction = a.Key == "block"
+		}
+	}
+
 	// if the rule is missing the phase, the default phase assigned is phase 2 (See NewRule())
 	phase := rp.rule.Phase_
 
@@ -356,6 +373,8 @@ func ParseRule(options RuleOptions) (*corazawaf.Rule, error) {
 	if options.WAF != nil {
 		rule.SetMemoizer(options.WAF.Memoizer())
 	}
+	// until it declares its own, the rule takes the disruptive action of SecDefaultAction
+	rule.InheritsDisruptiveAction = true
 	rp := RuleParser{
 		options:        options,
 		rule:           rule,
@@ -485,6 +485,7 @@ func TestParseRule(t *testing.T) {
 		{"Does not contain escape characters", `ARGS_GET:/(test)/|REQUEST_XML`, 2},
 		{"The last variable contains escape characters", `ARGS_GET|REQUEST_XML:/(test)\b/`, 2},
 		{"Contains escape characters", `ARGS_GET:/(test\b)/|REQUEST_XML`, 2},
+		{"XPath expressions", `XML:/a/b[@c='d']|RESPONSE_XML://e/@f|REQUEST_XML:count(//g)`, 3},
 	}
 
 	for _, tc := range tests {
@@ -504,6 +505,17 @@ func TestParseRule(t *testing.T) {
 	}
 }
 
+func TestInvalidXPathKey(t *testing.T) {
+	for _, vars := range []string{`XML://a[`, `REQUEST_XML:/a/b[1`, `RESPONSE_XML:$a`} {
+		rp := RuleParser{
+			rule: corazawaf.NewRule(),
+		}
+		if err := rp.ParseVariables(vars); err == nil {
+			t.Errorf("expected error for %s", vars)
+		}
+	}
+}
+
 func TestNonSelectableCollection(t *testing.T) {
 	waf := corazawaf.NewWAF()
 	p := NewParser(waf)
@@ -0,0 +1,51 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo && !coraza.disabled_operators.validateSchema
+
+package seclang
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/corazawaf/coraza/v3/internal/corazawaf"
+)
+
+// keepsRawJSONBody returns true if the JSON body processor keeps the raw
+// request body of the transactions of the WAF.
+func keepsRawJSONBody(t *testing.T, waf *corazawaf.WAF) bool {
+	t.Helper()
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.RequestBodyAccess = true
+	tx.AddRequestHeader("Content-Type", "application/json")
+	tx.ProcessRequestHeaders()
+	if _, _, err := tx.WriteRequestBody([]byte(`{"a": 1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ProcessRequestBody(); err != nil {
+		t.Fatal(err)
+	}
+	return len(tx.Variables().TX().Get("json_request_body")) > 0
+}
+
+func TestValidateSchemaKeepsRawJSONBody(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	p.SetRoot(fstest.MapFS{"schema.json": {Data: []byte(`{"type": "object"}`)}})
+
+	if err := p.FromString(`SecRule REQUEST_HEADERS:Content-Type "application/json" "id:1,phase:1,pass,nolog,ctl:requestBodyProcessor=JSON"`); err != nil {
+		t.Fatal(err)
+	}
+	if keepsRawJSONBody(t, waf) {
+		t.Error("unexpected raw JSON body kept without @validateSchema")
+	}
+	if err := p.FromString(`SecRule REQUEST_METHOD "@streq POST" "id:2,phase:2,chain"
+SecRule REQUEST_BODY "!@validateSchema schema.json" ""`); err != nil {
+		t.Fatal(err)
+	}
+	if !keepsRawJSONBody(t, waf) {
+		t.Error("expected the raw JSON body to be kept for @validateSchema")
+	}
+}
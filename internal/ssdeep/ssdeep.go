@@ -0,0 +1,275 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package ssdeep implements the ssdeep context triggered piecewise hashing
+// (CTPH) fuzzy hash, compatible with the hashes and similarity scores of the
+// ssdeep tool (https://ssdeep-project.github.io/ssdeep/).
+package ssdeep
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	rollingWindow  = 7
+	minBlockSize   = 3
+	spamsumLength  = 64
+	numBlockHashes = 31
+	// hashInit is the initial value of the FNV based piece hash, truncated to
+	// the 6 bits used by the base64 alphabet.
+	hashInit = 0x27
+)
+
+const b64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+var errInvalidHash = errors.New("invalid ssdeep hash")
+
+// Digest is an ssdeep hash: the pieces hashed with the block size and with
+// twice the block size.
+type Digest struct {
+	BlockSize uint64
+	Hash1     string
+	Hash2     string
+}
+
+// String returns the hash in the ssdeep format, blocksize:hash1:hash2.
+func (d Digest) String() string {
+	return strconv.FormatUint(d.BlockSize, 10) + ":" + d.Hash1 + ":" + d.Hash2
+}
+
+// Parse parses a hash in the ssdeep format. Anything following a comma, like
+// the file name in the ssdeep tool output, is ignored.
+func Parse(s string) (Digest, error) {
+	s, _, _ = strings.Cut(s, ",")
+	bs, rest, ok := strings.Cut(s, ":")
+	if !ok {
+		return Digest{}, errInvalidHash
+	}
+	blockSize, err := strconv.ParseUint(bs, 10, 64)
+	if err != nil || blockSize < minBlockSize {
+		return Digest{}, errInvalidHash
+	}
+	h1, h2, ok := strings.Cut(rest, ":")
+	if !ok || len(h1) > spamsumLength || len(h2) > spamsumLength {
+		return Digest{}, errInvalidHash
+	}
+	return Digest{BlockSize: blockSize, Hash1: h1, Hash2: h2}, nil
+}
+
+// rollingHash is the Adler-32 like hash of the last rollingWindow bytes,
+// its value selects the boundaries of the hashed pieces.
+type rollingHash struct {
+	window     [rollingWindow]byte
+	h1, h2, h3 uint32
+	n          int
+}
+
+func (r *rollingHash) update(c byte) {
+	r.h2 -= r.h1
+	r.h2 += rollingWindow * uint32(c)
+	r.h1 += uint32(c)
+	r.h1 -= uint32(r.window[r.n])
+	r.window[r.n] = c
+	r.n = (r.n + 1) % rollingWindow
+	r.h3 <<= 5
+	r.h3 ^= uint32(c)
+}
+
+func (r *rollingHash) sum() uint32 {
+	return r.h1 + r.h2 + r.h3
+}
+
+// sumHash is the FNV hash step, only the 6 bits used by the base64 alphabet
+// are kept.
+func sumHash(c byte, h byte) byte {
+	return (h*0x13 ^ c) & 0x3f
+}
+
+// blockHash is the state of the pieces of a block size. halfH and halfDigest
+// are the last piece when the digest is truncated to spamsumLength/2.
+type blockHash struct {
+	digest     [spamsumLength]byte
+	dindex     int
+	h, halfH   byte
+	halfDigest byte
+}
+
+// Sum returns the ssdeep hash of data.
+func Sum(data []byte) Digest {
+	var (
+		roll  rollingHash
+		bh    [numBlockHashes]blockHash
+		bhEnd = 1
+	)
+	bh[0].h, bh[0].halfH = hashInit, hashInit
+
+	for _, c := range data {
+		roll.update(c)
+		rh := roll.sum()
+		for i := 0; i < bhEnd; i++ {
+			bh[i].h = sumHash(c, bh[i].h)
+			bh[i].halfH = sumHash(c, bh[i].halfH)
+		}
+		for i := 0; i < bhEnd; i++ {
+			bs := uint32(minBlockSize) << i
+			// a boundary of a block size is a boundary of the smaller ones
+			if rh%bs != bs-1 {
+				break
+			}
+			b := &bh[i]
+			if b.dindex == 0 && bhEnd < numBlockHashes {
+				// first boundary of this block size, start tracking the next
+				// one, which had the same pieces so far
+				bh[bhEnd] = blockHash{h: b.h, halfH: b.halfH}
+				bhEnd++
+			}
+			b.digest[b.dindex] = b64[b.h]
+			b.halfDigest = b64[b.halfH]
+			if b.dindex < spamsumLength-1 {
+				b.dindex++
+				b.digest[b.dindex] = 0
+				b.h = hashInit
+				if b.dindex < spamsumLength/2 {
+					b.halfH = hashInit
+					b.halfDigest = 0
+				}
+			}
+		}
+	}
+
+	// the block size is the smallest one producing at most spamsumLength
+	// pieces for the input size, reduced while it produced less than half
+	bi := 0
+	for uint64(minBlockSize)<<bi*spamsumLength < uint64(len(data)) && bi < numBlockHashes-1 {
+		bi++
+	}
+	if bi >= bhEnd {
+		bi = bhEnd - 1
+	}
+	for bi > 0 && bh[bi].dindex < spamsumLength/2 {
+		bi--
+	}
+
+	rh := roll.sum()
+	d := Digest{BlockSize: uint64(minBlockSize) << bi}
+	b := &bh[bi]
+	h1 := append([]byte{}, b.digest[:b.dindex]...)
+	if rh != 0 {
+		h1 = append(h1, b64[b.h])
+	} else if b.digest[b.dindex] != 0 {
+		h1 = append(h1, b.digest[b.dindex])
+	}
+	d.Hash1 = string(h1)
+
+	if bi < bhEnd-1 {
+		b = &bh[bi+1]
+		n := min(b.dindex, spamsumLength/2-1)
+		h2 := append([]byte{}, b.digest[:n]...)
+		if rh != 0 {
+			h2 = append(h2, b64[b.halfH])
+		} else if b.halfDigest != 0 {
+			h2 = append(h2, b.halfDigest)
+		}
+		d.Hash2 = string(h2)
+	} else if rh != 0 {
+		d.Hash2 = string(b64[b.h])
+	}
+	return d
+}
+
+// Compare returns the similarity of two hashes, from 0 for unrelated inputs
+// to 100 for identical or nearly identical ones. Only hashes with the same
+// block size or block sizes differing by a factor of two can be compared,
+// the score of the others is 0.
+func Compare(a, b Digest) int {
+	if a.BlockSize != b.BlockSize && a.BlockSize*2 != b.BlockSize && a.BlockSize != b.BlockSize*2 {
+		return 0
+	}
+	a1, a2 := eliminateSequences(a.Hash1), eliminateSequences(a.Hash2)
+	b1, b2 := eliminateSequences(b.Hash1), eliminateSequences(b.Hash2)
+
+	switch {
+	case a.BlockSize == b.BlockSize:
+		if a1 == b1 && a2 == b2 {
+			return 100
+		}
+		return max(scoreStrings(a1, b1, a.BlockSize), scoreStrings(a2, b2, a.BlockSize*2))
+	case a.BlockSize*2 == b.BlockSize:
+		return scoreStrings(b1, a2, b.BlockSize)
+	default:
+		return scoreStrings(a1, b2, a.BlockSize)
+	}
+}
+
+// eliminateSequences reduces the runs of more than three identical
+// characters to three, they carry little information.
+func eliminateSequences(s string) string {
+	res := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if i >= 3 && s[i] == s[i-1] && s[i] == s[i-2] && s[i] == s[i-3] {
+			continue
+		}
+		res = append(res, s[i])
+	}
+	return string(res)
+}
+
+func scoreStrings(s1, s2 string, blockSize uint64) int {
+	if !hasCommonSubstring(s1, s2) {
+		return 0
+	}
+	// the edit distance is scaled by the length of the strings to measure
+	// the proportion of the input that changed, then rescaled from 0 to 100
+	score := uint64(editDistance(s1, s2)) * spamsumLength / uint64(len(s1)+len(s2))
+	score = 100 - 100*score/spamsumLength
+	// small block sizes don't exaggerate the matches of short inputs
+	if blockSize >= (99+rollingWindow)/rollingWindow*minBlockSize {
+		return int(score)
+	}
+	if limit := blockSize / minBlockSize * uint64(min(len(s1), len(s2))); score > limit {
+		score = limit
+	}
+	return int(score)
+}
+
+// hasCommonSubstring reports whether the strings share a substring of
+// rollingWindow characters, unrelated hashes are not scored.
+func hasCommonSubstring(s1, s2 string) bool {
+	if len(s1) < rollingWindow || len(s2) < rollingWindow {
+		return false
+	}
+	substrings := make(map[string]struct{}, len(s1)-rollingWindow+1)
+	for i := 0; i+rollingWindow <= len(s1); i++ {
+		substrings[s1[i:i+rollingWindow]] = struct{}{}
+	}
+	for i := 0; i+rollingWindow <= len(s2); i++ {
+		if _, ok := substrings[s2[i:i+rollingWindow]]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance returns the edit distance of the strings, insertions and
+// deletions costing 1 and substitutions 2.
+func editDistance(s1, s2 string) int {
+	prev := make([]int, len(s2)+1)
+	cur := make([]int, len(s2)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s1); i++ {
+		cur[0] = i
+		for j := 1; j <= len(s2); j++ {
+			cost := prev[j-1]
+			if s1[i-1] != s2[j-1] {
+				cost += 2
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(s2)]
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package ssdeep
+
+import "testing"
+
+// testInput returns n pseudo random bytes generated with xorshift32.
+func testInput(n int, seed uint32) []byte {
+	b := make([]byte, n)
+	x := seed
+	for i := range b {
+		x ^= x << 13
+		x ^= x >> 17
+		x ^= x << 5
+		b[i] = byte(x)
+	}
+	return b
+}
+
+func TestSum(t *testing.T) {
+	// the hashes were generated with the ssdeep tool
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{
+			data: testInput(65536, 2),
+			want: "1536:1gC+gMbTY4o8bEhSfOlWOQOyCnh9amg1tIC1dLwLHfFs1IXuCUe:1gTvTY4o8bsSfaWOQuhHqhAfSunr",
+		},
+		{
+			data: testInput(1<<20, 3),
+			want: "12288:TMTXM5eU9lzF5l3oCVdCcVfufCIxrf/Dx0ZhGhiHsrVtCjU2gP043C0U81XhM3wv:F5X9lzFj3J5VfuNF0+hiH8Viz4Q819uI",
+		},
+		{
+			// the rolling hash is 0 at the end of the input
+			data: append(testInput(32768, 2), make([]byte, 32768)...),
+			want: "768:1hs7Ax1+gMwXq3N+EY4i9l8bGlPJnggo1ROlN5WOQOyCn2Pt:1gC+gMbTY4o8bEhSfOlWOQOyCn",
+		},
+	}
+	for _, tc := range tests {
+		if got := Sum(tc.data).String(); got != tc.want {
+			t.Errorf("unexpected hash of %d bytes, want %q, have %q", len(tc.data), tc.want, got)
+		}
+	}
+
+	if got := Sum(nil).String(); got != "3::" {
+		t.Errorf("unexpected hash of empty input %q", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	d, err := Parse(`1536:1gC+gMbTY4o8bEhSfOlWOQOyCnh9amg1tIC1dLwLHfFs1IXuCUe:1gTvTY4o8bsSfaWOQuhHqhAfSunr,"/tmp/shell.php"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.BlockSize != 1536 || d.Hash1 != "1gC+gMbTY4o8bEhSfOlWOQOyCnh9amg1tIC1dLwLHfFs1IXuCUe" || d.Hash2 != "1gTvTY4o8bsSfaWOQuhHqhAfSunr" {
+		t.Errorf("unexpected digest %+v", d)
+	}
+
+	for _, s := range []string{
+		"",
+		"1536",
+		"1536:abc",
+		"foo:abc:def",
+		"1:abc:def",
+		"3:" + string(make([]byte, spamsumLength+1)) + ":abc",
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("expected an error parsing %q", s)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	mustParse := func(s string) Digest {
+		t.Helper()
+		d, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	// the scores were computed with the ssdeep tool
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{
+			a:    "192:MUPMinqP6+wNQ7Q40L/iB3n2rIBrP0GZKF4jsef+0FVQLSwbLbj41iH8nFVYv980:x0CllivQiFmt",
+			b:    "192:JkjRcePWsNVQza3ntZStn5VfsoXMhRD9+xJMinqF6+wNQ7Q40L/i737rPVt:JkjlQyIrx+kll2",
+			want: 35,
+		},
+		{
+			a:    "196608:pDSC8olnoL1v/uawvbQD7XlZUFYzYyMb615NktYHF7dREN/JNnQrmhnUPI+/n2Yr:5DHoJXv7XOq7Mb2TwYHXREN/3QrmktPd",
+			b:    "196608:7DSC8olnoL1v/uawvbQD7XlZUFYzYyMb615NktYHF7dREN/JNnQrmhnUPI+/n2Y7:3DHoJXv7XOq7Mb2TwYHXREN/3QrmktPt",
+			want: 97,
+		},
+		{
+			a:    "24:YDVLfsT1ds/1H9Wpgq7n4XMijV6h4Z3QCw4qat:YD51H9CiMuV6uACwVat",
+			b:    "24:YDVLfyvDj+C+opg8DV0Mdle6hPZ3QCw4qat:YDMvDj+C+kBOM+6HACwVat",
+			want: 54,
+		},
+		{
+			a:    "24:YDVLfsT1ds/1H9Wpgq7n4XMijV6h4Z3QCw4qat:YD51H9CiMuV6uACwVat",
+			b:    "24:YDVLfsT1ds/1H9Wpgq7n4XMijV6h4Z3QCw4qat:YD51H9CiMuV6uACwVat",
+			want: 100,
+		},
+		{
+			// block sizes differing by more than a factor of two
+			a:    "24:YDVLfsT1ds/1H9Wpgq7n4XMijV6h4Z3QCw4qat:YD51H9CiMuV6uACwVat",
+			b:    "96:YDVLfsT1ds/1H9Wpgq7n4XMijV6h4Z3QCw4qat:YD51H9CiMuV6uACwVat",
+			want: 0,
+		},
+		{
+			// no common substring
+			a:    "1536:1gC+gMbTY4o8bEhSfOlWOQOyCnh9amg1tIC1dLwLHfFs1IXuCUe:1gTvTY4o8bsSfaWOQuhHqhAfSunr",
+			b:    "1536:TMTXM5eU9lzF5l3oCVdCcVfufCIxrf/Dx0ZhGhiHsrVtCjU2gP043C0U81XhM3wv:F5X9lzFj3J5VfuNF0+hiH8Viz4Q819uI",
+			want: 0,
+		},
+	}
+	for _, tc := range tests {
+		a, b := mustParse(tc.a), mustParse(tc.b)
+		if got := Compare(a, b); got != tc.want {
+			t.Errorf("unexpected score of %q and %q, want %d, have %d", tc.a, tc.b, tc.want, got)
+		}
+		if got := Compare(b, a); got != tc.want {
+			t.Errorf("unexpected score of %q and %q, want %d, have %d", tc.b, tc.a, tc.want, got)
+		}
+	}
+}
+
+func TestCompareModifiedInput(t *testing.T) {
+	data := testInput(65536, 2)
+	modified := append([]byte{}, data...)
+	copy(modified[30000:], "<?php system($_GET['cmd']); ?>")
+
+	if got := Compare(Sum(data), Sum(modified)); got != 99 {
+		t.Errorf("unexpected score %d", got)
+	}
+}
+
+func TestEliminateSequences(t *testing.T) {
+	tests := map[string]string{
+		"":           "",
+		"abc":        "abc",
+		"aaaa":       "aaa",
+		"aaaaaabccc": "aaabccc",
+		"xAAAAAyBBB": "xAAAyBBB",
+	}
+	for in, want := range tests {
+		if got := eliminateSequences(in); got != want {
+			t.Errorf("unexpected result for %q, want %q, have %q", in, want, got)
+		}
+	}
+}
@@ -312,12 +312,15 @@ const (
 	// SecRule STATUS_LINE "@contains 500" "phase:3,id:49,log,pass,logdata:'Application error detected!',t:none"
 	// ```
 	//
-	// **Note:** This variable is currently NOT implemented by Coraza, but only kept for compatibility.
+	// **Note:** The status line is built from the protocol and the status code passed to
+	// ProcessResponseHeaders, the reason phrase is the standard one for the status code.
 	StatusLine
 	// Description: Contains the number of microseconds elapsed since the beginning of the
-	// current transaction.
-	//
-	// **Note:** This variable is currently NOT implemented by Coraza, but only kept for compatibility.
+	// current transaction. The value is updated every time the variable is read.
+	// ---
+	// ```seclang
+	// SecRule DURATION "@gt 500000" "phase:5,id:98,log,pass,msg:'Slow transaction: %{DURATION}us'"
+	// ```
 	Duration
 	// Description: Collection of the response header names.
 	// ---
@@ -460,16 +463,16 @@ const (
 	ResponseHeaders // CanBeSelected
 	// Description: Contains the name of the currently used response body processor (e.g., XML).
 	ResBodyProcessor
-	// Description: Collection intended to be populated by the @geoLookup operator with
-	// geographical data for a given IP address. Fields include COUNTRY_CODE, COUNTRY_NAME,
-	// COUNTRY_CONTINENT, REGION, CITY, POSTAL_CODE, LATITUDE, LONGITUDE.
+	// Description: Collection populated by the @geoLookup operator with geographical data
+	// for a given IP address, using the databases configured with SecGeoLookupDb. Fields
+	// include COUNTRY_CODE, COUNTRY_CODE3, COUNTRY_NAME, CONTINENT_CODE, REGION, CITY,
+	// POSTAL_CODE, LATITUDE, LONGITUDE and ASN.
 	// ---
 	// ```seclang
+	// SecGeoLookupDb /usr/share/GeoIP/GeoLite2-City.mmdb
 	// SecRule REMOTE_ADDR "@geoLookup" "phase:1,id:22,nolog,pass"
 	// SecRule GEO:COUNTRY_CODE "!@streq GB" "id:23,deny,log,msg:'Non-GB IP address'"
 	// ```
-	//
-	// **Note:** Requires coraza-geoip plugin.
 	Geo // CanBeSelected
 	// Description: This variable is a collection of the names of all request cookies. For
 	// example, the following rule will trigger if the JSESSIONID cookie is not present:
@@ -546,14 +549,22 @@ const (
 	// ResponseArgs contains the response parsed arguments
 	ResponseArgs // CanBeSelected
 	// Description: Collection for interacting with the response XML body via XPath expressions.
-	//
-	// **Not Implemented yet**
+	// Populated when the response body processor is XML, keys are evaluated like the ones of XML.
+	// ---
+	// ```seclang
+	// SecRule RESPONSE_HEADERS:Content-Type "@beginsWith text/xml" "phase:3,id:89,nolog,pass,ctl:responseBodyProcessor=XML"
+	// SecRule RESPONSE_XML://card/number "@rx ^\d{16}$" "phase:4,id:90,deny,log"
+	// ```
 	ResponseXML // CanBeSelected
-	// RequestXML contains the request body parsed as XML. Populated by the XML body processor.
+	// RequestXML contains the request body parsed as XML. Populated by the XML body processor,
+	// keys are evaluated like the ones of XML.
 	RequestXML // CanBeSelected
 	// Description: Special collection used to interact with the XML parser. It must contain a
 	// valid XPath expression, which will then be evaluated against a previously parsed XML DOM
-	// tree. Requires the XML body processor to be active.
+	// tree. Requires the XML body processor to be active. XPath 1.0 is supported, except for
+	// variable references, and namespace prefixes are ignored when matching names. The keys
+	// //@* and /* keep returning all the attribute values and all the text contents.
+	// The size of the parsed documents is bounded by SecXmlDepthLimit and SecXmlNodeLimit.
 	// ---
 	// ```seclang
 	// SecRule REQUEST_HEADERS:Content-Type "^text/xml$" "phase:1,id:87,t:lowercase,nolog,pass,ctl:requestBodyProcessor=XML"
@@ -652,6 +663,107 @@ const (
 	// SecRule TIME_YEAR "^2006$" "id:81"
 	// ```
 	TimeYear
+	// Description: Contains the value set with setsid. See SESSION for a
+	// complete example.
+	Sessionid
+	// Description: Contains the value set with setuid.
+	// ---
+	// ```seclang
+	// # Initialize user tracking
+	// SecAction "nolog,id:84,pass,setuid:%{REMOTE_USER}"
+	//
+	// # Is the current user the administrator?
+	// SecRule USERID "admin" "id:85"
+	// ```
+	Userid
+	// Description: Persistent collection that holds data about the client IP address. It has
+	// to be initialized with initcol before it can be used, and changes made with setvar are
+	// kept across transactions until the record times out (see SecCollectionTimeout).
+	// Besides user defined variables, every persistent collection exposes the built-in keys
+	// KEY, CREATE_TIME, LAST_UPDATE_TIME, TIMEOUT, UPDATE_COUNTER, UPDATE_RATE and IS_NEW.
+	// ---
+	// ```seclang
+	// SecAction "phase:1,id:86,nolog,pass,initcol:ip=%{REMOTE_ADDR}"
+	// SecRule IP:blocked "@eq 1" "phase:1,id:87,deny,log,msg:'IP address blocked'"
+	// SecRule REQUEST_FILENAME "@streq /login" "phase:1,id:88,nolog,pass,setvar:ip.attempts=+1,expirevar:ip.attempts=60"
+	// SecRule IP:attempts "@gt 10" "phase:1,id:89,deny,log,setvar:ip.blocked=1,expirevar:ip.blocked=300"
+	// ```
+	IP // CanBeSelected
+	// Description: Persistent collection that holds session data. It is initialized with the
+	// setsid action, and its records are separated per SecWebAppId.
+	// ---
+	// ```seclang
+	// # Initialize session storage
+	// SecRule REQUEST_COOKIES:PHPSESSID "!^$" "phase:1,id:90,nolog,pass,setsid:%{REQUEST_COOKIES.PHPSESSID}"
+	//
+	// # Increment the session score on a match
+	// SecRule ARGS "attack" "phase:2,id:91,pass,setvar:session.score=+10"
+	//
+	// # Block sessions over the threshold
+	// SecRule SESSION:score "@gt 50" "phase:2,id:92,deny,log"
+	// ```
+	Session // CanBeSelected
+	// Description: Persistent collection that holds user data. It is initialized with the
+	// setuid action, and its records are separated per SecWebAppId.
+	// ---
+	// ```seclang
+	// SecAction "phase:2,id:93,nolog,pass,setuid:%{ARGS.username}"
+	// SecRule USER:failed_logins "@gt 3" "phase:2,id:94,deny,log"
+	// ```
+	User // CanBeSelected
+	// Description: Persistent collection shared by all the transactions. It is initialized with
+	// initcol, and the key is only used to name the record.
+	// ---
+	// ```seclang
+	// SecAction "phase:1,id:95,nolog,pass,initcol:global=global"
+	// ```
+	Global // CanBeSelected
+	// Description: Persistent collection that holds data about a resource, usually keyed by
+	// the requested file name. It is initialized with initcol, and its records are separated
+	// per SecWebAppId.
+	// ---
+	// ```seclang
+	// SecAction "phase:1,id:96,nolog,pass,initcol:resource=%{REQUEST_FILENAME}"
+	// ```
+	Resource // CanBeSelected
+	// Description: This variable holds the IP address of the peer the connection was received
+	// from. It is the same as REMOTE_ADDR unless the connector resolves the client address
+	// from the headers set by a trusted proxy, in which case it holds the address of the proxy.
+	// ---
+	// ```seclang
+	// SecRule PEER_ADDR "!@ipMatch 10.0.0.0/8" "phase:1,id:97,deny,log,msg:'Request not coming from the load balancers'"
+	// ```
+	PeerAddr
+	// Description: Collection of the time, in microseconds, spent evaluating the rules which
+	// reached the SecRulePerfTime threshold, keyed by rule ID. The time includes the
+	// transformations, the operator and the actions of the rule and of its chained rules.
+	// Empty unless SecRulePerfTime is set.
+	// ---
+	// ```seclang
+	// SecRulePerfTime 1000
+	// SecRule PERF_RULES "@gt 10000" "phase:5,id:99,log,pass,msg:'Slow rule %{MATCHED_VAR_NAME}: %{MATCHED_VAR}us'"
+	// ```
+	PerfRules // CanBeSelected
+	// Description: Contains the time, in microseconds, spent in the request headers phase.
+	PerfPhase1
+	// Description: Contains the time, in microseconds, spent in the request body phase.
+	PerfPhase2
+	// Description: Contains the time, in microseconds, spent in the response headers phase.
+	PerfPhase3
+	// Description: Contains the time, in microseconds, spent in the response body phase.
+	PerfPhase4
+	// Description: Contains the time, in microseconds, spent in the logging phase.
+	PerfPhase5
+	// Description: Contains the time, in microseconds, spent in all the phases so far.
+	// ---
+	// ```seclang
+	// SecRule PERF_COMBINED "@gt 20000" "phase:5,id:100,log,pass,msg:'Slow inspection: %{PERF_COMBINED}us'"
+	// ```
+	PerfCombined
+	// Description: Contains all the phase timings, in microseconds, in the format of the
+	// audit log stopwatch: combined=<us>, p1=<us>, p2=<us>, p3=<us>, p4=<us>, p5=<us>. It is
+	// meant to be logged rather than matched.
+	PerfAll
 
 	// Unsupported variables. Variables comments are not starting with "Description" so that they are not
 	// included in the documentation.
@@ -691,19 +803,4 @@ const (
 	// example, in the URI /index.php/123, /123 is the path info.) Available only in embedded
 	// deployments.
 	PathInfo
-	// Contains the value set with setsid. See SESSION for a
-	// complete example.
-	Sessionid
-	// Contains the value set with setuid.
-	// ---
-	// ```seclang
-	// # Initialize user tracking
-	// SecAction "nolog,id:84,pass,setuid:%{REMOTE_USER}"
-	//
-	// # Is the current user the administrator?
-	// SecRule USERID "admin" "id:85"
-	// ```
-	Userid
-	// IP is kept for compatibility
-	IP
 )
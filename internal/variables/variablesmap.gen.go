@@ -186,6 +186,38 @@ func (v RuleVariable) Name() string {
 		return "TIME_WDAY"
 	case TimeYear:
 		return "TIME_YEAR"
+	case Sessionid:
+		return "SESSIONID"
+	case Userid:
+		return "USERID"
+	case IP:
+		return "IP"
+	case Session:
+		return "SESSION"
+	case User:
+		return "USER"
+	case Global:
+		return "GLOBAL"
+	case Resource:
+		return "RESOURCE"
+	case PeerAddr:
+		return "PEER_ADDR"
+	case PerfRules:
+		return "PERF_RULES"
+	case PerfPhase1:
+		return "PERF_PHASE1"
+	case PerfPhase2:
+		return "PERF_PHASE2"
+	case PerfPhase3:
+		return "PERF_PHASE3"
+	case PerfPhase4:
+		return "PERF_PHASE4"
+	case PerfPhase5:
+		return "PERF_PHASE5"
+	case PerfCombined:
+		return "PERF_COMBINED"
+	case PerfAll:
+		return "PERF_ALL"
 	case AuthType:
 		return "AUTH_TYPE"
 	case FullRequest:
@@ -218,12 +250,6 @@ func (v RuleVariable) Name() string {
 		return "MULTIPART_UNMATCHED_BOUNDARY"
 	case PathInfo:
 		return "PATH_INFO"
-	case Sessionid:
-		return "SESSIONID"
-	case Userid:
-		return "USERID"
-	case IP:
-		return "IP"
 
 	default:
 		return "INVALID_VARIABLE"
@@ -297,6 +323,18 @@ func (v RuleVariable) CanBeSelected() bool {
 		return true
 	case MultipartPartHeaders:
 		return true
+	case IP:
+		return true
+	case Session:
+		return true
+	case User:
+		return true
+	case Global:
+		return true
+	case Resource:
+		return true
+	case PerfRules:
+		return true
 	default:
 		return false
 	}
@@ -389,6 +427,22 @@ var rulemapRev = map[string]RuleVariable{
 	"TIME_SEC":                         TimeSec,
 	"TIME_WDAY":                        TimeWday,
 	"TIME_YEAR":                        TimeYear,
+	"SESSIONID":                        Sessionid,
+	"USERID":                           Userid,
+	"IP":                               IP,
+	"SESSION":                          Session,
+	"USER":                             User,
+	"GLOBAL":                           Global,
+	"RESOURCE":                         Resource,
+	"PEER_ADDR":                        PeerAddr,
+	"PERF_RULES":                       PerfRules,
+	"PERF_PHASE1":                      PerfPhase1,
+	"PERF_PHASE2":                      PerfPhase2,
+	"PERF_PHASE3":                      PerfPhase3,
+	"PERF_PHASE4":                      PerfPhase4,
+	"PERF_PHASE5":                      PerfPhase5,
+	"PERF_COMBINED":                    PerfCombined,
+	"PERF_ALL":                         PerfAll,
 	"AUTH_TYPE":                        AuthType,
 	"FULL_REQUEST":                     FullRequest,
 	"MULTIPART_BOUNDARY_QUOTED":        MultipartBoundaryQuoted,
@@ -405,9 +459,6 @@ var rulemapRev = map[string]RuleVariable{
 	"MULTIPART_STRICT_ERROR":           MultipartStrictError,
 	"MULTIPART_UNMATCHED_BOUNDARY":     MultipartUnmatchedBoundary,
 	"PATH_INFO":                        PathInfo,
-	"SESSIONID":                        Sessionid,
-	"USERID":                           Userid,
-	"IP":                               IP,
 }
 
 var errUnknownVariable = errors.New("unknown variable")
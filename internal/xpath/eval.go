@@ -0,0 +1,429 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package xpath
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Values produced while evaluating expressions are node-sets ([]*Node in
+// document order), strings, numbers (float64) or booleans.
+
+type context struct {
+	node *Node
+	pos  int
+	size int
+}
+
+func eval(e expr, ctx context) any {
+	switch e := e.(type) {
+	case *literalExpr:
+		return e.s
+	case *numberExpr:
+		return e.f
+	case *negExpr:
+		return -toNumber(eval(e.e, ctx))
+	case *unionExpr:
+		left, _ := eval(e.left, ctx).([]*Node)
+		right, _ := eval(e.right, ctx).([]*Node)
+		return documentOrder(append(append([]*Node{}, left...), right...))
+	case *binaryExpr:
+		return evalBinary(e, ctx)
+	case *funcExpr:
+		return e.fn.call(ctx, e.args)
+	case *filterExpr:
+		nodes, ok := eval(e.primary, ctx).([]*Node)
+		if !ok {
+			return []*Node{}
+		}
+		for _, p := range e.predicates {
+			nodes = filter(nodes, p, false)
+		}
+		return nodes
+	case *pathExpr:
+		return evalPath(e, ctx)
+	}
+	panic(fmt.Sprintf("unexpected xpath expression %T", e))
+}
+
+func evalBinary(e *binaryExpr, ctx context) any {
+	switch e.op {
+	case "or":
+		return toBoolean(eval(e.left, ctx)) || toBoolean(eval(e.right, ctx))
+	case "and":
+		return toBoolean(eval(e.left, ctx)) && toBoolean(eval(e.right, ctx))
+	case "=", "!=", "<", "<=", ">", ">=":
+		return compare(e.op, eval(e.left, ctx), eval(e.right, ctx))
+	}
+
+	left, right := toNumber(eval(e.left, ctx)), toNumber(eval(e.right, ctx))
+	switch e.op {
+	case "+":
+		return left + right
+	case "-":
+		return left - right
+	case "*":
+		return left * right
+	case "div":
+		return left / right
+	default: // mod
+		return math.Mod(left, right)
+	}
+}
+
+// compare implements the comparison rules of XPath 1.0, where comparisons
+// involving node-sets are true if any of their nodes satisfies them.
+func compare(op string, left, right any) bool {
+	leftNodes, leftIsNodes := left.([]*Node)
+	rightNodes, rightIsNodes := right.([]*Node)
+
+	switch {
+	case leftIsNodes && rightIsNodes:
+		for _, l := range leftNodes {
+			lv := l.StringValue()
+			for _, r := range rightNodes {
+				if compareAtomic(op, lv, r.StringValue()) {
+					return true
+				}
+			}
+		}
+		return false
+	case leftIsNodes:
+		return compareNodes(op, leftNodes, right, false)
+	case rightIsNodes:
+		return compareNodes(op, rightNodes, left, true)
+	}
+	return compareAtomic(op, left, right)
+}
+
+// compareNodes compares every node of a node-set with an atomic value. If
+// swapped, the node-set is the right operand.
+func compareNodes(op string, nodes []*Node, v any, swapped bool) bool {
+	if b, ok := v.(bool); ok {
+		l, r := len(nodes) > 0, b
+		if swapped {
+			l, r = r, l
+		}
+		return compareAtomic(op, l, r)
+	}
+	for _, n := range nodes {
+		var nv any = n.StringValue()
+		if _, ok := v.(float64); ok {
+			nv = toNumber(nv)
+		}
+		l, r := nv, v
+		if swapped {
+			l, r = r, l
+		}
+		if compareAtomic(op, l, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareAtomic(op string, left, right any) bool {
+	if op == "=" || op == "!=" {
+		var eq bool
+		_, lb := left.(bool)
+		_, rb := right.(bool)
+		_, ln := left.(float64)
+		_, rn := right.(float64)
+		switch {
+		case lb || rb:
+			eq = toBoolean(left) == toBoolean(right)
+		case ln || rn:
+			eq = toNumber(left) == toNumber(right)
+		default:
+			eq = toString(left) == toString(right)
+		}
+		return eq == (op == "=")
+	}
+
+	l, r := toNumber(left), toNumber(right)
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	default:
+		return l >= r
+	}
+}
+
+func evalPath(e *pathExpr, ctx context) any {
+	var nodes []*Node
+	switch {
+	case e.filter != nil:
+		v, ok := eval(e.filter, ctx).([]*Node)
+		if !ok {
+			return []*Node{}
+		}
+		nodes = v
+	case e.absolute:
+		nodes = []*Node{ctx.node.root()}
+	default:
+		nodes = []*Node{ctx.node}
+	}
+
+	for i := range e.steps {
+		s := &e.steps[i]
+		var res []*Node
+		for _, n := range nodes {
+			selected := selectAxis(n, s.axis, s.test)
+			for _, p := range s.predicates {
+				selected = filter(selected, p, s.axis.isReverse())
+			}
+			res = append(res, selected...)
+		}
+		nodes = documentOrder(res)
+	}
+	if nodes == nil {
+		nodes = []*Node{}
+	}
+	return nodes
+}
+
+// filter keeps the nodes satisfying the predicate. Nodes are in axis order,
+// so the proximity positions of reverse axes count from the end.
+func filter(nodes []*Node, predicate expr, reverse bool) []*Node {
+	res := nodes[:0:0]
+	size := len(nodes)
+	for i, n := range nodes {
+		pos := i + 1
+		if reverse {
+			pos = size - i
+		}
+		v := eval(predicate, context{node: n, pos: pos, size: size})
+		if f, ok := v.(float64); ok {
+			if f == float64(pos) {
+				res = append(res, n)
+			}
+			continue
+		}
+		if toBoolean(v) {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// selectAxis returns the nodes of the axis of n matching the test, in
+// document order.
+func selectAxis(n *Node, a axis, test nodeTest) []*Node {
+	var res []*Node
+	add := func(c *Node) {
+		if test.matches(c, a) {
+			res = append(res, c)
+		}
+	}
+
+	switch a {
+	case axisChild:
+		for _, c := range n.Children {
+			add(c)
+		}
+	case axisDescendant:
+		walkDescendants(n, add)
+	case axisDescendantOrSelf:
+		add(n)
+		walkDescendants(n, add)
+	case axisParent:
+		if n.Parent != nil {
+			add(n.Parent)
+		}
+	case axisAncestor, axisAncestorOrSelf:
+		if a == axisAncestorOrSelf {
+			add(n)
+		}
+		for p := n.Parent; p != nil; p = p.Parent {
+			add(p)
+		}
+		reverse(res)
+	case axisFollowingSibling:
+		if siblings := n.siblings(); siblings != nil {
+			for _, c := range siblings[n.index+1:] {
+				add(c)
+			}
+		}
+	case axisPrecedingSibling:
+		if siblings := n.siblings(); siblings != nil {
+			for _, c := range siblings[:n.index] {
+				add(c)
+			}
+		}
+	case axisFollowing:
+		// The nodes after n in document order, excluding its descendants
+		if n.Type == AttributeNode && n.Parent != nil {
+			walkDescendants(n.Parent, add)
+		}
+		for c := n; c.Parent != nil; c = c.Parent {
+			if c.Type == AttributeNode {
+				continue
+			}
+			for _, s := range c.Parent.Children[c.index+1:] {
+				add(s)
+				walkDescendants(s, add)
+			}
+		}
+		sortDocumentOrder(res)
+	case axisPreceding:
+		// The nodes before n in document order, excluding its ancestors
+		for c := n; c.Parent != nil; c = c.Parent {
+			if c.Type == AttributeNode {
+				continue
+			}
+			for _, s := range c.Parent.Children[:c.index] {
+				add(s)
+				walkDescendants(s, add)
+			}
+		}
+		sortDocumentOrder(res)
+	case axisAttribute:
+		for _, c := range n.Attributes {
+			add(c)
+		}
+	case axisSelf:
+		add(n)
+	}
+	return res
+}
+
+func walkDescendants(n *Node, f func(*Node)) {
+	for _, c := range n.Children {
+		f(c)
+		walkDescendants(c, f)
+	}
+}
+
+func (t nodeTest) matches(n *Node, a axis) bool {
+	switch t.kind {
+	case testNode:
+		return true
+	case testText:
+		return n.Type == TextNode
+	case testComment:
+		return n.Type == CommentNode
+	case testPI:
+		return n.Type == ProcessingInstructionNode && (t.name == "" || t.name == n.Name)
+	}
+	principal := ElementNode
+	if a == axisAttribute {
+		principal = AttributeNode
+	}
+	return n.Type == principal && (t.name == "" || t.name == n.Name)
+}
+
+func reverse(nodes []*Node) {
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+}
+
+func sortDocumentOrder(nodes []*Node) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].order < nodes[j].order })
+}
+
+// documentOrder sorts the nodes in document order removing duplicates.
+func documentOrder(nodes []*Node) []*Node {
+	sortDocumentOrder(nodes)
+	res := nodes[:0]
+	for i, n := range nodes {
+		if i == 0 || n != nodes[i-1] {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+func toBoolean(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	case []*Node:
+		return len(v) > 0
+	}
+	return false
+}
+
+func toNumber(v any) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case string:
+		return stringToNumber(v)
+	case []*Node:
+		return stringToNumber(toString(v))
+	}
+	return math.NaN()
+}
+
+// stringToNumber parses an optional minus sign followed by a decimal
+// number, other strings are NaN.
+func stringToNumber(s string) float64 {
+	s = strings.TrimSpace(s)
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" || digits == "." {
+		return math.NaN()
+	}
+	for i := 0; i < len(digits); i++ {
+		if !isDigit(digits[i]) && digits[i] != '.' {
+			return math.NaN()
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+func toString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case float64:
+		return numberToString(v)
+	case []*Node:
+		if len(v) == 0 {
+			return ""
+		}
+		return v[0].StringValue()
+	}
+	return ""
+}
+
+func numberToString(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		return "0"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,204 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package xpath
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+type function struct {
+	minArgs int
+	// maxArgs is -1 for variadic functions
+	maxArgs int
+	call    func(ctx context, args []expr) any
+}
+
+// functions is the XPath 1.0 core function library.
+var functions map[string]*function
+
+func init() {
+	functions = map[string]*function{
+		// node-set functions
+		"last":     {0, 0, func(ctx context, _ []expr) any { return float64(ctx.size) }},
+		"position": {0, 0, func(ctx context, _ []expr) any { return float64(ctx.pos) }},
+		"count": {1, 1, func(ctx context, args []expr) any {
+			nodes, _ := eval(args[0], ctx).([]*Node)
+			return float64(len(nodes))
+		}},
+		// there are no ID attributes without a DTD
+		"id": {1, 1, func(context, []expr) any { return []*Node{} }},
+		"local-name": {0, 1, func(ctx context, args []expr) any {
+			if n := firstNode(ctx, args); n != nil {
+				return n.Name
+			}
+			return ""
+		}},
+		"namespace-uri": {0, 1, func(ctx context, args []expr) any {
+			if n := firstNode(ctx, args); n != nil {
+				return n.Space
+			}
+			return ""
+		}},
+		"name": {0, 1, func(ctx context, args []expr) any {
+			if n := firstNode(ctx, args); n != nil {
+				return n.Name
+			}
+			return ""
+		}},
+
+		// string functions
+		"string": {0, 1, func(ctx context, args []expr) any { return stringArg(ctx, args) }},
+		"concat": {2, -1, func(ctx context, args []expr) any {
+			var sb strings.Builder
+			for _, a := range args {
+				sb.WriteString(toString(eval(a, ctx)))
+			}
+			return sb.String()
+		}},
+		"starts-with": {2, 2, func(ctx context, args []expr) any {
+			return strings.HasPrefix(toString(eval(args[0], ctx)), toString(eval(args[1], ctx)))
+		}},
+		"contains": {2, 2, func(ctx context, args []expr) any {
+			return strings.Contains(toString(eval(args[0], ctx)), toString(eval(args[1], ctx)))
+		}},
+		"substring-before": {2, 2, func(ctx context, args []expr) any {
+			before, _, found := strings.Cut(toString(eval(args[0], ctx)), toString(eval(args[1], ctx)))
+			if !found {
+				return ""
+			}
+			return before
+		}},
+		"substring-after": {2, 2, func(ctx context, args []expr) any {
+			_, after, found := strings.Cut(toString(eval(args[0], ctx)), toString(eval(args[1], ctx)))
+			if !found {
+				return ""
+			}
+			return after
+		}},
+		"substring": {2, 3, substring},
+		"string-length": {0, 1, func(ctx context, args []expr) any {
+			return float64(utf8.RuneCountInString(stringArg(ctx, args)))
+		}},
+		"normalize-space": {0, 1, func(ctx context, args []expr) any {
+			return strings.Join(strings.Fields(stringArg(ctx, args)), " ")
+		}},
+		"translate": {3, 3, translate},
+
+		// boolean functions
+		"boolean": {1, 1, func(ctx context, args []expr) any { return toBoolean(eval(args[0], ctx)) }},
+		"not":     {1, 1, func(ctx context, args []expr) any { return !toBoolean(eval(args[0], ctx)) }},
+		"true":    {0, 0, func(context, []expr) any { return true }},
+		"false":   {0, 0, func(context, []expr) any { return false }},
+		"lang":    {1, 1, lang},
+
+		// number functions
+		"number": {0, 1, func(ctx context, args []expr) any {
+			if len(args) == 0 {
+				return toNumber([]*Node{ctx.node})
+			}
+			return toNumber(eval(args[0], ctx))
+		}},
+		"sum": {1, 1, func(ctx context, args []expr) any {
+			nodes, _ := eval(args[0], ctx).([]*Node)
+			sum := 0.0
+			for _, n := range nodes {
+				sum += stringToNumber(n.StringValue())
+			}
+			return sum
+		}},
+		"floor":   {1, 1, func(ctx context, args []expr) any { return math.Floor(toNumber(eval(args[0], ctx))) }},
+		"ceiling": {1, 1, func(ctx context, args []expr) any { return math.Ceil(toNumber(eval(args[0], ctx))) }},
+		"round":   {1, 1, func(ctx context, args []expr) any { return round(toNumber(eval(args[0], ctx))) }},
+	}
+}
+
+// firstNode returns the first node of the node-set argument, or the context
+// node when there are no arguments.
+func firstNode(ctx context, args []expr) *Node {
+	if len(args) == 0 {
+		return ctx.node
+	}
+	nodes, _ := eval(args[0], ctx).([]*Node)
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[0]
+}
+
+// stringArg returns the string value of the argument, or of the context node
+// when there are no arguments.
+func stringArg(ctx context, args []expr) string {
+	if len(args) == 0 {
+		return ctx.node.StringValue()
+	}
+	return toString(eval(args[0], ctx))
+}
+
+// round rounds to the closest integer, halves rounding towards positive infinity.
+func round(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return f
+	}
+	return math.Floor(f + 0.5)
+}
+
+// substring returns the characters whose position p, starting at 1,
+// satisfies round(start) <= p < round(start) + round(length).
+func substring(ctx context, args []expr) any {
+	s := []rune(toString(eval(args[0], ctx)))
+	start := round(toNumber(eval(args[1], ctx)))
+	end := math.Inf(1)
+	if len(args) == 3 {
+		end = start + round(toNumber(eval(args[2], ctx)))
+	}
+
+	var sb strings.Builder
+	for i, r := range s {
+		p := float64(i + 1)
+		if p >= start && p < end {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func translate(ctx context, args []expr) any {
+	s := toString(eval(args[0], ctx))
+	from := []rune(toString(eval(args[1], ctx)))
+	to := []rune(toString(eval(args[2], ctx)))
+
+	mapping := make(map[rune]int, len(from))
+	for i, r := range from {
+		if _, ok := mapping[r]; !ok {
+			mapping[r] = i
+		}
+	}
+	var sb strings.Builder
+	for _, r := range s {
+		i, ok := mapping[r]
+		switch {
+		case !ok:
+			sb.WriteRune(r)
+		case i < len(to):
+			sb.WriteRune(to[i])
+		}
+	}
+	return sb.String()
+}
+
+// lang checks the xml:lang attribute of the closest ancestor declaring it.
+func lang(ctx context, args []expr) any {
+	want := strings.ToLower(toString(eval(args[0], ctx)))
+	for n := ctx.node; n != nil; n = n.Parent {
+		for _, a := range n.Attributes {
+			if a.Name == "lang" && (a.Space == "xml" || a.Space == "http://www.w3.org/XML/1998/namespace") {
+				have := strings.ToLower(a.Data)
+				return have == want || strings.HasPrefix(have, want+"-")
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package xpath
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokNumber
+	tokLiteral
+	// tokName is a QName or a name test like prefix:*
+	tokName
+	tokStar
+	tokOperator
+	tokSlash
+	tokDoubleSlash
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokDot
+	tokDotDot
+	tokAt
+	tokComma
+	tokColonColon
+	tokPipe
+	tokDollar
+)
+
+type token struct {
+	typ tokenType
+	val string
+	num float64
+	pos int
+}
+
+// lex splits an expression into tokens, applying the disambiguation rules
+// of the XPath 1.0 specification for * and operator names.
+func lex(expr string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for {
+		for i < len(expr) && isSpace(expr[i]) {
+			i++
+		}
+		if i >= len(expr) {
+			tokens = append(tokens, token{typ: tokEOF, pos: i})
+			return tokens, nil
+		}
+
+		start := i
+		c := expr[i]
+		var tok token
+		switch {
+		case c == '(':
+			tok, i = token{typ: tokLParen}, i+1
+		case c == ')':
+			tok, i = token{typ: tokRParen}, i+1
+		case c == '[':
+			tok, i = token{typ: tokLBracket}, i+1
+		case c == ']':
+			tok, i = token{typ: tokRBracket}, i+1
+		case c == '@':
+			tok, i = token{typ: tokAt}, i+1
+		case c == ',':
+			tok, i = token{typ: tokComma}, i+1
+		case c == '|':
+			tok, i = token{typ: tokPipe}, i+1
+		case c == '$':
+			tok, i = token{typ: tokDollar}, i+1
+		case c == ':' && i+1 < len(expr) && expr[i+1] == ':':
+			tok, i = token{typ: tokColonColon}, i+2
+		case c == '/':
+			if i+1 < len(expr) && expr[i+1] == '/' {
+				tok, i = token{typ: tokDoubleSlash}, i+2
+			} else {
+				tok, i = token{typ: tokSlash}, i+1
+			}
+		case c == '.' && i+1 < len(expr) && expr[i+1] == '.':
+			tok, i = token{typ: tokDotDot}, i+2
+		case c == '.' && (i+1 >= len(expr) || !isDigit(expr[i+1])):
+			tok, i = token{typ: tokDot}, i+1
+		case c == '.' || isDigit(c):
+			j := i
+			for j < len(expr) && isDigit(expr[j]) {
+				j++
+			}
+			if j < len(expr) && expr[j] == '.' {
+				j++
+				for j < len(expr) && isDigit(expr[j]) {
+					j++
+				}
+			}
+			f, err := strconv.ParseFloat(expr[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number at position %d", i)
+			}
+			tok, i = token{typ: tokNumber, num: f}, j
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(expr) && expr[j] != c {
+				j++
+			}
+			if j >= len(expr) {
+				return nil, fmt.Errorf("unterminated literal at position %d", i)
+			}
+			tok, i = token{typ: tokLiteral, val: expr[i+1 : j]}, j+1
+		case c == '*':
+			tok, i = token{typ: tokStar, val: "*"}, i+1
+		case c == '=' || c == '+' || c == '-':
+			tok, i = token{typ: tokOperator, val: string(c)}, i+1
+		case c == '!' || c == '<' || c == '>':
+			if i+1 < len(expr) && expr[i+1] == '=' {
+				tok, i = token{typ: tokOperator, val: expr[i : i+2]}, i+2
+			} else if c != '!' {
+				tok, i = token{typ: tokOperator, val: string(c)}, i+1
+			} else {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+		default:
+			name, j := scanNCName(expr, i)
+			if name == "" {
+				r, _ := utf8.DecodeRuneInString(expr[i:])
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+			// QName or prefix:*
+			if j+1 < len(expr) && expr[j] == ':' && expr[j+1] != ':' {
+				if expr[j+1] == '*' {
+					name, j = expr[i:j+2], j+2
+				} else if local, k := scanNCName(expr, j+1); local != "" {
+					name, j = expr[i:k], k
+				}
+			}
+			tok, i = token{typ: tokName, val: name}, j
+		}
+		tok.pos = start
+
+		// A * or an operator name is an operator when preceded by a token
+		// that is not @, ::, (, [, , or an operator.
+		if tok.typ == tokStar || (tok.typ == tokName && isOperatorName(tok.val)) {
+			if len(tokens) > 0 && !precedesOperand(tokens[len(tokens)-1]) {
+				tok.typ = tokOperator
+			}
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// precedesOperand returns true if t can't be followed by an operator.
+func precedesOperand(t token) bool {
+	switch t.typ {
+	case tokAt, tokColonColon, tokLParen, tokLBracket, tokComma, tokOperator,
+		tokSlash, tokDoubleSlash, tokPipe, tokDollar:
+		return true
+	}
+	return false
+}
+
+func isOperatorName(s string) bool {
+	return s == "and" || s == "or" || s == "mod" || s == "div"
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func scanNCName(s string, i int) (string, int) {
+	j := i
+	for j < len(s) {
+		r, size := utf8.DecodeRuneInString(s[j:])
+		if r == '_' || unicode.IsLetter(r) || (j > i && (r == '-' || r == '.' || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r))) {
+			j += size
+			continue
+		}
+		break
+	}
+	return s[i:j], j
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package xpath implements an XML DOM and an XPath 1.0 evaluator used to
+// select values from XML bodies.
+package xpath
+
+import (
+	"strings"
+)
+
+// NodeType is the type of a node of the XPath data model.
+type NodeType int
+
+const (
+	DocumentNode NodeType = iota
+	ElementNode
+	AttributeNode
+	TextNode
+	CommentNode
+	ProcessingInstructionNode
+)
+
+// Node is a node of an XML document.
+type Node struct {
+	Type NodeType
+	// Name is the local name of elements and attributes, or the target of
+	// processing instructions
+	Name string
+	// Space is the namespace of elements and attributes
+	Space string
+	// Data is the value of attributes, text, comments and processing instructions
+	Data string
+
+	Parent     *Node
+	Children   []*Node
+	Attributes []*Node
+
+	// index is the position of the node in its parent children or attributes
+	index int
+	// order is the position of the node in document order
+	order int
+}
+
+// NewDocument creates an empty document node.
+func NewDocument() *Node {
+	return &Node{Type: DocumentNode}
+}
+
+// AppendChild adds a child at the end of the children of n. Text is merged
+// into the last child when it is also a text node, as the XPath data model
+// has no adjacent text nodes.
+func (n *Node) AppendChild(c *Node) *Node {
+	if c.Type == TextNode && len(n.Children) > 0 {
+		if last := n.Children[len(n.Children)-1]; last.Type == TextNode {
+			last.Data += c.Data
+			return last
+		}
+	}
+	c.Parent = n
+	c.index = len(n.Children)
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// AppendAttribute adds an attribute to the element n.
+func (n *Node) AppendAttribute(a *Node) {
+	a.Parent = n
+	a.index = len(n.Attributes)
+	n.Attributes = append(n.Attributes, a)
+}
+
+// Index numbers the nodes in document order. It must be called once the
+// document is complete and before evaluating expressions.
+func (n *Node) Index() {
+	order := 0
+	var walk func(*Node)
+	walk = func(n *Node) {
+		n.order = order
+		order++
+		for _, a := range n.Attributes {
+			a.order = order
+			order++
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(n)
+}
+
+// StringValue returns the string-value of the node as defined by XPath: the
+// concatenation of the descendant text nodes for documents and elements and
+// the data of the node otherwise.
+func (n *Node) StringValue() string {
+	switch n.Type {
+	case DocumentNode, ElementNode:
+		var sb strings.Builder
+		n.writeText(&sb)
+		return sb.String()
+	}
+	return n.Data
+}
+
+func (n *Node) writeText(sb *strings.Builder) {
+	for _, c := range n.Children {
+		switch c.Type {
+		case TextNode:
+			sb.WriteString(c.Data)
+		case ElementNode:
+			c.writeText(sb)
+		}
+	}
+}
+
+// root returns the document node containing n.
+func (n *Node) root() *Node {
+	for n.Parent != nil {
+		n = n.Parent
+	}
+	return n
+}
+
+// siblings returns the nodes sharing the parent list of n.
+func (n *Node) siblings() []*Node {
+	if n.Parent == nil || n.Type == AttributeNode {
+		return nil
+	}
+	return n.Parent.Children
+}
@@ -0,0 +1,500 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package xpath
+
+import (
+	"fmt"
+	"strings"
+)
+
+type axis int
+
+const (
+	axisChild axis = iota
+	axisDescendant
+	axisDescendantOrSelf
+	axisParent
+	axisAncestor
+	axisAncestorOrSelf
+	axisFollowingSibling
+	axisPrecedingSibling
+	axisFollowing
+	axisPreceding
+	axisAttribute
+	axisSelf
+	axisNamespace
+)
+
+var axes = map[string]axis{
+	"child":              axisChild,
+	"descendant":         axisDescendant,
+	"descendant-or-self": axisDescendantOrSelf,
+	"parent":             axisParent,
+	"ancestor":           axisAncestor,
+	"ancestor-or-self":   axisAncestorOrSelf,
+	"following-sibling":  axisFollowingSibling,
+	"preceding-sibling":  axisPrecedingSibling,
+	"following":          axisFollowing,
+	"preceding":          axisPreceding,
+	"attribute":          axisAttribute,
+	"self":               axisSelf,
+	"namespace":          axisNamespace,
+}
+
+// isReverse returns true for the axes whose proximity positions are in
+// reverse document order.
+func (a axis) isReverse() bool {
+	switch a {
+	case axisAncestor, axisAncestorOrSelf, axisPreceding, axisPrecedingSibling:
+		return true
+	}
+	return false
+}
+
+type nodeTestKind int
+
+const (
+	// testName matches the principal node type by name, * matches any name
+	testName nodeTestKind = iota
+	testNode
+	testText
+	testComment
+	testPI
+)
+
+type nodeTest struct {
+	kind nodeTestKind
+	// name is the local name to match, empty for *
+	name string
+}
+
+type step struct {
+	axis       axis
+	test       nodeTest
+	predicates []expr
+}
+
+// expressions
+type (
+	expr interface{}
+
+	binaryExpr struct {
+		op          string
+		left, right expr
+	}
+	negExpr struct {
+		e expr
+	}
+	unionExpr struct {
+		left, right expr
+	}
+	literalExpr struct {
+		s string
+	}
+	numberExpr struct {
+		f float64
+	}
+	funcExpr struct {
+		fn   *function
+		args []expr
+	}
+	filterExpr struct {
+		primary    expr
+		predicates []expr
+	}
+	pathExpr struct {
+		// filter is the expression selecting the initial node-set, nil for
+		// location paths which start at the context node or the root
+		filter   expr
+		absolute bool
+		steps    []step
+	}
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+	expr   string
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("invalid xpath %q at position %d: %s", p.expr, t.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) expect(typ tokenType, what string) error {
+	if t := p.next(); t.typ != typ {
+		return p.errorf(t, "expected %s", what)
+	}
+	return nil
+}
+
+func (p *parser) isOperator(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.typ != tokOperator {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.val == op {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func parse(s string) (expr, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xpath %q: %w", s, err)
+	}
+	p := &parser{tokens: tokens, expr: s}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokEOF {
+		return nil, p.errorf(t, "unexpected token")
+	}
+	return e, nil
+}
+
+// parseBinary parses a left associative chain of operators.
+func (p *parser) parseBinary(operand func() (expr, error), ops ...string) (expr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.isOperator(ops...)
+		if !ok {
+			return left, nil
+		}
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseOr() (expr, error) {
+	return p.parseBinary(p.parseAnd, "or")
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	return p.parseBinary(p.parseEquality, "and")
+}
+
+func (p *parser) parseEquality() (expr, error) {
+	return p.parseBinary(p.parseRelational, "=", "!=")
+}
+
+func (p *parser) parseRelational() (expr, error) {
+	return p.parseBinary(p.parseAdditive, "<", "<=", ">", ">=")
+}
+
+func (p *parser) parseAdditive() (expr, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *parser) parseMultiplicative() (expr, error) {
+	return p.parseBinary(p.parseUnary, "*", "div", "mod")
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if _, ok := p.isOperator("-"); ok {
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negExpr{e: e}, nil
+	}
+	return p.parseUnion()
+}
+
+func (p *parser) parseUnion() (expr, error) {
+	left, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokPipe {
+		p.next()
+		right, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		left = &unionExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+// startsPrimary returns true if the next token starts a filter expression
+// instead of a location path.
+func (p *parser) startsPrimary() bool {
+	t := p.peek()
+	switch t.typ {
+	case tokDollar, tokLParen, tokLiteral, tokNumber:
+		return true
+	case tokName:
+		next := p.tokens[p.pos+1]
+		if next.typ != tokLParen {
+			return false
+		}
+		// node type tests look like function calls
+		switch t.val {
+		case "node", "text", "comment", "processing-instruction":
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func (p *parser) parsePath() (expr, error) {
+	if !p.startsPrimary() {
+		return p.parseLocationPath()
+	}
+
+	primary, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	predicates, err := p.parsePredicates()
+	if err != nil {
+		return nil, err
+	}
+	var filter expr = primary
+	if len(predicates) > 0 {
+		filter = &filterExpr{primary: primary, predicates: predicates}
+	}
+
+	t := p.peek()
+	if t.typ != tokSlash && t.typ != tokDoubleSlash {
+		return filter, nil
+	}
+	path := &pathExpr{filter: filter}
+	if err := p.parseRelativePath(path); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+func (p *parser) parseLocationPath() (expr, error) {
+	path := &pathExpr{}
+	switch p.peek().typ {
+	case tokSlash:
+		path.absolute = true
+		p.next()
+		// "/" alone selects the root
+		if !p.startsStep() {
+			return path, nil
+		}
+		if err := p.parseSteps(path); err != nil {
+			return nil, err
+		}
+		return path, nil
+	case tokDoubleSlash:
+		path.absolute = true
+		if err := p.parseRelativePath(path); err != nil {
+			return nil, err
+		}
+		return path, nil
+	}
+	if err := p.parseSteps(path); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+func (p *parser) startsStep() bool {
+	switch p.peek().typ {
+	case tokName, tokStar, tokAt, tokDot, tokDotDot:
+		return true
+	}
+	return false
+}
+
+// parseRelativePath parses a sequence of / or // separated steps, the
+// next token being / or //.
+func (p *parser) parseRelativePath(path *pathExpr) error {
+	for {
+		switch p.peek().typ {
+		case tokSlash:
+			p.next()
+		case tokDoubleSlash:
+			p.next()
+			path.steps = append(path.steps, step{axis: axisDescendantOrSelf, test: nodeTest{kind: testNode}})
+		default:
+			return nil
+		}
+		s, err := p.parseStep()
+		if err != nil {
+			return err
+		}
+		path.steps = append(path.steps, s)
+	}
+}
+
+func (p *parser) parseSteps(path *pathExpr) error {
+	s, err := p.parseStep()
+	if err != nil {
+		return err
+	}
+	path.steps = append(path.steps, s)
+	return p.parseRelativePath(path)
+}
+
+func (p *parser) parseStep() (step, error) {
+	switch p.peek().typ {
+	case tokDot:
+		p.next()
+		return step{axis: axisSelf, test: nodeTest{kind: testNode}}, nil
+	case tokDotDot:
+		p.next()
+		return step{axis: axisParent, test: nodeTest{kind: testNode}}, nil
+	}
+
+	s := step{axis: axisChild}
+	if p.peek().typ == tokAt {
+		p.next()
+		s.axis = axisAttribute
+	} else if t := p.peek(); t.typ == tokName && p.tokens[p.pos+1].typ == tokColonColon {
+		a, ok := axes[t.val]
+		if !ok {
+			return step{}, p.errorf(t, "unknown axis %q", t.val)
+		}
+		p.next()
+		p.next()
+		s.axis = a
+	}
+
+	t := p.next()
+	switch t.typ {
+	case tokStar:
+		s.test = nodeTest{kind: testName}
+	case tokName:
+		if p.peek().typ == tokLParen {
+			p.next()
+			switch t.val {
+			case "node":
+				s.test = nodeTest{kind: testNode}
+			case "text":
+				s.test = nodeTest{kind: testText}
+			case "comment":
+				s.test = nodeTest{kind: testComment}
+			case "processing-instruction":
+				s.test = nodeTest{kind: testPI}
+				if p.peek().typ == tokLiteral {
+					s.test.name = p.next().val
+				}
+			default:
+				return step{}, p.errorf(t, "unknown node type %q", t.val)
+			}
+			if err := p.expect(tokRParen, ")"); err != nil {
+				return step{}, err
+			}
+			break
+		}
+		s.test = nodeTest{kind: testName, name: localName(t.val)}
+	default:
+		return step{}, p.errorf(t, "expected a node test")
+	}
+
+	predicates, err := p.parsePredicates()
+	if err != nil {
+		return step{}, err
+	}
+	s.predicates = predicates
+	return s, nil
+}
+
+// localName strips the prefix of a name test. Namespace prefixes can't be
+// bound, so names are matched by their local part.
+func localName(name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "*" {
+		return ""
+	}
+	return name
+}
+
+func (p *parser) parsePredicates() ([]expr, error) {
+	var predicates []expr
+	for p.peek().typ == tokLBracket {
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRBracket, "]"); err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, e)
+	}
+	return predicates, nil
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.typ {
+	case tokDollar:
+		return nil, p.errorf(t, "variables are not supported")
+	case tokLiteral:
+		return &literalExpr{s: t.val}, nil
+	case tokNumber:
+		return &numberExpr{f: t.num}, nil
+	case tokLParen:
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+
+	// function call
+	fn, ok := functions[t.val]
+	if !ok {
+		return nil, p.errorf(t, "unknown function %q", t.val)
+	}
+	p.next() // (
+	var args []expr
+	if p.peek().typ != tokRParen {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.peek().typ != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if err := p.expect(tokRParen, ")"); err != nil {
+		return nil, err
+	}
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, p.errorf(t, "invalid number of arguments for %s()", t.val)
+	}
+	return &funcExpr{fn: fn, args: args}, nil
+}
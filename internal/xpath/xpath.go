@@ -0,0 +1,48 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package xpath
+
+// Expr is a compiled XPath 1.0 expression.
+type Expr struct {
+	s string
+	e expr
+}
+
+// Compile parses an XPath 1.0 expression. Variable references are not
+// supported and namespace prefixes are ignored when matching names.
+func Compile(s string) (*Expr, error) {
+	parsed, err := parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{s: s, e: parsed}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.s
+}
+
+// Evaluate evaluates the expression with the document node as context and
+// returns the string-values of the selected nodes, or the result converted
+// to a string for expressions not returning a node-set.
+func (e *Expr) Evaluate(doc *Node) []string {
+	switch v := eval(e.e, context{node: doc, pos: 1, size: 1}).(type) {
+	case []*Node:
+		res := make([]string, 0, len(v))
+		for _, n := range v {
+			res = append(res, n.StringValue())
+		}
+		return res
+	default:
+		return []string{toString(v)}
+	}
+}
+
+// Select evaluates the expression with the document node as context and
+// returns the selected nodes, nil if the expression doesn't return a node-set.
+func (e *Expr) Select(doc *Node) []*Node {
+	nodes, _ := eval(e.e, context{node: doc, pos: 1, size: 1}).([]*Node)
+	return nodes
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package xpath
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+// parseDocument builds a document with encoding/xml, keeping whitespace
+// only text out of the tree to make expectations readable.
+func parseDocument(t *testing.T, s string) *Node {
+	t.Helper()
+	doc := NewDocument()
+	current := doc
+	dec := xml.NewDecoder(strings.NewReader(s))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			el := current.AppendChild(&Node{Type: ElementNode, Name: tok.Name.Local, Space: tok.Name.Space})
+			for _, a := range tok.Attr {
+				el.AppendAttribute(&Node{Type: AttributeNode, Name: a.Name.Local, Space: a.Name.Space, Data: a.Value})
+			}
+			current = el
+		case xml.EndElement:
+			current = current.Parent
+		case xml.CharData:
+			if strings.TrimSpace(string(tok)) != "" {
+				current.AppendChild(&Node{Type: TextNode, Data: string(tok)})
+			}
+		case xml.Comment:
+			current.AppendChild(&Node{Type: CommentNode, Data: string(tok)})
+		case xml.ProcInst:
+			if tok.Target != "xml" {
+				current.AppendChild(&Node{Type: ProcessingInstructionNode, Name: tok.Target, Data: string(tok.Inst)})
+			}
+		}
+	}
+	doc.Index()
+	return doc
+}
+
+const bookstore = `<?xml version="1.0"?>
+<bookstore>
+  <book category="cooking" xml:lang="en-US">
+    <title lang="en">Everyday Italian</title>
+    <author>Giada De Laurentiis</author>
+    <price>30.00</price>
+  </book>
+  <book category="children">
+    <title lang="en">Harry Potter</title>
+    <author>J K. Rowling</author>
+    <price>29.99</price>
+  </book>
+  <!-- sold out -->
+  <book category="web">
+    <title lang="es">XQuery Kick Start</title>
+    <author>James McGovern</author>
+    <author>Per Bothner</author>
+    <price>49.99</price>
+  </book>
+  <?stock count="0"?>
+</bookstore>`
+
+func TestEvaluate(t *testing.T) {
+	doc := parseDocument(t, bookstore)
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		// location paths
+		{"/bookstore/book/title", []string{"Everyday Italian", "Harry Potter", "XQuery Kick Start"}},
+		{"//title/@lang", []string{"en", "en", "es"}},
+		{"//book[1]/title", []string{"Everyday Italian"}},
+		{"//book[last()]/title", []string{"XQuery Kick Start"}},
+		{"(//author)[last()]", []string{"Per Bothner"}},
+		{"//book[price > 35]/title", []string{"XQuery Kick Start"}},
+		{"//book[@category='children']/price", []string{"29.99"}},
+		{"//book[author='Per Bothner']/@category", []string{"web"}},
+		{"//book[count(author) = 2]/title", []string{"XQuery Kick Start"}},
+		{"/bookstore/*[2]/author", []string{"J K. Rowling"}},
+		{"/bookstore/book/title[@lang='es']/../price", []string{"49.99"}},
+		{"//price/ancestor::bookstore/book[1]/price", []string{"30.00"}},
+		{"//title[. = 'Harry Potter']/following-sibling::price", []string{"29.99"}},
+		{"//author[. = 'Per Bothner']/preceding-sibling::*[1]", []string{"James McGovern"}},
+		{"//book[2]/following::title", []string{"XQuery Kick Start"}},
+		{"//book[3]/preceding::title[1]", []string{"Harry Potter"}},
+		{"//book[2]/ancestor-or-self::*[last()]/book[1]/author", []string{"Giada De Laurentiis"}},
+		{"//title[1]/self::title/text()", []string{"Everyday Italian", "Harry Potter", "XQuery Kick Start"}},
+		{"//comment()", []string{" sold out "}},
+		{"//processing-instruction('stock')", []string{`count="0"`}},
+		{"//book[1]/title | //book[3]/title", []string{"Everyday Italian", "XQuery Kick Start"}},
+		{"//nothing", []string{}},
+		{"//@*[name() = 'category']", []string{"cooking", "children", "web"}},
+		{"//book[lang('en')]/@category", []string{"cooking"}},
+		{"//*[local-name() = 'price'][1]", []string{"30.00", "29.99", "49.99"}},
+		// functions
+		{"count(//book)", []string{"3"}},
+		{"floor(sum(//price))", []string{"109"}},
+		{"string(//book[2]/title)", []string{"Harry Potter"}},
+		{"concat(//book[1]/@category, '-', //book[2]/@category)", []string{"cooking-children"}},
+		{"starts-with(//book[3]/title, 'XQuery')", []string{"true"}},
+		{"contains(//book[3]/title, 'Kick')", []string{"true"}},
+		{"substring-before('1999/04/01', '/')", []string{"1999"}},
+		{"substring-after('1999/04/01', '/')", []string{"04/01"}},
+		{"substring('12345', 1.5, 2.6)", []string{"234"}},
+		{"substring('12345', 0, 3)", []string{"12"}},
+		{"string-length('héllo')", []string{"5"}},
+		{"normalize-space('  a   b  ')", []string{"a b"}},
+		{"translate('--aaa--', 'abc-', 'ABC')", []string{"AAA"}},
+		{"not(//book[4])", []string{"true"}},
+		{"boolean(//book)", []string{"true"}},
+		{"floor(2.5) + ceiling(2.5) + round(2.5)", []string{"8"}},
+		{"round(-0.5)", []string{"0"}},
+		{"number('abc')", []string{"NaN"}},
+		{"1 div 0", []string{"Infinity"}},
+		{"7 mod 3", []string{"1"}},
+		{"-2 * 3", []string{"-6"}},
+		{"2 < 3 and 3 <= 3 or false()", []string{"true"}},
+		{"'abc' != 'abd'", []string{"true"}},
+		{"//price = 29.99", []string{"true"}},
+		{"//price != 29.99", []string{"true"}},
+		{"local-name(/*)", []string{"bookstore"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			e, err := Compile(tc.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := e.Evaluate(doc)
+			if len(got) != len(tc.want) {
+				t.Fatalf("unexpected result, got %q, want %q", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("unexpected result, got %q, want %q", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"/bookstore/",
+		"//book[",
+		"//book[1",
+		"unknown()",
+		"count()",
+		"concat('a')",
+		"$var",
+		"'unterminated",
+		"foo::bar",
+		"//book!",
+		"1 +",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := Compile(expr); err == nil {
+				t.Errorf("expected an error for %q", expr)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	doc := parseDocument(t, bookstore)
+	e, err := Compile("//book/@category")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := e.Select(doc)
+	if len(nodes) != 3 || nodes[0].Type != AttributeNode || nodes[0].Parent.Name != "book" {
+		t.Errorf("unexpected nodes %v", nodes)
+	}
+	if e, _ := Compile("count(//book)"); e.Select(doc) != nil {
+		t.Error("expected no nodes for a number expression")
+	}
+}
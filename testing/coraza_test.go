@@ -63,58 +63,7 @@ func testList(t *testing.T, p *profile.Profile) ([]*Test, error) {
 	logger := debuglog.Default().
 		WithLevel(debuglog.LevelDebug).
 		WithOutput(testLogOutput{t})
-	var tests []*Test
-	for _, test := range p.Tests {
-		name := test.Title
-		for _, stage := range test.Stages {
-			w, err := coraza.NewWAF(coraza.NewWAFConfig().
-				WithRootFS(os.DirFS("testdata")).
-				WithDirectives(p.Rules).
-				WithDebugLogger(logger))
-			if err != nil {
-				return nil, err
-			}
-			test := NewTest(name, w)
-			test.ExpectedOutput = stage.Stage.Output
-			// test.RequestAddress =
-			// test.RequestPort =
-			if stage.Stage.Input.URI != "" {
-				test.RequestURI = stage.Stage.Input.URI
-			}
-			if stage.Stage.Input.Method != "" {
-				test.RequestMethod = stage.Stage.Input.Method
-			}
-			if stage.Stage.Input.Version != "" {
-				test.RequestProtocol = stage.Stage.Input.Version
-			}
-			if stage.Stage.Input.Headers != nil {
-				test.RequestHeaders = stage.Stage.Input.Headers
-			}
-			if stage.Stage.Output.Headers != nil {
-				test.ResponseHeaders = stage.Stage.Output.Headers
-			}
-			// test.ResponseHeaders = stage.Output.Headers
-			test.ResponseCode = 200
-			test.ResponseProtocol = "HTTP/1.1"
-			test.ServerAddress = stage.Stage.Input.DestAddr
-			test.ServerPort = stage.Stage.Input.Port
-			if stage.Stage.Input.StopMagic {
-				test.DisableMagic()
-			}
-			if err := test.SetEncodedRequest(stage.Stage.Input.EncodedRequest); err != nil {
-				return nil, err
-			}
-			if err := test.SetRawRequest(stage.Stage.Input.RawRequest); err != nil {
-				return nil, err
-			}
-			if err := test.SetRequestBody(stage.Stage.Input.Data); err != nil {
-				return nil, err
-			}
-			if err := test.SetResponseBody(stage.Stage.Output.Data); err != nil {
-				return nil, err
-			}
-			tests = append(tests, test)
-		}
-	}
-	return tests, nil
+	return NewProfileTests(p, coraza.NewWAFConfig().
+		WithRootFS(os.DirFS("testdata")).
+		WithDebugLogger(logger))
 }
@@ -151,6 +151,94 @@ func BenchmarkCRSLargePOST(b *testing.B) {
 	}
 }
 
+// BenchmarkCRSRuleIndex measures full CRS transactions for requests populating
+// a few or many collections, as the rule index skips the rules whose
+// collections are all empty. Run with and without the coraza.rule.no_index
+// build tag and compare via benchstat:
+//
+//	go test -bench=BenchmarkCRSRuleIndex -benchmem -count=6 ./testing/coreruleset/ > index.txt
+//	go test -tags coraza.rule.no_index -bench=BenchmarkCRSRuleIndex -benchmem -count=6 ./testing/coreruleset/ > noindex.txt
+//	benchstat noindex.txt index.txt
+func BenchmarkCRSRuleIndex(b *testing.B) {
+	waf := crsWAF(b)
+
+	tests := []struct {
+		name        string
+		method      string
+		uri         string
+		headers     [][2]string
+		contentType string
+		body        []byte
+	}{
+		{
+			name:   "GET/NoArgs",
+			method: "GET",
+			uri:    "/static/app.js",
+		},
+		{
+			name:   "GET/ArgsAndCookies",
+			method: "GET",
+			uri:    "/search?q=coraza&page=2&lang=en",
+			headers: [][2]string{
+				{"Cookie", "session=4f2a9c; theme=dark"},
+				{"Referer", "https://app.example.com/"},
+			},
+		},
+		{
+			name:        "POST/Form",
+			method:      "POST",
+			uri:         "/login",
+			contentType: "application/x-www-form-urlencoded",
+			body:        []byte("username=john&password=s3cret&remember=on"),
+		},
+		{
+			name:        "POST/JSON",
+			method:      "POST",
+			uri:         "/api/v1/orders",
+			contentType: "application/json",
+			body:        []byte(`{"items":[{"sku":"A-1","qty":2}],"coupon":"SPRING"}`),
+		},
+	}
+
+	for _, tc := range tests {
+		b.Run(tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tx := waf.NewTransaction()
+				tx.ProcessConnection("127.0.0.1", 8080, "10.0.0.1", 443)
+				tx.ProcessURI(tc.uri, tc.method, "HTTP/1.1")
+				tx.AddRequestHeader("Host", "app.example.com")
+				tx.AddRequestHeader("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:126.0) Gecko/20100101 Firefox/126.0")
+				if tc.contentType != "" {
+					tx.AddRequestHeader("Content-Type", tc.contentType)
+				}
+				for _, h := range tc.headers {
+					tx.AddRequestHeader(h[0], h[1])
+				}
+				tx.ProcessRequestHeaders()
+				if tc.body != nil {
+					if _, _, err := tx.WriteRequestBody(tc.body); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if _, err := tx.ProcessRequestBody(); err != nil {
+					b.Fatal(err)
+				}
+				tx.AddResponseHeader("Content-Type", "application/json")
+				tx.ProcessResponseHeaders(200, "OK")
+				if _, err := tx.ProcessResponseBody(); err != nil {
+					b.Fatal(err)
+				}
+				tx.ProcessLogging()
+				if err := tx.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
 // BenchmarkCRSPrefilter measures CRS request processing across diverse traffic
 // patterns. Run with and without the coraza.rule.rx_prefilter build tag and
 // compare via benchstat:
@@ -313,6 +313,62 @@ func NewTest(name string, waf coraza.WAF) *Test {
 	return t
 }
 
+// NewProfileTests creates a test for each stage of the profile tests. Every
+// stage gets its own WAF, created from config and the profile rules.
+func NewProfileTests(p *profile.Profile, config coraza.WAFConfig) ([]*Test, error) {
+	var tests []*Test
+	for _, test := range p.Tests {
+		name := test.Title
+		for _, stage := range test.Stages {
+			w, err := coraza.NewWAF(config.WithDirectives(p.Rules))
+			if err != nil {
+				return nil, err
+			}
+			test := NewTest(name, w)
+			test.ExpectedOutput = stage.Stage.Output
+			// test.RequestAddress =
+			// test.RequestPort =
+			if stage.Stage.Input.URI != "" {
+				test.RequestURI = stage.Stage.Input.URI
+			}
+			if stage.Stage.Input.Method != "" {
+				test.RequestMethod = stage.Stage.Input.Method
+			}
+			if stage.Stage.Input.Version != "" {
+				test.RequestProtocol = stage.Stage.Input.Version
+			}
+			if stage.Stage.Input.Headers != nil {
+				test.RequestHeaders = stage.Stage.Input.Headers
+			}
+			if stage.Stage.Output.Headers != nil {
+				test.ResponseHeaders = stage.Stage.Output.Headers
+			}
+			// test.ResponseHeaders = stage.Output.Headers
+			test.ResponseCode = 200
+			test.ResponseProtocol = "HTTP/1.1"
+			test.ServerAddress = stage.Stage.Input.DestAddr
+			test.ServerPort = stage.Stage.Input.Port
+			if stage.Stage.Input.StopMagic {
+				test.DisableMagic()
+			}
+			if err := test.SetEncodedRequest(stage.Stage.Input.EncodedRequest); err != nil {
+				return nil, err
+			}
+			if err := test.SetRawRequest(stage.Stage.Input.RawRequest); err != nil {
+				return nil, err
+			}
+			if err := test.SetRequestBody(stage.Stage.Input.Data); err != nil {
+				return nil, err
+			}
+			if err := test.SetResponseBody(stage.Stage.Output.Data); err != nil {
+				return nil, err
+			}
+			tests = append(tests, test)
+		}
+	}
+	return tests, nil
+}
+
 func bodyToString(iface any) string {
 	data := ""
 	switch v := iface.(type) {
@@ -321,6 +377,12 @@ func bodyToString(iface any) string {
 			data += fmt.Sprintf("%s\r\n", v[i])
 		}
 		data += "\r\n"
+	case []any:
+		// lists decoded from YAML profiles
+		for i := range v {
+			data += fmt.Sprintf("%v\r\n", v[i])
+		}
+		data += "\r\n"
 	case string:
 		data = v
 	default:
@@ -0,0 +1,51 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.rule.multiphase_evaluation
+
+package engine
+
+import (
+	"github.com/corazawaf/coraza/v3/testing/profile"
+)
+
+var _ = profile.RegisterProfile(profile.Profile{
+	Meta: profile.Meta{
+		Author:      "coraza",
+		Description: "Tests skip actions around rules targeting empty collections",
+		Enabled:     true,
+		Name:        "rule_index.yaml",
+	},
+	Tests: []profile.Test{
+		{
+			Title: "rule index",
+			Stages: []profile.Stage{
+				{
+					Stage: profile.SubStage{
+						Input: profile.StageInput{
+							URI: "/index?a=1",
+						},
+						Output: profile.ExpectedOutput{
+							TriggeredRules:    []int{1, 4, 6, 8, 10},
+							NonTriggeredRules: []int{2, 3, 5, 7, 9},
+						},
+					},
+				},
+			},
+		},
+	},
+	Rules: `
+# skip:2 counts the rules on empty collections as any other rule
+SecRule ARGS_GET:a "@eq 1" "id:1,phase:1,pass,log,skip:2"
+SecRule REQUEST_COOKIES "@unconditionalMatch" "id:2,phase:1,pass,log"
+SecRule ARGS_GET "@unconditionalMatch" "id:3,phase:1,pass,log"
+SecRule ARGS_GET "@unconditionalMatch" "id:4,phase:1,pass,log,skipAfter:END_EMPTY"
+SecRule REQUEST_COOKIES "@unconditionalMatch" "id:5,phase:1,pass,log"
+SecMarker END_EMPTY
+SecRule &REQUEST_COOKIES "@eq 0" "id:6,phase:1,pass,log"
+SecRule REQUEST_COOKIES_NAMES|ARGS_POST "@unconditionalMatch" "id:7,phase:1,pass,log"
+SecRule REQUEST_COOKIES|ARGS_GET_NAMES "@streq a" "id:8,phase:1,pass,log"
+SecRule ARGS_POST "!@rx ." "id:9,phase:2,pass,log"
+SecAction "id:10,phase:2,pass,log"
+`,
+})
@@ -51,6 +51,8 @@ var _ = profile.RegisterProfile(profile.Profile{
 								123123,
 								9123,
 								99999,
+								1600,
+								1700,
 							},
 							NonTriggeredRules: []int{
 								800,
@@ -106,6 +108,9 @@ SecRule ARGS "bbb" "id:9124,phase:1,log"
 SecRuleUpdateTargetById 9124 "!ARGS:t2"
 
 SecAction "id: 99999, log, msg:'%{env.test}'"
+
+SecRule STATUS_LINE "@streq HTTP/1.1 200 OK" "id:1600,phase:3,log"
+SecRule DURATION "@ge 0" "id:1700,phase:1,log"
 `,
 })
 
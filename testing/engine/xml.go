@@ -50,3 +50,52 @@ SecRule XML:/* "test123" "id:500, log"
 SecRule XML://@* "attribute_value" "id:501, log"
 `,
 })
+
+var _ = profile.RegisterProfile(profile.Profile{
+	Meta: profile.Meta{
+		Author:      "coraza",
+		Description: "Test XPath expressions against XML bodies",
+		Enabled:     true,
+		Name:        "xpath.yaml",
+	},
+	Tests: []profile.Test{
+		{
+			Title: "xpath",
+			Stages: []profile.Stage{
+				{
+					Stage: profile.SubStage{
+						Input: profile.StageInput{
+							URI:    "/employees",
+							Method: "POST",
+							Headers: map[string]string{
+								"content-type": "text/xml",
+							},
+							Data: `<employees><employee id="7"><name>Fred Jones</name><salary>1000</salary></employee></employees>`,
+						},
+						Output: profile.ExpectedOutput{
+							Headers: map[string]string{
+								"content-type": "text/xml",
+							},
+							Data:              `<cards><card><number>4111111111111111</number></card></cards>`,
+							TriggeredRules:    []int{201, 202, 203, 301},
+							NonTriggeredRules: []int{204, 205},
+						},
+					},
+				},
+			},
+		},
+	},
+	Rules: `
+SecRequestBodyAccess On
+SecResponseBodyAccess On
+SecResponseBodyMimeType text/xml
+SecRule REQUEST_HEADERS:content-type "text/xml" "id:200,phase:1,pass,nolog,ctl:requestBodyProcessor=XML"
+SecRule XML:/employees/employee/name "@rx ^Fred" "id:201,phase:2,pass,log"
+SecRule REQUEST_XML://employee[salary>500]/@id "@eq 7" "id:202,phase:2,pass,log"
+SecRule &XML:/employees/employee "@eq 1" "id:203,phase:2,pass,log"
+SecRule XML:/employees/Employee "@rx ." "id:204,phase:2,pass,log"
+SecRule XML:/employees/employee/name|!XML:/employees/employee/name "@rx ." "id:205,phase:2,pass,log"
+SecRule RESPONSE_HEADERS:content-type "text/xml" "id:300,phase:3,pass,nolog,ctl:responseBodyProcessor=XML"
+SecRule RESPONSE_XML://card/number "@rx ^\d{16}$" "id:301,phase:4,pass,log"
+`,
+})
@@ -219,6 +219,38 @@ const (
 	TimeWday = variables.TimeWday
 	// TimeYear the current four-digit year value
 	TimeYear = variables.TimeYear
+	// Sessionid contains the value set with setsid
+	Sessionid = variables.Sessionid
+	// Userid contains the value set with setuid
+	Userid = variables.Userid
+	// IP is the persistent collection of the client IP address, initialized with initcol
+	IP = variables.IP
+	// Session is the persistent collection of the session, initialized with setsid
+	Session = variables.Session
+	// User is the persistent collection of the user, initialized with setuid
+	User = variables.User
+	// Global is the persistent collection shared by all transactions, initialized with initcol
+	Global = variables.Global
+	// Resource is the persistent collection of a resource, initialized with initcol
+	Resource = variables.Resource
+	// PeerAddr is the address of the peer the connection was received from
+	PeerAddr = variables.PeerAddr
+	// PerfRules is the time, in microseconds, spent in the rules above the SecRulePerfTime threshold
+	PerfRules = variables.PerfRules
+	// PerfPhase1 is the time, in microseconds, spent in the request headers phase
+	PerfPhase1 = variables.PerfPhase1
+	// PerfPhase2 is the time, in microseconds, spent in the request body phase
+	PerfPhase2 = variables.PerfPhase2
+	// PerfPhase3 is the time, in microseconds, spent in the response headers phase
+	PerfPhase3 = variables.PerfPhase3
+	// PerfPhase4 is the time, in microseconds, spent in the response body phase
+	PerfPhase4 = variables.PerfPhase4
+	// PerfPhase5 is the time, in microseconds, spent in the logging phase
+	PerfPhase5 = variables.PerfPhase5
+	// PerfCombined is the time, in microseconds, spent in all the phases
+	PerfCombined = variables.PerfCombined
+	// PerfAll contains all the phase timings in the format of the audit log stopwatch
+	PerfAll = variables.PerfAll
 )
 
 // Parse returns the byte interpretation
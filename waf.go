@@ -76,6 +76,10 @@ func NewWAF(config WAFConfig) (WAF, error) {
 		return nil, fmt.Errorf("invalid WAF config from audit log: %w", err)
 	}
 
+	if c.metrics != nil {
+		waf.SetMetrics(c.metrics)
+	}
+
 	if c.requestBodyAccess {
 		waf.RequestBodyAccess = true
 	}
@@ -120,6 +124,8 @@ func NewWAF(config WAFConfig) (WAF, error) {
 		return nil, err
 	}
 
+	waf.Rules.BuildIndex()
+
 	return wafWrapper{waf: waf}, nil
 }
 
@@ -176,3 +182,21 @@ func (w wafWrapper) RulesCount() int {
 func (w wafWrapper) Close() error {
 	return w.waf.Close()
 }
+
+// ReopenAuditLog reopens the files of the audit log writer.
+func (w wafWrapper) ReopenAuditLog() error {
+	return w.waf.ReopenAuditLog()
+}
+
+// InheritState takes over the persistent collections and the rate limit zones
+// of the WAF this one replaces.
+func (w wafWrapper) InheritState(previous WAF) {
+	if p, ok := previous.(wafWrapper); ok {
+		w.waf.InheritState(p.waf)
+	}
+}
+
+// Retire closes the WAF once all the transactions in-flight are closed.
+func (w wafWrapper) Retire() {
+	w.waf.Retire()
+}